@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/stock-recommender/internal/portfolio"
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// runImportHoldings implements the import-holdings subcommand, which creates
+// a portfolio from a broker holdings or tradebook export.
+func runImportHoldings(repo *storage.Repository, args []string) error {
+	fs := flag.NewFlagSet("import-holdings", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: recommender [-config file] import-holdings [-name NAME] [-broker FORMAT] [-sheet NAME] FILE.csv|FILE.xlsx")
+		fs.PrintDefaults()
+	}
+	name := fs.String("name", "", "portfolio name (default the file name)")
+	broker := fs.String("broker", "", "export format, one of "+strings.Join(portfolio.BrokerFormats(), ", ")+" (default detected)")
+	sheet := fs.String("sheet", "", "worksheet of XLSX files to import, by name or 1-based position (default first)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one file")
+	}
+
+	path := fs.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("→ Importing %s...\n", path)
+	importer := portfolio.NewImporter(repo)
+	result, err := importer.Import(context.Background(), filepath.Base(path), data, portfolio.ImportOptions{
+		Name:   *name,
+		Broker: *broker,
+		Sheet:  *sheet,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	p := result.Portfolio
+	fmt.Printf("  ✓ Portfolio %d %q (%s): %d lots, %d new stocks, %d rows skipped\n",
+		p.ID, p.Name, result.Broker, len(p.Lots), len(result.StocksCreated), len(result.Errors))
+	for _, rowErr := range result.Errors {
+		fmt.Printf("    row %d: %s\n", rowErr.Row, rowErr.Message)
+	}
+	return nil
+}
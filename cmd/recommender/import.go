@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/user/stock-recommender/internal/screener"
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// runImportScreener implements the import-screener subcommand, which imports
+// screener.in CSV and XLSX exports through the same path as the upload API.
+func runImportScreener(repo *storage.Repository, args []string) error {
+	fs := flag.NewFlagSet("import-screener", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: recommender [-config file] import-screener [-sheet NAME] [-dry-run] FILE.csv|FILE.xlsx...")
+		fs.PrintDefaults()
+	}
+	sheet := fs.String("sheet", "", "worksheet of XLSX files to import, by name or 1-based position (default first)")
+	dryRun := fs.Bool("dry-run", false, "report how the files would be imported without importing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no files given")
+	}
+
+	importer := screener.NewImporter(repo, screener.NewCSVParser())
+	importer.OnProgress = func(p screener.ImportProgress) {
+		if p.Stage == screener.StageImporting && p.Total > 0 {
+			fmt.Printf("\r  → %d/%d rows imported", p.Processed, p.Total)
+		}
+	}
+
+	opts := screener.ImportOptions{Sheet: *sheet}
+	if *dryRun {
+		for _, path := range fs.Args() {
+			if err := previewScreener(importer, path, opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, path := range fs.Args() {
+		fmt.Printf("→ Importing %s...\n", path)
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		result, err := importer.Import(context.Background(), filepath.Base(path), file, opts)
+		file.Close()
+		fmt.Println()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		upload := result.Upload
+		fmt.Printf("  ✓ Upload %d: %d created, %d skipped, %d failed\n",
+			upload.ID, upload.CreatedCount, upload.SkippedCount, upload.ErrorCount)
+		for _, row := range result.Errors(20) {
+			fmt.Printf("    row %d: %s\n", row.RowNumber, row.ErrorMessage)
+		}
+	}
+
+	return nil
+}
+
+// previewScreener prints how a file would be imported.
+func previewScreener(importer *screener.Importer, path string, opts screener.ImportOptions) error {
+	fmt.Printf("→ Previewing %s...\n", path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	preview, err := importer.Preview(context.Background(), data, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	fmt.Printf("  %s, %d rows: %d would be imported, %d skipped\n",
+		preview.Format, preview.TotalRows, preview.ImportRows, preview.SkippedRows)
+	for _, col := range preview.Columns {
+		field := col.Field
+		if field == "" {
+			field = "(ignored)"
+		} else if col.Custom {
+			field += " (custom)"
+		}
+		fmt.Printf("    %-40s → %s\n", col.Column, field)
+	}
+	for _, skip := range preview.Skipped {
+		fmt.Printf("    row %d %s: %s\n", skip.Row, skip.Status, skip.Reason)
+	}
+	for _, invalid := range preview.InvalidValues {
+		fmt.Printf("    row %d %s: %q is not a number\n", invalid.Row, invalid.Column, invalid.Value)
+	}
+	return nil
+}
@@ -2,17 +2,25 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/user/stock-recommender/internal/alerts"
 	"github.com/user/stock-recommender/internal/api"
+	"github.com/user/stock-recommender/internal/calendar"
 	"github.com/user/stock-recommender/internal/llm"
 	"github.com/user/stock-recommender/internal/recommender"
+	"github.com/user/stock-recommender/internal/scheduler"
 	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/internal/telegram"
+	"github.com/user/stock-recommender/internal/webhooks"
 	"github.com/user/stock-recommender/pkg/config"
 )
 
@@ -42,6 +50,23 @@ func main() {
 	defer repo.Close()
 	fmt.Println("  ✓ Database connected")
 
+	// Run a subcommand instead of the server if one was given
+	if flag.NArg() > 0 {
+		switch cmd := flag.Arg(0); cmd {
+		case "import-screener":
+			if err := runImportScreener(repo, flag.Args()[1:]); err != nil {
+				log.Fatalf("Import failed: %v", err)
+			}
+		case "import-holdings":
+			if err := runImportHoldings(repo, flag.Args()[1:]); err != nil {
+				log.Fatalf("Import failed: %v", err)
+			}
+		default:
+			log.Fatalf("Unknown command: %s", cmd)
+		}
+		return
+	}
+
 	// Initialize LLM provider
 	var llmProvider llm.Provider
 	if cfg.Analysis.UseLLM {
@@ -55,14 +80,54 @@ func main() {
 		}
 	}
 
+	// Load NSE trading calendar
+	fmt.Println("→ Loading trading calendar...")
+	cal, err := calendar.LoadDir(cfg.Market.HolidaysDir, calendar.LoadLocation(cfg.Market.Timezone))
+	if err != nil {
+		log.Printf("  ⚠ Warning: %v", err)
+		log.Println("  → Continuing with weekends as the only non-trading days")
+		cal = calendar.New(calendar.LoadLocation(cfg.Market.Timezone))
+	} else {
+		fmt.Printf("  ✓ Trading calendar loaded (market %s)\n", cal.Status(time.Now()).Session)
+	}
+
 	// Initialize recommendation engine
 	fmt.Println("→ Initializing recommendation engine...")
-	engine := recommender.NewEngine(repo, llmProvider, cfg)
+	engine := recommender.NewEngine(repo, llmProvider, cal, cfg)
 	fmt.Println("  ✓ Recommendation engine ready")
 
+	// Initialize alerting
+	alerter := alerts.NewService(repo, engine, cfg)
+	fmt.Printf("  ✓ Alert channels: %s\n", strings.Join(alerter.Channels(), ", "))
+
+	// Initialize outbound webhooks
+	hooks := webhooks.NewDispatcher(repo, cfg)
+	engine.SetEventPublisher(hooks)
+
+	// Start the Telegram bot if any chats are allowed to use it
+	botCtx, stopBot := context.WithCancel(context.Background())
+	var bot *telegram.Bot
+	if cfg.Telegram.BotToken != "" && len(cfg.Telegram.AllowedChatIDs) > 0 {
+		bot = telegram.NewBot(telegram.NewClient(cfg.Telegram, nil), engine, repo, cfg)
+		bot.Start(botCtx)
+		fmt.Printf("  ✓ Telegram bot answering %d chats\n", len(cfg.Telegram.AllowedChatIDs))
+	}
+
+	// Initialize background job scheduler
+	var sched *scheduler.Scheduler
+	if cfg.Scheduler.Enabled {
+		fmt.Println("→ Starting job scheduler...")
+		sched = scheduler.New(repo, calendar.LoadLocation(cfg.Scheduler.Timezone))
+		if err := scheduler.RegisterDefaultJobs(sched, engine, alerter, hooks, cal, cfg); err != nil {
+			log.Fatalf("Failed to register jobs: %v", err)
+		}
+		sched.Start()
+		fmt.Printf("  ✓ Scheduler running %d jobs (%s)\n", len(sched.Jobs()), sched.Location())
+	}
+
 	// Initialize API server
 	fmt.Println("→ Starting API server...")
-	server := api.NewServer(engine, repo, cfg)
+	server := api.NewServer(engine, repo, sched, alerter, hooks, cfg)
 
 	// Handle graceful shutdown
 	quit := make(chan os.Signal, 1)
@@ -71,6 +136,29 @@ func main() {
 	go func() {
 		<-quit
 		fmt.Println("\n→ Shutting down gracefully...")
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if sched != nil {
+			if err := sched.Stop(ctx); err != nil {
+				log.Printf("  ⚠ Warning: jobs did not stop in time: %v", err)
+			}
+		}
+		if err := server.Importer().Wait(ctx); err != nil {
+			log.Printf("  ⚠ Warning: CSV imports did not finish in time: %v", err)
+		}
+		stopBot()
+		if bot != nil {
+			if err := bot.Wait(ctx); err != nil {
+				log.Printf("  ⚠ Warning: Telegram bot did not stop in time: %v", err)
+			}
+		}
+		if err := server.Slack().Wait(ctx); err != nil {
+			log.Printf("  ⚠ Warning: Slack analyses did not finish in time: %v", err)
+		}
+		if err := hooks.Wait(ctx); err != nil {
+			log.Printf("  ⚠ Warning: webhook deliveries did not finish in time: %v", err)
+		}
+		cancel()
+		repo.Close()
 		os.Exit(0)
 	}()
 
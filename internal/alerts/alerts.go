@@ -0,0 +1,407 @@
+// Package alerts raises alerts from rules over recommendations, watchlists,
+// news and daily picks, and delivers them through notification channels
+// with retries. Every alert a rule raises is recorded under a dedupe key,
+// so the same event is never sent twice.
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/stock-recommender/internal/recommender"
+	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/internal/telegram"
+	"github.com/user/stock-recommender/pkg/config"
+	"github.com/user/stock-recommender/pkg/textutil"
+)
+
+// ErrInvalidRule is returned for an alert rule that cannot be saved.
+var ErrInvalidRule = errors.New("invalid alert rule")
+
+// deliveryBatchSize limits how many due deliveries are attempted in a run.
+const deliveryBatchSize = 100
+
+// Notification is an alert as sent through a channel. Webhooks receive it
+// as JSON.
+type Notification struct {
+	RuleID    uint                   `json:"rule_id"`
+	Rule      string                 `json:"rule"`
+	Condition string                 `json:"condition"`
+	Subject   string                 `json:"subject"`
+	Message   string                 `json:"message"`
+	Symbol    string                 `json:"symbol,omitempty"`
+	URL       string                 `json:"url,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	RaisedAt  time.Time              `json:"raised_at"`
+}
+
+// RunResult summarises an evaluation and delivery run.
+type RunResult struct {
+	Rules    int   `json:"rules"`
+	Raised   int64 `json:"raised"`
+	Sent     int   `json:"sent"`
+	Retrying int   `json:"retrying"`
+	Failed   int   `json:"failed"`
+}
+
+// deliveryStore records alert deliveries. *storage.Repository is the store
+// used in the server; tests can stand in one kept in memory.
+type deliveryStore interface {
+	CreateAlertDeliveries(ctx context.Context, deliveries []storage.AlertDelivery) (int64, error)
+	ListDueAlertDeliveries(ctx context.Context, now time.Time, limit int) ([]storage.AlertDelivery, error)
+	UpdateAlertDelivery(ctx context.Context, delivery *storage.AlertDelivery) error
+}
+
+// Service evaluates alert rules and delivers the alerts they raise.
+type Service struct {
+	repo       *storage.Repository
+	deliveries deliveryStore
+	engine     *recommender.Engine
+	channels   map[string]Channel
+	config     config.AlertsConfig
+	publicURL  string
+}
+
+// NewService creates an alert service with the webhook channel, and the
+// email and Telegram channels if they are configured.
+func NewService(repo *storage.Repository, engine *recommender.Engine, cfg *config.Config) *Service {
+	s := &Service{
+		repo:       repo,
+		deliveries: repo,
+		engine:     engine,
+		channels:   make(map[string]Channel),
+		config:     cfg.Alerts,
+		publicURL:  strings.TrimRight(cfg.Server.PublicURL, "/"),
+	}
+	if s.config.MaxAttempts <= 0 {
+		s.config.MaxAttempts = 1
+	}
+	if s.config.Timeout <= 0 {
+		s.config.Timeout = 10 * time.Second
+	}
+
+	client := &http.Client{}
+	s.AddChannel(NewWebhookChannel(client))
+	if cfg.Alerts.SMTP.Host != "" {
+		s.AddChannel(NewEmailChannel(cfg.Alerts.SMTP))
+	}
+	if cfg.Telegram.BotToken != "" {
+		s.AddChannel(NewTelegramChannel(telegram.NewClient(cfg.Telegram, client)))
+	}
+	return s
+}
+
+// AddChannel adds a channel, replacing any with the same name.
+func (s *Service) AddChannel(c Channel) {
+	s.channels[c.Name()] = c
+}
+
+// Channels returns the names of the available channels.
+func (s *Service) Channels() []string {
+	names := make([]string, 0, len(s.channels))
+	for name := range s.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateRule checks a rule before it is saved, normalizing its symbol and
+// filling in the default threshold of its condition.
+func (s *Service) ValidateRule(ctx context.Context, rule *storage.AlertRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Symbol = strings.ToUpper(strings.TrimSpace(rule.Symbol))
+	rule.Target = strings.TrimSpace(rule.Target)
+
+	if rule.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+	cond, ok := conditions[rule.Condition]
+	if !ok {
+		return fmt.Errorf("%w: unknown condition %q (one of %s)", ErrInvalidRule, rule.Condition, strings.Join(Conditions(), ", "))
+	}
+	if rule.Threshold == 0 {
+		rule.Threshold = cond.defaultThreshold
+	}
+	if rule.Threshold < 0 || rule.Threshold > cond.maxThreshold {
+		return fmt.Errorf("%w: threshold must be between 0 and %g for %s", ErrInvalidRule, cond.maxThreshold, rule.Condition)
+	}
+
+	channel, ok := s.channels[rule.Channel]
+	if !ok {
+		return fmt.Errorf("%w: channel %q is not available (configured: %s)", ErrInvalidRule, rule.Channel, strings.Join(s.Channels(), ", "))
+	}
+	if err := channel.Validate(rule.Target); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+
+	if rule.Symbol != "" && rule.WatchlistID != nil {
+		return fmt.Errorf("%w: set a symbol or a watchlist, not both", ErrInvalidRule)
+	}
+	if rule.Symbol != "" {
+		stock, err := s.repo.GetStockBySymbol(ctx, rule.Symbol)
+		if err != nil {
+			return err
+		}
+		if stock == nil {
+			return fmt.Errorf("%w: stock %s is not tracked", ErrInvalidRule, rule.Symbol)
+		}
+	}
+	if rule.WatchlistID != nil {
+		watchlist, err := s.repo.GetWatchlist(ctx, *rule.WatchlistID)
+		if err != nil {
+			return err
+		}
+		if watchlist == nil {
+			return fmt.Errorf("%w: watchlist %d not found", ErrInvalidRule, *rule.WatchlistID)
+		}
+	}
+	return nil
+}
+
+// Run evaluates every enabled rule and then attempts the deliveries that
+// are due, including retries of earlier failures.
+func (s *Service) Run(ctx context.Context) (*RunResult, error) {
+	result := &RunResult{}
+	if err := s.evaluate(ctx, result); err != nil {
+		return result, err
+	}
+	if err := s.deliverDue(ctx, result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// evaluate raises and records the alerts of every enabled rule. A rule that
+// fails to evaluate is skipped with a warning.
+func (s *Service) evaluate(ctx context.Context, result *RunResult) error {
+	rules, err := s.repo.ListAlertRules(ctx, true)
+	if err != nil {
+		return fmt.Errorf("failed to list alert rules: %w", err)
+	}
+
+	for i := range rules {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rule := &rules[i]
+		result.Rules++
+
+		raised, err := s.evaluateRule(ctx, rule)
+		if err != nil {
+			fmt.Printf("Warning: failed to evaluate alert rule %d (%s): %v\n", rule.ID, rule.Name, err)
+			continue
+		}
+
+		created, err := s.record(ctx, rule, raised)
+		if err != nil {
+			return err
+		}
+		result.Raised += created
+	}
+	return nil
+}
+
+// record saves the alerts a rule raised as pending deliveries and returns
+// how many are new. Alerts already recorded under the same dedupe key are
+// left out.
+func (s *Service) record(ctx context.Context, rule *storage.AlertRule, raised []alert) (int64, error) {
+	deliveries := make([]storage.AlertDelivery, 0, len(raised))
+	for _, a := range raised {
+		delivery, err := s.newDelivery(rule, a.key, a.notification)
+		if err != nil {
+			return 0, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	created, err := s.deliveries.CreateAlertDeliveries(ctx, deliveries)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save alerts of rule %d: %w", rule.ID, err)
+	}
+	return created, nil
+}
+
+// evaluateRule returns the alerts a rule's condition raises now, before
+// dedupe.
+func (s *Service) evaluateRule(ctx context.Context, rule *storage.AlertRule) ([]alert, error) {
+	cond, ok := conditions[rule.Condition]
+	if !ok {
+		return nil, fmt.Errorf("unknown condition %q", rule.Condition)
+	}
+	sc, err := s.scopeOf(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+	return cond.evaluate(s, ctx, rule, sc)
+}
+
+// newDelivery makes a pending delivery of a notification raised by a rule.
+func (s *Service) newDelivery(rule *storage.AlertRule, key string, n Notification) (*storage.AlertDelivery, error) {
+	n.RuleID = rule.ID
+	n.Rule = rule.Name
+	n.Condition = rule.Condition
+	if n.RaisedAt.IsZero() {
+		n.RaisedAt = time.Now()
+	}
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return nil, err
+	}
+	return &storage.AlertDelivery{
+		RuleID:    rule.ID,
+		DedupeKey: textutil.Truncate(key, 200),
+		Channel:   rule.Channel,
+		Target:    rule.Target,
+		Subject:   textutil.Truncate(n.Subject, 255),
+		Message:   n.Message,
+		Payload:   string(payload),
+		Status:    storage.AlertDeliveryPending,
+	}, nil
+}
+
+// deliverDue attempts the pending deliveries that are due.
+func (s *Service) deliverDue(ctx context.Context, result *RunResult) error {
+	deliveries, err := s.deliveries.ListDueAlertDeliveries(ctx, time.Now(), deliveryBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to list due alerts: %w", err)
+	}
+
+	for i := range deliveries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		d := &deliveries[i]
+		if err := s.attempt(ctx, d); err != nil {
+			return err
+		}
+		switch d.Status {
+		case storage.AlertDeliverySent:
+			result.Sent++
+		case storage.AlertDeliveryFailed:
+			result.Failed++
+		default:
+			result.Retrying++
+		}
+	}
+	return nil
+}
+
+// attempt sends a delivery once and records the outcome: sent, pending
+// with the next attempt backed off, or failed once attempts run out. Only
+// saving the outcome can fail.
+func (s *Service) attempt(ctx context.Context, d *storage.AlertDelivery) error {
+	sendErr := s.send(ctx, d)
+
+	now := time.Now()
+	d.Attempts++
+	if sendErr == nil {
+		d.Status = storage.AlertDeliverySent
+		d.SentAt = &now
+		d.LastError = ""
+		d.NextAttemptAt = nil
+	} else {
+		d.LastError = textutil.Truncate(sendErr.Error(), 500)
+		if d.Attempts >= s.config.MaxAttempts {
+			d.Status = storage.AlertDeliveryFailed
+			d.NextAttemptAt = nil
+		} else {
+			next := now.Add(s.backoff(d.Attempts))
+			d.NextAttemptAt = &next
+		}
+	}
+
+	if err := s.deliveries.UpdateAlertDelivery(ctx, d); err != nil {
+		return fmt.Errorf("failed to save alert delivery %d: %w", d.ID, err)
+	}
+	return nil
+}
+
+// send delivers a recorded alert through its channel.
+func (s *Service) send(ctx context.Context, d *storage.AlertDelivery) error {
+	channel, ok := s.channels[d.Channel]
+	if !ok {
+		return fmt.Errorf("channel %s is not configured", d.Channel)
+	}
+
+	var n Notification
+	if err := json.Unmarshal([]byte(d.Payload), &n); err != nil {
+		n = Notification{Subject: d.Subject, Message: d.Message}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+	return channel.Send(ctx, d.Target, n)
+}
+
+// maxBackoff caps the wait between delivery attempts.
+const maxBackoff = 24 * time.Hour
+
+// backoff returns the wait after the given number of failed attempts.
+func (s *Service) backoff(attempts int) time.Duration {
+	wait := s.config.RetryBackoff
+	if wait <= 0 {
+		wait = time.Minute
+	}
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+// Test sends a test notification through a rule's channel now and records
+// it with the rule's alerts.
+func (s *Service) Test(ctx context.Context, rule *storage.AlertRule) (*storage.AlertDelivery, error) {
+	now := time.Now()
+	n := Notification{
+		Subject:  fmt.Sprintf("Test alert: %s", rule.Name),
+		Message:  fmt.Sprintf("This is a test of the %s alert rule %q. Real alerts will look like this.", rule.Condition, rule.Name),
+		Symbol:   rule.Symbol,
+		URL:      s.publicURL,
+		RaisedAt: now,
+	}
+	delivery, err := s.newDelivery(rule, fmt.Sprintf("test:%d", now.UnixNano()), n)
+	if err != nil {
+		return nil, err
+	}
+	saved := []storage.AlertDelivery{*delivery}
+	if _, err := s.deliveries.CreateAlertDeliveries(ctx, saved); err != nil {
+		return nil, err
+	}
+	delivery = &saved[0]
+
+	// A test is sent once; a failure is reported, not retried.
+	if err := s.send(ctx, delivery); err != nil {
+		delivery.Status = storage.AlertDeliveryFailed
+		delivery.LastError = textutil.Truncate(err.Error(), 500)
+	} else {
+		delivery.Status = storage.AlertDeliverySent
+		delivery.SentAt = &now
+	}
+	delivery.Attempts = 1
+	if err := s.deliveries.UpdateAlertDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// Retry sends a delivery again now, restarting its attempts.
+func (s *Service) Retry(ctx context.Context, d *storage.AlertDelivery) error {
+	d.Status = storage.AlertDeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = nil
+	return s.attempt(ctx, d)
+}
+
+// recommendationURL returns the dashboard page of a recommendation.
+func (s *Service) recommendationURL(id uint) string {
+	return fmt.Sprintf("%s/recommendation/%d", s.publicURL, id)
+}
@@ -0,0 +1,499 @@
+package alerts
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/pkg/config"
+)
+
+// memStore keeps alert deliveries in memory, with the unique index on rule
+// and dedupe key of the alert_deliveries table.
+type memStore struct {
+	mu         sync.Mutex
+	deliveries []storage.AlertDelivery
+}
+
+func (m *memStore) CreateAlertDeliveries(ctx context.Context, deliveries []storage.AlertDelivery) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var created int64
+	for i := range deliveries {
+		d := &deliveries[i]
+		if m.find(d.RuleID, d.DedupeKey) != nil {
+			continue
+		}
+		d.ID = uint(len(m.deliveries) + 1)
+		m.deliveries = append(m.deliveries, *d)
+		created++
+	}
+	return created, nil
+}
+
+func (m *memStore) ListDueAlertDeliveries(ctx context.Context, now time.Time, limit int) ([]storage.AlertDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []storage.AlertDelivery
+	for _, d := range m.deliveries {
+		if d.Status == storage.AlertDeliveryPending && (d.NextAttemptAt == nil || !d.NextAttemptAt.After(now)) && len(due) < limit {
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+func (m *memStore) UpdateAlertDelivery(ctx context.Context, delivery *storage.AlertDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries[delivery.ID-1] = *delivery
+	return nil
+}
+
+func (m *memStore) find(ruleID uint, key string) *storage.AlertDelivery {
+	for i := range m.deliveries {
+		if m.deliveries[i].RuleID == ruleID && m.deliveries[i].DedupeKey == key {
+			return &m.deliveries[i]
+		}
+	}
+	return nil
+}
+
+// get returns a copy of a recorded delivery.
+func (m *memStore) get(t *testing.T, ruleID uint, key string) storage.AlertDelivery {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d := m.find(ruleID, key)
+	if d == nil {
+		t.Fatalf("no delivery of rule %d for %q", ruleID, key)
+	}
+	return *d
+}
+
+// makeDue moves the next attempt of every pending delivery into the past,
+// as if the backoff had passed.
+func (m *memStore) makeDue() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	past := time.Now().Add(-time.Second)
+	for i := range m.deliveries {
+		if m.deliveries[i].NextAttemptAt != nil {
+			m.deliveries[i].NextAttemptAt = &past
+		}
+	}
+}
+
+// newTestService returns a service recording deliveries in memory.
+func newTestService(cfg *config.Config) (*Service, *memStore) {
+	s := NewService(nil, nil, cfg)
+	store := &memStore{}
+	s.deliveries = store
+	return s, store
+}
+
+// raise makes an alert about a symbol.
+func raise(key, symbol string) alert {
+	return alert{key: key, notification: Notification{
+		Subject: symbol + " hit its target",
+		Message: symbol + " closed at 3,650.00, above the target of 3,600.00.",
+		Symbol:  symbol,
+		URL:     "https://stockchef.example.com/recommendation/7",
+	}}
+}
+
+// deliver runs deliverDue and returns its result.
+func deliver(t *testing.T, s *Service) *RunResult {
+	t.Helper()
+	result := &RunResult{}
+	if err := s.deliverDue(context.Background(), result); err != nil {
+		t.Fatalf("deliverDue: %v", err)
+	}
+	return result
+}
+
+// webhookServer answers the first failures requests with HTTP 503 and the
+// rest with 200, recording the notifications it receives.
+func webhookServer(t *testing.T, failures int) (*httptest.Server, func() []Notification) {
+	t.Helper()
+	var mu sync.Mutex
+	var received []Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Errorf("invalid notification: %v", err)
+		}
+		mu.Lock()
+		received = append(received, n)
+		fail := len(received) <= failures
+		mu.Unlock()
+		if fail {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []Notification {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Notification(nil), received...)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	server, received := webhookServer(t, 2)
+	s, store := newTestService(&config.Config{Alerts: config.AlertsConfig{MaxAttempts: 3, RetryBackoff: time.Minute}})
+	rule := &storage.AlertRule{ID: 1, Name: "Targets", Condition: storage.AlertTargetHit, Channel: storage.AlertChannelWebhook, Target: server.URL}
+	if _, err := s.record(context.Background(), rule, []alert{raise("target:7", "TCS")}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	// Two failures back off one and then two minutes.
+	for attempt, wantBackoff := range []time.Duration{time.Minute, 2 * time.Minute} {
+		before := time.Now()
+		if result := deliver(t, s); result.Retrying != 1 || result.Sent != 0 || result.Failed != 0 {
+			t.Fatalf("attempt %d: result = %+v, want one retrying", attempt+1, result)
+		}
+		d := store.get(t, 1, "target:7")
+		if d.Status != storage.AlertDeliveryPending || d.Attempts != attempt+1 || d.LastError != "webhook returned HTTP 503: busy" {
+			t.Errorf("attempt %d: status %s, %d attempts, last error %q", attempt+1, d.Status, d.Attempts, d.LastError)
+		}
+		if d.NextAttemptAt == nil || d.NextAttemptAt.Before(before.Add(wantBackoff)) || d.NextAttemptAt.After(time.Now().Add(wantBackoff)) {
+			t.Errorf("attempt %d: next attempt at %v, want %v from now", attempt+1, d.NextAttemptAt, wantBackoff)
+		}
+
+		// Nothing is sent until the backoff has passed.
+		if result := deliver(t, s); *result != (RunResult{}) {
+			t.Errorf("attempt %d: delivered %+v before the backoff passed", attempt+1, result)
+		}
+		store.makeDue()
+	}
+
+	if result := deliver(t, s); result.Sent != 1 {
+		t.Fatalf("result = %+v, want one sent", result)
+	}
+	d := store.get(t, 1, "target:7")
+	if d.Status != storage.AlertDeliverySent || d.Attempts != 3 || d.SentAt == nil || d.NextAttemptAt != nil || d.LastError != "" {
+		t.Errorf("delivery = %+v, want sent on the third attempt", d)
+	}
+
+	notifications := received()
+	if len(notifications) != 3 {
+		t.Fatalf("webhook received %d requests, want 3", len(notifications))
+	}
+	n := notifications[2]
+	if n.RuleID != 1 || n.Rule != "Targets" || n.Condition != storage.AlertTargetHit || n.Symbol != "TCS" || n.Subject != "TCS hit its target" || n.RaisedAt.IsZero() {
+		t.Errorf("notification = %+v", n)
+	}
+}
+
+func TestWebhookGivesUp(t *testing.T) {
+	server, received := webhookServer(t, 100)
+	s, store := newTestService(&config.Config{Alerts: config.AlertsConfig{MaxAttempts: 3, RetryBackoff: time.Minute}})
+	rule := &storage.AlertRule{ID: 1, Name: "Targets", Condition: storage.AlertTargetHit, Channel: storage.AlertChannelWebhook, Target: server.URL}
+	if _, err := s.record(context.Background(), rule, []alert{raise("target:7", "TCS")}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	var result *RunResult
+	for i := 0; i < 3; i++ {
+		result = deliver(t, s)
+		store.makeDue()
+	}
+	if result.Failed != 1 || result.Retrying != 0 {
+		t.Errorf("last result = %+v, want one failed", result)
+	}
+	d := store.get(t, 1, "target:7")
+	if d.Status != storage.AlertDeliveryFailed || d.Attempts != 3 || d.NextAttemptAt != nil || d.SentAt != nil || d.LastError == "" {
+		t.Errorf("delivery = %+v, want failed after 3 attempts", d)
+	}
+
+	// A failed delivery is not attempted again.
+	if result := deliver(t, s); *result != (RunResult{}) {
+		t.Errorf("delivered %+v after giving up", result)
+	}
+	if n := len(received()); n != 3 {
+		t.Errorf("webhook received %d requests, want 3", n)
+	}
+
+	// Retry starts its attempts over.
+	if err := s.Retry(context.Background(), &d); err != nil {
+		t.Fatalf("Retry: %v", err)
+	}
+	if d = store.get(t, 1, "target:7"); d.Status != storage.AlertDeliveryPending || d.Attempts != 1 || d.NextAttemptAt == nil {
+		t.Errorf("retried delivery = %+v, want pending after 1 attempt", d)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		retryBackoff time.Duration
+		attempts     int
+		want         time.Duration
+	}{
+		{time.Minute, 1, time.Minute},
+		{time.Minute, 2, 2 * time.Minute},
+		{time.Minute, 4, 8 * time.Minute},
+		{time.Minute, 20, maxBackoff},
+		{0, 1, time.Minute},
+		{30 * time.Second, 3, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		s := &Service{config: config.AlertsConfig{RetryBackoff: tt.retryBackoff}}
+		if got := s.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) with retry_backoff %v = %v, want %v", tt.attempts, tt.retryBackoff, got, tt.want)
+		}
+	}
+}
+
+// telegramServer stands in for the Bot API and records the messages sent.
+func telegramServer(t *testing.T) (*httptest.Server, func() []map[string]interface{}) {
+	t.Helper()
+	var mu sync.Mutex
+	var sent []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/botTEST-TOKEN/sendMessage" {
+			t.Errorf("unexpected call to %s", r.URL.Path)
+		}
+		var params map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			t.Errorf("invalid parameters: %v", err)
+		}
+		mu.Lock()
+		sent = append(sent, params)
+		mu.Unlock()
+		fmt.Fprint(w, `{"ok":true,"result":{}}`)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]map[string]interface{}(nil), sent...)
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	server, sent := telegramServer(t)
+	s, _ := newTestService(&config.Config{Telegram: config.TelegramConfig{BotToken: "TEST-TOKEN", APIURL: server.URL}})
+	targets := &storage.AlertRule{ID: 1, Name: "Targets", Condition: storage.AlertTargetHit, Channel: storage.AlertChannelTelegram, Target: "-100123"}
+	picks := &storage.AlertRule{ID: 2, Name: "Picks", Condition: storage.AlertDailyPick, Channel: storage.AlertChannelTelegram, Target: "@stockchef_picks"}
+
+	tests := []struct {
+		rule     *storage.AlertRule
+		raised   []alert
+		wantNew  int64
+		wantSent int
+	}{
+		{targets, []alert{raise("target:7", "TCS"), raise("target:8", "INFY")}, 2, 2},
+		// The same events raised again are not sent again.
+		{targets, []alert{raise("target:7", "TCS"), raise("target:8", "INFY")}, 0, 0},
+		{targets, []alert{raise("target:8", "INFY"), raise("target:9", "ITC")}, 1, 1},
+		// Another rule alerts on the same key.
+		{picks, []alert{raise("target:7", "TCS")}, 1, 1},
+	}
+
+	total := 0
+	for i, tt := range tests {
+		created, err := s.record(context.Background(), tt.rule, tt.raised)
+		if err != nil {
+			t.Fatalf("run %d: record: %v", i+1, err)
+		}
+		if created != tt.wantNew {
+			t.Errorf("run %d: %d new alerts, want %d", i+1, created, tt.wantNew)
+		}
+		if result := deliver(t, s); result.Sent != tt.wantSent {
+			t.Errorf("run %d: sent %d, want %d", i+1, result.Sent, tt.wantSent)
+		}
+		total += tt.wantSent
+	}
+
+	messages := sent()
+	if len(messages) != total {
+		t.Fatalf("Telegram received %d messages, want %d", len(messages), total)
+	}
+	want := "TCS hit its target\n\nTCS closed at 3,650.00, above the target of 3,600.00.\nhttps://stockchef.example.com/recommendation/7"
+	if messages[0]["chat_id"] != "-100123" || messages[0]["text"] != want {
+		t.Errorf("first message = %v", messages[0])
+	}
+	if last := messages[len(messages)-1]; last["chat_id"] != "@stockchef_picks" {
+		t.Errorf("last message sent to %v", last["chat_id"])
+	}
+}
+
+// smtpServer is a minimal SMTP server that records the messages it is
+// sent. Recipients in reject are refused.
+type smtpServer struct {
+	listener net.Listener
+	reject   string
+
+	mu       sync.Mutex
+	messages []string // envelope and data of each message
+}
+
+func newSMTPServer(t *testing.T, reject string) *smtpServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &smtpServer{listener: listener, reject: reject}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return srv
+}
+
+func (srv *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+	reply("220 localhost ESMTP test")
+	var envelope strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			envelope.Reset()
+			envelope.WriteString(line + "\n")
+			reply("250 OK")
+		case "RCPT":
+			if srv.reject != "" && strings.Contains(line, srv.reject) {
+				reply("550 No such user")
+				continue
+			}
+			envelope.WriteString(line + "\n")
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			srv.mu.Lock()
+			srv.messages = append(srv.messages, envelope.String()+data.String())
+			srv.mu.Unlock()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (srv *smtpServer) received() []string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return append([]string(nil), srv.messages...)
+}
+
+func TestEmailDelivery(t *testing.T) {
+	srv := newSMTPServer(t, "nobody@")
+	addr := srv.listener.Addr().(*net.TCPAddr)
+	s, store := newTestService(&config.Config{Alerts: config.AlertsConfig{
+		MaxAttempts:  2,
+		RetryBackoff: time.Minute,
+		SMTP:         config.SMTPConfig{Host: "127.0.0.1", Port: addr.Port, From: "alerts@stockchef.example.com"},
+	}})
+
+	rule := &storage.AlertRule{ID: 1, Name: "Targets", Condition: storage.AlertTargetHit, Channel: storage.AlertChannelEmail, Target: "desk@example.com"}
+	refused := &storage.AlertRule{ID: 2, Name: "Refused", Condition: storage.AlertTargetHit, Channel: storage.AlertChannelEmail, Target: "nobody@example.com"}
+	for _, r := range []*storage.AlertRule{rule, refused} {
+		if _, err := s.record(context.Background(), r, []alert{raise("target:7", "TCS")}); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	if result := deliver(t, s); result.Sent != 1 || result.Retrying != 1 {
+		t.Fatalf("result = %+v, want one sent and one retrying", result)
+	}
+	messages := srv.received()
+	if len(messages) != 1 {
+		t.Fatalf("SMTP server received %d messages, want 1", len(messages))
+	}
+	for _, want := range []string{
+		"MAIL FROM:<alerts@stockchef.example.com>",
+		"RCPT TO:<desk@example.com>",
+		"Subject: TCS hit its target\r\n",
+		"TCS closed at 3,650.00, above the target of 3,600.00.\r\n\r\nhttps://stockchef.example.com/recommendation/7\r\n",
+	} {
+		if !strings.Contains(messages[0], want) {
+			t.Errorf("message does not contain %q:\n%s", want, messages[0])
+		}
+	}
+	if d := store.get(t, 1, "target:7"); d.Status != storage.AlertDeliverySent {
+		t.Errorf("status = %s, want sent", d.Status)
+	}
+
+	// The refused recipient fails on its second and last attempt.
+	d := store.get(t, 2, "target:7")
+	if d.Status != storage.AlertDeliveryPending || !strings.HasPrefix(d.LastError, "550 ") {
+		t.Errorf("refused delivery = %s, last error %q", d.Status, d.LastError)
+	}
+	store.makeDue()
+	if result := deliver(t, s); result.Failed != 1 {
+		t.Errorf("result = %+v, want one failed", result)
+	}
+	if d := store.get(t, 2, "target:7"); d.Status != storage.AlertDeliveryFailed || d.Attempts != 2 {
+		t.Errorf("refused delivery = %s after %d attempts, want failed after 2", d.Status, d.Attempts)
+	}
+}
+
+func TestSendTest(t *testing.T) {
+	server, received := webhookServer(t, 1)
+	s, store := newTestService(&config.Config{Alerts: config.AlertsConfig{MaxAttempts: 3}})
+	rule := &storage.AlertRule{ID: 1, Name: "Targets", Condition: storage.AlertTargetHit, Channel: storage.AlertChannelWebhook, Target: server.URL}
+
+	// A failed test is reported and not retried.
+	d, err := s.Test(context.Background(), rule)
+	if err != nil {
+		t.Fatalf("Test: %v", err)
+	}
+	if d.Status != storage.AlertDeliveryFailed || d.Attempts != 1 || !strings.HasPrefix(d.DedupeKey, "test:") {
+		t.Errorf("delivery = %+v, want a failed test", d)
+	}
+	if saved := store.get(t, 1, d.DedupeKey); saved.Status != storage.AlertDeliveryFailed {
+		t.Errorf("recorded status = %s, want failed", saved.Status)
+	}
+	if result := deliver(t, s); *result != (RunResult{}) {
+		t.Errorf("retried a test: %+v", result)
+	}
+
+	if d, err = s.Test(context.Background(), rule); err != nil || d.Status != storage.AlertDeliverySent {
+		t.Errorf("second test = %+v, %v, want sent", d, err)
+	}
+	if n := received(); len(n) != 2 || n[1].Subject != "Test alert: Targets" {
+		t.Errorf("webhook received %+v", n)
+	}
+}
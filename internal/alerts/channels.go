@@ -0,0 +1,200 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/internal/telegram"
+	"github.com/user/stock-recommender/pkg/config"
+)
+
+// Channel sends notifications to targets such as a URL, an email address
+// or a chat.
+type Channel interface {
+	// Name is the channel name rules refer to.
+	Name() string
+	// Validate checks that target can be sent to.
+	Validate(target string) error
+	// Send delivers a notification to target.
+	Send(ctx context.Context, target string, n Notification) error
+}
+
+// WebhookChannel POSTs notifications as JSON to a URL.
+type WebhookChannel struct {
+	client *http.Client
+}
+
+// NewWebhookChannel creates a webhook channel. If client is nil,
+// http.DefaultClient is used.
+func NewWebhookChannel(client *http.Client) *WebhookChannel {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebhookChannel{client: client}
+}
+
+// Name implements Channel.
+func (c *WebhookChannel) Name() string {
+	return storage.AlertChannelWebhook
+}
+
+// Validate implements Channel. The target must be an http or https URL.
+func (c *WebhookChannel) Validate(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("target must be an http or https URL")
+	}
+	return nil
+}
+
+// Send implements Channel. Any 2xx response counts as delivered.
+func (c *WebhookChannel) Send(ctx context.Context, target string, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "StockChef-Alerts/1.0")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("webhook returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
+
+// EmailChannel sends notifications as plain text email through an SMTP
+// server.
+type EmailChannel struct {
+	cfg config.SMTPConfig
+}
+
+// NewEmailChannel creates an email channel.
+func NewEmailChannel(cfg config.SMTPConfig) *EmailChannel {
+	return &EmailChannel{cfg: cfg}
+}
+
+// Name implements Channel.
+func (c *EmailChannel) Name() string {
+	return storage.AlertChannelEmail
+}
+
+// Validate implements Channel. The target must be a single email address.
+func (c *EmailChannel) Validate(target string) error {
+	if _, err := mail.ParseAddress(target); err != nil {
+		return errors.New("target must be an email address")
+	}
+	return nil
+}
+
+// Send implements Channel. The server is authenticated with PLAIN when a
+// username is configured, which net/smtp only allows over TLS or to
+// localhost.
+func (c *EmailChannel) Send(ctx context.Context, target string, n Notification) error {
+	to, err := mail.ParseAddress(target)
+	if err != nil {
+		return err
+	}
+	from := c.cfg.From
+	if from == "" {
+		from = c.cfg.Username
+	}
+
+	var body strings.Builder
+	body.WriteString(strings.ReplaceAll(n.Message, "\r\n", "\n"))
+	if n.URL != "" {
+		body.WriteString("\n\n")
+		body.WriteString(n.URL)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to.String())
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.Subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body.String(), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	var auth smtp.Auth
+	if c.cfg.Username != "" {
+		auth = smtp.PlainAuth("", c.cfg.Username, c.cfg.Password, c.cfg.Host)
+	}
+	addr := net.JoinHostPort(c.cfg.Host, strconv.Itoa(c.cfg.Port))
+
+	// net/smtp takes no context, so give up waiting once it is done.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, from, []string{to.Address}, msg.Bytes())
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// chatIDPattern matches a Telegram chat ID or @channel username.
+var chatIDPattern = regexp.MustCompile(`^(-?\d+|@[A-Za-z][A-Za-z0-9_]{4,})$`)
+
+// TelegramChannel sends notifications as Telegram messages from a bot.
+type TelegramChannel struct {
+	client *telegram.Client
+}
+
+// NewTelegramChannel creates a Telegram channel.
+func NewTelegramChannel(client *telegram.Client) *TelegramChannel {
+	return &TelegramChannel{client: client}
+}
+
+// Name implements Channel.
+func (c *TelegramChannel) Name() string {
+	return storage.AlertChannelTelegram
+}
+
+// Validate implements Channel. The target must be a chat ID or @channel
+// username.
+func (c *TelegramChannel) Validate(target string) error {
+	if !chatIDPattern.MatchString(target) {
+		return errors.New("target must be a Telegram chat ID or @channel")
+	}
+	return nil
+}
+
+// Send implements Channel.
+func (c *TelegramChannel) Send(ctx context.Context, target string, n Notification) error {
+	text := n.Subject + "\n\n" + n.Message
+	if n.URL != "" {
+		text += "\n" + n.URL
+	}
+	return c.client.SendMessage(ctx, target, text)
+}
@@ -0,0 +1,373 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/pkg/textutil"
+)
+
+// alert is a notification raised by a rule, keyed by what it is about.
+type alert struct {
+	key          string
+	notification Notification
+}
+
+// condition evaluates the alerts of one kind of rule.
+type condition struct {
+	description      string
+	defaultThreshold float64
+	maxThreshold     float64
+	evaluate         func(s *Service, ctx context.Context, rule *storage.AlertRule, sc *scope) ([]alert, error)
+}
+
+// conditions are the alert conditions, by name.
+var conditions = map[string]condition{
+	storage.AlertTargetHit: {
+		description: "Price reached the target of an active recommendation",
+		evaluate: func(s *Service, ctx context.Context, rule *storage.AlertRule, sc *scope) ([]alert, error) {
+			return s.priceAlerts(ctx, sc, true)
+		},
+	},
+	storage.AlertStopLossHit: {
+		description: "Price reached the stop-loss of an active recommendation",
+		evaluate: func(s *Service, ctx context.Context, rule *storage.AlertRule, sc *scope) ([]alert, error) {
+			return s.priceAlerts(ctx, sc, false)
+		},
+	},
+	storage.AlertActionChange: {
+		description: "Re-analysis changed the action of a watchlist stock",
+		evaluate:    (*Service).actionChangeAlerts,
+	},
+	storage.AlertBearishNews: {
+		description:      "News scored at or below -threshold (0 to 1)",
+		defaultThreshold: 0.5,
+		maxThreshold:     1,
+		evaluate:         (*Service).bearishNewsAlerts,
+	},
+	storage.AlertDailyPick: {
+		description:  "A stock was picked with at least threshold confidence (0 to 100)",
+		maxThreshold: 100,
+		evaluate:     (*Service).dailyPickAlerts,
+	},
+}
+
+// Conditions returns the names of the alert conditions.
+func Conditions() []string {
+	names := make([]string, 0, len(conditions))
+	for name := range conditions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ConditionDescriptions describes each alert condition, by name.
+func ConditionDescriptions() map[string]string {
+	descriptions := make(map[string]string, len(conditions))
+	for name, cond := range conditions {
+		descriptions[name] = cond.description
+	}
+	return descriptions
+}
+
+// scope is the set of stocks a rule watches. A nil scope watches every
+// stock.
+type scope struct {
+	stockIDs map[uint]bool
+}
+
+// has reports whether a stock is in scope.
+func (sc *scope) has(stockID uint) bool {
+	return sc == nil || sc.stockIDs[stockID]
+}
+
+// ids returns the stocks in scope, or nil for every stock.
+func (sc *scope) ids() []uint {
+	if sc == nil {
+		return nil
+	}
+	ids := make([]uint, 0, len(sc.stockIDs))
+	for id := range sc.stockIDs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// empty reports whether the scope has no stocks at all.
+func (sc *scope) empty() bool {
+	return sc != nil && len(sc.stockIDs) == 0
+}
+
+// scopeOf returns the stocks a rule watches: its stock, the stocks on its
+// watchlist, or nil for every stock.
+func (s *Service) scopeOf(ctx context.Context, rule *storage.AlertRule) (*scope, error) {
+	switch {
+	case rule.Symbol != "":
+		sc := &scope{stockIDs: make(map[uint]bool)}
+		stock, err := s.repo.GetStockBySymbol(ctx, rule.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		if stock != nil {
+			sc.stockIDs[stock.ID] = true
+		}
+		return sc, nil
+
+	case rule.WatchlistID != nil:
+		sc := &scope{stockIDs: make(map[uint]bool)}
+		watchlist, err := s.repo.GetWatchlist(ctx, *rule.WatchlistID)
+		if err != nil {
+			return nil, err
+		}
+		if watchlist != nil {
+			for _, item := range watchlist.Items {
+				sc.stockIDs[item.StockID] = true
+			}
+		}
+		return sc, nil
+	}
+	return nil, nil
+}
+
+// priceAlerts raises an alert for each active recommendation in scope whose
+// stock's latest price has reached its target, or its stop-loss. Targets
+// below the entry price (SELL calls) are reached from above, and their
+// stop-losses from below. Each recommendation alerts once.
+func (s *Service) priceAlerts(ctx context.Context, sc *scope, target bool) ([]alert, error) {
+	if sc.empty() {
+		return nil, nil
+	}
+	recs, err := s.repo.ListRecommendations(ctx, true, "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []alert
+	prices := make(map[uint]float64)
+	for _, rec := range recs {
+		if !sc.has(rec.StockID) {
+			continue
+		}
+		level := rec.StopLoss
+		if target {
+			level = rec.TargetPrice
+		}
+		if level <= 0 {
+			continue
+		}
+
+		price, cached := prices[rec.StockID]
+		if !cached {
+			fundamental, err := s.repo.GetLatestFundamental(ctx, rec.StockID)
+			if err != nil {
+				return nil, err
+			}
+			if fundamental != nil {
+				price = fundamental.CurrentPrice
+			}
+			prices[rec.StockID] = price
+		}
+		if price <= 0 {
+			continue
+		}
+
+		rising := rec.TargetPrice >= rec.EntryPrice
+		if !target {
+			rising = !rising
+		}
+		if (rising && price < level) || (!rising && price > level) {
+			continue
+		}
+
+		what, key := "stop-loss", "stop_loss"
+		if target {
+			what, key = "target", "target"
+		}
+		symbol := rec.Stock.Symbol
+		alerts = append(alerts, alert{
+			key: fmt.Sprintf("%s:recommendation:%d", key, rec.ID),
+			notification: Notification{
+				Subject: fmt.Sprintf("%s reached its %s of ₹%.2f", symbol, what, level),
+				Message: fmt.Sprintf("%s is at ₹%.2f. The %s recommendation of %s had entry ₹%.2f, target ₹%.2f and stop-loss ₹%.2f (confidence %.0f%%).",
+					symbol, price, rec.Action, rec.CreatedAt.Format("2 Jan 2006"), rec.EntryPrice, rec.TargetPrice, rec.StopLoss, rec.ConfidenceScore),
+				Symbol: symbol,
+				URL:    s.recommendationURL(rec.ID),
+				Data: map[string]interface{}{
+					"recommendation_id": rec.ID,
+					"action":            rec.Action,
+					"price":             price,
+					"entry_price":       rec.EntryPrice,
+					"target_price":      rec.TargetPrice,
+					"stop_loss":         rec.StopLoss,
+				},
+			},
+		})
+	}
+	return alerts, nil
+}
+
+// actionChangeAlerts raises an alert for each watchlist stock in scope
+// whose action changed in a re-analysis after the rule was created. A
+// change seen on several watchlists alerts once.
+func (s *Service) actionChangeAlerts(ctx context.Context, rule *storage.AlertRule, sc *scope) ([]alert, error) {
+	var watchlists []storage.Watchlist
+	if rule.WatchlistID != nil {
+		watchlist, err := s.repo.GetWatchlist(ctx, *rule.WatchlistID)
+		if err != nil {
+			return nil, err
+		}
+		if watchlist == nil {
+			return nil, nil
+		}
+		watchlists = []storage.Watchlist{*watchlist}
+	} else {
+		var err error
+		if watchlists, err = s.repo.ListWatchlists(ctx, false); err != nil {
+			return nil, err
+		}
+	}
+
+	var alerts []alert
+	seen := make(map[string]bool)
+	for _, watchlist := range watchlists {
+		for _, item := range watchlist.Items {
+			if !item.ActionChanged || item.ActionChangedAt == nil || item.LastRecommendationID == nil ||
+				item.ActionChangedAt.Before(rule.CreatedAt) || !sc.has(item.StockID) {
+				continue
+			}
+			key := fmt.Sprintf("action:%d:recommendation:%d", item.StockID, *item.LastRecommendationID)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			alerts = append(alerts, alert{
+				key: key,
+				notification: Notification{
+					Subject: fmt.Sprintf("%s changed from %s to %s", item.Symbol, item.PreviousAction, item.LastAction),
+					Message: fmt.Sprintf("Re-analysis of %s on watchlist %s changed its recommendation from %s to %s.",
+						item.Symbol, watchlist.Name, item.PreviousAction, item.LastAction),
+					Symbol:   item.Symbol,
+					URL:      s.recommendationURL(*item.LastRecommendationID),
+					RaisedAt: *item.ActionChangedAt,
+					Data: map[string]interface{}{
+						"watchlist":         watchlist.Name,
+						"from":              item.PreviousAction,
+						"to":                item.LastAction,
+						"recommendation_id": *item.LastRecommendationID,
+					},
+				},
+			})
+		}
+	}
+	return alerts, nil
+}
+
+// bearishNewsAlerts raises an alert for each article saved after the rule
+// was created that scored at or below -Threshold, for a stock in scope if
+// the rule has one.
+func (s *Service) bearishNewsAlerts(ctx context.Context, rule *storage.AlertRule, sc *scope) ([]alert, error) {
+	if sc.empty() {
+		return nil, nil
+	}
+	news, err := s.repo.ListBearishNews(ctx, rule.CreatedAt, -rule.Threshold, sc.ids(), 50)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := make([]alert, 0, len(news))
+	for _, n := range news {
+		score := n.SentimentScore
+		var symbols []string
+		for _, link := range n.StockLinks {
+			if link.Stock != nil {
+				symbols = append(symbols, link.Stock.Symbol)
+			}
+			if link.SentimentScore < score {
+				score = link.SentimentScore
+			}
+		}
+		if len(symbols) == 0 && n.Stock != nil {
+			symbols = append(symbols, n.Stock.Symbol)
+		}
+
+		message := fmt.Sprintf("%s (sentiment %.2f)", n.Source, score)
+		if len(symbols) > 0 {
+			message = fmt.Sprintf("%s about %s (sentiment %.2f)", n.Source, strings.Join(symbols, ", "), score)
+		}
+		if n.Description != "" {
+			message += "\n" + textutil.Truncate(n.Description, 500)
+		}
+
+		notification := Notification{
+			Subject:  textutil.Truncate("Bearish news: "+n.Title, 255),
+			Message:  message,
+			URL:      n.URL,
+			RaisedAt: n.CreatedAt,
+			Data: map[string]interface{}{
+				"news_id":         n.ID,
+				"sentiment_score": score,
+				"symbols":         symbols,
+				"published_at":    n.PublishedAt,
+			},
+		}
+		if len(symbols) == 1 {
+			notification.Symbol = symbols[0]
+		}
+		alerts = append(alerts, alert{key: fmt.Sprintf("news:%d", n.ID), notification: notification})
+	}
+	return alerts, nil
+}
+
+// dailyPickAlerts raises an alert for each stock in scope in the latest
+// daily picks, generated after the rule was created, with at least
+// Threshold confidence.
+func (s *Service) dailyPickAlerts(ctx context.Context, rule *storage.AlertRule, sc *scope) ([]alert, error) {
+	if sc.empty() {
+		return nil, nil
+	}
+	picks, found := s.engine.GetCachedDailyPicks(ctx)
+	if !found || picks.GeneratedAt.Before(rule.CreatedAt) {
+		return nil, nil
+	}
+
+	var alerts []alert
+	for _, pick := range picks.Picks {
+		if pick.ConfidenceScore < rule.Threshold {
+			continue
+		}
+		if sc != nil && (pick.Recommendation == nil || !sc.has(pick.Recommendation.StockID)) {
+			continue
+		}
+
+		notification := Notification{
+			Subject: fmt.Sprintf("Daily pick #%d: %s %s", pick.Rank, pick.Action, pick.Symbol),
+			Message: fmt.Sprintf("%s (%s): %s at ₹%.2f, target ₹%.2f, stop-loss ₹%.2f, confidence %.0f%%, %s risk, %s.\n%s",
+				pick.Name, pick.Symbol, pick.Action, pick.EntryPrice, pick.TargetPrice, pick.StopLoss,
+				pick.ConfidenceScore, pick.RiskLevel, strings.ReplaceAll(pick.TimeHorizon, "_", " "), textutil.Truncate(pick.Reasoning, 500)),
+			Symbol:   pick.Symbol,
+			RaisedAt: picks.GeneratedAt,
+			Data: map[string]interface{}{
+				"rank":             pick.Rank,
+				"action":           pick.Action,
+				"entry_price":      pick.EntryPrice,
+				"target_price":     pick.TargetPrice,
+				"stop_loss":        pick.StopLoss,
+				"confidence_score": pick.ConfidenceScore,
+			},
+		}
+		if pick.Recommendation != nil {
+			notification.URL = s.recommendationURL(pick.Recommendation.ID)
+			notification.Data["recommendation_id"] = pick.Recommendation.ID
+		}
+		alerts = append(alerts, alert{
+			key:          fmt.Sprintf("pick:%s:%s", pick.Symbol, picks.GeneratedAt.Format("2006-01-02T15:04")),
+			notification: notification,
+		})
+	}
+	return alerts, nil
+}
@@ -113,15 +113,7 @@ func (f *NewsFetcher) fetchFromSource(ctx context.Context, url string) ([]Fetche
 		sentimentResult := f.analyzer.Analyze(textToAnalyze)
 
 		// Convert sentiment
-		var sentimentScore storage.SentimentScore
-		switch sentimentResult.Sentiment {
-		case sentiment.Bullish:
-			sentimentScore = storage.SentimentBullish
-		case sentiment.Bearish:
-			sentimentScore = storage.SentimentBearish
-		default:
-			sentimentScore = storage.SentimentNeutral
-		}
+		sentimentScore := toSentimentScore(sentimentResult.Sentiment)
 
 		// Extract related stock symbols
 		relatedSymbols := extractStockSymbols(item.Title + " " + item.Description)
@@ -163,6 +155,113 @@ func (n *FetchedNews) ToNewsModel(stockID *uint) *storage.News {
 	}
 }
 
+// StockMention is how strongly, and in what tone, an article talks about one stock.
+type StockMention struct {
+	Symbol         string
+	Relevance      float64 // 0 to 1
+	Sentiment      storage.SentimentScore
+	SentimentScore float64
+}
+
+// ScoreMention scores an article's mention of a symbol. Relevance is higher
+// when the symbol appears in the title or description; sentiment is taken
+// from the sentences that mention the symbol, falling back to the article's
+// overall sentiment.
+func (f *NewsFetcher) ScoreMention(n FetchedNews, symbol string) StockMention {
+	symbol = strings.ToUpper(symbol)
+	mention := StockMention{
+		Symbol:         symbol,
+		Relevance:      0.4,
+		Sentiment:      n.Sentiment,
+		SentimentScore: n.SentimentScore,
+	}
+
+	if mentions(n.Title, symbol) {
+		mention.Relevance += 0.4
+	}
+	if mentions(n.Description, symbol) {
+		mention.Relevance += 0.2
+	}
+	if mention.Relevance > 1 {
+		mention.Relevance = 1
+	}
+
+	var mentioning []string
+	for _, sentence := range splitSentences(n.Title + ". " + n.Description) {
+		if mentions(sentence, symbol) {
+			mentioning = append(mentioning, sentence)
+		}
+	}
+	if len(mentioning) > 0 {
+		result := f.analyzer.Analyze(strings.Join(mentioning, " "))
+		if result.Score != 0 {
+			mention.Sentiment = toSentimentScore(result.Sentiment)
+			mention.SentimentScore = result.Score
+		}
+	}
+
+	return mention
+}
+
+// symbolSeparator matches the characters that cannot be part of a symbol.
+var symbolSeparator = regexp.MustCompile(`[^A-Z0-9&-]+`)
+
+// mentions reports whether text contains symbol as a whole word, so that a
+// short symbol such as ITC is not found in PITCH. The parts of hyphenated
+// words count as words too. symbol must be upper case.
+func mentions(text, symbol string) bool {
+	for _, word := range symbolSeparator.Split(strings.ToUpper(text), -1) {
+		if word == symbol {
+			return true
+		}
+		if strings.Contains(word, "-") {
+			for _, part := range strings.Split(word, "-") {
+				if part == symbol {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// ToNewsStock converts a mention to a link between a news article and a stock.
+func (m StockMention) ToNewsStock(stockID uint) storage.NewsStock {
+	return storage.NewsStock{
+		StockID:        stockID,
+		Relevance:      m.Relevance,
+		Sentiment:      m.Sentiment,
+		SentimentScore: m.SentimentScore,
+	}
+}
+
+// toSentimentScore converts an analyzer sentiment to the storage type.
+func toSentimentScore(s sentiment.Sentiment) storage.SentimentScore {
+	switch s {
+	case sentiment.Bullish:
+		return storage.SentimentBullish
+	case sentiment.Bearish:
+		return storage.SentimentBearish
+	default:
+		return storage.SentimentNeutral
+	}
+}
+
+// sentenceEnd matches sentence-ending punctuation and the space after it.
+var sentenceEnd = regexp.MustCompile(`[.!?]+\s+`)
+
+// splitSentences splits text on sentence-ending punctuation.
+func splitSentences(text string) []string {
+	parts := sentenceEnd.Split(text, -1)
+	sentences := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			sentences = append(sentences, p)
+		}
+	}
+	return sentences
+}
+
 // getSourceName extracts a friendly name from URL.
 func getSourceName(url string) string {
 	switch {
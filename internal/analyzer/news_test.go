@@ -0,0 +1,117 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		text   string
+		symbol string
+		want   bool
+	}{
+		{"ITC shares rise after Q2 results", "ITC", true},
+		{"Analysts see upside in itc", "ITC", true},
+		{"ITC's hotel demerger completes", "ITC", true},
+		{"FMCG stocks, led by ITC, gain", "ITC", true},
+		{"ITC-led rally lifts FMCG index", "ITC", true},
+		{"Start-up founders pitch to investors", "ITC", false},
+		{"Quarterly results beat estimates", "LT", false},
+		{"L&T bags order; LT up 2%", "LT", true},
+		{"M&M launches a new SUV", "M&M", true},
+		{"Bajaj-Auto sales rise", "BAJAJ-AUTO", true},
+		{"Bajaj Auto sales rise", "BAJAJ-AUTO", false},
+		{"Infosys, TCS and Wipro report results", "TCS", true},
+		{"", "TCS", false},
+	}
+
+	for _, tt := range tests {
+		if got := mentions(tt.text, tt.symbol); got != tt.want {
+			t.Errorf("mentions(%q, %q) = %v, want %v", tt.text, tt.symbol, got, tt.want)
+		}
+	}
+}
+
+func TestScoreMention(t *testing.T) {
+	f := NewNewsFetcher(nil)
+
+	tests := []struct {
+		name          string
+		title         string
+		description   string
+		symbol        string
+		wantRelevance float64
+	}{
+		{
+			name:          "in the title and description",
+			title:         "ITC shares rise after Q2 results",
+			description:   "ITC reported a 10% rise in net profit.",
+			symbol:        "itc",
+			wantRelevance: 1,
+		},
+		{
+			name:          "in the title",
+			title:         "ITC shares rise after Q2 results",
+			description:   "Cigarette volumes grew.",
+			symbol:        "ITC",
+			wantRelevance: 0.8,
+		},
+		{
+			name:          "in the description",
+			title:         "FMCG stocks gain",
+			description:   "ITC and HUL led the index higher.",
+			symbol:        "ITC",
+			wantRelevance: 0.6,
+		},
+		{
+			// A short symbol inside longer words is not a mention.
+			name:          "inside other words",
+			title:         "Founders pitch to investors as results season starts",
+			description:   "Switching costs remain high.",
+			symbol:        "ITC",
+			wantRelevance: 0.4,
+		},
+		{
+			name:          "two-letter symbol inside other words",
+			title:         "Results beat estimates",
+			description:   "Multiple brokerages raise targets.",
+			symbol:        "LT",
+			wantRelevance: 0.4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := FetchedNews{
+				Title:          tt.title,
+				Description:    tt.description,
+				Sentiment:      storage.SentimentNeutral,
+				SentimentScore: 0,
+			}
+			got := f.ScoreMention(n, tt.symbol)
+			if math.Abs(got.Relevance-tt.wantRelevance) > 1e-9 {
+				t.Errorf("Relevance = %v, want %v", got.Relevance, tt.wantRelevance)
+			}
+		})
+	}
+}
+
+func TestScoreMentionSentiment(t *testing.T) {
+	f := NewNewsFetcher(nil)
+
+	// Only the sentences that mention the stock decide its sentiment, and
+	// a sentence mentioning PITCH does not mention ITC.
+	n := FetchedNews{
+		Title:          "ITC shares surge to a record high",
+		Description:    "Startups pitch plunge in funding as losses widen. Investors worry about a crash.",
+		Sentiment:      storage.SentimentBearish,
+		SentimentScore: -0.5,
+	}
+	got := f.ScoreMention(n, "ITC")
+	if got.Sentiment != storage.SentimentBullish || got.SentimentScore <= 0 {
+		t.Errorf("sentiment = %s (%v), want bullish", got.Sentiment, got.SentimentScore)
+	}
+}
@@ -0,0 +1,267 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/stock-recommender/internal/alerts"
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// AlertRuleRequest creates or updates an alert rule. On update, fields left
+// out keep their values.
+type AlertRuleRequest struct {
+	Name        *string  `json:"name"`
+	Condition   *string  `json:"condition"`
+	Symbol      *string  `json:"symbol"`
+	WatchlistID *uint    `json:"watchlist_id"`
+	Threshold   *float64 `json:"threshold"`
+	Channel     *string  `json:"channel"`
+	Target      *string  `json:"target"`
+	Enabled     *bool    `json:"enabled"` // defaults to true
+}
+
+// apply copies the fields set in the request onto a rule.
+func (req *AlertRuleRequest) apply(rule *storage.AlertRule) {
+	if req.Name != nil {
+		rule.Name = *req.Name
+	}
+	if req.Condition != nil {
+		rule.Condition = *req.Condition
+	}
+	if req.Symbol != nil {
+		rule.Symbol = *req.Symbol
+	}
+	if req.WatchlistID != nil {
+		rule.WatchlistID = req.WatchlistID
+		if *req.WatchlistID == 0 {
+			rule.WatchlistID = nil
+		}
+	}
+	if req.Threshold != nil {
+		rule.Threshold = *req.Threshold
+	}
+	if req.Channel != nil {
+		rule.Channel = *req.Channel
+	}
+	if req.Target != nil {
+		rule.Target = *req.Target
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+}
+
+// handleListAlertRules lists alert rules with the conditions and channels
+// available.
+func (s *Server) handleListAlertRules(c *gin.Context) {
+	rules, err := s.repo.ListAlertRules(c.Request.Context(), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rules":      rules,
+		"count":      len(rules),
+		"conditions": alerts.ConditionDescriptions(),
+		"channels":   s.alerts.Channels(),
+	})
+}
+
+// handleCreateAlertRule creates an alert rule.
+func (s *Server) handleCreateAlertRule(c *gin.Context) {
+	var req AlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := &storage.AlertRule{Enabled: true}
+	req.apply(rule)
+	if !s.saveAlertRule(c, rule) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// loadAlertRule loads the alert rule named by the :id parameter, responding
+// with an error and returning nil if there is none.
+func (s *Server) loadAlertRule(c *gin.Context) *storage.AlertRule {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alert rule ID"})
+		return nil
+	}
+
+	rule, err := s.repo.GetAlertRule(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil
+	}
+	if rule == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "alert rule not found"})
+		return nil
+	}
+	return rule
+}
+
+// handleGetAlertRule returns an alert rule with its latest deliveries.
+func (s *Server) handleGetAlertRule(c *gin.Context) {
+	rule := s.loadAlertRule(c)
+	if rule == nil {
+		return
+	}
+
+	deliveries, err := s.repo.ListAlertDeliveries(c.Request.Context(), rule.ID, "", 20)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rule":       rule,
+		"deliveries": deliveries,
+	})
+}
+
+// handleUpdateAlertRule updates an alert rule.
+func (s *Server) handleUpdateAlertRule(c *gin.Context) {
+	rule := s.loadAlertRule(c)
+	if rule == nil {
+		return
+	}
+
+	var req AlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.apply(rule)
+	if !s.saveAlertRule(c, rule) {
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// saveAlertRule validates and saves a rule, responding with an error and
+// returning false if it cannot be saved.
+func (s *Server) saveAlertRule(c *gin.Context, rule *storage.AlertRule) bool {
+	if err := s.alerts.ValidateRule(c.Request.Context(), rule); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, alerts.ErrInvalidRule) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return false
+	}
+
+	save := s.repo.UpdateAlertRule
+	if rule.ID == 0 {
+		save = s.repo.CreateAlertRule
+	}
+	if err := save(c.Request.Context(), rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// handleDeleteAlertRule deletes an alert rule and its deliveries.
+func (s *Server) handleDeleteAlertRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alert rule ID"})
+		return
+	}
+
+	deleted, err := s.repo.DeleteAlertRule(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "alert rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "alert rule deleted"})
+}
+
+// handleTestAlertRule sends a test notification through a rule's channel
+// and returns the delivery. A failed test is not retried.
+func (s *Server) handleTestAlertRule(c *gin.Context) {
+	rule := s.loadAlertRule(c)
+	if rule == nil {
+		return
+	}
+
+	delivery, err := s.alerts.Test(c.Request.Context(), rule)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := http.StatusOK
+	if delivery.Status != storage.AlertDeliverySent {
+		status = http.StatusBadGateway
+	}
+	c.JSON(status, delivery)
+}
+
+// handleListAlertDeliveries lists alert deliveries, newest first.
+// ?rule_id= and ?status= (pending, sent or failed) narrow the list.
+func (s *Server) handleListAlertDeliveries(c *gin.Context) {
+	var ruleID uint
+	if v := c.Query("rule_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule_id"})
+			return
+		}
+		ruleID = uint(id)
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	deliveries, err := s.repo.ListAlertDeliveries(c.Request.Context(), ruleID, c.Query("status"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	})
+}
+
+// handleRetryAlertDelivery sends an alert again now, restarting its
+// attempts.
+func (s *Server) handleRetryAlertDelivery(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid delivery ID"})
+		return
+	}
+
+	delivery, err := s.repo.GetAlertDelivery(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if delivery == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "delivery not found"})
+		return
+	}
+
+	if err := s.alerts.Retry(c.Request.Context(), delivery); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
@@ -2,14 +2,19 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/user/stock-recommender/internal/calendar"
 	"github.com/user/stock-recommender/internal/recommender"
+	"github.com/user/stock-recommender/internal/screener"
 	"github.com/user/stock-recommender/internal/storage"
 )
 
@@ -46,14 +51,22 @@ func (s *Server) handleAnalyzeStock(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"recommendation": result.Recommendation,
 		"stock":          result.Stock,
 		"fundamental":    result.Fundamental,
 		"news_count":     len(result.News),
 		"news_sentiment": result.NewsSentiment,
 		"data_sources":   result.DataSources,
-	})
+	}
+	if result.Fundamental != nil {
+		response["data_as_of"] = result.Fundamental.FetchedAt
+		response["data_age"] = recommender.FormatAge(result.DataAge)
+		response["data_age_hours"] = math.Round(result.DataAge.Hours()*10) / 10
+		response["data_stale"] = result.DataStale
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // handleListRecommendations handles listing recommendations.
@@ -104,7 +117,17 @@ func (s *Server) handleGetRecommendation(c *gin.Context) {
 	c.JSON(http.StatusOK, recommendation)
 }
 
-// handleScreenerUpload handles screener.in CSV uploads.
+// asyncUploadThreshold is the file size above which uploads are imported in
+// the background.
+const asyncUploadThreshold = 1 << 20
+
+// handleScreenerUpload handles screener.in CSV and XLSX uploads; ?sheet=
+// chooses the worksheet of a workbook. Every row's outcome is recorded
+// against the upload so that failures can be inspected and the upload
+// rolled back. Large files, or any file with ?async=true, are imported
+// in the background; follow them at /screener/uploads/:id/progress.
+// With ?dry_run=true nothing is imported and the column mapping, sample
+// rows and problem rows are returned instead.
 func (s *Server) handleScreenerUpload(c *gin.Context) {
 	file, header, err := c.Request.FormFile("file")
 	if err != nil {
@@ -113,54 +136,70 @@ func (s *Server) handleScreenerUpload(c *gin.Context) {
 	}
 	defer file.Close()
 
-	// Create upload record
-	upload := &storage.ScreenerUpload{
-		Filename:    header.Filename,
-		Status:      "processing",
-		ProcessedAt: time.Now(),
-	}
-	if err := s.repo.CreateScreenerUpload(c.Request.Context(), upload); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create upload record"})
-		return
-	}
+	// The format is detected from the content, so the file name does not matter
+	opts := screener.ImportOptions{Sheet: c.Query("sheet")}
 
-	// Parse CSV
-	stocks, err := s.csvParser.Parse(file)
-	if err != nil {
-		upload.Status = "failed"
-		upload.ErrorMessage = err.Error()
-		s.repo.UpdateScreenerUpload(c.Request.Context(), upload)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if c.Query("dry_run") == "true" {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+			return
+		}
+
+		preview, err := s.importer.Preview(c.Request.Context(), data, opts)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, screener.ErrInvalidCSV) {
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, preview)
 		return
 	}
 
-	// Process stocks
-	created := 0
-	updated := 0
-	for _, parsed := range stocks {
-		stock, err := s.repo.GetOrCreateStock(c.Request.Context(), parsed.Symbol, parsed.Name, "NSE")
+	if c.Query("async") == "true" || header.Size > asyncUploadThreshold {
+		data, err := io.ReadAll(file)
 		if err != nil {
-			continue
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+			return
 		}
 
-		parsed.Fundamental.StockID = stock.ID
-		if err := s.repo.CreateFundamental(c.Request.Context(), parsed.Fundamental); err == nil {
-			created++
-		} else {
-			updated++
+		upload, err := s.importer.ImportAsync(c.Request.Context(), header.Filename, data, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":      "Import started",
+			"upload_id":    upload.ID,
+			"progress_url": fmt.Sprintf("/api/v1/screener/uploads/%d/progress", upload.ID),
+		})
+		return
 	}
 
-	upload.Status = "completed"
-	upload.RecordsCount = len(stocks)
-	s.repo.UpdateScreenerUpload(c.Request.Context(), upload)
+	result, err := s.importer.Import(c.Request.Context(), header.Filename, file, opts)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, screener.ErrInvalidCSV) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
 
+	upload := result.Upload
 	c.JSON(http.StatusOK, gin.H{
-		"message":         "CSV processed successfully",
-		"total_records":   len(stocks),
-		"created":         created,
-		"updated":         updated,
-		"upload_id":       upload.ID,
+		"message":       "File processed",
+		"total_records": upload.RecordsCount,
+		"created":       upload.CreatedCount,
+		"skipped":       upload.SkippedCount,
+		"failed":        upload.ErrorCount,
+		"errors":        result.Errors(20),
+		"upload_id":     upload.ID,
 	})
 }
 
@@ -170,6 +209,69 @@ func (s *Server) handleGetSupportedColumns(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"columns": columns})
 }
 
+// handleListColumnMappings lists the custom screener column mappings and
+// the fields columns can be mapped to.
+func (s *Server) handleListColumnMappings(c *gin.Context) {
+	mappings, err := s.repo.ListScreenerColumnMappings(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mappings": mappings,
+		"fields":   screener.MappableFields(),
+	})
+}
+
+// ColumnMappingRequest maps an export column to a field.
+type ColumnMappingRequest struct {
+	Column string `json:"column" binding:"required"`
+	Field  string `json:"field" binding:"required"`
+}
+
+// handleSaveColumnMapping creates or replaces a custom column mapping.
+func (s *Server) handleSaveColumnMapping(c *gin.Context) {
+	var req ColumnMappingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	mapping, err := s.importer.SaveColumnMapping(c.Request.Context(), req.Column, req.Field)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, screener.ErrInvalidMapping) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, mapping)
+}
+
+// handleDeleteColumnMapping deletes a custom column mapping.
+func (s *Server) handleDeleteColumnMapping(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mapping ID"})
+		return
+	}
+
+	deleted, err := s.repo.DeleteScreenerColumnMapping(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "mapping not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Mapping deleted"})
+}
+
 // handleListNews handles listing news.
 func (s *Server) handleListNews(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
@@ -192,6 +294,80 @@ func (s *Server) handleListNews(c *gin.Context) {
 	})
 }
 
+// handleSearchNews handles full-text news search.
+func (s *Server) handleSearchNews(c *gin.Context) {
+	params, err := parseNewsSearchParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := s.repo.SearchNews(c.Request.Context(), params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"count":   len(results),
+		"query":   params.Query,
+		"limit":   params.Limit,
+		"offset":  params.Offset,
+	})
+}
+
+// parseNewsSearchParams parses news search filters from the query string.
+func parseNewsSearchParams(c *gin.Context) (storage.NewsSearchParams, error) {
+	params := storage.NewsSearchParams{
+		Query:     strings.TrimSpace(c.Query("q")),
+		Symbol:    strings.TrimSpace(c.Query("symbol")),
+		Sentiment: storage.SentimentScore(strings.ToUpper(c.Query("sentiment"))),
+	}
+
+	switch params.Sentiment {
+	case "", storage.SentimentBullish, storage.SentimentBearish, storage.SentimentNeutral:
+	default:
+		return params, fmt.Errorf("sentiment must be one of BULLISH, BEARISH, NEUTRAL")
+	}
+
+	var err error
+	if params.From, err = parseDateParam(c.Query("from"), false); err != nil {
+		return params, fmt.Errorf("invalid from date: %w", err)
+	}
+	if params.To, err = parseDateParam(c.Query("to"), true); err != nil {
+		return params, fmt.Errorf("invalid to date: %w", err)
+	}
+
+	params.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	params.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if params.Limit <= 0 || params.Limit > 200 {
+		params.Limit = 200
+	}
+
+	return params, nil
+}
+
+// parseDateParam parses a YYYY-MM-DD (in IST) or RFC3339 date. A plain date
+// used as an upper bound covers that whole day.
+func parseDateParam(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, calendar.IST)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("use YYYY-MM-DD or RFC3339")
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
 // handleRefreshNews handles refreshing news from RSS feeds.
 func (s *Server) handleRefreshNews(c *gin.Context) {
 	count, err := s.engine.RefreshNews(c.Request.Context())
@@ -241,6 +417,11 @@ type DailyPicksRequest struct {
 	Sectors         []string `json:"sectors"`
 	MinROE          float64  `json:"min_roe"`
 	MaxDebtToEquity float64  `json:"max_debt_to_equity"`
+	MinSalesGrowth  float64  `json:"min_sales_growth"`
+	MinProfitGrowth float64  `json:"min_profit_growth"`
+
+	Attributes []recommender.AttributeFilter `json:"attributes"`
+	Watchlists []string                      `json:"watchlists"`
 }
 
 // handleGenerateDailyPicks handles generating daily stock picks.
@@ -254,7 +435,8 @@ func (s *Server) handleGenerateDailyPicks(c *gin.Context) {
 	var filter *recommender.DailyPicksFilter
 	if req.MinPrice > 0 || req.MaxPrice > 0 || req.MinMarketCap > 0 || req.MaxMarketCap > 0 ||
 		req.MinPE > 0 || req.MaxPE > 0 || req.MinConfidence > 0 || len(req.RiskLevels) > 0 ||
-		len(req.TimeHorizons) > 0 || len(req.Sectors) > 0 || req.MinROE > 0 || req.MaxDebtToEquity > 0 {
+		len(req.TimeHorizons) > 0 || len(req.Sectors) > 0 || req.MinROE > 0 || req.MaxDebtToEquity > 0 ||
+		req.MinSalesGrowth > 0 || req.MinProfitGrowth > 0 || len(req.Attributes) > 0 || len(req.Watchlists) > 0 {
 		filter = &recommender.DailyPicksFilter{
 			MinPrice:        req.MinPrice,
 			MaxPrice:        req.MaxPrice,
@@ -268,11 +450,19 @@ func (s *Server) handleGenerateDailyPicks(c *gin.Context) {
 			Sectors:         req.Sectors,
 			MinROE:          req.MinROE,
 			MaxDebtToEquity: req.MaxDebtToEquity,
+			MinSalesGrowth:  req.MinSalesGrowth,
+			MinProfitGrowth: req.MinProfitGrowth,
+			Attributes:      req.Attributes,
+			Watchlists:      req.Watchlists,
 		}
 	}
 
 	result, err := s.engine.GenerateDailyPicksWithFilter(c.Request.Context(), filter)
 	if err != nil {
+		if errors.Is(err, recommender.ErrWatchlistNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -302,6 +492,13 @@ func (s *Server) handleGetDailyPicks(c *gin.Context) {
 // handleGetDailyPicksFilters returns available filter options.
 func (s *Server) handleGetDailyPicksFilters(c *gin.Context) {
 	filters := s.engine.GetAvailableFilters()
+
+	attributes, err := s.repo.ListFundamentalAttributeKeys(c.Request.Context())
+	if err != nil {
+		fmt.Printf("Warning: failed to list attribute keys: %v\n", err)
+	}
+	filters["attributes"] = attributes
+
 	c.JSON(http.StatusOK, filters)
 }
 
@@ -411,6 +608,28 @@ func parseFiltersFromQuery(c *gin.Context) *recommender.DailyPicksFilter {
 			hasFilter = true
 		}
 	}
+	if v := c.Query("min_sales_growth"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			filter.MinSalesGrowth = f
+			hasFilter = true
+		}
+	}
+	if v := c.Query("min_profit_growth"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			filter.MinProfitGrowth = f
+			hasFilter = true
+		}
+	}
+	for _, v := range c.QueryArray("attr") {
+		if attr, ok := parseAttributeFilter(v); ok {
+			filter.Attributes = append(filter.Attributes, attr)
+			hasFilter = true
+		}
+	}
+	if v := c.QueryArray("watchlist"); len(v) > 0 {
+		filter.Watchlists = v
+		hasFilter = true
+	}
 
 	if !hasFilter {
 		return nil
@@ -418,6 +637,30 @@ func parseFiltersFromQuery(c *gin.Context) *recommender.DailyPicksFilter {
 	return filter
 }
 
+// parseAttributeFilter parses an attribute filter written as key:min:max,
+// where either bound may be left empty, e.g. "piotroskiscore:7:".
+func parseAttributeFilter(v string) (recommender.AttributeFilter, bool) {
+	parts := strings.Split(v, ":")
+	if len(parts) != 3 || strings.TrimSpace(parts[0]) == "" {
+		return recommender.AttributeFilter{}, false
+	}
+
+	attr := recommender.AttributeFilter{Key: strings.TrimSpace(parts[0])}
+	bounds := []**float64{&attr.Min, &attr.Max}
+	for i, bound := range bounds {
+		s := strings.TrimSpace(parts[i+1])
+		if s == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return recommender.AttributeFilter{}, false
+		}
+		*bound = &f
+	}
+	return attr, true
+}
+
 // handleGetStock handles getting a single stock.
 func (s *Server) handleGetStock(c *gin.Context) {
 	symbol := c.Param("symbol")
@@ -442,11 +685,16 @@ func (s *Server) handleGetStock(c *gin.Context) {
 	// Get recent news
 	news, _ := s.repo.ListNewsByStockID(c.Request.Context(), stock.ID, 10)
 
+	// Get recent quarterly shareholding
+	shareholding, _ := s.repo.ListShareholdingSnapshots(c.Request.Context(), stock.ID, 8)
+
 	c.JSON(http.StatusOK, gin.H{
-		"stock":          stock,
-		"fundamental":    fundamental,
-		"recommendation": recommendation,
-		"news":           news,
+		"stock":                stock,
+		"fundamental":          fundamental,
+		"recommendation":       recommendation,
+		"news":                 news,
+		"shareholding":         shareholding,
+		"shareholding_changes": recommender.ShareholdingChanges(shareholding),
 	})
 }
 
@@ -459,6 +707,7 @@ func (s *Server) handleDashboard(c *gin.Context) {
 	c.HTML(http.StatusOK, "dashboard.html", gin.H{
 		"title":           "Stock Recommender",
 		"recommendations": recommendations,
+		"sellTax":         s.sellTaxImpacts(c.Request.Context(), recommendations),
 	})
 }
 
@@ -482,16 +731,50 @@ func (s *Server) handleRecommendationDetail(c *gin.Context) {
 	// Get related news
 	news, _ := s.repo.ListNewsByStockID(c.Request.Context(), recommendation.StockID, 10)
 
+	// Age of the fundamentals when the recommendation was made
+	dataAge := ""
+	if recommendation.DataAsOf != nil {
+		dataAge = recommender.FormatAge(recommendation.CreatedAt.Sub(*recommendation.DataAsOf))
+	}
+
 	c.HTML(http.StatusOK, "recommendation.html", gin.H{
 		"title":          recommendation.Stock.Name + " - Recommendation",
 		"recommendation": recommendation,
 		"fundamental":    fundamental,
+		"dataAge":        dataAge,
 		"news":           news,
 	})
 }
 
-// handleNewsPage renders the news page.
+// handleNewsPage renders the news page, or search results when a query is given.
 func (s *Server) handleNewsPage(c *gin.Context) {
+	params, err := parseNewsSearchParams(c)
+	searching := err == nil && (params.Query != "" || params.Symbol != "" || params.Sentiment != "" ||
+		!params.From.IsZero() || !params.To.IsZero())
+
+	if searching {
+		params.Limit = 100
+		results, err := s.repo.SearchNews(c.Request.Context(), params)
+		if err != nil {
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Search failed: " + err.Error()})
+			return
+		}
+
+		c.HTML(http.StatusOK, "news.html", gin.H{
+			"title":     "Market News - Search",
+			"news":      results,
+			"searching": true,
+			"search": gin.H{
+				"q":         c.Query("q"),
+				"symbol":    c.Query("symbol"),
+				"sentiment": params.Sentiment,
+				"from":      c.Query("from"),
+				"to":        c.Query("to"),
+			},
+		})
+		return
+	}
+
 	since := time.Now().Add(-48 * time.Hour)
 	news, _ := s.engine.GetRecentNews(c.Request.Context(), 100, since)
 
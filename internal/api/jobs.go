@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/stock-recommender/internal/scheduler"
+)
+
+// handleListJobs lists scheduled jobs with their next run and recent history.
+func (s *Server) handleListJobs(c *gin.Context) {
+	limit := queryLimit(c, 50, 200)
+
+	runs, err := s.repo.ListJobRuns(c.Request.Context(), "", limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if s.scheduler == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"enabled":     false,
+			"jobs":        []interface{}{},
+			"recent_runs": runs,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":     true,
+		"timezone":    s.scheduler.Location().String(),
+		"jobs":        s.scheduler.Jobs(),
+		"recent_runs": runs,
+	})
+}
+
+// handleListJobRuns lists the run history of a single job.
+func (s *Server) handleListJobRuns(c *gin.Context) {
+	limit := queryLimit(c, 20, 200)
+
+	runs, err := s.repo.ListJobRuns(c.Request.Context(), c.Param("name"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job":   c.Param("name"),
+		"runs":  runs,
+		"count": len(runs),
+	})
+}
+
+// handleRunJob triggers a job immediately, or answers 409 if it is already
+// running.
+func (s *Server) handleRunJob(c *gin.Context) {
+	if s.scheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "scheduler is disabled"})
+		return
+	}
+
+	name := c.Param("name")
+	if !s.scheduler.HasJob(name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+
+	if err := s.scheduler.RunNow(name); errors.Is(err, scheduler.ErrJobRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Job triggered",
+		"job":     name,
+	})
+}
+
+// queryLimit reads the limit query parameter, using defaultLimit when it is
+// missing, not a number or not positive, and capping it at maxLimit.
+func queryLimit(c *gin.Context, defaultLimit, maxLimit int) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		return defaultLimit
+	}
+	return min(limit, maxLimit)
+}
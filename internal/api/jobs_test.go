@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestQueryLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 20},
+		{"?limit=5", 5},
+		{"?limit=200", 200},
+		{"?limit=500", 200},
+		{"?limit=0", 20},
+		{"?limit=-3", 20},
+		{"?limit=ten", 20},
+		{"?limit=", 20},
+	}
+
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/api/v1/jobs"+tt.query, nil)
+		if got := queryLimit(c, 20, 200); got != tt.want {
+			t.Errorf("queryLimit(%q) = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/stock-recommender/internal/market"
+)
+
+// handleMarketStatus returns whether the market is open and the next session times.
+func (s *Server) handleMarketStatus(c *gin.Context) {
+	cal := s.engine.Calendar()
+	status := cal.Status(time.Now())
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   status,
+		"sessions": cal.Sessions(),
+		"timezone": cal.Location().String(),
+	})
+}
+
+// handleMarketHolidays lists exchange holidays for a year.
+func (s *Server) handleMarketHolidays(c *gin.Context) {
+	cal := s.engine.Calendar()
+
+	year := time.Now().In(cal.Location()).Year()
+	if v := c.Query("year"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+			return
+		}
+		year = parsed
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"year":     year,
+		"loaded":   cal.HasYear(year),
+		"holidays": cal.Holidays(year),
+	})
+}
+
+// handleMarketConditions returns the latest condition of each tracked index
+// and the summary passed to the LLM.
+func (s *Server) handleMarketConditions(c *gin.Context) {
+	conditions, err := s.engine.Market().Latest(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conditions": conditions,
+		"summary":    market.Describe(conditions, s.engine.Calendar().Location()),
+	})
+}
+
+// handleImportMarketConditions imports an NSE/BSE index, India VIX or
+// FII/DII download (CSV or JSON).
+func (s *Server) handleImportMarketConditions(c *gin.Context) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	defer file.Close()
+
+	conditions, err := s.engine.Market().Import(c.Request.Context(), header.Filename, file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Market data imported",
+		"conditions": conditions,
+		"summary":    market.Describe(conditions, s.engine.Calendar().Location()),
+	})
+}
+
+// handleRefreshMarketConditions ingests market data from the configured sources.
+func (s *Server) handleRefreshMarketConditions(c *gin.Context) {
+	conditions, err := s.engine.Market().Ingest(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Market conditions refreshed",
+		"conditions": conditions,
+		"summary":    market.Describe(conditions, s.engine.Calendar().Location()),
+	})
+}
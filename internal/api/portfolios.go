@@ -0,0 +1,476 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/stock-recommender/internal/portfolio"
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// handleImportPortfolio creates a portfolio from a broker holdings or
+// tradebook export. ?name= names the portfolio, ?broker= chooses the format
+// instead of detecting it and ?sheet= the worksheet of a workbook.
+func (s *Server) handleImportPortfolio(c *gin.Context) {
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return
+	}
+
+	opts := portfolio.ImportOptions{
+		Name:   c.DefaultPostForm("name", c.Query("name")),
+		Broker: c.DefaultPostForm("broker", c.Query("broker")),
+		Sheet:  c.DefaultPostForm("sheet", c.Query("sheet")),
+	}
+	result, err := s.portfolios.Import(c.Request.Context(), header.Filename, data, opts)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, portfolio.ErrInvalidExport) || errors.Is(err, portfolio.ErrUnknownBroker) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// PortfolioRequest creates an empty portfolio.
+type PortfolioRequest struct {
+	Name string  `json:"name" binding:"required"`
+	Cash float64 `json:"cash"` // opening cash, recorded as a deposit
+}
+
+// handleCreatePortfolio creates an empty portfolio to record transactions in.
+func (s *Server) handleCreatePortfolio(c *gin.Context) {
+	var req PortfolioRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	p, err := s.tracker.Create(c.Request.Context(), req.Name, req.Cash)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, portfolio.ErrInvalidTransaction) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, p)
+}
+
+// handleListPortfolios lists portfolios.
+func (s *Server) handleListPortfolios(c *gin.Context) {
+	portfolios, err := s.repo.ListPortfolios(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"portfolios": portfolios,
+		"count":      len(portfolios),
+		"brokers":    portfolio.BrokerFormats(),
+	})
+}
+
+// loadPortfolio loads the portfolio named by the :id parameter, responding
+// with an error and returning nil if there is none.
+func (s *Server) loadPortfolio(c *gin.Context) *storage.Portfolio {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid portfolio ID"})
+		return nil
+	}
+
+	p, err := s.repo.GetPortfolio(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil
+	}
+	if p == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "portfolio not found"})
+		return nil
+	}
+	return p
+}
+
+// handleGetPortfolio returns a portfolio with its lots.
+func (s *Server) handleGetPortfolio(c *gin.Context) {
+	p := s.loadPortfolio(c)
+	if p == nil {
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// handleDeletePortfolio deletes a portfolio, its lots and transactions.
+// Stocks created by its import are kept.
+func (s *Server) handleDeletePortfolio(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid portfolio ID"})
+		return
+	}
+
+	deleted, err := s.repo.DeletePortfolio(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "portfolio not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Portfolio deleted"})
+}
+
+// handlePortfolioSummary values a portfolio's holdings at their latest
+// stored prices with its P&L, sector allocation, XIRR and the holdings whose
+// latest recommendation conflicts with holding them.
+func (s *Server) handlePortfolioSummary(c *gin.Context) {
+	p := s.loadPortfolio(c)
+	if p == nil {
+		return
+	}
+
+	summary, err := s.tracker.Summary(c.Request.Context(), p)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// handleListPortfolioTransactions lists a portfolio's transactions in date
+// order.
+func (s *Server) handleListPortfolioTransactions(c *gin.Context) {
+	p := s.loadPortfolio(c)
+	if p == nil {
+		return
+	}
+
+	transactions, err := s.repo.ListPortfolioTransactions(c.Request.Context(), p.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transactions": transactions,
+		"count":        len(transactions),
+	})
+}
+
+// TransactionRequest records a portfolio transaction. Buys and sells need a
+// symbol, quantity and price; dividends, deposits and withdrawals an amount.
+type TransactionRequest struct {
+	Type     string  `json:"type" binding:"required"`
+	Symbol   string  `json:"symbol"`
+	Quantity float64 `json:"quantity"`
+	Price    float64 `json:"price"`
+	Fees     float64 `json:"fees"`
+	Amount   float64 `json:"amount"`
+	Date     string  `json:"date"` // YYYY-MM-DD, today if empty
+	Notes    string  `json:"notes"`
+}
+
+// handleAddPortfolioTransaction records a transaction and returns it with
+// the portfolio's updated cash.
+func (s *Server) handleAddPortfolioTransaction(c *gin.Context) {
+	p := s.loadPortfolio(c)
+	if p == nil {
+		return
+	}
+
+	var req TransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := s.tracker.AddTransaction(c.Request.Context(), p, portfolio.TransactionInput{
+		Type:     req.Type,
+		Symbol:   req.Symbol,
+		Quantity: req.Quantity,
+		Price:    req.Price,
+		Fees:     req.Fees,
+		Amount:   req.Amount,
+		Date:     req.Date,
+		Notes:    req.Notes,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, portfolio.ErrInvalidTransaction) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"transaction": tx,
+		"cash":        p.Cash,
+	})
+}
+
+// handleDeletePortfolioTransaction removes a transaction, e.g. one entered
+// by mistake, and works out the portfolio's lots and cash again.
+func (s *Server) handleDeletePortfolioTransaction(c *gin.Context) {
+	p := s.loadPortfolio(c)
+	if p == nil {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("txid"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction ID"})
+		return
+	}
+
+	deleted, err := s.tracker.DeleteTransaction(c.Request.Context(), p, uint(id))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, portfolio.ErrInvalidTransaction) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Transaction deleted",
+		"cash":    p.Cash,
+	})
+}
+
+// handlePortfolioTax reports the capital gains of a portfolio's sales in a
+// financial year with the tax on them. ?fy= chooses the year, e.g. 2024-25,
+// and defaults to the current one; ?format=csv downloads it as CSV.
+func (s *Server) handlePortfolioTax(c *gin.Context) {
+	p := s.loadPortfolio(c)
+	if p == nil {
+		return
+	}
+
+	fy := portfolio.FinancialYear(time.Now())
+	if v := c.Query("fy"); v != "" {
+		var err error
+		fy, err = portfolio.ParseFinancialYear(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	report, err := s.tracker.TaxReport(c.Request.Context(), p, fy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		filename := fmt.Sprintf("capital-gains-%d-fy%s.csv", p.ID, report.FinancialYear)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Header("Content-Type", "text/csv")
+		c.Status(http.StatusOK)
+		if err := portfolio.WriteTaxReportCSV(c.Writer, report); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// handlePortfolioSellTax works out the tax of selling a holding today.
+// ?symbol= names the stock, ?quantity= the shares to sell (all of them if
+// omitted) and ?price= the price, which defaults to the latest stored one.
+func (s *Server) handlePortfolioSellTax(c *gin.Context) {
+	p := s.loadPortfolio(c)
+	if p == nil {
+		return
+	}
+
+	stock, err := s.repo.GetStockBySymbol(c.Request.Context(), strings.ToUpper(c.Query("symbol")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if stock == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "stock not found"})
+		return
+	}
+
+	quantity, _ := strconv.ParseFloat(c.Query("quantity"), 64)
+	price, _ := strconv.ParseFloat(c.Query("price"), 64)
+	if price <= 0 {
+		fundamental, err := s.repo.GetLatestFundamental(c.Request.Context(), stock.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if fundamental != nil {
+			price = fundamental.CurrentPrice
+		}
+	}
+
+	impact, err := s.tracker.SellTaxImpact(c.Request.Context(), p, stock.ID, quantity, price)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, portfolio.ErrInvalidTransaction) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, impact)
+}
+
+// sellTaxImpacts works out, for each SELL among recs, the tax of selling the
+// stock in every portfolio that holds it, at its latest stored price or the
+// recommendation's entry price. Failures are logged and left out.
+func (s *Server) sellTaxImpacts(ctx context.Context, recs []storage.Recommendation) map[uint][]*portfolio.SellTaxImpact {
+	impacts := make(map[uint][]*portfolio.SellTaxImpact)
+	for _, rec := range recs {
+		if rec.Action != storage.ActionSell {
+			continue
+		}
+		portfolios, err := s.repo.ListPortfoliosHoldingStock(ctx, rec.StockID)
+		if err != nil {
+			fmt.Printf("Warning: failed to find portfolios holding %s: %v\n", rec.Stock.Symbol, err)
+			continue
+		}
+		if len(portfolios) == 0 {
+			continue
+		}
+
+		price := rec.EntryPrice
+		if fundamental, err := s.repo.GetLatestFundamental(ctx, rec.StockID); err == nil && fundamental != nil && fundamental.CurrentPrice > 0 {
+			price = fundamental.CurrentPrice
+		}
+		for i := range portfolios {
+			impact, err := s.tracker.SellTaxImpact(ctx, &portfolios[i], rec.StockID, 0, price)
+			if err != nil {
+				fmt.Printf("Warning: failed to work out tax of selling %s: %v\n", rec.Stock.Symbol, err)
+				continue
+			}
+			impacts[rec.ID] = append(impacts[rec.ID], impact)
+		}
+	}
+	return impacts
+}
+
+// handlePortfoliosPage renders the list of portfolios.
+func (s *Server) handlePortfoliosPage(c *gin.Context) {
+	portfolios, err := s.repo.ListPortfolios(c.Request.Context())
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to load portfolios: " + err.Error()})
+		return
+	}
+
+	c.HTML(http.StatusOK, "portfolios.html", gin.H{
+		"title":      "Portfolios",
+		"portfolios": portfolios,
+		"brokers":    portfolio.BrokerFormats(),
+	})
+}
+
+// handlePortfolioPage renders a portfolio's holdings, P&L and transactions.
+func (s *Server) handlePortfolioPage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{"error": "Invalid portfolio ID"})
+		return
+	}
+
+	p, err := s.repo.GetPortfolio(c.Request.Context(), uint(id))
+	if err != nil || p == nil {
+		c.HTML(http.StatusNotFound, "error.html", gin.H{"error": "Portfolio not found"})
+		return
+	}
+
+	summary, err := s.tracker.Summary(c.Request.Context(), p)
+	if err != nil {
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{"error": "Failed to value portfolio: " + err.Error()})
+		return
+	}
+	transactions, _ := s.repo.ListPortfolioTransactions(c.Request.Context(), p.ID)
+	tax, _ := s.tracker.TaxReport(c.Request.Context(), p, portfolio.FinancialYear(time.Now()))
+
+	var xirr float64
+	if summary.XIRR != nil {
+		xirr = *summary.XIRR
+	}
+
+	c.HTML(http.StatusOK, "portfolio.html", gin.H{
+		"title":        p.Name + " - Portfolio",
+		"summary":      summary,
+		"hasXIRR":      summary.XIRR != nil,
+		"xirr":         xirr,
+		"tax":          tax,
+		"transactions": transactions,
+	})
+}
+
+// handleAnalyzePortfolio runs the analysis of /analyze for every stock held
+// in a portfolio, one after the other, and returns the recommendations.
+func (s *Server) handleAnalyzePortfolio(c *gin.Context) {
+	p := s.loadPortfolio(c)
+	if p == nil {
+		return
+	}
+
+	var results []gin.H
+	analyzed := make(map[string]bool)
+	failed := 0
+	for _, lot := range p.Lots {
+		if analyzed[lot.Symbol] {
+			continue
+		}
+		analyzed[lot.Symbol] = true
+		if c.Request.Context().Err() != nil {
+			return
+		}
+
+		result, err := s.engine.AnalyzeStock(c.Request.Context(), lot.Symbol)
+		if err != nil {
+			failed++
+			results = append(results, gin.H{"symbol": lot.Symbol, "error": err.Error()})
+			continue
+		}
+		results = append(results, gin.H{"symbol": lot.Symbol, "recommendation": result.Recommendation})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"portfolio_id": p.ID,
+		"results":      results,
+		"analyzed":     len(results) - failed,
+		"failed":       failed,
+	})
+}
@@ -2,32 +2,54 @@
 package api
 
 import (
+	"html/template"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/user/stock-recommender/internal/alerts"
+	"github.com/user/stock-recommender/internal/portfolio"
 	"github.com/user/stock-recommender/internal/recommender"
+	"github.com/user/stock-recommender/internal/scheduler"
 	"github.com/user/stock-recommender/internal/screener"
+	"github.com/user/stock-recommender/internal/slack"
 	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/internal/webhooks"
 	"github.com/user/stock-recommender/pkg/config"
 )
 
 // Server represents the API server.
 type Server struct {
-	router    *gin.Engine
-	engine    *recommender.Engine
-	repo      *storage.Repository
-	csvParser *screener.CSVParser
-	config    *config.Config
+	router     *gin.Engine
+	engine     *recommender.Engine
+	repo       *storage.Repository
+	csvParser  *screener.CSVParser
+	importer   *screener.Importer
+	portfolios *portfolio.Importer
+	tracker    *portfolio.Tracker
+	alerts     *alerts.Service
+	webhooks   *webhooks.Dispatcher
+	slack      *slack.Handler
+	scheduler  *scheduler.Scheduler
+	config     *config.Config
 }
 
-// NewServer creates a new API server.
-func NewServer(engine *recommender.Engine, repo *storage.Repository, cfg *config.Config) *Server {
+// NewServer creates a new API server. The scheduler may be nil when
+// background jobs are disabled.
+func NewServer(engine *recommender.Engine, repo *storage.Repository, sched *scheduler.Scheduler, alerter *alerts.Service, hooks *webhooks.Dispatcher, cfg *config.Config) *Server {
+	csvParser := screener.NewCSVParser()
 	s := &Server{
-		engine:    engine,
-		repo:      repo,
-		csvParser: screener.NewCSVParser(),
-		config:    cfg,
+		engine:     engine,
+		repo:       repo,
+		csvParser:  csvParser,
+		importer:   screener.NewImporter(repo, csvParser),
+		portfolios: portfolio.NewImporter(repo),
+		tracker:    portfolio.NewTracker(repo),
+		alerts:     alerter,
+		webhooks:   hooks,
+		slack:      slack.NewHandler(engine, cfg),
+		scheduler:  sched,
+		config:     cfg,
 	}
 
 	s.setupRouter()
@@ -59,6 +81,8 @@ func (s *Server) setupRouter() {
 	r.GET("/recommendation/:id", s.handleRecommendationDetail)
 	r.GET("/news", s.handleNewsPage)
 	r.GET("/upload", s.handleUploadPage)
+	r.GET("/portfolios", s.handlePortfoliosPage)
+	r.GET("/portfolios/:id", s.handlePortfolioPage)
 
 	// API v1 routes
 	api := r.Group("/api/v1")
@@ -82,19 +106,104 @@ func (s *Server) setupRouter() {
 		// Screener CSV upload
 		api.POST("/screener/upload", s.handleScreenerUpload)
 		api.GET("/screener/columns", s.handleGetSupportedColumns)
+		api.GET("/screener/mappings", s.handleListColumnMappings)
+		api.PUT("/screener/mappings", s.handleSaveColumnMapping)
+		api.DELETE("/screener/mappings/:id", s.handleDeleteColumnMapping)
+		api.GET("/screener/uploads", s.handleListScreenerUploads)
+		api.GET("/screener/uploads/:id", s.handleGetScreenerUpload)
+		api.GET("/screener/uploads/:id/rows", s.handleListScreenerUploadRows)
+		api.GET("/screener/uploads/:id/progress", s.handleScreenerUploadProgress)
+		api.POST("/screener/uploads/:id/rollback", s.handleRollbackScreenerUpload)
 
 		// News
 		api.GET("/news", s.handleListNews)
+		api.GET("/news/search", s.handleSearchNews)
 		api.POST("/news/refresh", s.handleRefreshNews)
 
 		// Stocks
 		api.GET("/stocks", s.handleListStocks)
 		api.GET("/stocks/:symbol", s.handleGetStock)
+		api.GET("/stocks/:symbol/financials", s.handleStockFinancials)
+		api.GET("/stocks/:symbol/shareholding", s.handleStockShareholding)
+		api.GET("/stocks/:symbol/peers", s.handleStockPeers)
+		api.PUT("/stocks/:symbol/basis", s.handleSetStockBasis)
+		api.PUT("/stocks/:symbol/fmv-2018", s.handleSetStockFMV)
+
+		// Portfolios
+		api.POST("/portfolios", s.handleCreatePortfolio)
+		api.POST("/portfolios/import", s.handleImportPortfolio)
+		api.GET("/portfolios", s.handleListPortfolios)
+		api.GET("/portfolios/:id", s.handleGetPortfolio)
+		api.DELETE("/portfolios/:id", s.handleDeletePortfolio)
+		api.GET("/portfolios/:id/summary", s.handlePortfolioSummary)
+		api.GET("/portfolios/:id/transactions", s.handleListPortfolioTransactions)
+		api.POST("/portfolios/:id/transactions", s.handleAddPortfolioTransaction)
+		api.DELETE("/portfolios/:id/transactions/:txid", s.handleDeletePortfolioTransaction)
+		api.GET("/portfolios/:id/tax", s.handlePortfolioTax)
+		api.GET("/portfolios/:id/tax/sell", s.handlePortfolioSellTax)
+		api.POST("/portfolios/:id/analyze", s.handleAnalyzePortfolio)
+
+		// Watchlists
+		api.GET("/watchlists", s.handleListWatchlists)
+		api.POST("/watchlists", s.handleCreateWatchlist)
+		api.GET("/watchlists/:id", s.handleGetWatchlist)
+		api.PUT("/watchlists/:id", s.handleUpdateWatchlist)
+		api.DELETE("/watchlists/:id", s.handleDeleteWatchlist)
+		api.POST("/watchlists/:id/stocks", s.handleAddWatchlistStocks)
+		api.DELETE("/watchlists/:id/stocks/:symbol", s.handleRemoveWatchlistStock)
+		api.POST("/watchlists/:id/analyze", s.handleAnalyzeWatchlist)
+
+		// Alerts
+		api.GET("/alerts/rules", s.handleListAlertRules)
+		api.POST("/alerts/rules", s.handleCreateAlertRule)
+		api.GET("/alerts/rules/:id", s.handleGetAlertRule)
+		api.PUT("/alerts/rules/:id", s.handleUpdateAlertRule)
+		api.DELETE("/alerts/rules/:id", s.handleDeleteAlertRule)
+		api.POST("/alerts/rules/:id/test", s.handleTestAlertRule)
+		api.GET("/alerts/deliveries", s.handleListAlertDeliveries)
+		api.POST("/alerts/deliveries/:id/retry", s.handleRetryAlertDelivery)
+
+		// Outbound webhooks
+		api.GET("/webhooks", s.handleListWebhooks)
+		api.POST("/webhooks", s.handleCreateWebhook)
+		api.GET("/webhooks/events", s.handleListWebhookEvents)
+		api.GET("/webhooks/:id", s.handleGetWebhook)
+		api.PUT("/webhooks/:id", s.handleUpdateWebhook)
+		api.DELETE("/webhooks/:id", s.handleDeleteWebhook)
+		api.POST("/webhooks/:id/test", s.handleTestWebhook)
+		api.GET("/webhooks/:id/deliveries", s.handleListWebhookDeliveries)
+		api.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", s.handleRedeliverWebhook)
+
+		// Slack slash command and interactivity request URLs
+		api.POST("/slack/commands", s.handleSlackCommand)
+		api.POST("/slack/interactions", s.handleSlackInteraction)
+
+		// Market calendar and conditions
+		api.GET("/market/status", s.handleMarketStatus)
+		api.GET("/market/holidays", s.handleMarketHolidays)
+		api.GET("/market/conditions", s.handleMarketConditions)
+		api.POST("/market/conditions/import", s.handleImportMarketConditions)
+		api.POST("/market/conditions/refresh", s.handleRefreshMarketConditions)
+
+		// Background jobs
+		api.GET("/jobs", s.handleListJobs)
+		api.GET("/jobs/:name/runs", s.handleListJobRuns)
+		api.POST("/jobs/:name/run", s.handleRunJob)
 	}
 
 	s.router = r
 }
 
+// Importer returns the screener CSV importer.
+func (s *Server) Importer() *screener.Importer {
+	return s.importer
+}
+
+// Slack returns the Slack command handler.
+func (s *Server) Slack() *slack.Handler {
+	return s.slack
+}
+
 // Router returns the Gin router.
 func (s *Server) Router() *gin.Engine {
 	return s.router
@@ -157,6 +266,11 @@ func templateFuncs() map[string]interface{} {
 		"lte": func(a, b interface{}) bool {
 			return toFloat(a) <= toFloat(b)
 		},
+		// highlight renders search fragments that storage.HighlightHTML has
+		// already escaped, keeping only its <mark> elements as markup.
+		"highlight": func(s string) template.HTML {
+			return template.HTML(s)
+		},
 	}
 }
 
@@ -183,4 +297,3 @@ func toFloat(v interface{}) float64 {
 		return 0
 	}
 }
-
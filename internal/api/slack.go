@@ -0,0 +1,59 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/stock-recommender/internal/slack"
+)
+
+// readSlackRequest reads and verifies a signed Slack request, returning its
+// form values. It responds with an error and returns nil if Slack is not
+// configured or the request is not signed with the signing secret.
+func (s *Server) readSlackRequest(c *gin.Context) url.Values {
+	form, err := s.slack.ReadRequest(c.Request)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, slack.ErrNotConfigured):
+			status = http.StatusNotFound
+		case errors.Is(err, slack.ErrInvalidSignature):
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return nil
+	}
+	return form
+}
+
+// handleSlackCommand answers a /stockchef slash command. Analyses that
+// take longer than slack.ack_timeout are acknowledged now and posted to
+// the command's response_url when they finish.
+func (s *Server) handleSlackCommand(c *gin.Context) {
+	form := s.readSlackRequest(c)
+	if form == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, s.slack.Command(slack.ParseSlashCommand(form)))
+}
+
+// handleSlackInteraction handles a click on a button of a Slack message.
+// The click is acknowledged at once; any reply goes to its response_url.
+func (s *Server) handleSlackInteraction(c *gin.Context) {
+	form := s.readSlackRequest(c)
+	if form == nil {
+		return
+	}
+
+	interaction, err := slack.ParseInteraction(form)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	s.slack.Interaction(interaction)
+
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/stock-recommender/internal/recommender"
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// handleStockFinancials lists a stock's scraped financial statements, latest
+// period first. Filter with ?type=profit_loss, quarterly_results,
+// balance_sheet or cash_flow, and ?basis=consolidated or standalone.
+func (s *Server) handleStockFinancials(c *gin.Context) {
+	statementType := c.Query("type")
+	switch statementType {
+	case "", storage.StatementQuarterly, storage.StatementProfitLoss,
+		storage.StatementBalanceSheet, storage.StatementCashFlow:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be quarterly_results, profit_loss, balance_sheet or cash_flow"})
+		return
+	}
+
+	basis := c.Query("basis")
+	switch basis {
+	case "", storage.BasisConsolidated, storage.BasisStandalone:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "basis must be consolidated or standalone"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit > 500 {
+		limit = 500
+	}
+
+	stock, err := s.repo.GetStockBySymbol(c.Request.Context(), c.Param("symbol"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if stock == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "stock not found"})
+		return
+	}
+
+	statements, err := s.repo.ListFinancialStatements(c.Request.Context(), stock.ID, statementType, basis, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stock":      stock,
+		"statements": statements,
+		"count":      len(statements),
+	})
+}
+
+// handleStockShareholding returns a stock's quarterly shareholding pattern,
+// latest quarter first, with notable changes.
+func (s *Server) handleStockShareholding(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "12"))
+	if limit > 100 {
+		limit = 100
+	}
+
+	stock, err := s.repo.GetStockBySymbol(c.Request.Context(), c.Param("symbol"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if stock == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "stock not found"})
+		return
+	}
+
+	snapshots, err := s.repo.ListShareholdingSnapshots(c.Request.Context(), stock.ID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stock":        stock,
+		"shareholding": snapshots,
+		"changes":      recommender.ShareholdingChanges(snapshots),
+		"count":        len(snapshots),
+	})
+}
+
+// handleSetStockBasis sets whether a stock's financials are fetched on a
+// consolidated or standalone basis and refreshes its fundamentals. An empty
+// basis reverts to the configured default.
+func (s *Server) handleSetStockBasis(c *gin.Context) {
+	var req struct {
+		Basis string `json:"basis"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	switch req.Basis {
+	case "", storage.BasisConsolidated, storage.BasisStandalone:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "basis must be consolidated, standalone or empty"})
+		return
+	}
+
+	stock, err := s.repo.GetStockBySymbol(c.Request.Context(), c.Param("symbol"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if stock == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "stock not found"})
+		return
+	}
+
+	fundamental, err := s.engine.SetFinancialBasis(c.Request.Context(), stock, req.Basis)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stock":       stock,
+		"fundamental": fundamental,
+	})
+}
+
+// handleSetStockFMV sets a stock's highest price on 31 Jan 2018, the fair
+// market value long-term gains on shares bought before then are
+// grandfathered at. A price of 0 clears it.
+func (s *Server) handleSetStockFMV(c *gin.Context) {
+	var req struct {
+		Price float64 `json:"price"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Price < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "price cannot be negative"})
+		return
+	}
+
+	stock, err := s.repo.GetStockBySymbol(c.Request.Context(), c.Param("symbol"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if stock == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "stock not found"})
+		return
+	}
+
+	stock.FMV2018 = req.Price
+	if err := s.repo.UpdateStock(c.Request.Context(), stock); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stock)
+}
+
+// handleStockPeers ranks a stock's P/E, P/B, ROE and ROCE against the peer
+// group of its industry.
+func (s *Server) handleStockPeers(c *gin.Context) {
+	stock, err := s.repo.GetStockBySymbol(c.Request.Context(), c.Param("symbol"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if stock == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "stock not found"})
+		return
+	}
+
+	fundamental, err := s.repo.GetLatestFundamental(c.Request.Context(), stock.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	comparison, err := s.engine.ComparePeers(c.Request.Context(), stock, fundamental)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if comparison == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no peer data for this stock's industry"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stock":    stock,
+		"industry": comparison.Industry,
+		"peers":    comparison.Peers,
+		"metrics":  comparison.Metrics,
+		"summary":  comparison.Summary(),
+		"count":    len(comparison.Peers),
+	})
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/stock-recommender/internal/screener"
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// handleListScreenerUploads lists screener CSV uploads, newest first.
+func (s *Server) handleListScreenerUploads(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit > 200 {
+		limit = 200
+	}
+
+	uploads, err := s.repo.ListScreenerUploads(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"uploads": uploads,
+		"count":   len(uploads),
+	})
+}
+
+// handleGetScreenerUpload returns a single upload with its row counts.
+func (s *Server) handleGetScreenerUpload(c *gin.Context) {
+	upload, ok := s.loadScreenerUpload(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, upload)
+}
+
+// handleListScreenerUploadRows lists the row results of an upload. Filter
+// with ?status=error to see only the rows that failed.
+func (s *Server) handleListScreenerUploadRows(c *gin.Context) {
+	upload, ok := s.loadScreenerUpload(c)
+	if !ok {
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", storage.UploadRowCreated, storage.UploadRowSkipped, storage.UploadRowError:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be created, skipped or error"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit > 1000 {
+		limit = 1000
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	rows, err := s.repo.ListScreenerUploadRows(c.Request.Context(), upload.ID, status, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"upload": upload,
+		"rows":   rows,
+		"count":  len(rows),
+	})
+}
+
+// handleScreenerUploadProgress reports the progress of an import. Imports
+// that finished before the server started report their stored totals.
+func (s *Server) handleScreenerUploadProgress(c *gin.Context) {
+	upload, ok := s.loadScreenerUpload(c)
+	if !ok {
+		return
+	}
+
+	if progress, ok := s.importer.Progress(upload.ID); ok {
+		c.JSON(http.StatusOK, gin.H{"progress": progress, "upload": upload})
+		return
+	}
+
+	progress := screener.ImportProgress{
+		UploadID:  upload.ID,
+		Stage:     upload.Status,
+		Processed: upload.CreatedCount,
+		Total:     upload.CreatedCount,
+		Error:     upload.ErrorMessage,
+		StartedAt: upload.CreatedAt,
+		UpdatedAt: upload.UpdatedAt,
+	}
+	switch upload.Status {
+	case storage.UploadStatusPending, storage.UploadStatusProcessing:
+		// The import's transaction did not survive a server restart.
+		progress.Stage = screener.StageFailed
+		progress.Error = "import was interrupted"
+	case storage.UploadStatusFailed:
+		progress.Stage = screener.StageFailed
+	case storage.UploadStatusCompleted, storage.UploadStatusRolledBack:
+		progress.Stage = screener.StageCompleted
+	}
+	c.JSON(http.StatusOK, gin.H{"progress": progress, "upload": upload})
+}
+
+// handleRollbackScreenerUpload removes all data created by a completed
+// upload.
+func (s *Server) handleRollbackScreenerUpload(c *gin.Context) {
+	upload, ok := s.loadScreenerUpload(c)
+	if !ok {
+		return
+	}
+
+	result, err := s.repo.RollbackScreenerUpload(c.Request.Context(), upload.ID)
+	if errors.Is(err, storage.ErrUploadRolledBack) || errors.Is(err, storage.ErrUploadNotCompleted) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Upload rolled back",
+		"upload_id": upload.ID,
+		"rollback":  result,
+	})
+}
+
+// loadScreenerUpload loads the upload named by the :id parameter, writing an
+// error response if it cannot.
+func (s *Server) loadScreenerUpload(c *gin.Context) (*storage.ScreenerUpload, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid upload ID"})
+		return nil, false
+	}
+
+	upload, err := s.repo.GetScreenerUpload(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if upload == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "upload not found"})
+		return nil, false
+	}
+
+	return upload, true
+}
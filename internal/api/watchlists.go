@@ -0,0 +1,324 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// symbolPattern matches an NSE or BSE trading symbol.
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9&\-.]{0,19}$`)
+
+// errInvalidSymbol is returned for a watchlist symbol that is not a trading
+// symbol.
+var errInvalidSymbol = errors.New("invalid symbol")
+
+// WatchlistRequest creates or updates a watchlist. Symbols are only read on
+// create; the stocks endpoints change the stocks of an existing watchlist.
+type WatchlistRequest struct {
+	Name        string   `json:"name"`
+	Description *string  `json:"description"`
+	AutoAnalyze *bool    `json:"auto_analyze"` // defaults to true
+	Symbols     []string `json:"symbols"`
+}
+
+// WatchlistStocksRequest adds stocks to a watchlist.
+type WatchlistStocksRequest struct {
+	Symbols []string `json:"symbols" binding:"required"`
+}
+
+// handleListWatchlists lists watchlists with their stocks.
+func (s *Server) handleListWatchlists(c *gin.Context) {
+	watchlists, err := s.repo.ListWatchlists(c.Request.Context(), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"watchlists": watchlists,
+		"count":      len(watchlists),
+	})
+}
+
+// handleCreateWatchlist creates a watchlist, adding any symbols given as
+// stocks if they are not tracked yet.
+func (s *Server) handleCreateWatchlist(c *gin.Context) {
+	var req WatchlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	existing, err := s.repo.GetWatchlistByName(c.Request.Context(), req.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "a watchlist with this name already exists"})
+		return
+	}
+
+	items, err := s.watchlistItems(c, req.Symbols)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errInvalidSymbol) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	watchlist := &storage.Watchlist{
+		Name:        req.Name,
+		AutoAnalyze: req.AutoAnalyze == nil || *req.AutoAnalyze,
+		Items:       items,
+	}
+	if req.Description != nil {
+		watchlist.Description = strings.TrimSpace(*req.Description)
+	}
+	if err := s.repo.CreateWatchlist(c.Request.Context(), watchlist); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, watchlist)
+}
+
+// loadWatchlist loads the watchlist named by the :id parameter, responding
+// with an error and returning nil if there is none.
+func (s *Server) loadWatchlist(c *gin.Context) *storage.Watchlist {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid watchlist ID"})
+		return nil
+	}
+
+	watchlist, err := s.repo.GetWatchlist(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil
+	}
+	if watchlist == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "watchlist not found"})
+		return nil
+	}
+	return watchlist
+}
+
+// handleGetWatchlist returns a watchlist with its stocks and the stocks
+// whose action changed in the latest analysis.
+func (s *Server) handleGetWatchlist(c *gin.Context) {
+	watchlist := s.loadWatchlist(c)
+	if watchlist == nil {
+		return
+	}
+
+	changed := []storage.WatchlistItem{}
+	for _, item := range watchlist.Items {
+		if item.ActionChanged {
+			changed = append(changed, item)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"watchlist": watchlist,
+		"changed":   changed,
+	})
+}
+
+// handleUpdateWatchlist renames a watchlist or changes its description or
+// whether it is re-analysed on schedule.
+func (s *Server) handleUpdateWatchlist(c *gin.Context) {
+	watchlist := s.loadWatchlist(c)
+	if watchlist == nil {
+		return
+	}
+
+	var req WatchlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if name := strings.TrimSpace(req.Name); name != "" && name != watchlist.Name {
+		existing, err := s.repo.GetWatchlistByName(c.Request.Context(), name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if existing != nil && existing.ID != watchlist.ID {
+			c.JSON(http.StatusConflict, gin.H{"error": "a watchlist with this name already exists"})
+			return
+		}
+		watchlist.Name = name
+	}
+	if req.Description != nil {
+		watchlist.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.AutoAnalyze != nil {
+		watchlist.AutoAnalyze = *req.AutoAnalyze
+	}
+
+	if err := s.repo.UpdateWatchlist(c.Request.Context(), watchlist); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, watchlist)
+}
+
+// handleDeleteWatchlist deletes a watchlist. Its stocks and their
+// recommendations are kept.
+func (s *Server) handleDeleteWatchlist(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid watchlist ID"})
+		return
+	}
+
+	deleted, err := s.repo.DeleteWatchlist(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "watchlist not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "watchlist deleted"})
+}
+
+// handleAddWatchlistStocks adds stocks to a watchlist. Stocks already on it
+// are skipped.
+func (s *Server) handleAddWatchlistStocks(c *gin.Context) {
+	watchlist := s.loadWatchlist(c)
+	if watchlist == nil {
+		return
+	}
+
+	var req WatchlistStocksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "symbols are required"})
+		return
+	}
+
+	items, err := s.watchlistItems(c, req.Symbols)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errInvalidSymbol) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range items {
+		items[i].WatchlistID = watchlist.ID
+	}
+
+	added, err := s.repo.AddWatchlistItems(c.Request.Context(), items)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	watchlist, err = s.repo.GetWatchlist(c.Request.Context(), watchlist.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"watchlist": watchlist,
+		"added":     added,
+	})
+}
+
+// handleRemoveWatchlistStock removes a stock from a watchlist.
+func (s *Server) handleRemoveWatchlistStock(c *gin.Context) {
+	watchlist := s.loadWatchlist(c)
+	if watchlist == nil {
+		return
+	}
+
+	symbol := strings.ToUpper(strings.TrimSpace(c.Param("symbol")))
+	for _, item := range watchlist.Items {
+		if item.Symbol != symbol {
+			continue
+		}
+		if _, err := s.repo.RemoveWatchlistItem(c.Request.Context(), watchlist.ID, item.StockID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "stock removed from watchlist"})
+		return
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"error": "stock is not on the watchlist"})
+}
+
+// handleAnalyzeWatchlist re-analyses every stock on a watchlist now, as the
+// watchlist_analysis job does, and returns the action changes.
+func (s *Server) handleAnalyzeWatchlist(c *gin.Context) {
+	watchlist := s.loadWatchlist(c)
+	if watchlist == nil {
+		return
+	}
+
+	result, err := s.engine.AnalyzeWatchlists(c.Request.Context(), []storage.Watchlist{*watchlist})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	watchlist, err = s.repo.GetWatchlist(c.Request.Context(), watchlist.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"watchlist": watchlist,
+		"result":    result,
+	})
+}
+
+// watchlistItems makes watchlist items for the given symbols, tracking any
+// stock not seen before. Duplicate symbols are dropped.
+func (s *Server) watchlistItems(c *gin.Context, symbols []string) ([]storage.WatchlistItem, error) {
+	var items []storage.WatchlistItem
+	seen := make(map[string]bool)
+	for _, raw := range symbols {
+		symbol := strings.ToUpper(strings.TrimSpace(raw))
+		if symbol == "" || seen[symbol] {
+			continue
+		}
+		if !symbolPattern.MatchString(symbol) {
+			return nil, fmt.Errorf("%w: %q", errInvalidSymbol, raw)
+		}
+		seen[symbol] = true
+
+		stock, err := s.repo.GetOrCreateStock(c.Request.Context(), symbol, symbol, "NSE")
+		if err != nil {
+			return nil, fmt.Errorf("failed to save stock %s: %w", symbol, err)
+		}
+		items = append(items, storage.WatchlistItem{
+			StockID: stock.ID,
+			Symbol:  stock.Symbol,
+		})
+	}
+	return items, nil
+}
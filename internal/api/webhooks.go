@@ -0,0 +1,276 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/internal/webhooks"
+)
+
+// WebhookRequest creates or updates a webhook subscription. On update,
+// fields left out keep their values.
+type WebhookRequest struct {
+	Name         *string  `json:"name"`
+	URL          *string  `json:"url"`
+	Events       []string `json:"events"` // event types, or ["*"] for all
+	Description  *string  `json:"description"`
+	Enabled      *bool    `json:"enabled"`       // defaults to true
+	Secret       *string  `json:"secret"`        // generated if left out on create
+	RotateSecret bool     `json:"rotate_secret"` // replace the secret with a generated one
+}
+
+// apply copies the fields set in the request onto a subscription.
+func (req *WebhookRequest) apply(sub *storage.WebhookSubscription) {
+	if req.Name != nil {
+		sub.Name = *req.Name
+	}
+	if req.URL != nil {
+		sub.URL = *req.URL
+	}
+	if req.Events != nil {
+		sub.Events = strings.Join(req.Events, ",")
+	}
+	if req.Description != nil {
+		sub.Description = *req.Description
+	}
+	if req.Enabled != nil {
+		sub.Enabled = *req.Enabled
+	}
+	if req.Secret != nil {
+		sub.Secret = *req.Secret
+	}
+	if req.RotateSecret {
+		sub.Secret = ""
+	}
+}
+
+// handleListWebhookEvents lists the event types a webhook can subscribe to.
+func (s *Server) handleListWebhookEvents(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"events": webhooks.EventDescriptions(),
+	})
+}
+
+// handleListWebhooks lists webhook subscriptions. Secrets are not
+// included.
+func (s *Server) handleListWebhooks(c *gin.Context) {
+	subs, err := s.repo.ListWebhookSubscriptions(c.Request.Context(), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks": subs,
+		"count":    len(subs),
+	})
+}
+
+// handleCreateWebhook creates a webhook subscription. The response is the
+// only time its secret is returned.
+func (s *Server) handleCreateWebhook(c *gin.Context) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub := &storage.WebhookSubscription{Enabled: true}
+	req.apply(sub)
+	if !s.saveWebhook(c, sub) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"webhook": sub,
+		"secret":  sub.Secret,
+	})
+}
+
+// loadWebhook loads the webhook subscription named by the :id parameter,
+// responding with an error and returning nil if there is none.
+func (s *Server) loadWebhook(c *gin.Context) *storage.WebhookSubscription {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook ID"})
+		return nil
+	}
+
+	sub, err := s.repo.GetWebhookSubscription(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil
+	}
+	if sub == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return nil
+	}
+	return sub
+}
+
+// handleGetWebhook returns a webhook subscription with its latest
+// deliveries.
+func (s *Server) handleGetWebhook(c *gin.Context) {
+	sub := s.loadWebhook(c)
+	if sub == nil {
+		return
+	}
+
+	deliveries, err := s.repo.ListWebhookDeliveries(c.Request.Context(), sub.ID, "", 20)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhook":    sub,
+		"deliveries": deliveries,
+	})
+}
+
+// handleUpdateWebhook updates a webhook subscription. The secret is
+// returned only if it changed.
+func (s *Server) handleUpdateWebhook(c *gin.Context) {
+	sub := s.loadWebhook(c)
+	if sub == nil {
+		return
+	}
+
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	secret := sub.Secret
+	req.apply(sub)
+	if !s.saveWebhook(c, sub) {
+		return
+	}
+
+	response := gin.H{"webhook": sub}
+	if sub.Secret != secret {
+		response["secret"] = sub.Secret
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// saveWebhook validates and saves a subscription, responding with an error
+// and returning false if it cannot be saved.
+func (s *Server) saveWebhook(c *gin.Context, sub *storage.WebhookSubscription) bool {
+	if err := s.webhooks.ValidateSubscription(sub); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, webhooks.ErrInvalidSubscription) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return false
+	}
+
+	save := s.repo.UpdateWebhookSubscription
+	if sub.ID == 0 {
+		save = s.repo.CreateWebhookSubscription
+	}
+	if err := save(c.Request.Context(), sub); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// handleDeleteWebhook deletes a webhook subscription and its deliveries.
+func (s *Server) handleDeleteWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook ID"})
+		return
+	}
+
+	deleted, err := s.repo.DeleteWebhookSubscription(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "webhook deleted"})
+}
+
+// handleTestWebhook sends a ping event to a webhook and returns the
+// delivery. A failed test is not retried.
+func (s *Server) handleTestWebhook(c *gin.Context) {
+	sub := s.loadWebhook(c)
+	if sub == nil {
+		return
+	}
+
+	delivery, err := s.webhooks.Test(c.Request.Context(), sub)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := http.StatusOK
+	if delivery.Status != storage.WebhookDeliveryDelivered {
+		status = http.StatusBadGateway
+	}
+	c.JSON(status, delivery)
+}
+
+// handleListWebhookDeliveries lists a webhook's deliveries, newest first.
+// ?status= (pending, delivered or failed) narrows the list.
+func (s *Server) handleListWebhookDeliveries(c *gin.Context) {
+	sub := s.loadWebhook(c)
+	if sub == nil {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	deliveries, err := s.repo.ListWebhookDeliveries(c.Request.Context(), sub.ID, c.Query("status"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	})
+}
+
+// handleRedeliverWebhook sends a delivery again now, restarting its
+// attempts.
+func (s *Server) handleRedeliverWebhook(c *gin.Context) {
+	sub := s.loadWebhook(c)
+	if sub == nil {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("delivery_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid delivery ID"})
+		return
+	}
+	delivery, err := s.repo.GetWebhookDelivery(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if delivery == nil || delivery.SubscriptionID != sub.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "delivery not found"})
+		return
+	}
+
+	if err := s.webhooks.Redeliver(c.Request.Context(), sub, delivery); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
@@ -0,0 +1,355 @@
+// Package calendar provides the NSE trading calendar: exchange holidays,
+// session timings and trading-day arithmetic in IST.
+package calendar
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Session names.
+const (
+	SessionPreOpen = "pre_open"
+	SessionNormal  = "normal"
+	SessionClosing = "closing"
+	SessionClosed  = "closed"
+)
+
+// SessionTime is a trading session window within a day, in exchange time.
+type SessionTime struct {
+	Name        string `json:"name"`
+	StartHour   int    `json:"start_hour"`
+	StartMinute int    `json:"start_minute"`
+	EndHour     int    `json:"end_hour"`
+	EndMinute   int    `json:"end_minute"`
+}
+
+// NSESessions are the NSE equity segment session timings.
+var NSESessions = []SessionTime{
+	{Name: SessionPreOpen, StartHour: 9, StartMinute: 0, EndHour: 9, EndMinute: 15},
+	{Name: SessionNormal, StartHour: 9, StartMinute: 15, EndHour: 15, EndMinute: 30},
+	{Name: SessionClosing, StartHour: 15, StartMinute: 40, EndHour: 16, EndMinute: 0},
+}
+
+// Holiday is an exchange trading holiday.
+type Holiday struct {
+	Date        string `json:"date"` // YYYY-MM-DD
+	Description string `json:"description"`
+}
+
+// HolidayFile is the on-disk format of a yearly holiday list.
+type HolidayFile struct {
+	Exchange string    `json:"exchange"`
+	Year     int       `json:"year"`
+	Source   string    `json:"source,omitempty"`
+	Holidays []Holiday `json:"holidays"`
+}
+
+// Calendar answers trading-day and market-hours questions for an exchange.
+type Calendar struct {
+	location *time.Location
+	sessions []SessionTime
+
+	mu       sync.RWMutex
+	holidays map[string]string // YYYY-MM-DD -> description
+	years    map[int]bool
+}
+
+// New creates an NSE calendar with no holidays loaded; only weekends are
+// treated as non-trading days until holidays are added.
+func New(location *time.Location) *Calendar {
+	if location == nil {
+		location = LoadLocation("")
+	}
+	return &Calendar{
+		location: location,
+		sessions: NSESessions,
+		holidays: make(map[string]string),
+		years:    make(map[int]bool),
+	}
+}
+
+// IST is Indian Standard Time as a fixed offset, which needs no tz database.
+// India has no daylight saving, so it matches Asia/Kolkata.
+var IST = time.FixedZone("IST", 5*60*60+30*60)
+
+// LoadLocation loads a timezone by name, falling back to IST when the tz
+// database is unavailable.
+func LoadLocation(name string) *time.Location {
+	if name == "" {
+		name = "Asia/Kolkata"
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		fmt.Printf("Warning: could not load timezone %s, using IST: %v\n", name, err)
+		return IST
+	}
+	return loc
+}
+
+// LoadDir creates a calendar and loads every *.json holiday file in dir.
+func LoadDir(dir string, location *time.Location) (*Calendar, error) {
+	c := New(location)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list holiday files: %w", err)
+	}
+	if len(files) == 0 {
+		return c, fmt.Errorf("no holiday files found in %s", dir)
+	}
+
+	for _, path := range files {
+		if err := c.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// LoadFile loads a yearly holiday file into the calendar.
+func (c *Calendar) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read holiday file %s: %w", path, err)
+	}
+
+	var file HolidayFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse holiday file %s: %w", path, err)
+	}
+
+	return c.AddHolidays(file.Year, file.Holidays)
+}
+
+// AddHolidays adds holidays for a year.
+func (c *Calendar) AddHolidays(year int, holidays []Holiday) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, h := range holidays {
+		d, err := time.ParseInLocation("2006-01-02", h.Date, c.location)
+		if err != nil {
+			return fmt.Errorf("invalid holiday date %q: %w", h.Date, err)
+		}
+		if year != 0 && d.Year() != year {
+			return fmt.Errorf("holiday %s does not belong to year %d", h.Date, year)
+		}
+		c.holidays[h.Date] = h.Description
+	}
+	if year != 0 {
+		c.years[year] = true
+	}
+
+	return nil
+}
+
+// Location returns the exchange timezone.
+func (c *Calendar) Location() *time.Location {
+	return c.location
+}
+
+// Sessions returns the session timings.
+func (c *Calendar) Sessions() []SessionTime {
+	return c.sessions
+}
+
+// HasYear reports whether a holiday list has been loaded for the year.
+func (c *Calendar) HasYear(year int) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.years[year]
+}
+
+// Holiday returns the holiday description if t falls on an exchange holiday.
+func (c *Calendar) Holiday(t time.Time) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	desc, ok := c.holidays[t.In(c.location).Format("2006-01-02")]
+	return desc, ok
+}
+
+// Holidays returns the holidays of a year in date order.
+func (c *Calendar) Holidays(year int) []Holiday {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	prefix := fmt.Sprintf("%04d-", year)
+	var holidays []Holiday
+	for date, desc := range c.holidays {
+		if len(date) > 5 && date[:5] == prefix {
+			holidays = append(holidays, Holiday{Date: date, Description: desc})
+		}
+	}
+	sort.Slice(holidays, func(i, j int) bool {
+		return holidays[i].Date < holidays[j].Date
+	})
+	return holidays
+}
+
+// IsTradingDay reports whether the exchange trades on t's date in IST.
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	local := t.In(c.location)
+	if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+		return false
+	}
+	_, holiday := c.Holiday(local)
+	return !holiday
+}
+
+// NextTradingDay returns the start of the first trading day after t's date.
+func (c *Calendar) NextTradingDay(t time.Time) time.Time {
+	d := c.startOfDay(t).AddDate(0, 0, 1)
+	for !c.IsTradingDay(d) {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+// PreviousTradingDay returns the start of the last trading day before t's date.
+func (c *Calendar) PreviousTradingDay(t time.Time) time.Time {
+	d := c.startOfDay(t).AddDate(0, 0, -1)
+	for !c.IsTradingDay(d) {
+		d = d.AddDate(0, 0, -1)
+	}
+	return d
+}
+
+// TradingDaysBetween counts trading days after from's date up to and
+// including to's date. It is negative when to is before from.
+func (c *Calendar) TradingDaysBetween(from, to time.Time) int {
+	start, end := c.startOfDay(from), c.startOfDay(to)
+	sign := 1
+	if end.Before(start) {
+		start, end = end, start
+		sign = -1
+	}
+
+	count := 0
+	for d := start.AddDate(0, 0, 1); !d.After(end); d = d.AddDate(0, 0, 1) {
+		if c.IsTradingDay(d) {
+			count++
+		}
+	}
+	return sign * count
+}
+
+// AddTradingDays returns the start of the trading day n trading days after
+// t's date (or before it, for negative n).
+func (c *Calendar) AddTradingDays(t time.Time, n int) time.Time {
+	d := c.startOfDay(t)
+	for ; n > 0; n-- {
+		d = c.NextTradingDay(d)
+	}
+	for ; n < 0; n++ {
+		d = c.PreviousTradingDay(d)
+	}
+	return d
+}
+
+// MarketClose returns the end of the normal session on t's date.
+func (c *Calendar) MarketClose(t time.Time) time.Time {
+	return c.sessionBound(t, c.normalSession(), false)
+}
+
+// MarketOpen returns the start of the normal session on t's date.
+func (c *Calendar) MarketOpen(t time.Time) time.Time {
+	return c.sessionBound(t, c.normalSession(), true)
+}
+
+// Session returns the session in progress at t, or SessionClosed.
+func (c *Calendar) Session(t time.Time) string {
+	if !c.IsTradingDay(t) {
+		return SessionClosed
+	}
+	for _, s := range c.sessions {
+		start, end := c.sessionBound(t, s, true), c.sessionBound(t, s, false)
+		if !t.Before(start) && t.Before(end) {
+			return s.Name
+		}
+	}
+	return SessionClosed
+}
+
+// IsOpen reports whether the normal trading session is in progress at t.
+func (c *Calendar) IsOpen(t time.Time) bool {
+	return c.Session(t) == SessionNormal
+}
+
+// Status describes the market state at a point in time.
+type Status struct {
+	Exchange       string    `json:"exchange"`
+	Now            time.Time `json:"now"`
+	IsOpen         bool      `json:"is_open"`
+	Session        string    `json:"session"`
+	IsTradingDay   bool      `json:"is_trading_day"`
+	Holiday        string    `json:"holiday,omitempty"`
+	NextOpen       time.Time `json:"next_open"`
+	NextClose      time.Time `json:"next_close"`
+	HolidaysLoaded bool      `json:"holidays_loaded"` // false when no holiday list exists for this year
+}
+
+// Status returns the market status at t.
+func (c *Calendar) Status(t time.Time) Status {
+	local := t.In(c.location)
+	holiday, _ := c.Holiday(local)
+
+	status := Status{
+		Exchange:       "NSE",
+		Now:            local,
+		Session:        c.Session(local),
+		IsTradingDay:   c.IsTradingDay(local),
+		Holiday:        holiday,
+		HolidaysLoaded: c.HasYear(local.Year()),
+	}
+	status.IsOpen = status.Session == SessionNormal
+
+	openToday := c.MarketOpen(local)
+	closeToday := c.MarketClose(local)
+	switch {
+	case status.IsTradingDay && local.Before(openToday):
+		status.NextOpen = openToday
+		status.NextClose = closeToday
+	case status.IsTradingDay && local.Before(closeToday):
+		status.NextOpen = c.MarketOpen(c.NextTradingDay(local))
+		status.NextClose = closeToday
+	default:
+		next := c.NextTradingDay(local)
+		status.NextOpen = c.MarketOpen(next)
+		status.NextClose = c.MarketClose(next)
+	}
+
+	return status
+}
+
+// normalSession returns the normal trading session.
+func (c *Calendar) normalSession() SessionTime {
+	for _, s := range c.sessions {
+		if s.Name == SessionNormal {
+			return s
+		}
+	}
+	return c.sessions[0]
+}
+
+// sessionBound returns the start or end of a session on t's date.
+func (c *Calendar) sessionBound(t time.Time, s SessionTime, start bool) time.Time {
+	local := t.In(c.location)
+	hour, minute := s.EndHour, s.EndMinute
+	if start {
+		hour, minute = s.StartHour, s.StartMinute
+	}
+	return time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, c.location)
+}
+
+// startOfDay returns midnight of t's date in exchange time.
+func (c *Calendar) startOfDay(t time.Time) time.Time {
+	local := t.In(c.location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.location)
+}
@@ -0,0 +1,240 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestCalendar returns a calendar with the holiday files shipped in
+// configs/holidays.
+func newTestCalendar(t *testing.T) *Calendar {
+	t.Helper()
+	c, err := LoadDir("../../configs/holidays", IST)
+	if err != nil {
+		t.Fatalf("LoadDir: %v", err)
+	}
+	return c
+}
+
+// ist returns a time in IST.
+func ist(year int, month time.Month, day, hour, minute, sec int) time.Time {
+	return time.Date(year, month, day, hour, minute, sec, 0, IST)
+}
+
+func TestHolidayFiles(t *testing.T) {
+	c := newTestCalendar(t)
+
+	tests := []struct {
+		year      int
+		wantCount int
+	}{
+		{2025, 14},
+		{2026, 15},
+	}
+	for _, tt := range tests {
+		if !c.HasYear(tt.year) {
+			t.Errorf("no holidays loaded for %d", tt.year)
+		}
+		holidays := c.Holidays(tt.year)
+		if len(holidays) != tt.wantCount {
+			t.Errorf("%d has %d holidays, want %d", tt.year, len(holidays), tt.wantCount)
+		}
+		for i, h := range holidays {
+			d, _ := time.ParseInLocation("2006-01-02", h.Date, IST)
+			if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+				t.Errorf("holiday %s (%s) falls on a %s", h.Date, h.Description, d.Weekday())
+			}
+			if i > 0 && holidays[i-1].Date >= h.Date {
+				t.Errorf("holidays out of order: %s before %s", holidays[i-1].Date, h.Date)
+			}
+		}
+	}
+	if c.HasYear(2027) {
+		t.Error("holidays loaded for 2027")
+	}
+
+	if desc, ok := c.Holiday(ist(2026, time.October, 20, 12, 0, 0)); !ok || desc != "Dussehra" {
+		t.Errorf("Holiday(2026-10-20) = %q, %v, want Dussehra", desc, ok)
+	}
+}
+
+func TestIsTradingDay(t *testing.T) {
+	c := newTestCalendar(t)
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		{"monday", ist(2026, time.October, 19, 12, 0, 0), true},
+		{"saturday", ist(2026, time.October, 17, 12, 0, 0), false},
+		{"sunday", ist(2026, time.October, 18, 12, 0, 0), false},
+		{"dussehra 2026", ist(2026, time.October, 20, 12, 0, 0), false},
+		{"diwali laxmi pujan 2025", ist(2025, time.October, 21, 12, 0, 0), false},
+		{"diwali balipratipada 2025", ist(2025, time.October, 22, 12, 0, 0), false},
+		{"christmas 2025", ist(2025, time.December, 25, 12, 0, 0), false},
+		{"republic day 2026", ist(2026, time.January, 26, 12, 0, 0), false},
+		{"day after a holiday", ist(2025, time.December, 26, 12, 0, 0), true},
+		// 19:00 UTC on Monday is already Dussehra in IST.
+		{"holiday in IST, not in UTC", time.Date(2026, time.October, 19, 19, 0, 0, 0, time.UTC), false},
+		// 20:00 UTC on Sunday is Monday in IST.
+		{"weekday in IST, not in UTC", time.Date(2026, time.October, 18, 20, 0, 0, 0, time.UTC), true},
+	}
+
+	for _, tt := range tests {
+		if got := c.IsTradingDay(tt.t); got != tt.want {
+			t.Errorf("IsTradingDay(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	c := newTestCalendar(t)
+
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{ist(2026, time.October, 19, 8, 59, 59), SessionClosed},
+		{ist(2026, time.October, 19, 9, 0, 0), SessionPreOpen},
+		{ist(2026, time.October, 19, 9, 14, 59), SessionPreOpen},
+		{ist(2026, time.October, 19, 9, 15, 0), SessionNormal},
+		{ist(2026, time.October, 19, 15, 29, 59), SessionNormal},
+		{ist(2026, time.October, 19, 15, 30, 0), SessionClosed},
+		{ist(2026, time.October, 19, 15, 39, 59), SessionClosed},
+		{ist(2026, time.October, 19, 15, 40, 0), SessionClosing},
+		{ist(2026, time.October, 19, 15, 59, 59), SessionClosing},
+		{ist(2026, time.October, 19, 16, 0, 0), SessionClosed},
+		// 03:45 UTC is 09:15 IST.
+		{time.Date(2026, time.October, 19, 3, 45, 0, 0, time.UTC), SessionNormal},
+		{time.Date(2026, time.October, 19, 3, 44, 59, 0, time.UTC), SessionPreOpen},
+		// Weekends and holidays have no sessions.
+		{ist(2026, time.October, 17, 10, 0, 0), SessionClosed},
+		{ist(2026, time.October, 20, 10, 0, 0), SessionClosed},
+	}
+
+	for _, tt := range tests {
+		got := c.Session(tt.t)
+		if got != tt.want {
+			t.Errorf("Session(%s) = %s, want %s", tt.t.In(IST).Format(time.DateTime), got, tt.want)
+		}
+		if open := c.IsOpen(tt.t); open != (tt.want == SessionNormal) {
+			t.Errorf("IsOpen(%s) = %v", tt.t.In(IST).Format(time.DateTime), open)
+		}
+	}
+}
+
+func TestAddTradingDays(t *testing.T) {
+	c := newTestCalendar(t)
+
+	tests := []struct {
+		name string
+		from time.Time
+		n    int
+		want time.Time
+	}{
+		{"same day", ist(2026, time.October, 19, 14, 0, 0), 0, ist(2026, time.October, 19, 0, 0, 0)},
+		{"over a weekend", ist(2026, time.October, 16, 14, 0, 0), 1, ist(2026, time.October, 19, 0, 0, 0)},
+		{"over a holiday", ist(2026, time.October, 19, 14, 0, 0), 1, ist(2026, time.October, 21, 0, 0, 0)},
+		{"back over a holiday", ist(2026, time.October, 21, 14, 0, 0), -1, ist(2026, time.October, 19, 0, 0, 0)},
+		{"over two holidays", ist(2025, time.October, 20, 14, 0, 0), 1, ist(2025, time.October, 23, 0, 0, 0)},
+		{"a week over diwali", ist(2025, time.October, 17, 14, 0, 0), 5, ist(2025, time.October, 28, 0, 0, 0)},
+		{"from a saturday", ist(2026, time.October, 17, 14, 0, 0), 1, ist(2026, time.October, 19, 0, 0, 0)},
+		{"into the new year", ist(2025, time.December, 31, 14, 0, 0), 1, ist(2026, time.January, 1, 0, 0, 0)},
+	}
+
+	for _, tt := range tests {
+		got := c.AddTradingDays(tt.from, tt.n)
+		if !got.Equal(tt.want) {
+			t.Errorf("AddTradingDays(%s) = %s, want %s", tt.name, got.Format(time.DateTime), tt.want.Format(time.DateTime))
+		}
+		if tt.n > 0 {
+			if days := c.TradingDaysBetween(tt.from, got); days != tt.n {
+				t.Errorf("TradingDaysBetween(%s) = %d, want %d", tt.name, days, tt.n)
+			}
+		}
+	}
+}
+
+func TestMarketOpenClose(t *testing.T) {
+	c := newTestCalendar(t)
+
+	tests := []struct {
+		name      string
+		t         time.Time
+		wantOpen  time.Time
+		wantClose time.Time
+	}{
+		{
+			name:      "during the session",
+			t:         ist(2026, time.October, 19, 11, 0, 0),
+			wantOpen:  ist(2026, time.October, 19, 9, 15, 0),
+			wantClose: ist(2026, time.October, 19, 15, 30, 0),
+		},
+		{
+			name:      "after the close",
+			t:         ist(2026, time.October, 19, 23, 59, 0),
+			wantOpen:  ist(2026, time.October, 19, 9, 15, 0),
+			wantClose: ist(2026, time.October, 19, 15, 30, 0),
+		},
+		{
+			// 20:00 UTC on the 19th is the 20th in IST.
+			name:      "date taken in IST",
+			t:         time.Date(2026, time.October, 19, 20, 0, 0, 0, time.UTC),
+			wantOpen:  ist(2026, time.October, 20, 9, 15, 0),
+			wantClose: ist(2026, time.October, 20, 15, 30, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		if got := c.MarketOpen(tt.t); !got.Equal(tt.wantOpen) {
+			t.Errorf("MarketOpen(%s) = %s, want %s", tt.name, got, tt.wantOpen)
+		}
+		if got := c.MarketClose(tt.t); !got.Equal(tt.wantClose) {
+			t.Errorf("MarketClose(%s) = %s, want %s", tt.name, got, tt.wantClose)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	c := newTestCalendar(t)
+
+	tests := []struct {
+		name          string
+		t             time.Time
+		wantOpen      bool
+		wantNextOpen  time.Time
+		wantNextClose time.Time
+	}{
+		{
+			name:          "before the open",
+			t:             ist(2026, time.October, 19, 9, 5, 0),
+			wantNextOpen:  ist(2026, time.October, 19, 9, 15, 0),
+			wantNextClose: ist(2026, time.October, 19, 15, 30, 0),
+		},
+		{
+			name:          "open, before a holiday",
+			t:             ist(2026, time.October, 19, 10, 0, 0),
+			wantOpen:      true,
+			wantNextOpen:  ist(2026, time.October, 21, 9, 15, 0),
+			wantNextClose: ist(2026, time.October, 19, 15, 30, 0),
+		},
+		{
+			name:          "on a holiday",
+			t:             ist(2026, time.October, 20, 10, 0, 0),
+			wantNextOpen:  ist(2026, time.October, 21, 9, 15, 0),
+			wantNextClose: ist(2026, time.October, 21, 15, 30, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		s := c.Status(tt.t)
+		if s.IsOpen != tt.wantOpen || !s.NextOpen.Equal(tt.wantNextOpen) || !s.NextClose.Equal(tt.wantNextClose) {
+			t.Errorf("Status(%s) = open %v, next open %s, next close %s, want %v, %s, %s", tt.name,
+				s.IsOpen, s.NextOpen, s.NextClose, tt.wantOpen, tt.wantNextOpen, tt.wantNextClose)
+		}
+		if !s.HolidaysLoaded {
+			t.Errorf("Status(%s) reports no holidays loaded", tt.name)
+		}
+	}
+}
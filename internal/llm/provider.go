@@ -16,6 +16,8 @@ type AnalysisRequest struct {
 	Fundamentals   map[string]float64 `json:"fundamentals"`
 	NewsHeadlines  []string          `json:"news_headlines"`
 	MarketSentiment string           `json:"market_sentiment"`
+	FinancialBasis string            `json:"financial_basis,omitempty"` // consolidated or standalone
+	PeerComparison []string          `json:"peer_comparison,omitempty"` // valuation relative to industry peers
 }
 
 // AnalysisResponse represents the LLM's analysis response.
@@ -91,6 +93,16 @@ Fundamentals:
 	for key, value := range req.Fundamentals {
 		prompt += fmt.Sprintf("- %s: %.2f\n", key, value)
 	}
+	if req.FinancialBasis != "" {
+		prompt += fmt.Sprintf("(Financial figures are on a %s basis)\n", req.FinancialBasis)
+	}
+
+	if len(req.PeerComparison) > 0 {
+		prompt += "\nRelative Valuation (vs industry peers):\n"
+		for _, line := range req.PeerComparison {
+			prompt += fmt.Sprintf("- %s\n", line)
+		}
+	}
 
 	if len(req.NewsHeadlines) > 0 {
 		prompt += "\nRecent News Headlines:\n"
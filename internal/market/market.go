@@ -0,0 +1,442 @@
+// Package market ingests overall market conditions: index levels, India VIX,
+// market breadth and FII/DII flows.
+package market
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// Index names stored in MarketCondition.IndexName.
+const (
+	IndexNifty50   = "NIFTY50"
+	IndexSensex    = "SENSEX"
+	IndexBankNifty = "BANKNIFTY"
+	IndexIndiaVIX  = "INDIAVIX"
+)
+
+// TrackedIndices are the indices recorded as market conditions, the first
+// being the benchmark used for the overall market sentiment.
+var TrackedIndices = []string{IndexNifty50, IndexSensex, IndexBankNifty}
+
+// IndexQuote is the level of an index.
+type IndexQuote struct {
+	Name          string
+	Value         float64
+	Change        float64
+	ChangePercent float64
+	Advances      int
+	Declines      int
+	AsOf          time.Time
+}
+
+// Snapshot is market data gathered from one or more sources. Sections that
+// were not present in a source are left zero.
+type Snapshot struct {
+	Indices  map[string]IndexQuote
+	VIX      float64
+	Advances int
+	Declines int
+	FIINet   float64 // net buy/sell in crores
+	DIINet   float64 // net buy/sell in crores
+	HasFlows bool
+	AsOf     time.Time
+
+	vixAsOf time.Time
+}
+
+// NewSnapshot creates an empty snapshot.
+func NewSnapshot() *Snapshot {
+	return &Snapshot{Indices: make(map[string]IndexQuote)}
+}
+
+// Empty reports whether the snapshot holds no market data.
+func (s *Snapshot) Empty() bool {
+	return len(s.Indices) == 0 && s.VIX == 0 && s.Advances == 0 && s.Declines == 0 && !s.HasFlows
+}
+
+// Merge fills the snapshot with data from other. Index quotes from other
+// replace older quotes for the same index.
+func (s *Snapshot) Merge(other *Snapshot) {
+	if other == nil {
+		return
+	}
+	for name, q := range other.Indices {
+		if existing, ok := s.Indices[name]; !ok || !q.AsOf.Before(existing.AsOf) {
+			s.Indices[name] = q
+		}
+	}
+	if other.VIX != 0 {
+		s.VIX = other.VIX
+	}
+	if other.Advances != 0 || other.Declines != 0 {
+		s.Advances, s.Declines = other.Advances, other.Declines
+	}
+	if other.HasFlows {
+		s.FIINet, s.DIINet, s.HasFlows = other.FIINet, other.DIINet, true
+	}
+	if other.AsOf.After(s.AsOf) {
+		s.AsOf = other.AsOf
+	}
+}
+
+// stamp dates undated data in the snapshot as of t.
+func (s *Snapshot) stamp(t time.Time) {
+	if s.AsOf.IsZero() {
+		s.AsOf = t
+	}
+	for name, q := range s.Indices {
+		if q.AsOf.IsZero() {
+			q.AsOf = s.AsOf
+			s.Indices[name] = q
+		}
+	}
+}
+
+// Fetcher retrieves market data from a source. Implementations for live
+// sources can be added alongside the file-based DirFetcher.
+type Fetcher interface {
+	// Name returns the source name.
+	Name() string
+
+	// Fetch returns the latest market data available from the source.
+	Fetch(ctx context.Context) (*Snapshot, error)
+}
+
+// DirFetcher reads NSE/BSE downloads (CSV or JSON) placed in a directory.
+// Files are applied oldest first, so newer downloads take precedence.
+type DirFetcher struct {
+	dir      string
+	location *time.Location
+}
+
+// NewDirFetcher creates a fetcher for a directory of downloaded files.
+func NewDirFetcher(dir string, location *time.Location) *DirFetcher {
+	return &DirFetcher{dir: dir, location: location}
+}
+
+// Name returns the source name.
+func (f *DirFetcher) Name() string {
+	return "files:" + f.dir
+}
+
+// Fetch parses every CSV and JSON file in the directory.
+func (f *DirFetcher) Fetch(ctx context.Context) (*Snapshot, error) {
+	var files []string
+	for _, pattern := range []string{"*.csv", "*.json"} {
+		matches, err := filepath.Glob(filepath.Join(f.dir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list market data files: %w", err)
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no market data files found in %s", f.dir)
+	}
+
+	type dataFile struct {
+		path    string
+		modTime time.Time
+	}
+	var dataFiles []dataFile
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		dataFiles = append(dataFiles, dataFile{path: path, modTime: info.ModTime()})
+	}
+	sort.Slice(dataFiles, func(i, j int) bool {
+		return dataFiles[i].modTime.Before(dataFiles[j].modTime)
+	})
+
+	snapshot := NewSnapshot()
+	for _, df := range dataFiles {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		file, err := os.Open(df.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", df.path, err)
+		}
+		parsed, err := ParseFile(filepath.Base(df.path), file, f.location)
+		file.Close()
+		if err != nil {
+			fmt.Printf("Warning: skipping market data file %s: %v\n", df.path, err)
+			continue
+		}
+
+		// Undated data is as of the time the file was downloaded.
+		parsed.stamp(df.modTime)
+		snapshot.Merge(parsed)
+	}
+
+	return snapshot, nil
+}
+
+// Service ingests market conditions from fetchers and uploaded files.
+type Service struct {
+	repo     *storage.Repository
+	location *time.Location
+	fetchers []Fetcher
+}
+
+// NewService creates a market condition service.
+func NewService(repo *storage.Repository, location *time.Location, fetchers ...Fetcher) *Service {
+	return &Service{
+		repo:     repo,
+		location: location,
+		fetchers: fetchers,
+	}
+}
+
+// AddFetcher adds a market data source.
+func (s *Service) AddFetcher(f Fetcher) {
+	s.fetchers = append(s.fetchers, f)
+}
+
+// Ingest fetches data from every source and stores the resulting market
+// conditions. A failing source is skipped.
+func (s *Service) Ingest(ctx context.Context) ([]storage.MarketCondition, error) {
+	if len(s.fetchers) == 0 {
+		return nil, fmt.Errorf("no market data sources configured")
+	}
+
+	snapshot := NewSnapshot()
+	for _, f := range s.fetchers {
+		data, err := f.Fetch(ctx)
+		if err != nil {
+			fmt.Printf("Warning: failed to fetch market data from %s: %v\n", f.Name(), err)
+			continue
+		}
+		snapshot.Merge(data)
+	}
+
+	return s.Save(ctx, snapshot)
+}
+
+// Import parses an uploaded NSE/BSE file and stores the market conditions it
+// contains. Flows and VIX in the file are combined with the latest stored
+// index levels when the file has no index data of its own.
+func (s *Service) Import(ctx context.Context, filename string, r io.Reader) ([]storage.MarketCondition, error) {
+	parsed, err := ParseFile(filename, r, s.location)
+	if err != nil {
+		return nil, err
+	}
+	parsed.stamp(time.Now())
+
+	if len(parsed.Indices) == 0 {
+		latest, err := s.Latest(ctx)
+		if err != nil {
+			return nil, err
+		}
+		base := FromConditions(latest)
+		base.Merge(parsed)
+		parsed = base
+	}
+
+	return s.Save(ctx, parsed)
+}
+
+// Save converts a snapshot into market conditions and stores them. A
+// condition already recorded for the same index and time is updated.
+func (s *Service) Save(ctx context.Context, snapshot *Snapshot) ([]storage.MarketCondition, error) {
+	conditions := Conditions(snapshot)
+	if len(conditions) == 0 {
+		return nil, fmt.Errorf("no tracked index data found (expected one of %s)", strings.Join(TrackedIndices, ", "))
+	}
+
+	for i := range conditions {
+		if err := s.repo.SaveMarketCondition(ctx, &conditions[i]); err != nil {
+			return nil, fmt.Errorf("failed to save market condition for %s: %w", conditions[i].IndexName, err)
+		}
+	}
+	return conditions, nil
+}
+
+// Latest returns the most recent condition of each tracked index, benchmark first.
+func (s *Service) Latest(ctx context.Context) ([]storage.MarketCondition, error) {
+	return s.repo.ListLatestMarketConditions(ctx, TrackedIndices)
+}
+
+// Conditions builds a market condition for each tracked index in the snapshot.
+func Conditions(snapshot *Snapshot) []storage.MarketCondition {
+	var conditions []storage.MarketCondition
+	for _, name := range TrackedIndices {
+		q, ok := snapshot.Indices[name]
+		if !ok || q.Value == 0 {
+			continue
+		}
+
+		advances, declines := q.Advances, q.Declines
+		if advances == 0 && declines == 0 {
+			advances, declines = snapshot.Advances, snapshot.Declines
+		}
+
+		recordedAt := q.AsOf
+		if recordedAt.IsZero() {
+			recordedAt = snapshot.AsOf
+		}
+		if recordedAt.IsZero() {
+			recordedAt = time.Now()
+		}
+		recordedAt = recordedAt.Truncate(time.Second)
+
+		mc := storage.MarketCondition{
+			IndexName:      name,
+			IndexValue:     q.Value,
+			Change:         q.Change,
+			ChangePercent:  q.ChangePercent,
+			VIX:            snapshot.VIX,
+			AdvanceDecline: advanceDeclineRatio(advances, declines),
+			FIIActivity:    snapshot.FIINet,
+			DIIActivity:    snapshot.DIINet,
+			RecordedAt:     recordedAt,
+		}
+		mc.Sentiment = Classify(&mc)
+		conditions = append(conditions, mc)
+	}
+	return conditions
+}
+
+// FromConditions rebuilds a snapshot from stored market conditions.
+func FromConditions(conditions []storage.MarketCondition) *Snapshot {
+	snapshot := NewSnapshot()
+	for _, mc := range conditions {
+		snapshot.Indices[mc.IndexName] = IndexQuote{
+			Name:          mc.IndexName,
+			Value:         mc.IndexValue,
+			Change:        mc.Change,
+			ChangePercent: mc.ChangePercent,
+			AsOf:          mc.RecordedAt,
+		}
+		if snapshot.VIX == 0 {
+			snapshot.VIX = mc.VIX
+		}
+		if !snapshot.HasFlows && (mc.FIIActivity != 0 || mc.DIIActivity != 0) {
+			snapshot.FIINet, snapshot.DIINet, snapshot.HasFlows = mc.FIIActivity, mc.DIIActivity, true
+		}
+	}
+	return snapshot
+}
+
+// Classify derives a sentiment from index movement, breadth, volatility and
+// institutional flows.
+func Classify(mc *storage.MarketCondition) storage.SentimentScore {
+	score := 0
+
+	switch {
+	case mc.ChangePercent >= 0.5:
+		score++
+	case mc.ChangePercent <= -0.5:
+		score--
+	}
+
+	switch {
+	case mc.AdvanceDecline >= 1.5:
+		score++
+	case mc.AdvanceDecline > 0 && mc.AdvanceDecline <= 0.67:
+		score--
+	}
+
+	switch {
+	case mc.VIX >= 20:
+		score--
+	case mc.VIX > 0 && mc.VIX <= 13:
+		score++
+	}
+
+	switch net := mc.FIIActivity + mc.DIIActivity; {
+	case net >= 1000:
+		score++
+	case net <= -1000:
+		score--
+	}
+
+	switch {
+	case score >= 2:
+		return storage.SentimentBullish
+	case score <= -2:
+		return storage.SentimentBearish
+	default:
+		return storage.SentimentNeutral
+	}
+}
+
+// Describe summarises market conditions for the LLM prompt, e.g.
+// "BULLISH (as of 17 Oct 2025: NIFTY50 25,100.50 (+0.85%), India VIX 11.80, ...)".
+// Dates are shown in the given location.
+func Describe(conditions []storage.MarketCondition, location *time.Location) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	benchmark := conditions[0]
+
+	var parts []string
+	for _, mc := range conditions {
+		parts = append(parts, fmt.Sprintf("%s %s (%+.2f%%)", mc.IndexName, formatIndian(mc.IndexValue), mc.ChangePercent))
+	}
+	if benchmark.VIX > 0 {
+		parts = append(parts, fmt.Sprintf("India VIX %.2f", benchmark.VIX))
+	}
+	if benchmark.AdvanceDecline > 0 {
+		parts = append(parts, fmt.Sprintf("advance/decline %.2f", benchmark.AdvanceDecline))
+	}
+	if benchmark.FIIActivity != 0 || benchmark.DIIActivity != 0 {
+		parts = append(parts, fmt.Sprintf("FII net %s Cr, DII net %s Cr",
+			formatSigned(benchmark.FIIActivity), formatSigned(benchmark.DIIActivity)))
+	}
+
+	return fmt.Sprintf("%s (as of %s: %s)", benchmark.Sentiment,
+		benchmark.RecordedAt.In(location).Format("02 Jan 2006"), strings.Join(parts, ", "))
+}
+
+// advanceDeclineRatio returns advances per decline, or 0 without breadth data.
+func advanceDeclineRatio(advances, declines int) float64 {
+	switch {
+	case declines > 0:
+		return float64(advances) / float64(declines)
+	case advances > 0:
+		return float64(advances)
+	default:
+		return 0
+	}
+}
+
+// formatSigned formats a number with an explicit sign and Indian digit grouping.
+func formatSigned(v float64) string {
+	if v < 0 {
+		return "-" + formatIndian(-v)
+	}
+	return "+" + formatIndian(v)
+}
+
+// formatIndian formats a non-negative number with two decimals and Indian
+// digit grouping (12,34,567.89).
+func formatIndian(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	intPart, frac := s[:len(s)-3], s[len(s)-3:]
+	if len(intPart) <= 3 {
+		return intPart + frac
+	}
+
+	head, tail := intPart[:len(intPart)-3], intPart[len(intPart)-3:]
+	var groups []string
+	for len(head) > 2 {
+		groups = append([]string{head[len(head)-2:]}, groups...)
+		head = head[:len(head)-2]
+	}
+	if head != "" {
+		groups = append([]string{head}, groups...)
+	}
+	return strings.Join(groups, ",") + "," + tail + frac
+}
@@ -0,0 +1,130 @@
+package market
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/stock-recommender/internal/calendar"
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		mc   storage.MarketCondition
+		want storage.SentimentScore
+	}{
+		{"no data", storage.MarketCondition{}, storage.SentimentNeutral},
+
+		// Each signal alone is not enough.
+		{"index up", storage.MarketCondition{ChangePercent: 0.5}, storage.SentimentNeutral},
+		{"broad advance", storage.MarketCondition{AdvanceDecline: 1.5}, storage.SentimentNeutral},
+		{"low volatility", storage.MarketCondition{VIX: 13}, storage.SentimentNeutral},
+		{"institutions buying", storage.MarketCondition{FIIActivity: -500, DIIActivity: 1500}, storage.SentimentNeutral},
+
+		// Two signals at their thresholds are.
+		{"up at the thresholds", storage.MarketCondition{ChangePercent: 0.5, AdvanceDecline: 1.5}, storage.SentimentBullish},
+		{"up just under the thresholds", storage.MarketCondition{ChangePercent: 0.49, AdvanceDecline: 1.49}, storage.SentimentNeutral},
+		{"calm with inflows", storage.MarketCondition{VIX: 13, FIIActivity: 400, DIIActivity: 600}, storage.SentimentBullish},
+		{"down at the thresholds", storage.MarketCondition{ChangePercent: -0.5, AdvanceDecline: 0.67}, storage.SentimentBearish},
+		{"down just inside the thresholds", storage.MarketCondition{ChangePercent: -0.49, AdvanceDecline: 0.68}, storage.SentimentNeutral},
+		{"fearful with outflows", storage.MarketCondition{VIX: 20, FIIActivity: -1500, DIIActivity: 500}, storage.SentimentBearish},
+		{"volatility just under the threshold", storage.MarketCondition{VIX: 19.99, FIIActivity: -1000}, storage.SentimentNeutral},
+
+		// Opposite signals cancel out.
+		{"mixed", storage.MarketCondition{ChangePercent: 1.2, AdvanceDecline: 2, VIX: 22, FIIActivity: -3000}, storage.SentimentNeutral},
+		{"strongly up", storage.MarketCondition{ChangePercent: 1.2, AdvanceDecline: 2, VIX: 22, FIIActivity: 3000}, storage.SentimentBullish},
+	}
+
+	for _, tt := range tests {
+		if got := Classify(&tt.mc); got != tt.want {
+			t.Errorf("Classify(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	// 20:00 UTC on the 16th is the 17th in IST.
+	recorded := time.Date(2025, time.October, 16, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		conditions []storage.MarketCondition
+		want       string
+	}{
+		{"none", nil, ""},
+		{
+			name: "benchmark with breadth, volatility and flows",
+			conditions: []storage.MarketCondition{
+				{
+					IndexName: IndexNifty50, IndexValue: 25145.5, ChangePercent: 0.85, Sentiment: storage.SentimentBullish,
+					VIX: 11.8, AdvanceDecline: 1.5772, FIIActivity: -1512.45, DIIActivity: 3344.44, RecordedAt: recorded,
+				},
+				{IndexName: IndexSensex, IndexValue: 82952.26, ChangePercent: 0.42, RecordedAt: recorded},
+				{IndexName: IndexBankNifty, IndexValue: 56780.9, ChangePercent: -0.1, RecordedAt: recorded},
+			},
+			want: "BULLISH (as of 17 Oct 2025: NIFTY50 25,145.50 (+0.85%), SENSEX 82,952.26 (+0.42%), " +
+				"BANKNIFTY 56,780.90 (-0.10%), India VIX 11.80, advance/decline 1.58, FII net -1,512.45 Cr, DII net +3,344.44 Cr)",
+		},
+		{
+			name: "index levels only",
+			conditions: []storage.MarketCondition{
+				{IndexName: IndexNifty50, IndexValue: 999.5, ChangePercent: -1.234, Sentiment: storage.SentimentNeutral, RecordedAt: recorded},
+			},
+			want: "NEUTRAL (as of 17 Oct 2025: NIFTY50 999.50 (-1.23%))",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := Describe(tt.conditions, calendar.IST); got != tt.want {
+			t.Errorf("Describe(%s) =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConditions(t *testing.T) {
+	s, err := parseFixture(t, "allIndices.json", "allIndices.json")
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	flows, err := parseFixture(t, "fii_dii.json", "fii_dii.json")
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	s.Merge(flows)
+
+	conditions := Conditions(s)
+	if len(conditions) != 2 || conditions[0].IndexName != IndexNifty50 || conditions[1].IndexName != IndexBankNifty {
+		t.Fatalf("conditions = %+v, want NIFTY50 and BANKNIFTY", conditions)
+	}
+	nifty := conditions[0]
+	if !approxEqual(nifty.AdvanceDecline, 38.0/12) || nifty.VIX != 11.8 || nifty.FIIActivity != -1512.45 || nifty.DIIActivity != 3344.44 {
+		t.Errorf("NIFTY50 = %+v", nifty)
+	}
+	// Up 0.85% on 38 advances to 12 declines, with VIX at 11.8 and net
+	// institutional buying.
+	if nifty.Sentiment != storage.SentimentBullish {
+		t.Errorf("Sentiment = %s, want %s", nifty.Sentiment, storage.SentimentBullish)
+	}
+}
+
+func TestFormatIndian(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want string
+	}{
+		{0, "0.00"},
+		{999.5, "999.50"},
+		{999.999, "1,000.00"},
+		{1000, "1,000.00"},
+		{25145.5, "25,145.50"},
+		{123456.78, "1,23,456.78"},
+		{12345678.9, "1,23,45,678.90"},
+	}
+
+	for _, tt := range tests {
+		if got := formatIndian(tt.v); got != tt.want {
+			t.Errorf("formatIndian(%v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package market
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Column aliases, as normalised by normalizeKey. They cover the NSE
+// all-indices and index-close downloads, NSE FII/DII activity and BSE index
+// archives, in both CSV and JSON form.
+var (
+	indexNameKeys = []string{"index", "indexname", "indices", "indexsymbol", "name"}
+	valueKeys     = []string{"last", "current", "currentvalue", "closingindexvalue", "close", "closeindexval", "ltp", "indexvalue", "value"}
+	changeKeys    = []string{"variation", "change", "chng", "pointschange", "pointchange"}
+	pctKeys       = []string{"percentchange", "pctchng", "changepct", "pctchange", "chngpct", "pchange"}
+	advanceKeys   = []string{"advances", "advance", "adv"}
+	declineKeys   = []string{"declines", "decline", "dec"}
+	dateKeys      = []string{"date", "indexdate", "timestamp", "tradedate", "asof"}
+	categoryKeys  = []string{"category", "clienttype"}
+	netKeys       = []string{"netvalue", "net", "netpurchasesales", "netpurchasesale"}
+)
+
+// dateLayouts are the date formats used in NSE and BSE downloads.
+var dateLayouts = []string{
+	"02-Jan-2006 15:04:05",
+	"02-Jan-2006 15:04",
+	"02-Jan-2006",
+	"02-Jan-06",
+	"2-Jan-2006",
+	"02 Jan 2006",
+	"2 Jan 2006",
+	"Jan 02, 2006",
+	"02-01-2006",
+	"02/01/2006",
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+}
+
+// row is one record of a download, keyed by normalised column name.
+type row map[string]string
+
+// ParseFile parses an NSE/BSE market data download. The format (CSV or
+// JSON) is detected from the content; the filename is only used to name the
+// index of single-index history files such as SENSEX.csv.
+func ParseFile(filename string, r io.Reader, location *time.Location) (*Snapshot, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read market data: %w", err)
+	}
+
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("market data file is empty")
+	}
+
+	var rows []row
+	var asOf time.Time
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		rows, asOf, err = parseJSONRows(trimmed, location)
+	} else {
+		rows, err = parseCSVRows(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := parseRows(filename, rows, location)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.stamp(asOf)
+	return snapshot, nil
+}
+
+// parseCSVRows reads CSV records into rows.
+func parseCSVRows(data []byte) ([]row, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	keys := make([]string, len(header))
+	for i, col := range header {
+		keys[i] = normalizeKey(col)
+	}
+
+	var rows []row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV row: %w", err)
+		}
+
+		rec := make(row, len(keys))
+		for i, key := range keys {
+			if i < len(record) {
+				rec[key] = strings.TrimSpace(record[i])
+			}
+		}
+		rows = append(rows, rec)
+	}
+	return rows, nil
+}
+
+// parseJSONRows reads a JSON array of objects, or an object holding one in
+// "data" (as the NSE APIs return), into rows. A top-level "timestamp" is
+// returned as the as-of time.
+func parseJSONRows(data []byte, location *time.Location) ([]row, time.Time, error) {
+	var asOf time.Time
+	var items []map[string]interface{}
+
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, asOf, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+	} else {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return nil, asOf, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		if ts, ok := obj["timestamp"].(string); ok {
+			asOf = parseDate(ts, location)
+		}
+		if list, ok := obj["data"].([]interface{}); ok {
+			for _, item := range list {
+				if m, ok := item.(map[string]interface{}); ok {
+					items = append(items, m)
+				}
+			}
+		} else {
+			items = append(items, obj)
+		}
+	}
+
+	rows := make([]row, 0, len(items))
+	for _, item := range items {
+		rec := make(row, len(item))
+		for key, value := range item {
+			switch v := value.(type) {
+			case string:
+				rec[normalizeKey(key)] = strings.TrimSpace(v)
+			case float64:
+				rec[normalizeKey(key)] = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+		rows = append(rows, rec)
+	}
+	return rows, asOf, nil
+}
+
+// parseRows interprets rows as index levels, FII/DII flows or market breadth.
+func parseRows(filename string, rows []row, location *time.Location) (*Snapshot, error) {
+	snapshot := NewSnapshot()
+	fileIndex := IndexName(strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)))
+
+	for _, rec := range rows {
+		date := parseDate(rec.get(dateKeys...), location)
+
+		switch {
+		case rec.has(categoryKeys...) && rec.has(netKeys...):
+			category := strings.ToUpper(rec.get(categoryKeys...))
+			net := parseNumber(rec.get(netKeys...))
+			switch {
+			case strings.Contains(category, "FII"), strings.Contains(category, "FPI"):
+				snapshot.FIINet = net
+			case strings.Contains(category, "DII"):
+				snapshot.DIINet = net
+			default:
+				continue
+			}
+			snapshot.HasFlows = true
+			if date.After(snapshot.AsOf) {
+				snapshot.AsOf = date
+			}
+
+		case rec.has(indexNameKeys...) && rec.has(valueKeys...):
+			addQuote(snapshot, IndexName(rec.get(indexNameKeys...)), rec, date)
+
+		case rec.has(valueKeys...) && fileIndex != "":
+			addQuote(snapshot, fileIndex, rec, date)
+
+		case rec.has(advanceKeys...) && rec.has(declineKeys...):
+			snapshot.Advances = int(parseNumber(rec.get(advanceKeys...)))
+			snapshot.Declines = int(parseNumber(rec.get(declineKeys...)))
+		}
+	}
+
+	if snapshot.Empty() {
+		return nil, fmt.Errorf("unrecognised market data format: expected index levels, India VIX, advances/declines or FII/DII activity")
+	}
+	return snapshot, nil
+}
+
+// addQuote records an index level, keeping the latest when a file holds a
+// history. India VIX is stored as the snapshot's volatility.
+func addQuote(snapshot *Snapshot, name string, rec row, date time.Time) {
+	value := parseNumber(rec.get(valueKeys...))
+	if name == "" || value == 0 {
+		return
+	}
+	if name == IndexIndiaVIX {
+		if !date.Before(snapshot.vixAsOf) {
+			snapshot.VIX, snapshot.vixAsOf = value, date
+		}
+		return
+	}
+
+	quote := IndexQuote{
+		Name:          name,
+		Value:         value,
+		Change:        parseNumber(rec.get(changeKeys...)),
+		ChangePercent: parseNumber(rec.get(pctKeys...)),
+		Advances:      int(parseNumber(rec.get(advanceKeys...))),
+		Declines:      int(parseNumber(rec.get(declineKeys...))),
+		AsOf:          date,
+	}
+
+	// History files (e.g. BSE archives) have closes but no change columns;
+	// derive the change from the previous close.
+	existing, ok := snapshot.Indices[name]
+	if ok && date.Before(existing.AsOf) {
+		if existing.Change == 0 && existing.ChangePercent == 0 {
+			existing.Change = existing.Value - value
+			existing.ChangePercent = existing.Change / value * 100
+			snapshot.Indices[name] = existing
+		}
+		return
+	}
+	if ok && quote.Change == 0 && quote.ChangePercent == 0 && existing.Value != 0 {
+		quote.Change = value - existing.Value
+		quote.ChangePercent = quote.Change / existing.Value * 100
+	}
+	snapshot.Indices[name] = quote
+}
+
+// IndexName normalises an index name as published by NSE or BSE, such as
+// "NIFTY 50", "NIFTY BANK" or "S&P BSE SENSEX", to the stored name. Unknown
+// names are returned upper-cased without spaces or punctuation.
+func IndexName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	normalized := b.String()
+
+	switch normalized {
+	case "NIFTY", "NIFTY50", "CNXNIFTY":
+		return IndexNifty50
+	case "NIFTYBANK", "BANKNIFTY", "CNXBANK":
+		return IndexBankNifty
+	case "SENSEX", "BSESENSEX", "SPBSESENSEX":
+		return IndexSensex
+	case "INDIAVIX", "VIX":
+		return IndexIndiaVIX
+	default:
+		return normalized
+	}
+}
+
+// normalizeKey normalises a column name so that "% Chng", "pChange" and
+// "Change(%)" compare equal to their aliases.
+func normalizeKey(key string) string {
+	key = strings.ToLower(key)
+	key = strings.ReplaceAll(key, "%", "pct")
+	var b strings.Builder
+	for _, r := range key {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// get returns the first non-empty value among the keys.
+func (r row) get(keys ...string) string {
+	for _, key := range keys {
+		if v := r[key]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// has reports whether any of the keys has a value.
+func (r row) has(keys ...string) bool {
+	return r.get(keys...) != ""
+}
+
+// parseNumber parses a number such as "1,234.50", "-0.45%" or "(123.4)".
+func parseNumber(s string) float64 {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "-" || strings.EqualFold(s, "NA") || strings.EqualFold(s, "N/A") {
+		return 0
+	}
+
+	negative := strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")")
+	s = strings.Trim(s, "()")
+	s = strings.ReplaceAll(s, ",", "")
+	s = strings.ReplaceAll(s, "₹", "")
+	s = strings.ReplaceAll(s, "%", "")
+	s = strings.ReplaceAll(s, " ", "")
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	if negative {
+		v = -v
+	}
+	return v
+}
+
+// parseDate parses a date in any of the download formats, or returns zero.
+func parseDate(s string, location *time.Location) time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}
+	}
+	if location == nil {
+		location = time.Local
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, location); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
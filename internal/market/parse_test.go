@@ -0,0 +1,216 @@
+package market
+
+import (
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/stock-recommender/internal/calendar"
+)
+
+// parseFixture parses a download in testdata as if it were uploaded with
+// the given filename.
+func parseFixture(t *testing.T, fixture, filename string) (*Snapshot, error) {
+	t.Helper()
+	f, err := os.Open("testdata/" + fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return ParseFile(filename, f, calendar.IST)
+}
+
+// approxEqual reports whether two numbers differ by less than 0.0001.
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-4
+}
+
+func TestParseFile(t *testing.T) {
+	closeOn17th := time.Date(2025, time.October, 17, 0, 0, 0, 0, calendar.IST)
+
+	tests := []struct {
+		name         string
+		fixture      string
+		filename     string // defaults to fixture
+		wantIndices  map[string]IndexQuote
+		wantVIX      float64
+		wantFII      float64
+		wantDII      float64
+		wantHasFlows bool
+		wantAsOf     time.Time // of flows, or of the file when it has a timestamp
+	}{
+		{
+			name:    "nse index close csv",
+			fixture: "ind_close_all_17102025.csv",
+			wantIndices: map[string]IndexQuote{
+				IndexNifty50:   {Value: 25145.50, Change: 212.15, ChangePercent: 0.85, AsOf: closeOn17th},
+				IndexBankNifty: {Value: 56780.90, Change: 545.35, ChangePercent: 0.97, AsOf: closeOn17th},
+				"NIFTYIT":      {Value: 34810.60, Change: -310.45, ChangePercent: -0.88, AsOf: closeOn17th},
+			},
+			wantVIX: 11.80,
+		},
+		{
+			// Rows carry no date; the response's timestamp applies to all.
+			name:    "nse all indices json",
+			fixture: "allIndices.json",
+			wantIndices: map[string]IndexQuote{
+				IndexNifty50: {
+					Value: 25145.5, Change: 212.15, ChangePercent: 0.85, Advances: 38, Declines: 12,
+					AsOf: time.Date(2025, time.October, 17, 15, 30, 0, 0, calendar.IST),
+				},
+				IndexBankNifty: {
+					Value: 56780.9, Change: 545.35, ChangePercent: 0.97, Advances: 9, Declines: 3,
+					AsOf: time.Date(2025, time.October, 17, 15, 30, 0, 0, calendar.IST),
+				},
+			},
+			wantVIX:  11.8,
+			wantAsOf: time.Date(2025, time.October, 17, 15, 30, 0, 0, calendar.IST),
+		},
+		{
+			name:         "nse fii/dii json",
+			fixture:      "fii_dii.json",
+			wantFII:      -1512.45,
+			wantDII:      3344.44,
+			wantHasFlows: true,
+			wantAsOf:     closeOn17th,
+		},
+		{
+			// Thousands separators, and a negative in parentheses.
+			name:         "nse fii/dii csv",
+			fixture:      "fii_dii.csv",
+			wantFII:      -1512.45,
+			wantDII:      3344.44,
+			wantHasFlows: true,
+			wantAsOf:     closeOn17th,
+		},
+		{
+			// A history named after its index; the change is taken from the
+			// previous close.
+			name:    "bse index history",
+			fixture: "SENSEX.csv",
+			wantIndices: map[string]IndexQuote{
+				IndexSensex: {Value: 82952.26, Change: 346.83, ChangePercent: 346.83 / 82605.43 * 100, AsOf: closeOn17th},
+			},
+		},
+		{
+			name:     "bse index history, newest first",
+			fixture:  "sensex_newest_first.csv",
+			filename: "SENSEX.csv",
+			wantIndices: map[string]IndexQuote{
+				IndexSensex: {Value: 82952.26, Change: 346.83, ChangePercent: 346.83 / 82605.43 * 100, AsOf: closeOn17th},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := tt.filename
+			if filename == "" {
+				filename = tt.fixture
+			}
+			s, err := parseFixture(t, tt.fixture, filename)
+			if err != nil {
+				t.Fatalf("ParseFile: %v", err)
+			}
+
+			if len(s.Indices) != len(tt.wantIndices) {
+				t.Errorf("got %d indices, want %d: %+v", len(s.Indices), len(tt.wantIndices), s.Indices)
+			}
+			for name, want := range tt.wantIndices {
+				got, ok := s.Indices[name]
+				if !ok {
+					t.Errorf("no quote for %s", name)
+					continue
+				}
+				if !approxEqual(got.Value, want.Value) || !approxEqual(got.Change, want.Change) || !approxEqual(got.ChangePercent, want.ChangePercent) ||
+					got.Advances != want.Advances || got.Declines != want.Declines || !got.AsOf.Equal(want.AsOf) {
+					t.Errorf("%s = %+v, want %+v", name, got, want)
+				}
+			}
+
+			if !approxEqual(s.VIX, tt.wantVIX) {
+				t.Errorf("VIX = %v, want %v", s.VIX, tt.wantVIX)
+			}
+			if s.HasFlows != tt.wantHasFlows || !approxEqual(s.FIINet, tt.wantFII) || !approxEqual(s.DIINet, tt.wantDII) {
+				t.Errorf("flows = %v FII %v DII %v, want %v FII %v DII %v",
+					s.HasFlows, s.FIINet, s.DIINet, tt.wantHasFlows, tt.wantFII, tt.wantDII)
+			}
+			if !tt.wantAsOf.IsZero() && !s.AsOf.Equal(tt.wantAsOf) {
+				t.Errorf("AsOf = %v, want %v", s.AsOf, tt.wantAsOf)
+			}
+		})
+	}
+}
+
+func TestParseFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"empty", " \n", "empty"},
+		{"byte order mark only", "\xef\xbb\xbf", "empty"},
+		{"invalid json", `{"data": [`, "failed to parse JSON"},
+		{"unrecognised columns", "Symbol,Series,Open,High\nTCS,EQ,3050.00,3075.50\n", "unrecognised market data format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFile("upload.csv", strings.NewReader(tt.content), calendar.IST)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseFile = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := parseFixture(t, "unrecognised.csv", "unrecognised.csv"); err == nil {
+		t.Error("parsed a file with no market data")
+	}
+}
+
+func TestIndexName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"NIFTY 50", IndexNifty50},
+		{"Nifty 50", IndexNifty50},
+		{"CNX Nifty", IndexNifty50},
+		{"NIFTY BANK", IndexBankNifty},
+		{"S&P BSE SENSEX", IndexSensex},
+		{"SENSEX", IndexSensex},
+		{"India VIX", IndexIndiaVIX},
+		{"Nifty Midcap 150", "NIFTYMIDCAP150"},
+	}
+
+	for _, tt := range tests {
+		if got := IndexName(tt.name); got != tt.want {
+			t.Errorf("IndexName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		s    string
+		want float64
+	}{
+		{"1,234.50", 1234.5},
+		{"-0.45%", -0.45},
+		{"(1,512.45)", -1512.45},
+		{"₹ 3,344.44", 3344.44},
+		{"-", 0},
+		{"NA", 0},
+		{"n/a", 0},
+		{"", 0},
+		{"abc", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseNumber(tt.s); got != tt.want {
+			t.Errorf("parseNumber(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Package portfolio imports broker holdings and tradebooks into portfolios
+// and tracks their transactions, P&L and allocation.
+package portfolio
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/stock-recommender/internal/calendar"
+	"github.com/user/stock-recommender/internal/screener"
+)
+
+// Broker export formats.
+const (
+	BrokerZerodhaTradebook = "zerodha_tradebook"
+	BrokerZerodhaConsole   = "zerodha_console"
+	BrokerZerodhaKite      = "zerodha_kite"
+	BrokerGroww            = "groww"
+	BrokerUpstox           = "upstox"
+	BrokerGeneric          = "generic"
+)
+
+// Fields read from broker exports.
+const (
+	fieldSymbol   = "symbol"
+	fieldISIN     = "isin"
+	fieldName     = "name"
+	fieldQuantity = "quantity"
+	fieldPrice    = "price"
+	fieldDate     = "date"
+	fieldSide     = "side"
+)
+
+// brokerFormat describes the columns of a broker's export. Column names
+// are normalized with screener.NormalizeColumnName.
+type brokerFormat struct {
+	name      string
+	signature []string            // columns that identify the format
+	fields    map[string][]string // field -> columns, in order of preference
+	tradebook bool                // rows are trades rather than holdings
+}
+
+// brokerFormats lists the supported formats in the order they are
+// detected. The generic format matches any export with a quantity, a price
+// and a symbol, ISIN or name column.
+var brokerFormats = []brokerFormat{
+	{
+		name:      BrokerZerodhaTradebook,
+		signature: []string{"symbol", "tradedate", "tradetype"},
+		fields: map[string][]string{
+			fieldSymbol:   {"symbol"},
+			fieldISIN:     {"isin"},
+			fieldQuantity: {"quantity"},
+			fieldPrice:    {"price"},
+			fieldDate:     {"tradedate"},
+			fieldSide:     {"tradetype"},
+		},
+		tradebook: true,
+	},
+	{
+		name:      BrokerZerodhaConsole,
+		signature: []string{"symbol", "quantityavailable", "averageprice"},
+		fields: map[string][]string{
+			fieldSymbol:   {"symbol"},
+			fieldISIN:     {"isin"},
+			fieldQuantity: {"quantityavailable"},
+			fieldPrice:    {"averageprice"},
+		},
+	},
+	{
+		name:      BrokerZerodhaKite,
+		signature: []string{"instrument", "qty", "avgcost"},
+		fields: map[string][]string{
+			fieldSymbol:   {"instrument"},
+			fieldQuantity: {"qty"},
+			fieldPrice:    {"avgcost"},
+		},
+	},
+	{
+		name:      BrokerGroww,
+		signature: []string{"stockname", "isin", "averagebuyprice"},
+		fields: map[string][]string{
+			fieldName:     {"stockname"},
+			fieldISIN:     {"isin"},
+			fieldQuantity: {"quantity"},
+			fieldPrice:    {"averagebuyprice"},
+		},
+	},
+	{
+		name:      BrokerUpstox,
+		signature: []string{"scripname", "isin", "avgprice"},
+		fields: map[string][]string{
+			fieldSymbol:   {"symbol", "tradingsymbol"},
+			fieldName:     {"scripname"},
+			fieldISIN:     {"isin"},
+			fieldQuantity: {"quantity", "qty", "netqty"},
+			fieldPrice:    {"avgprice"},
+		},
+	},
+	{
+		name: BrokerGeneric,
+		fields: map[string][]string{
+			fieldSymbol:   {"symbol", "tradingsymbol", "ticker", "nsecode", "instrument", "code"},
+			fieldISIN:     {"isin", "isincode"},
+			fieldName:     {"name", "stockname", "companyname", "company", "scripname"},
+			fieldQuantity: {"quantity", "qty", "shares", "units", "quantityavailable"},
+			fieldPrice:    {"averagecost", "avgcost", "averageprice", "avgprice", "averagebuyprice", "buyprice", "costprice", "cost", "price"},
+			fieldDate:     {"buydate", "purchasedate", "tradedate", "date"},
+			fieldSide:     {"side", "tradetype", "buysell", "transactiontype"},
+		},
+	},
+}
+
+// BrokerFormats returns the names of the supported broker formats.
+func BrokerFormats() []string {
+	names := make([]string, len(brokerFormats))
+	for i, f := range brokerFormats {
+		names[i] = f.name
+	}
+	return names
+}
+
+// columns resolves the format's fields to the columns of header.
+func (f *brokerFormat) columns(header []string) map[string]int {
+	colIndex := make(map[string]int)
+	for i, col := range header {
+		colIndex[screener.NormalizeColumnName(col)] = i
+	}
+
+	for _, col := range f.signature {
+		if _, ok := colIndex[col]; !ok {
+			return nil
+		}
+	}
+
+	fields := make(map[string]int)
+	for field, aliases := range f.fields {
+		for _, alias := range aliases {
+			if idx, ok := colIndex[alias]; ok {
+				fields[field] = idx
+				break
+			}
+		}
+	}
+
+	_, symbol := fields[fieldSymbol]
+	_, isin := fields[fieldISIN]
+	_, name := fields[fieldName]
+	_, quantity := fields[fieldQuantity]
+	_, price := fields[fieldPrice]
+	if !quantity || !price || !(symbol || isin || name) {
+		return nil
+	}
+	return fields
+}
+
+// detectFormat finds the header row of an export and its format, or of the
+// named format if broker is not empty.
+func detectFormat(rows [][]string, broker string) (*brokerFormat, int, map[string]int, error) {
+	var formats []*brokerFormat
+	for i := range brokerFormats {
+		if broker == "" || brokerFormats[i].name == broker {
+			formats = append(formats, &brokerFormats[i])
+		}
+	}
+	if len(formats) == 0 {
+		return nil, 0, nil, fmt.Errorf("%w %q, use one of %s", ErrUnknownBroker, broker, strings.Join(BrokerFormats(), ", "))
+	}
+
+	for i, row := range rows {
+		for _, f := range formats {
+			if fields := f.columns(row); fields != nil {
+				return f, i, fields, nil
+			}
+		}
+	}
+	return nil, 0, nil, fmt.Errorf("no header row with quantity, average price and symbol, ISIN or name columns")
+}
+
+// isinLength is the length of an ISIN, e.g. INE002A01018.
+const isinLength = 12
+
+// trade is a row of a broker export: a holding, or a buy or sell in a
+// tradebook.
+type trade struct {
+	Line     int
+	Symbol   string
+	ISIN     string
+	Name     string
+	Quantity float64
+	Price    float64
+	Date     *time.Time
+	Sell     bool
+}
+
+// key identifies the stock of a trade within an export.
+func (t *trade) key() string {
+	switch {
+	case t.Symbol != "":
+		return "symbol:" + t.Symbol
+	case t.ISIN != "":
+		return "isin:" + t.ISIN
+	default:
+		return "name:" + strings.ToLower(t.Name)
+	}
+}
+
+// parseTrades reads the rows below the header as trades.
+func parseTrades(rows [][]string, lines []int, header int, fields map[string]int, tradebook bool) ([]trade, []RowError) {
+	var trades []trade
+	var errs []RowError
+
+	for i := header + 1; i < len(rows); i++ {
+		record := rows[i]
+		get := func(field string) string {
+			if idx, ok := fields[field]; ok && idx < len(record) {
+				return strings.TrimSpace(record[idx])
+			}
+			return ""
+		}
+
+		t := trade{
+			Line:   lines[i],
+			Symbol: normalizeSymbol(get(fieldSymbol)),
+			ISIN:   strings.ToUpper(get(fieldISIN)),
+			Name:   get(fieldName),
+		}
+		if len(t.ISIN) != isinLength {
+			t.ISIN = ""
+		}
+		if t.Symbol == "" && t.ISIN == "" && t.Name == "" {
+			errs = append(errs, RowError{Row: t.Line, Message: "no symbol, ISIN or name"})
+			continue
+		}
+		if strings.EqualFold(t.Symbol, "TOTAL") || strings.EqualFold(t.Name, "Total") {
+			continue
+		}
+
+		quantity, ok := screener.ParseNumber(get(fieldQuantity))
+		if !ok || quantity <= 0 {
+			errs = append(errs, RowError{Row: t.Line, Message: fmt.Sprintf("invalid quantity %q", get(fieldQuantity))})
+			continue
+		}
+		price, ok := screener.ParseNumber(get(fieldPrice))
+		if !ok || price < 0 {
+			errs = append(errs, RowError{Row: t.Line, Message: fmt.Sprintf("invalid price %q", get(fieldPrice))})
+			continue
+		}
+		t.Quantity, t.Price = quantity, price
+
+		if s := get(fieldDate); s != "" {
+			date, err := parseDate(s)
+			if err != nil {
+				errs = append(errs, RowError{Row: t.Line, Message: err.Error()})
+				continue
+			}
+			t.Date = &date
+		}
+
+		if _, hasSide := fields[fieldSide]; hasSide || tradebook {
+			switch strings.ToLower(get(fieldSide)) {
+			case "buy", "b":
+			case "sell", "s":
+				t.Sell = true
+			default:
+				errs = append(errs, RowError{Row: t.Line, Message: fmt.Sprintf("unknown trade type %q", get(fieldSide))})
+				continue
+			}
+		}
+
+		trades = append(trades, t)
+	}
+	return trades, errs
+}
+
+// dateLayouts are the date formats accepted in broker exports.
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	"02-01-2006",
+	"02/01/2006",
+	"02-Jan-2006",
+	"02 Jan 2006",
+	"2 Jan 2006",
+	"Jan 2, 2006",
+}
+
+// parseDate parses a date in any of dateLayouts, in IST.
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, calendar.IST); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// normalizeSymbol upper-cases an NSE symbol and drops the series suffix
+// brokers add for some segments, e.g. "IDEA-BE".
+func normalizeSymbol(symbol string) string {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	for _, series := range []string{"-EQ", "-BE", "-BZ", "-SM", "-ST"} {
+		symbol = strings.TrimSuffix(symbol, series)
+	}
+	return symbol
+}
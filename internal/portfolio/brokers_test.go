@@ -0,0 +1,140 @@
+package portfolio
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/user/stock-recommender/internal/calendar"
+	"github.com/user/stock-recommender/internal/screener"
+)
+
+// readExport detects the format of a broker export in testdata and parses
+// its trades, as Importer.Import does.
+func readExport(t *testing.T, name, broker string) (*brokerFormat, []trade, []RowError, error) {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, lines, err := screener.ReadRows(data, "")
+	if err != nil {
+		t.Fatalf("ReadRows: %v", err)
+	}
+	format, header, fields, err := detectFormat(rows, broker)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	_, hasSide := fields[fieldSide]
+	trades, errs := parseTrades(rows, lines, header, fields, format.tradebook || hasSide)
+	return format, trades, errs, nil
+}
+
+// istDate returns midnight IST of a day.
+func istDate(year int, month time.Month, day int) *time.Time {
+	d := time.Date(year, month, day, 0, 0, 0, 0, calendar.IST)
+	return &d
+}
+
+func TestParseBrokerExports(t *testing.T) {
+	tests := []struct {
+		file       string
+		wantFormat string
+		want       []trade
+	}{
+		{
+			file:       "groww_holdings.csv",
+			wantFormat: BrokerGroww,
+			want: []trade{
+				{Line: 6, ISIN: "INE002A01018", Name: "Reliance Industries Ltd.", Quantity: 12, Price: 2410.25},
+				{Line: 7, ISIN: "INE009A01021", Name: "Infosys Ltd.", Quantity: 8, Price: 1390},
+				{Line: 8, ISIN: "INE021A01026", Name: "Asian Paints Ltd.", Quantity: 5, Price: 3120.5},
+			},
+		},
+		{
+			file:       "kite_holdings.csv",
+			wantFormat: BrokerZerodhaKite,
+			want: []trade{
+				{Line: 2, Symbol: "INFY", Quantity: 40, Price: 1425.6},
+				{Line: 3, Symbol: "HDFCBANK", Quantity: 25, Price: 1580.1},
+				{Line: 4, Symbol: "IDEA", Quantity: 500, Price: 11.45},
+				{Line: 5, Symbol: "TATAMOTORS", Quantity: 30, Price: 612.4},
+			},
+		},
+		{
+			file:       "zerodha_tradebook.csv",
+			wantFormat: BrokerZerodhaTradebook,
+			want: []trade{
+				{Line: 2, Symbol: "INFY", ISIN: "INE009A01021", Quantity: 10, Price: 1385.5, Date: istDate(2023, time.April, 12)},
+				{Line: 3, Symbol: "INFY", ISIN: "INE009A01021", Quantity: 5, Price: 1388, Date: istDate(2023, time.April, 12)},
+				{Line: 4, Symbol: "ITC", ISIN: "INE154A01025", Quantity: 100, Price: 438.2, Date: istDate(2023, time.June, 1)},
+				{Line: 5, Symbol: "INFY", ISIN: "INE009A01021", Quantity: 10, Price: 1612, Date: istDate(2024, time.January, 15)},
+				{Line: 6, Symbol: "INFY", ISIN: "INE009A01021", Quantity: 12, Price: 1720.25, Date: istDate(2024, time.July, 22), Sell: true},
+				{Line: 7, Symbol: "ITC", ISIN: "INE154A01025", Quantity: 40, Price: 512.65, Date: istDate(2024, time.September, 3), Sell: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			format, trades, errs, err := readExport(t, tt.file, "")
+			if err != nil {
+				t.Fatalf("detectFormat: %v", err)
+			}
+			if format.name != tt.wantFormat {
+				t.Errorf("format = %q, want %q", format.name, tt.wantFormat)
+			}
+			if len(errs) > 0 {
+				t.Errorf("unexpected row errors: %v", errs)
+			}
+			if !reflect.DeepEqual(trades, tt.want) {
+				t.Errorf("trades:\n got %+v\nwant %+v", trades, tt.want)
+			}
+
+			// The named broker reads its own export.
+			if _, _, _, err := readExport(t, tt.file, tt.wantFormat); err != nil {
+				t.Errorf("detectFormat with broker %q: %v", tt.wantFormat, err)
+			}
+		})
+	}
+}
+
+func TestDetectFormatBroker(t *testing.T) {
+	// A named broker does not fall back to another format.
+	if _, _, _, err := readExport(t, "kite_holdings.csv", BrokerGroww); err == nil || errors.Is(err, ErrUnknownBroker) {
+		t.Errorf("kite export read as groww: err = %v, want no header row", err)
+	}
+	if _, _, _, err := readExport(t, "kite_holdings.csv", "icici"); !errors.Is(err, ErrUnknownBroker) {
+		t.Errorf("err = %v, want ErrUnknownBroker", err)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []string{"2024-07-23", "2024-07-23 00:00:00", "23-07-2024", "23/07/2024", "23-Jul-2024", "23 Jul 2024", "Jul 23, 2024"}
+	want := istDate(2024, time.July, 23)
+	for _, s := range tests {
+		got, err := parseDate(s)
+		if err != nil || !got.Equal(*want) {
+			t.Errorf("parseDate(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	if _, err := parseDate("07/23/2024"); err == nil {
+		t.Error("parseDate accepted a month-first date")
+	}
+}
+
+func TestNormalizeSymbol(t *testing.T) {
+	tests := map[string]string{
+		"infy":        "INFY",
+		" IDEA-BE ":   "IDEA",
+		"RELIANCE-EQ": "RELIANCE",
+		"BAJAJ-AUTO":  "BAJAJ-AUTO",
+	}
+	for in, want := range tests {
+		if got := normalizeSymbol(in); got != want {
+			t.Errorf("normalizeSymbol(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package portfolio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/user/stock-recommender/internal/screener"
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// maxSymbolLength matches the size of storage.Stock.Symbol.
+const maxSymbolLength = 20
+
+var (
+	// ErrInvalidExport wraps errors caused by an unreadable broker export.
+	ErrInvalidExport = errors.New("invalid holdings export")
+
+	// ErrUnknownBroker is returned when a broker format is chosen that is
+	// not supported.
+	ErrUnknownBroker = errors.New("unknown broker format")
+)
+
+// ImportOptions adjusts how a broker export is read.
+type ImportOptions struct {
+	// Name names the portfolio; the file name is used if it is empty.
+	Name string
+
+	// Broker chooses one of BrokerFormats instead of detecting it from
+	// the columns.
+	Broker string
+
+	// Sheet chooses the worksheet of an XLSX workbook by name or 1-based
+	// position. The first sheet is read if it is empty.
+	Sheet string
+}
+
+// RowError is a row of an export that was not imported.
+type RowError struct {
+	Row     int    `json:"row"` // line or row number in the file
+	Message string `json:"message"`
+}
+
+// ImportResult is the outcome of a holdings import.
+type ImportResult struct {
+	Portfolio     *storage.Portfolio `json:"portfolio"`
+	Broker        string             `json:"broker"`
+	StocksCreated []string           `json:"stocks_created"` // symbols added to the stock table
+	Errors        []RowError         `json:"errors"`
+}
+
+// Importer imports broker holdings and tradebook exports as portfolios.
+// Holdings are matched to stocks by ISIN, then symbol, then name; stocks
+// with a symbol that are not tracked yet are created.
+type Importer struct {
+	repo *storage.Repository
+}
+
+// NewImporter creates a holdings importer.
+func NewImporter(repo *storage.Repository) *Importer {
+	return &Importer{repo: repo}
+}
+
+// Import reads a CSV or XLSX broker export and creates a portfolio with a
+// transaction for each holding or trade and the lots they leave held. Rows
+// that cannot be read or matched to a stock are reported in the result and
+// left out. Imported transactions do not change the portfolio's cash.
+func (im *Importer) Import(ctx context.Context, filename string, data []byte, opts ImportOptions) (*ImportResult, error) {
+	rows, lines, err := screener.ReadRows(data, opts.Sheet)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+
+	format, header, fields, err := detectFormat(rows, opts.Broker)
+	if errors.Is(err, ErrUnknownBroker) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+
+	_, hasSide := fields[fieldSide]
+	tradebook := format.tradebook || hasSide
+	trades, errs := parseTrades(rows, lines, header, fields, tradebook)
+
+	name := strings.TrimSpace(opts.Name)
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	result := &ImportResult{
+		Portfolio: &storage.Portfolio{
+			Name:     name,
+			Broker:   format.name,
+			Filename: filepath.Base(filename),
+		},
+		Broker:        format.name,
+		StocksCreated: []string{},
+	}
+
+	var transactions []storage.PortfolioTransaction
+	var txLines []int
+	stocks := make(map[string]*storage.Stock)
+	for _, t := range trades {
+		if t.Sell && !tradebook {
+			errs = append(errs, RowError{Row: t.Line, Message: "sell rows need a tradebook export"})
+			continue
+		}
+
+		key := t.key()
+		stock, ok := stocks[key]
+		if !ok {
+			var created bool
+			stock, created, err = im.resolveStock(ctx, t)
+			if err != nil {
+				return nil, err
+			}
+			stocks[key] = stock
+			if created {
+				result.StocksCreated = append(result.StocksCreated, stock.Symbol)
+			}
+		}
+		if stock == nil {
+			errs = append(errs, RowError{Row: t.Line, Message: fmt.Sprintf("no stock matches %s", describe(t))})
+			continue
+		}
+
+		tx := storage.PortfolioTransaction{
+			StockID:  &stock.ID,
+			Symbol:   stock.Symbol,
+			Type:     storage.TransactionBuy,
+			Quantity: t.Quantity,
+			Price:    t.Price,
+			Date:     t.Date,
+			Source:   storage.TransactionSourceImport,
+		}
+		if t.Sell {
+			tx.Type = storage.TransactionSell
+		}
+		setAmount(&tx)
+		transactions = append(transactions, tx)
+		txLines = append(txLines, t.Line)
+	}
+
+	// Sells of more shares than the export bought are left out, one at a
+	// time since dropping one changes what later sells match.
+	ledger, err := Replay(transactions)
+	for err != nil {
+		var oversold *OversoldError
+		if !errors.As(err, &oversold) {
+			return nil, err
+		}
+		errs = append(errs, RowError{Row: txLines[oversold.Index], Message: fmt.Sprintf("sells %g more shares than were bought", oversold.Quantity)})
+		transactions = append(transactions[:oversold.Index], transactions[oversold.Index+1:]...)
+		txLines = append(txLines[:oversold.Index], txLines[oversold.Index+1:]...)
+		ledger, err = Replay(transactions)
+	}
+	result.Errors = sortErrors(errs)
+
+	if len(transactions) == 0 {
+		return nil, fmt.Errorf("%w: no holdings found", ErrInvalidExport)
+	}
+	result.Portfolio.Transactions = transactions
+	result.Portfolio.Lots = ledger.Lots
+	if err := im.repo.CreatePortfolio(ctx, result.Portfolio); err != nil {
+		return nil, fmt.Errorf("failed to save portfolio: %w", err)
+	}
+	return result, nil
+}
+
+// resolveStock finds the stock of a trade by ISIN, symbol or name, in that
+// order. A stock with the trade's symbol is created if none matches; nil is
+// returned if the trade has no usable symbol either.
+func (im *Importer) resolveStock(ctx context.Context, l trade) (*storage.Stock, bool, error) {
+	if l.ISIN != "" {
+		stock, err := im.repo.GetStockByISIN(ctx, l.ISIN)
+		if err != nil || stock != nil {
+			return stock, false, err
+		}
+	}
+
+	if l.Symbol != "" {
+		stock, err := im.repo.GetStockBySymbol(ctx, l.Symbol)
+		if err != nil {
+			return nil, false, err
+		}
+		if stock != nil {
+			if stock.ISIN == "" && l.ISIN != "" {
+				stock.ISIN = l.ISIN
+				if err := im.repo.UpdateStock(ctx, stock); err != nil {
+					fmt.Printf("Warning: failed to record ISIN of %s: %v\n", stock.Symbol, err)
+				}
+			}
+			return stock, false, nil
+		}
+	}
+
+	for _, name := range nameVariants(l.Name) {
+		stock, err := im.repo.GetStockByName(ctx, name)
+		if err != nil || stock != nil {
+			return stock, false, err
+		}
+	}
+
+	if l.Symbol == "" || len(l.Symbol) > maxSymbolLength {
+		return nil, false, nil
+	}
+
+	name := l.Name
+	if name == "" {
+		name = l.Symbol
+	}
+	stock := &storage.Stock{Symbol: l.Symbol, Name: name, Exchange: "NSE", ISIN: l.ISIN}
+	if err := im.repo.CreateStock(ctx, stock); err != nil {
+		return nil, false, fmt.Errorf("failed to create stock %s: %w", l.Symbol, err)
+	}
+	return stock, true, nil
+}
+
+// nameVariants returns a company name as given and with its "Ltd" or
+// "Limited" suffix dropped or spelled the other ways, since brokers and
+// screener.in differ in how they write it.
+func nameVariants(name string) []string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+
+	base := name
+	lower := strings.ToLower(name)
+	for _, suffix := range []string{" ltd.", " ltd", " limited"} {
+		if strings.HasSuffix(lower, suffix) {
+			base = strings.TrimSpace(name[:len(name)-len(suffix)])
+			break
+		}
+	}
+
+	variants := []string{name}
+	for _, v := range []string{base, base + " Ltd.", base + " Ltd", base + " Limited"} {
+		if !strings.EqualFold(v, name) {
+			variants = append(variants, v)
+		}
+	}
+	return variants
+}
+
+// describe names the stock of a trade for error messages.
+func describe(l trade) string {
+	var parts []string
+	if l.Symbol != "" {
+		parts = append(parts, fmt.Sprintf("symbol %q", l.Symbol))
+	}
+	if l.ISIN != "" {
+		parts = append(parts, fmt.Sprintf("ISIN %s", l.ISIN))
+	}
+	if l.Name != "" {
+		parts = append(parts, fmt.Sprintf("name %q", l.Name))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sortErrors orders row errors by row.
+func sortErrors(errs []RowError) []RowError {
+	if errs == nil {
+		return []RowError{}
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Row < errs[j].Row })
+	return errs
+}
@@ -0,0 +1,194 @@
+package portfolio
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// quantityEpsilon absorbs rounding when fractional quantities are matched.
+const quantityEpsilon = 1e-9
+
+// Realization is the part of a sell matched against one bought lot.
+type Realization struct {
+	Transaction int        `json:"-"` // index of the sell in the replayed transactions
+	StockID     *uint      `json:"stock_id,omitempty"`
+	Symbol      string     `json:"symbol"`
+	Quantity    float64    `json:"quantity"`
+	BuyDate     *time.Time `json:"buy_date,omitempty"`
+	SellDate    *time.Time `json:"sell_date,omitempty"`
+	Cost        float64    `json:"cost"`     // buy price and fees of the shares sold
+	Proceeds    float64    `json:"proceeds"` // sale price less fees of the shares sold
+	Gain        float64    `json:"gain"`
+}
+
+// Ledger is the state of a portfolio worked out from its transactions.
+type Ledger struct {
+	Lots         []storage.PortfolioLot
+	Realizations []Realization
+	Cash         float64
+	RealizedPnL  float64
+	Dividends    float64
+}
+
+// OversoldError is returned by Replay when a sell is for more shares than
+// are held at the time.
+type OversoldError struct {
+	Index    int // index of the sell in the replayed transactions
+	Symbol   string
+	Quantity float64 // shares sold beyond those held
+}
+
+func (e *OversoldError) Error() string {
+	return fmt.Sprintf("sells %g more shares of %s than are held", e.Quantity, e.Symbol)
+}
+
+// openLot is a bought lot with shares still held.
+type openLot struct {
+	lot  storage.PortfolioLot
+	cost float64 // per share, including buy fees
+}
+
+// Replay works out the lots held, realized P&L and cash of a portfolio by
+// applying its transactions in date order, undated ones first. Buys of a
+// stock on the same day are merged into one lot and sells take shares from
+// the oldest lots first. The RealizedPnL of each sell in transactions is set.
+func Replay(transactions []storage.PortfolioTransaction) (*Ledger, error) {
+	order := make([]int, len(transactions))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return transactionTime(transactions[order[a]]).Before(transactionTime(transactions[order[b]]))
+	})
+
+	ledger := &Ledger{}
+	var keys []string
+	held := make(map[string][]openLot)
+	for _, i := range order {
+		t := &transactions[i]
+		if t.Source != storage.TransactionSourceImport {
+			ledger.Cash += t.Amount
+		}
+
+		switch t.Type {
+		case storage.TransactionDividend:
+			ledger.Dividends += t.Amount
+			continue
+		case storage.TransactionBuy, storage.TransactionSell:
+		default:
+			continue
+		}
+
+		key := holdingKey(t)
+		queue, seen := held[key]
+		if !seen {
+			keys = append(keys, key)
+		}
+
+		if t.Type == storage.TransactionBuy {
+			cost := t.Quantity*t.Price + t.Fees
+			if n := len(queue); n > 0 && sameDay(queue[n-1].lot.BuyDate, t.Date) {
+				last := &queue[n-1]
+				total := last.lot.Quantity*last.cost + cost
+				last.lot.Quantity += t.Quantity
+				last.cost = total / last.lot.Quantity
+				last.lot.AverageCost = last.cost
+			} else {
+				lot := storage.PortfolioLot{
+					Symbol:      t.Symbol,
+					Quantity:    t.Quantity,
+					AverageCost: cost / t.Quantity,
+					BuyDate:     t.Date,
+				}
+				if t.StockID != nil {
+					lot.StockID = *t.StockID
+				}
+				queue = append(queue, openLot{lot: lot, cost: lot.AverageCost})
+			}
+			held[key] = queue
+			continue
+		}
+
+		salePrice := t.Price - t.Fees/t.Quantity
+		remaining := t.Quantity
+		t.RealizedPnL = 0
+		for remaining > quantityEpsilon && len(queue) > 0 {
+			take := min(remaining, queue[0].lot.Quantity)
+			r := Realization{
+				Transaction: i,
+				StockID:     t.StockID,
+				Symbol:      t.Symbol,
+				Quantity:    take,
+				BuyDate:     queue[0].lot.BuyDate,
+				SellDate:    t.Date,
+				Cost:        take * queue[0].cost,
+				Proceeds:    take * salePrice,
+			}
+			r.Gain = r.Proceeds - r.Cost
+			ledger.Realizations = append(ledger.Realizations, r)
+			t.RealizedPnL += r.Gain
+
+			queue[0].lot.Quantity -= take
+			remaining -= take
+			if queue[0].lot.Quantity <= quantityEpsilon {
+				queue = queue[1:]
+			}
+		}
+		held[key] = queue
+		if remaining > quantityEpsilon {
+			return nil, &OversoldError{Index: i, Symbol: t.Symbol, Quantity: remaining}
+		}
+		ledger.RealizedPnL += t.RealizedPnL
+	}
+
+	for _, key := range keys {
+		for _, open := range held[key] {
+			ledger.Lots = append(ledger.Lots, open.lot)
+		}
+	}
+	return ledger, nil
+}
+
+// holdingKey identifies the stock of a buy or sell.
+func holdingKey(t *storage.PortfolioTransaction) string {
+	if t.StockID != nil {
+		return fmt.Sprintf("stock:%d", *t.StockID)
+	}
+	return "symbol:" + t.Symbol
+}
+
+// transactionTime returns the date of a transaction, or the zero time if it
+// has none.
+func transactionTime(t storage.PortfolioTransaction) time.Time {
+	if t.Date == nil {
+		return time.Time{}
+	}
+	return *t.Date
+}
+
+// sameDay reports whether two optional dates are the same day.
+func sameDay(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Format("2006-01-02") == b.Format("2006-01-02")
+}
+
+// setAmount sets the cash change of a transaction from its type, quantity,
+// price and fees. Dividends, deposits and withdrawals keep their amount,
+// made negative for withdrawals.
+func setAmount(t *storage.PortfolioTransaction) {
+	switch t.Type {
+	case storage.TransactionBuy:
+		t.Amount = -(t.Quantity*t.Price + t.Fees)
+	case storage.TransactionSell:
+		t.Amount = t.Quantity*t.Price - t.Fees
+	case storage.TransactionWithdrawal:
+		if t.Amount > 0 {
+			t.Amount = -t.Amount
+		}
+	}
+}
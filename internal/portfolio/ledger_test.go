@@ -0,0 +1,134 @@
+package portfolio
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// tradebookTransactions returns the trades of testdata/zerodha_tradebook.csv
+// as imported transactions.
+func tradebookTransactions(t *testing.T) []storage.PortfolioTransaction {
+	t.Helper()
+	_, trades, _, err := readExport(t, "zerodha_tradebook.csv", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var transactions []storage.PortfolioTransaction
+	for _, tr := range trades {
+		tx := storage.PortfolioTransaction{
+			Symbol:   tr.Symbol,
+			Type:     storage.TransactionBuy,
+			Quantity: tr.Quantity,
+			Price:    tr.Price,
+			Date:     tr.Date,
+			Source:   storage.TransactionSourceImport,
+		}
+		if tr.Sell {
+			tx.Type = storage.TransactionSell
+		}
+		setAmount(&tx)
+		transactions = append(transactions, tx)
+	}
+	return transactions
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestReplayFIFO(t *testing.T) {
+	transactions := tradebookTransactions(t)
+	ledger, err := Replay(transactions)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	// The two INFY buys of 12 Apr 2023 are one lot.
+	firstINFY := (10*1385.5 + 5*1388) / 15.0
+	wantLots := []storage.PortfolioLot{
+		{Symbol: "INFY", Quantity: 3, AverageCost: firstINFY, BuyDate: istDate(2023, 4, 12)},
+		{Symbol: "INFY", Quantity: 10, AverageCost: 1612, BuyDate: istDate(2024, 1, 15)},
+		{Symbol: "ITC", Quantity: 60, AverageCost: 438.2, BuyDate: istDate(2023, 6, 1)},
+	}
+	if len(ledger.Lots) != len(wantLots) {
+		t.Fatalf("got %d lots, want %d: %+v", len(ledger.Lots), len(wantLots), ledger.Lots)
+	}
+	for i, want := range wantLots {
+		got := ledger.Lots[i]
+		if got.Symbol != want.Symbol || !near(got.Quantity, want.Quantity) || !near(got.AverageCost, want.AverageCost) || !got.BuyDate.Equal(*want.BuyDate) {
+			t.Errorf("lot %d = %s %v @ %v bought %v, want %s %v @ %v bought %v", i,
+				got.Symbol, got.Quantity, got.AverageCost, got.BuyDate, want.Symbol, want.Quantity, want.AverageCost, want.BuyDate)
+		}
+	}
+
+	// The INFY sell takes all 12 shares from the oldest lot.
+	wantRealizations := []Realization{
+		{Transaction: 4, Symbol: "INFY", Quantity: 12, BuyDate: istDate(2023, 4, 12), SellDate: istDate(2024, 7, 22),
+			Cost: 12 * firstINFY, Proceeds: 12 * 1720.25, Gain: 12*1720.25 - 12*firstINFY},
+		{Transaction: 5, Symbol: "ITC", Quantity: 40, BuyDate: istDate(2023, 6, 1), SellDate: istDate(2024, 9, 3),
+			Cost: 40 * 438.2, Proceeds: 40 * 512.65, Gain: 40 * (512.65 - 438.2)},
+	}
+	if len(ledger.Realizations) != len(wantRealizations) {
+		t.Fatalf("got %d realizations, want %d", len(ledger.Realizations), len(wantRealizations))
+	}
+	for i, want := range wantRealizations {
+		got := ledger.Realizations[i]
+		if got.Transaction != want.Transaction || got.Symbol != want.Symbol || !near(got.Quantity, want.Quantity) ||
+			!got.BuyDate.Equal(*want.BuyDate) || !got.SellDate.Equal(*want.SellDate) ||
+			!near(got.Cost, want.Cost) || !near(got.Proceeds, want.Proceeds) || !near(got.Gain, want.Gain) {
+			t.Errorf("realization %d:\n got %+v\nwant %+v", i, got, want)
+		}
+		if !near(transactions[want.Transaction].RealizedPnL, want.Gain) {
+			t.Errorf("RealizedPnL of the %s sell = %v, want %v", want.Symbol, transactions[want.Transaction].RealizedPnL, want.Gain)
+		}
+	}
+	if want := wantRealizations[0].Gain + wantRealizations[1].Gain; !near(ledger.RealizedPnL, want) {
+		t.Errorf("RealizedPnL = %v, want %v", ledger.RealizedPnL, want)
+	}
+
+	// Imported trades do not move cash.
+	if ledger.Cash != 0 {
+		t.Errorf("Cash = %v, want 0", ledger.Cash)
+	}
+}
+
+func TestReplaySellAcrossLots(t *testing.T) {
+	transactions := tradebookTransactions(t)
+	// Sell 20 INFY instead of 12: 15 from the first lot, 5 from the second.
+	transactions[4].Quantity = 20
+	setAmount(&transactions[4])
+
+	ledger, err := Replay(transactions)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	var quantities []float64
+	for _, r := range ledger.Realizations {
+		if r.Symbol == "INFY" {
+			quantities = append(quantities, r.Quantity)
+		}
+	}
+	if len(quantities) != 2 || !near(quantities[0], 15) || !near(quantities[1], 5) {
+		t.Errorf("INFY sold from lots %v, want [15 5]", quantities)
+	}
+	if lot := ledger.Lots[0]; lot.Symbol != "INFY" || !near(lot.Quantity, 5) || !near(lot.AverageCost, 1612) {
+		t.Errorf("first lot = %s %v @ %v, want INFY 5 @ 1612", lot.Symbol, lot.Quantity, lot.AverageCost)
+	}
+}
+
+func TestReplayOversold(t *testing.T) {
+	transactions := tradebookTransactions(t)
+	transactions[5].Quantity = 150
+
+	_, err := Replay(transactions)
+	var oversold *OversoldError
+	if !errors.As(err, &oversold) {
+		t.Fatalf("err = %v, want an OversoldError", err)
+	}
+	if oversold.Index != 5 || oversold.Symbol != "ITC" || !near(oversold.Quantity, 50) {
+		t.Errorf("OversoldError = %+v, want index 5, 50 ITC", oversold)
+	}
+}
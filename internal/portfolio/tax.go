@@ -0,0 +1,581 @@
+package portfolio
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/stock-recommender/internal/calendar"
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// Capital gains terms.
+const (
+	TermShort   = "short"
+	TermLong    = "long"
+	TermUnknown = "unknown" // bought without a date, so the holding period is not known
+)
+
+// cessRate is the health and education cess charged on income tax, in
+// percent. Surcharge is not included.
+const cessRate = 4
+
+var (
+	// grandfatheringDate is the last day of the grandfathering of section
+	// 112A: shares bought on or before it count their cost as at least the
+	// highest price quoted that day.
+	grandfatheringDate = time.Date(2018, time.January, 31, 0, 0, 0, 0, calendar.IST)
+
+	// ltcgTaxedFrom is when long-term gains on listed equity became taxable
+	// again; sales before it were exempt under section 10(38).
+	ltcgTaxedFrom = time.Date(2018, time.April, 1, 0, 0, 0, 0, calendar.IST)
+
+	// budget2024Date is when the Finance (No. 2) Act, 2024 raised the rates
+	// to 20% short-term and 12.5% long-term.
+	budget2024Date = time.Date(2024, time.July, 23, 0, 0, 0, 0, calendar.IST)
+)
+
+// ErrInvalidFinancialYear is returned for a financial year that cannot be
+// parsed.
+var ErrInvalidFinancialYear = errors.New("invalid financial year")
+
+// taxRate returns the rate, in percent, at which a gain of the given term
+// on listed equity sold on sold is taxed (sections 111A and 112A).
+func taxRate(term string, sold time.Time) float64 {
+	switch {
+	case term == TermShort && sold.Before(budget2024Date):
+		return 15
+	case term == TermShort:
+		return 20
+	case sold.Before(ltcgTaxedFrom):
+		return 0
+	case sold.Before(budget2024Date):
+		return 10
+	default:
+		return 12.5
+	}
+}
+
+// ltcgExemption returns the long-term gains of a financial year, named by
+// the year it starts in, that are not taxed.
+func ltcgExemption(fy int) float64 {
+	switch {
+	case fy < 2018:
+		return 0
+	case fy < 2024:
+		return 100000
+	default:
+		return 125000
+	}
+}
+
+// FinancialYear returns the Indian financial year a date falls in, named by
+// the year it starts in: April 2024 to March 2025 is 2024.
+func FinancialYear(t time.Time) int {
+	t = t.In(calendar.IST)
+	if t.Month() < time.April {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
+
+// ParseFinancialYear parses a financial year written as "2024-25",
+// "2024-2025" or "2024".
+func ParseFinancialYear(s string) (int, error) {
+	s = strings.TrimSpace(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "FY"))
+	start, end, hasEnd := strings.Cut(s, "-")
+	year, err := strconv.Atoi(start)
+	if err != nil || year < 2000 || year > 2100 {
+		return 0, fmt.Errorf("%w %q, use e.g. 2024-25", ErrInvalidFinancialYear, s)
+	}
+	if hasEnd {
+		next := fmt.Sprintf("%d", year+1)
+		if end != next && end != next[2:] {
+			return 0, fmt.Errorf("%w %q, use e.g. 2024-25", ErrInvalidFinancialYear, s)
+		}
+	}
+	return year, nil
+}
+
+// financialYearLabel names a financial year as in "2024-25".
+func financialYearLabel(fy int) string {
+	return fmt.Sprintf("%d-%02d", fy, (fy+1)%100)
+}
+
+// financialYearBounds returns the first day of a financial year and of the
+// next.
+func financialYearBounds(fy int) (time.Time, time.Time) {
+	from := time.Date(fy, time.April, 1, 0, 0, 0, 0, calendar.IST)
+	return from, from.AddDate(1, 0, 0)
+}
+
+// GainLot is the gain on the shares of one bought lot sold in one sale.
+type GainLot struct {
+	Symbol            string     `json:"symbol"`
+	Quantity          float64    `json:"quantity"`
+	BuyDate           *time.Time `json:"buy_date,omitempty"`
+	SellDate          time.Time  `json:"sell_date"`
+	HoldingDays       int        `json:"holding_days"`
+	Term              string     `json:"term"`                        // short, long or unknown
+	Cost              float64    `json:"cost"`                        // price paid with fees
+	FairMarketValue   float64    `json:"fair_market_value,omitempty"` // on 31 Jan 2018, for grandfathered lots
+	CostOfAcquisition float64    `json:"cost_of_acquisition"`         // cost used for tax after grandfathering
+	Proceeds          float64    `json:"proceeds"`                    // sale value less fees
+	Gain              float64    `json:"gain"`                        // proceeds less cost of acquisition
+	Rate              float64    `json:"rate"`                        // percent
+}
+
+// TaxBucket totals the gains taxed at one rate.
+type TaxBucket struct {
+	Term    string  `json:"term"`
+	Rate    float64 `json:"rate"` // percent
+	Gains   float64 `json:"gains"`
+	SetOff  float64 `json:"set_off"` // losses set off against the gains
+	Exempt  float64 `json:"exempt"`  // long-term exemption used
+	Taxable float64 `json:"taxable"`
+	Tax     float64 `json:"tax"` // before cess
+}
+
+// TaxComputation is the tax on a financial year's capital gains.
+type TaxComputation struct {
+	ShortTermGains   float64     `json:"short_term_gains"`
+	ShortTermLosses  float64     `json:"short_term_losses"`
+	LongTermGains    float64     `json:"long_term_gains"`
+	LongTermLosses   float64     `json:"long_term_losses"`
+	Exemption        float64     `json:"exemption"` // long-term exemption of the year
+	Buckets          []TaxBucket `json:"buckets"`
+	UnusedLosses     float64     `json:"unused_losses"` // left to carry forward
+	Tax              float64     `json:"tax"`
+	Cess             float64     `json:"cess"`
+	TotalTax         float64     `json:"total_tax"`
+	TaxableShortTerm float64     `json:"taxable_short_term"`
+	TaxableLongTerm  float64     `json:"taxable_long_term"`
+}
+
+// computeTax works out the tax on the gains of a financial year. Short-term
+// losses are set off against short-term and then long-term gains, long-term
+// losses against long-term gains only, and the long-term exemption after
+// that; both go first to the gains taxed at the highest rate. Lots of
+// unknown term are left out.
+func computeTax(lots []GainLot, fy int) TaxComputation {
+	tc := TaxComputation{Exemption: ltcgExemption(fy), Buckets: []TaxBucket{}}
+
+	buckets := make(map[string]*TaxBucket)
+	for _, l := range lots {
+		if l.Term == TermUnknown {
+			continue
+		}
+		if l.Gain < 0 {
+			if l.Term == TermShort {
+				tc.ShortTermLosses -= l.Gain
+			} else {
+				tc.LongTermLosses -= l.Gain
+			}
+			continue
+		}
+		if l.Term == TermShort {
+			tc.ShortTermGains += l.Gain
+		} else {
+			tc.LongTermGains += l.Gain
+		}
+
+		key := fmt.Sprintf("%s:%g", l.Term, l.Rate)
+		b, ok := buckets[key]
+		if !ok {
+			b = &TaxBucket{Term: l.Term, Rate: l.Rate}
+			buckets[key] = b
+		}
+		b.Gains += l.Gain
+	}
+	for _, b := range buckets {
+		b.Taxable = b.Gains
+		tc.Buckets = append(tc.Buckets, *b)
+	}
+	sort.Slice(tc.Buckets, func(i, j int) bool {
+		if tc.Buckets[i].Term != tc.Buckets[j].Term {
+			return tc.Buckets[i].Term == TermShort
+		}
+		return tc.Buckets[i].Rate > tc.Buckets[j].Rate
+	})
+
+	// offset takes up to amount from the taxable gains of the buckets of
+	// the given terms, highest rate first, and returns what is left.
+	offset := func(amount float64, exempt bool, terms ...string) float64 {
+		order := make([]int, 0, len(tc.Buckets))
+		for i, b := range tc.Buckets {
+			for _, term := range terms {
+				if b.Term == term {
+					order = append(order, i)
+				}
+			}
+		}
+		sort.SliceStable(order, func(a, b int) bool { return tc.Buckets[order[a]].Rate > tc.Buckets[order[b]].Rate })
+		for _, i := range order {
+			b := &tc.Buckets[i]
+			take := min(amount, b.Taxable)
+			b.Taxable -= take
+			if exempt {
+				b.Exempt += take
+			} else {
+				b.SetOff += take
+			}
+			amount -= take
+		}
+		return amount
+	}
+
+	unusedShort := offset(tc.ShortTermLosses, false, TermShort)
+	unusedShort = offset(unusedShort, false, TermLong)
+	unusedLong := offset(tc.LongTermLosses, false, TermLong)
+	tc.UnusedLosses = unusedShort + unusedLong
+	offset(tc.Exemption, true, TermLong)
+
+	for i := range tc.Buckets {
+		b := &tc.Buckets[i]
+		b.Tax = b.Taxable * b.Rate / 100
+		tc.Tax += b.Tax
+		if b.Term == TermShort {
+			tc.TaxableShortTerm += b.Taxable
+		} else {
+			tc.TaxableLongTerm += b.Taxable
+		}
+	}
+	tc.Cess = tc.Tax * cessRate / 100
+	tc.TotalTax = tc.Tax + tc.Cess
+	return tc
+}
+
+// TaxReport is the capital gains of a portfolio in a financial year.
+type TaxReport struct {
+	PortfolioID   uint      `json:"portfolio_id"`
+	FinancialYear string    `json:"financial_year"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"` // last day of the year
+	Lots          []GainLot `json:"lots"`
+	TaxComputation
+	Warnings []string `json:"warnings"`
+}
+
+// gainCalculator turns realized lots into gains, looking up the fair market
+// value of grandfathered stocks.
+type gainCalculator struct {
+	repo     *storage.Repository
+	fmv      map[uint]float64
+	warnings []string
+	warned   map[string]bool
+}
+
+func newGainCalculator(repo *storage.Repository) *gainCalculator {
+	return &gainCalculator{repo: repo, fmv: make(map[uint]float64), warned: make(map[string]bool)}
+}
+
+// warn records a warning once.
+func (gc *gainCalculator) warn(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if !gc.warned[msg] {
+		gc.warned[msg] = true
+		gc.warnings = append(gc.warnings, msg)
+	}
+}
+
+// gain works out the gain on shares of a lot bought on bought at cost, sold
+// on sold for proceeds.
+func (gc *gainCalculator) gain(ctx context.Context, stockID *uint, symbol string, quantity float64, bought *time.Time, sold time.Time, cost, proceeds float64) (GainLot, error) {
+	l := GainLot{
+		Symbol:            symbol,
+		Quantity:          quantity,
+		BuyDate:           bought,
+		SellDate:          sold,
+		Cost:              cost,
+		CostOfAcquisition: cost,
+		Proceeds:          proceeds,
+	}
+
+	if bought == nil {
+		l.Term = TermUnknown
+		l.Gain = proceeds - cost
+		gc.warn("%s was bought without a date, so its gains are left out", symbol)
+		return l, nil
+	}
+
+	l.HoldingDays = int(sold.Sub(*bought).Hours() / 24)
+	l.Term = TermShort
+	if dateOnly(sold).After(dateOnly(bought.AddDate(1, 0, 0))) {
+		l.Term = TermLong
+	}
+	l.Rate = taxRate(l.Term, sold)
+
+	if l.Term == TermLong && !dateOnly(*bought).After(grandfatheringDate) && !sold.Before(ltcgTaxedFrom) {
+		fmv, err := gc.fairMarketValue(ctx, stockID)
+		if err != nil {
+			return l, err
+		}
+		if fmv > 0 {
+			l.FairMarketValue = fmv * quantity
+			l.CostOfAcquisition = max(cost, min(l.FairMarketValue, proceeds))
+		} else {
+			gc.warn("%s was bought before 1 Feb 2018 but has no 31 Jan 2018 price set, so its cost is not grandfathered", symbol)
+		}
+	}
+
+	l.Gain = proceeds - l.CostOfAcquisition
+	return l, nil
+}
+
+// fairMarketValue returns a stock's highest price on 31 Jan 2018, or 0.
+func (gc *gainCalculator) fairMarketValue(ctx context.Context, stockID *uint) (float64, error) {
+	if stockID == nil {
+		return 0, nil
+	}
+	if fmv, ok := gc.fmv[*stockID]; ok {
+		return fmv, nil
+	}
+	stock, err := gc.repo.GetStockByID(ctx, *stockID)
+	if err != nil {
+		return 0, err
+	}
+	var fmv float64
+	if stock != nil {
+		fmv = stock.FMV2018
+	}
+	gc.fmv[*stockID] = fmv
+	return fmv, nil
+}
+
+// dateOnly returns midnight IST of a time's day, so holding periods count
+// calendar days.
+func dateOnly(t time.Time) time.Time {
+	t = t.In(calendar.IST)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, calendar.IST)
+}
+
+// realizedGains returns the gains of the sales in a ledger that fall in a
+// financial year, in sale order.
+func (gc *gainCalculator) realizedGains(ctx context.Context, ledger *Ledger, fy int) ([]GainLot, error) {
+	from, to := financialYearBounds(fy)
+	lots := []GainLot{}
+	for _, r := range ledger.Realizations {
+		if r.SellDate == nil {
+			gc.warn("a sale of %s has no date, so it is left out", r.Symbol)
+			continue
+		}
+		if r.SellDate.Before(from) || !r.SellDate.Before(to) {
+			continue
+		}
+		l, err := gc.gain(ctx, r.StockID, r.Symbol, r.Quantity, r.BuyDate, *r.SellDate, r.Cost, r.Proceeds)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, l)
+	}
+	return lots, nil
+}
+
+// TaxReport works out the capital gains of a portfolio's sales in a
+// financial year, named by the year it starts in, matching each sale to the
+// lots it sold first in first out.
+func (tr *Tracker) TaxReport(ctx context.Context, p *storage.Portfolio, fy int) (*TaxReport, error) {
+	transactions, err := tr.repo.ListPortfolioTransactions(ctx, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := Replay(transactions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay transactions: %w", err)
+	}
+
+	gc := newGainCalculator(tr.repo)
+	lots, err := gc.realizedGains(ctx, ledger, fy)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := financialYearBounds(fy)
+	report := &TaxReport{
+		PortfolioID:    p.ID,
+		FinancialYear:  financialYearLabel(fy),
+		From:           from,
+		To:             to.AddDate(0, 0, -1),
+		Lots:           lots,
+		TaxComputation: computeTax(lots, fy),
+		Warnings:       gc.warnings,
+	}
+	if report.Warnings == nil {
+		report.Warnings = []string{}
+	}
+	return report, nil
+}
+
+// SellTaxImpact is the tax of selling shares of a holding now, on top of the
+// gains already realized in the financial year.
+type SellTaxImpact struct {
+	PortfolioID   uint      `json:"portfolio_id"`
+	Portfolio     string    `json:"portfolio"`
+	Symbol        string    `json:"symbol"`
+	Quantity      float64   `json:"quantity"`
+	Price         float64   `json:"price"`
+	Proceeds      float64   `json:"proceeds"`
+	Gain          float64   `json:"gain"`
+	ShortTermGain float64   `json:"short_term_gain"`
+	LongTermGain  float64   `json:"long_term_gain"`
+	Tax           float64   `json:"tax"` // extra tax for the year, cess included
+	FinancialYear string    `json:"financial_year"`
+	Lots          []GainLot `json:"lots"`
+	Warnings      []string  `json:"warnings"`
+}
+
+// SellTaxImpact works out the tax of selling quantity shares of a stock held
+// in a portfolio at price today, or all of them if quantity is 0: the tax of
+// the financial year with the sale less the tax without it.
+func (tr *Tracker) SellTaxImpact(ctx context.Context, p *storage.Portfolio, stockID uint, quantity, price float64) (*SellTaxImpact, error) {
+	transactions, err := tr.repo.ListPortfolioTransactions(ctx, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := Replay(transactions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay transactions: %w", err)
+	}
+	return tr.sellTaxImpact(ctx, p, ledger, stockID, quantity, price, time.Now())
+}
+
+// sellTaxImpact is SellTaxImpact for a replayed ledger.
+func (tr *Tracker) sellTaxImpact(ctx context.Context, p *storage.Portfolio, ledger *Ledger, stockID uint, quantity, price float64, now time.Time) (*SellTaxImpact, error) {
+	var held float64
+	var symbol string
+	for _, lot := range ledger.Lots {
+		if lot.StockID == stockID {
+			held += lot.Quantity
+			symbol = lot.Symbol
+		}
+	}
+	if held == 0 {
+		return nil, fmt.Errorf("%w: the portfolio does not hold this stock", ErrInvalidTransaction)
+	}
+	if quantity <= 0 {
+		quantity = held
+	}
+	if quantity > held+quantityEpsilon {
+		return nil, fmt.Errorf("%w: only %g shares of %s are held", ErrInvalidTransaction, held, symbol)
+	}
+	if price <= 0 {
+		return nil, fmt.Errorf("%w: no price to sell %s at", ErrInvalidTransaction, symbol)
+	}
+
+	fy := FinancialYear(now)
+	gc := newGainCalculator(tr.repo)
+	realized, err := gc.realizedGains(ctx, ledger, fy)
+	if err != nil {
+		return nil, err
+	}
+
+	impact := &SellTaxImpact{
+		PortfolioID:   p.ID,
+		Portfolio:     p.Name,
+		Symbol:        symbol,
+		Quantity:      quantity,
+		Price:         price,
+		Proceeds:      quantity * price,
+		FinancialYear: financialYearLabel(fy),
+		Lots:          []GainLot{},
+	}
+
+	id := stockID
+	remaining := quantity
+	for _, lot := range ledger.Lots {
+		if lot.StockID != stockID || remaining <= quantityEpsilon {
+			continue
+		}
+		take := min(remaining, lot.Quantity)
+		remaining -= take
+		l, err := gc.gain(ctx, &id, symbol, take, lot.BuyDate, now, take*lot.AverageCost, take*price)
+		if err != nil {
+			return nil, err
+		}
+		impact.Lots = append(impact.Lots, l)
+		impact.Gain += l.Gain
+		switch l.Term {
+		case TermShort:
+			impact.ShortTermGain += l.Gain
+		case TermLong:
+			impact.LongTermGain += l.Gain
+		}
+	}
+
+	before := computeTax(realized, fy)
+	after := computeTax(append(realized, impact.Lots...), fy)
+	impact.Tax = after.TotalTax - before.TotalTax
+	impact.Warnings = gc.warnings
+	if impact.Warnings == nil {
+		impact.Warnings = []string{}
+	}
+	return impact, nil
+}
+
+// WriteTaxReportCSV writes a tax report as CSV: a row per sold lot, then
+// the totals and tax of the year.
+func WriteTaxReportCSV(w io.Writer, report *TaxReport) error {
+	cw := csv.NewWriter(w)
+	money := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	date := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.In(calendar.IST).Format("2006-01-02")
+	}
+
+	records := [][]string{{
+		"Symbol", "Quantity", "Buy Date", "Sell Date", "Holding Days", "Term",
+		"Cost", "FMV 31-Jan-2018", "Cost of Acquisition", "Sale Value", "Gain", "Rate %",
+	}}
+	for _, l := range report.Lots {
+		fmv := ""
+		if l.FairMarketValue > 0 {
+			fmv = money(l.FairMarketValue)
+		}
+		records = append(records, []string{
+			l.Symbol,
+			strconv.FormatFloat(l.Quantity, 'f', -1, 64),
+			date(l.BuyDate),
+			date(&l.SellDate),
+			strconv.Itoa(l.HoldingDays),
+			l.Term,
+			money(l.Cost),
+			fmv,
+			money(l.CostOfAcquisition),
+			money(l.Proceeds),
+			money(l.Gain),
+			strconv.FormatFloat(l.Rate, 'f', -1, 64),
+		})
+	}
+
+	records = append(records,
+		[]string{},
+		[]string{"Financial Year", report.FinancialYear},
+		[]string{"Short-term Gains", money(report.ShortTermGains)},
+		[]string{"Short-term Losses", money(report.ShortTermLosses)},
+		[]string{"Long-term Gains", money(report.LongTermGains)},
+		[]string{"Long-term Losses", money(report.LongTermLosses)},
+		[]string{"Long-term Exemption", money(report.Exemption)},
+		[]string{"Taxable Short-term", money(report.TaxableShortTerm)},
+		[]string{"Taxable Long-term", money(report.TaxableLongTerm)},
+		[]string{"Losses to Carry Forward", money(report.UnusedLosses)},
+		[]string{"Tax", money(report.Tax)},
+		[]string{"Cess", money(report.Cess)},
+		[]string{"Total Tax", money(report.TotalTax)},
+	)
+	for _, warning := range report.Warnings {
+		records = append(records, []string{"Warning", warning})
+	}
+
+	if err := cw.WriteAll(records); err != nil {
+		return fmt.Errorf("failed to write tax report: %w", err)
+	}
+	return nil
+}
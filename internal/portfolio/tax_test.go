@@ -0,0 +1,251 @@
+package portfolio
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGainTerm(t *testing.T) {
+	tests := []struct {
+		name     string
+		bought   *time.Time
+		sold     *time.Time
+		wantTerm string
+		wantRate float64
+	}{
+		{"exactly twelve months", istDate(2023, 7, 22), istDate(2024, 7, 22), TermShort, 15},
+		{"a day over twelve months", istDate(2023, 7, 22), istDate(2024, 7, 23), TermLong, 12.5},
+		{"short after the 2024 budget", istDate(2023, 7, 23), istDate(2024, 7, 23), TermShort, 20},
+		{"long before the 2024 budget", istDate(2023, 1, 10), istDate(2024, 7, 22), TermLong, 10},
+		{"long before section 112A", istDate(2016, 1, 1), istDate(2018, 3, 31), TermLong, 0},
+		{"no buy date", nil, istDate(2024, 7, 23), TermUnknown, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gc := newGainCalculator(nil)
+			l, err := gc.gain(context.Background(), nil, "INFY", 10, tt.bought, *tt.sold, 1000, 1500)
+			if err != nil {
+				t.Fatalf("gain: %v", err)
+			}
+			if l.Term != tt.wantTerm || l.Rate != tt.wantRate {
+				t.Errorf("term %s at %v%%, want %s at %v%%", l.Term, l.Rate, tt.wantTerm, tt.wantRate)
+			}
+			if l.Gain != 500 {
+				t.Errorf("Gain = %v, want 500", l.Gain)
+			}
+			if tt.bought == nil && len(gc.warnings) != 1 {
+				t.Errorf("warnings = %q, want one about the missing date", gc.warnings)
+			}
+		})
+	}
+}
+
+func TestGainGrandfathered(t *testing.T) {
+	stockID := uint(7)
+	tests := []struct {
+		name     string
+		fmv      float64 // per share on 31 Jan 2018
+		proceeds float64
+		wantCost float64 // cost of acquisition
+	}{
+		{"fair value above cost", 150, 2000, 1500},
+		{"proceeds below fair value", 150, 1200, 1200},
+		{"fair value below cost", 80, 2000, 1000},
+		{"no fair value", 0, 2000, 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gc := newGainCalculator(nil)
+			gc.fmv[stockID] = tt.fmv
+			l, err := gc.gain(context.Background(), &stockID, "INFY", 10, istDate(2017, 5, 1), *istDate(2019, 6, 1), 1000, tt.proceeds)
+			if err != nil {
+				t.Fatalf("gain: %v", err)
+			}
+			if l.CostOfAcquisition != tt.wantCost || l.Gain != tt.proceeds-tt.wantCost {
+				t.Errorf("cost of acquisition %v, gain %v, want %v, %v", l.CostOfAcquisition, l.Gain, tt.wantCost, tt.proceeds-tt.wantCost)
+			}
+			if (tt.fmv == 0) != (len(gc.warnings) == 1) {
+				t.Errorf("warnings = %q", gc.warnings)
+			}
+		})
+	}
+}
+
+func TestComputeTax(t *testing.T) {
+	gain := func(term string, rate, amount float64) GainLot {
+		return GainLot{Term: term, Rate: rate, Gain: amount}
+	}
+
+	tests := []struct {
+		name          string
+		fy            int
+		lots          []GainLot
+		wantExemption float64
+		wantTaxable   float64
+		wantTax       float64
+		wantUnused    float64
+	}{
+		{
+			name:          "long-term over the 2024 exemption",
+			fy:            2024,
+			lots:          []GainLot{gain(TermLong, 12.5, 200000)},
+			wantExemption: 125000,
+			wantTaxable:   75000,
+			wantTax:       9375,
+		},
+		{
+			name:          "long-term over the 2023 exemption",
+			fy:            2023,
+			lots:          []GainLot{gain(TermLong, 10, 150000)},
+			wantExemption: 100000,
+			wantTaxable:   50000,
+			wantTax:       5000,
+		},
+		{
+			// The exemption goes to the gains at 12.5% first.
+			name: "both long-term rates in 2024",
+			fy:   2024,
+			lots: []GainLot{
+				gain(TermShort, 20, 50000),
+				gain(TermLong, 10, 60000),
+				gain(TermLong, 12.5, 100000),
+			},
+			wantExemption: 125000,
+			wantTaxable:   50000 + 35000,
+			wantTax:       10000 + 3500,
+		},
+		{
+			// Short-term losses left over are set off against long-term
+			// gains.
+			name: "short-term loss",
+			fy:   2024,
+			lots: []GainLot{
+				gain(TermShort, 20, -30000),
+				gain(TermShort, 20, 20000),
+				gain(TermLong, 12.5, 150000),
+			},
+			wantExemption: 125000,
+			wantTaxable:   15000,
+			wantTax:       1875,
+		},
+		{
+			// Long-term losses are not set off against short-term gains.
+			name: "long-term loss",
+			fy:   2024,
+			lots: []GainLot{
+				gain(TermLong, 12.5, -10000),
+				gain(TermShort, 20, 10000),
+			},
+			wantExemption: 125000,
+			wantTaxable:   10000,
+			wantTax:       2000,
+			wantUnused:    10000,
+		},
+		{
+			name:          "unknown term",
+			fy:            2024,
+			lots:          []GainLot{gain(TermUnknown, 0, 50000)},
+			wantExemption: 125000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := computeTax(tt.lots, tt.fy)
+			if tc.Exemption != tt.wantExemption {
+				t.Errorf("Exemption = %v, want %v", tc.Exemption, tt.wantExemption)
+			}
+			if taxable := tc.TaxableShortTerm + tc.TaxableLongTerm; taxable != tt.wantTaxable {
+				t.Errorf("taxable = %v, want %v", taxable, tt.wantTaxable)
+			}
+			if tc.Tax != tt.wantTax || tc.Cess != tt.wantTax*0.04 || tc.TotalTax != tt.wantTax*1.04 {
+				t.Errorf("tax %v, cess %v, total %v, want %v plus 4%% cess", tc.Tax, tc.Cess, tc.TotalTax, tt.wantTax)
+			}
+			if tc.UnusedLosses != tt.wantUnused {
+				t.Errorf("UnusedLosses = %v, want %v", tc.UnusedLosses, tt.wantUnused)
+			}
+		})
+	}
+}
+
+func TestTradebookTax(t *testing.T) {
+	ledger, err := Replay(tradebookTransactions(t))
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	lots, err := newGainCalculator(nil).realizedGains(context.Background(), ledger, 2024)
+	if err != nil {
+		t.Fatalf("realizedGains: %v", err)
+	}
+
+	// INFY was sold a day before the 2024 budget, ITC after it; both
+	// were held over a year.
+	if len(lots) != 2 {
+		t.Fatalf("got %d lots, want 2", len(lots))
+	}
+	if lots[0].Symbol != "INFY" || lots[0].Term != TermLong || lots[0].Rate != 10 {
+		t.Errorf("INFY sale = %s %s at %v%%", lots[0].Symbol, lots[0].Term, lots[0].Rate)
+	}
+	if lots[1].Symbol != "ITC" || lots[1].Term != TermLong || lots[1].Rate != 12.5 {
+		t.Errorf("ITC sale = %s %s at %v%%", lots[1].Symbol, lots[1].Term, lots[1].Rate)
+	}
+
+	// The gains are within the exemption.
+	if tc := computeTax(lots, 2024); tc.Tax != 0 || !near(tc.LongTermGains, lots[0].Gain+lots[1].Gain) {
+		t.Errorf("tax %v on long-term gains of %v", tc.Tax, tc.LongTermGains)
+	}
+
+	// Neither sale is in the previous year.
+	if lots, _ := newGainCalculator(nil).realizedGains(context.Background(), ledger, 2023); len(lots) != 0 {
+		t.Errorf("got %d lots in 2023-24", len(lots))
+	}
+}
+
+func TestFinancialYear(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want int
+	}{
+		{*istDate(2025, 3, 31), 2024},
+		{*istDate(2025, 4, 1), 2025},
+		// 00:30 on 1 April in IST.
+		{time.Date(2025, time.March, 31, 19, 0, 0, 0, time.UTC), 2025},
+	}
+	for _, tt := range tests {
+		if got := FinancialYear(tt.t); got != tt.want {
+			t.Errorf("FinancialYear(%v) = %d, want %d", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestParseFinancialYear(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    int
+		wantErr bool
+	}{
+		{"2024-25", 2024, false},
+		{"2024-2025", 2024, false},
+		{"FY2024-25", 2024, false},
+		{"2024", 2024, false},
+		{"2024-26", 0, true},
+		{"24-25", 0, true},
+		{"next year", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFinancialYear(tt.s)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidFinancialYear) {
+				t.Errorf("ParseFinancialYear(%q) err = %v, want ErrInvalidFinancialYear", tt.s, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseFinancialYear(%q) = %d, %v, want %d", tt.s, got, err, tt.want)
+		}
+	}
+}
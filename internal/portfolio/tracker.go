@@ -0,0 +1,448 @@
+package portfolio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/stock-recommender/internal/calendar"
+	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/pkg/textutil"
+)
+
+// ErrInvalidTransaction wraps errors caused by a transaction that cannot be
+// recorded.
+var ErrInvalidTransaction = errors.New("invalid transaction")
+
+// TransactionInput is a transaction to record in a portfolio.
+type TransactionInput struct {
+	Type     string  // buy, sell, dividend, deposit, withdrawal
+	Symbol   string  // required for buys and sells, optional for dividends
+	Quantity float64 // buys and sells
+	Price    float64 // buys and sells, per share
+	Fees     float64 // buys and sells
+	Amount   float64 // dividends, deposits and withdrawals
+	Date     string  // YYYY-MM-DD or any format of broker exports; today if empty
+	Notes    string
+}
+
+// Tracker records portfolio transactions and values portfolios.
+type Tracker struct {
+	repo *storage.Repository
+}
+
+// NewTracker creates a portfolio tracker.
+func NewTracker(repo *storage.Repository) *Tracker {
+	return &Tracker{repo: repo}
+}
+
+// Create creates an empty portfolio, with a deposit of cash if it is
+// positive.
+func (tr *Tracker) Create(ctx context.Context, name string, cash float64) (*storage.Portfolio, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidTransaction)
+	}
+	if cash < 0 {
+		return nil, fmt.Errorf("%w: cash cannot be negative", ErrInvalidTransaction)
+	}
+
+	p := &storage.Portfolio{Name: name, Cash: cash}
+	if cash > 0 {
+		now := time.Now().In(calendar.IST)
+		p.Transactions = []storage.PortfolioTransaction{{
+			Type:   storage.TransactionDeposit,
+			Amount: cash,
+			Date:   &now,
+			Source: storage.TransactionSourceManual,
+			Notes:  "Opening cash",
+		}}
+	}
+	if err := tr.repo.CreatePortfolio(ctx, p); err != nil {
+		return nil, fmt.Errorf("failed to create portfolio: %w", err)
+	}
+	return p, nil
+}
+
+// AddTransaction records a transaction in a portfolio and updates its lots,
+// realized P&L and cash. A sell of more shares than are held at its date is
+// rejected.
+func (tr *Tracker) AddTransaction(ctx context.Context, p *storage.Portfolio, input TransactionInput) (*storage.PortfolioTransaction, error) {
+	tx, err := tr.newTransaction(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := tr.repo.ListPortfolioTransactions(ctx, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	transactions = append(transactions, *tx)
+	if err := tr.save(ctx, p, transactions); err != nil {
+		return nil, err
+	}
+	return &transactions[len(transactions)-1], nil
+}
+
+// DeleteTransaction removes a transaction from a portfolio and updates its
+// lots, realized P&L and cash. It reports false if the portfolio has no such
+// transaction, and fails if removing a buy would leave a later sell
+// unmatched.
+func (tr *Tracker) DeleteTransaction(ctx context.Context, p *storage.Portfolio, id uint) (bool, error) {
+	transactions, err := tr.repo.ListPortfolioTransactions(ctx, p.ID)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range transactions {
+		if transactions[i].ID == id {
+			transactions = append(transactions[:i], transactions[i+1:]...)
+			return true, tr.save(ctx, p, transactions)
+		}
+	}
+	return false, nil
+}
+
+// save replays transactions and stores them with the lots and cash they
+// leave.
+func (tr *Tracker) save(ctx context.Context, p *storage.Portfolio, transactions []storage.PortfolioTransaction) error {
+	ledger, err := Replay(transactions)
+	if err != nil {
+		var oversold *OversoldError
+		if errors.As(err, &oversold) {
+			return fmt.Errorf("%w: %v", ErrInvalidTransaction, err)
+		}
+		return err
+	}
+
+	p.Cash = ledger.Cash
+	if err := tr.repo.SavePortfolioLedger(ctx, p, transactions, ledger.Lots); err != nil {
+		return fmt.Errorf("failed to save portfolio: %w", err)
+	}
+	p.Lots = ledger.Lots
+	return nil
+}
+
+// newTransaction validates input and resolves its stock.
+func (tr *Tracker) newTransaction(ctx context.Context, input TransactionInput) (*storage.PortfolioTransaction, error) {
+	tx := &storage.PortfolioTransaction{
+		Type:     strings.ToLower(strings.TrimSpace(input.Type)),
+		Quantity: input.Quantity,
+		Price:    input.Price,
+		Fees:     input.Fees,
+		Amount:   input.Amount,
+		Source:   storage.TransactionSourceManual,
+		Notes:    textutil.Truncate(strings.TrimSpace(input.Notes), 255),
+	}
+
+	date := time.Now().In(calendar.IST)
+	if s := strings.TrimSpace(input.Date); s != "" {
+		parsed, err := parseDate(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTransaction, err)
+		}
+		if parsed.After(date) {
+			return nil, fmt.Errorf("%w: date %s is in the future", ErrInvalidTransaction, s)
+		}
+		date = parsed
+	}
+	tx.Date = &date
+
+	switch tx.Type {
+	case storage.TransactionBuy, storage.TransactionSell:
+		if tx.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: quantity must be positive", ErrInvalidTransaction)
+		}
+		if tx.Price <= 0 {
+			return nil, fmt.Errorf("%w: price must be positive", ErrInvalidTransaction)
+		}
+		if tx.Fees < 0 {
+			return nil, fmt.Errorf("%w: fees cannot be negative", ErrInvalidTransaction)
+		}
+		if strings.TrimSpace(input.Symbol) == "" {
+			return nil, fmt.Errorf("%w: symbol is required", ErrInvalidTransaction)
+		}
+	case storage.TransactionDividend, storage.TransactionDeposit, storage.TransactionWithdrawal:
+		if tx.Amount <= 0 {
+			return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidTransaction)
+		}
+		tx.Quantity, tx.Price, tx.Fees = 0, 0, 0
+	default:
+		return nil, fmt.Errorf("%w: unknown type %q, use buy, sell, dividend, deposit or withdrawal", ErrInvalidTransaction, input.Type)
+	}
+
+	if symbol := normalizeSymbol(input.Symbol); symbol != "" && tx.Type != storage.TransactionDeposit && tx.Type != storage.TransactionWithdrawal {
+		stock, err := tr.repo.GetStockBySymbol(ctx, symbol)
+		if err != nil {
+			return nil, err
+		}
+		if stock == nil {
+			return nil, fmt.Errorf("%w: stock %s is not tracked", ErrInvalidTransaction, symbol)
+		}
+		tx.StockID = &stock.ID
+		tx.Symbol = stock.Symbol
+	}
+
+	setAmount(tx)
+	return tx, nil
+}
+
+// Conflict types.
+const (
+	ConflictSellSignal = "sell_signal"
+	ConflictStopLoss   = "stop_loss_breached"
+)
+
+// Conflict is a recommendation at odds with holding a stock.
+type Conflict struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// Holding is a stock held in a portfolio, valued at its latest stored price.
+type Holding struct {
+	StockID        uint                    `json:"stock_id"`
+	Symbol         string                  `json:"symbol"`
+	Name           string                  `json:"name"`
+	Sector         string                  `json:"sector"`
+	Quantity       float64                 `json:"quantity"`
+	AverageCost    float64                 `json:"average_cost"`
+	Invested       float64                 `json:"invested"`
+	Price          float64                 `json:"price"`
+	PriceAsOf      *time.Time              `json:"price_as_of,omitempty"`
+	PriceMissing   bool                    `json:"price_missing"` // valued at cost, no price is stored
+	Value          float64                 `json:"value"`
+	UnrealizedPnL  float64                 `json:"unrealized_pnl"`
+	UnrealizedPct  float64                 `json:"unrealized_pct"`
+	Weight         float64                 `json:"weight"` // percent of the market value of holdings
+	Recommendation *storage.Recommendation `json:"recommendation,omitempty"`
+	Conflicts      []Conflict              `json:"conflicts"`
+	SellTax        *SellTaxImpact          `json:"sell_tax,omitempty"` // tax of acting on an active SELL
+}
+
+// SectorAllocation is the part of a portfolio's holdings in one sector.
+type SectorAllocation struct {
+	Sector   string  `json:"sector"`
+	Value    float64 `json:"value"`
+	Weight   float64 `json:"weight"` // percent
+	Holdings int     `json:"holdings"`
+}
+
+// Summary values a portfolio and its holdings.
+type Summary struct {
+	Portfolio     *storage.Portfolio `json:"portfolio"`
+	Holdings      []Holding          `json:"holdings"`
+	Allocation    []SectorAllocation `json:"allocation"`
+	Cash          float64            `json:"cash"`
+	Invested      float64            `json:"invested"`
+	MarketValue   float64            `json:"market_value"`
+	TotalValue    float64            `json:"total_value"` // market value plus cash
+	UnrealizedPnL float64            `json:"unrealized_pnl"`
+	UnrealizedPct float64            `json:"unrealized_pct"`
+	RealizedPnL   float64            `json:"realized_pnl"`
+	Dividends     float64            `json:"dividends"`
+	XIRR          *float64           `json:"xirr,omitempty"` // percent a year
+	XIRRNote      string             `json:"xirr_note,omitempty"`
+	Conflicts     int                `json:"conflicts"`
+	AsOf          time.Time          `json:"as_of"`
+}
+
+// Summary values a portfolio's holdings at the latest stored price of each
+// stock, works out its realized P&L and XIRR from its transactions and
+// checks each holding against the stock's latest recommendation, with the
+// tax of selling it when that is an active SELL.
+func (tr *Tracker) Summary(ctx context.Context, p *storage.Portfolio) (*Summary, error) {
+	transactions, err := tr.repo.ListPortfolioTransactions(ctx, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := Replay(transactions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay transactions: %w", err)
+	}
+
+	now := time.Now()
+	summary := &Summary{
+		Portfolio:   p,
+		Holdings:    []Holding{},
+		Allocation:  []SectorAllocation{},
+		Cash:        ledger.Cash,
+		RealizedPnL: ledger.RealizedPnL,
+		Dividends:   ledger.Dividends,
+		AsOf:        now,
+	}
+
+	var order []uint
+	holdings := make(map[uint]*Holding)
+	for _, lot := range ledger.Lots {
+		h, ok := holdings[lot.StockID]
+		if !ok {
+			h = &Holding{StockID: lot.StockID, Symbol: lot.Symbol, Conflicts: []Conflict{}}
+			holdings[lot.StockID] = h
+			order = append(order, lot.StockID)
+		}
+		h.Quantity += lot.Quantity
+		h.Invested += lot.Quantity * lot.AverageCost
+	}
+
+	for _, id := range order {
+		h := holdings[id]
+		if err := tr.value(ctx, h); err != nil {
+			return nil, err
+		}
+		if rec := h.Recommendation; rec != nil && rec.Action == storage.ActionSell && rec.IsActive {
+			price := h.Price
+			if price == 0 {
+				price = rec.EntryPrice
+			}
+			if price > 0 {
+				h.SellTax, err = tr.sellTaxImpact(ctx, p, ledger, h.StockID, 0, price, now)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		summary.Invested += h.Invested
+		summary.MarketValue += h.Value
+		summary.Conflicts += len(h.Conflicts)
+	}
+
+	sectors := make(map[string]*SectorAllocation)
+	for _, id := range order {
+		h := holdings[id]
+		if summary.MarketValue > 0 {
+			h.Weight = h.Value / summary.MarketValue * 100
+		}
+
+		sector := h.Sector
+		if sector == "" {
+			sector = "Unknown"
+		}
+		a, ok := sectors[sector]
+		if !ok {
+			a = &SectorAllocation{Sector: sector}
+			sectors[sector] = a
+		}
+		a.Value += h.Value
+		a.Weight += h.Weight
+		a.Holdings++
+
+		summary.Holdings = append(summary.Holdings, *h)
+	}
+	for _, a := range sectors {
+		summary.Allocation = append(summary.Allocation, *a)
+	}
+	sort.Slice(summary.Allocation, func(i, j int) bool {
+		return summary.Allocation[i].Value > summary.Allocation[j].Value
+	})
+	sort.SliceStable(summary.Holdings, func(i, j int) bool {
+		return summary.Holdings[i].Value > summary.Holdings[j].Value
+	})
+
+	summary.TotalValue = summary.MarketValue + summary.Cash
+	summary.UnrealizedPnL = summary.MarketValue - summary.Invested
+	if summary.Invested > 0 {
+		summary.UnrealizedPct = summary.UnrealizedPnL / summary.Invested * 100
+	}
+
+	summary.XIRR, summary.XIRRNote = portfolioXIRR(transactions, summary.MarketValue, now)
+	return summary, nil
+}
+
+// value prices a holding at its stock's latest fundamentals and checks it
+// against the latest recommendation.
+func (tr *Tracker) value(ctx context.Context, h *Holding) error {
+	if h.Quantity > 0 {
+		h.AverageCost = h.Invested / h.Quantity
+	}
+
+	stock, err := tr.repo.GetStockByID(ctx, h.StockID)
+	if err != nil {
+		return err
+	}
+	if stock != nil {
+		h.Name = stock.Name
+		h.Sector = stock.Sector
+	}
+
+	fundamental, err := tr.repo.GetLatestFundamental(ctx, h.StockID)
+	if err != nil {
+		return err
+	}
+	if fundamental != nil && fundamental.CurrentPrice > 0 {
+		h.Price = fundamental.CurrentPrice
+		h.PriceAsOf = &fundamental.FetchedAt
+		h.Value = h.Quantity * h.Price
+	} else {
+		h.PriceMissing = true
+		h.Value = h.Invested
+	}
+	h.UnrealizedPnL = h.Value - h.Invested
+	if h.Invested > 0 {
+		h.UnrealizedPct = h.UnrealizedPnL / h.Invested * 100
+	}
+
+	rec, err := tr.repo.GetLatestRecommendationForStock(ctx, h.StockID)
+	if err != nil {
+		return err
+	}
+	if rec == nil {
+		return nil
+	}
+	h.Recommendation = rec
+
+	if rec.Action == storage.ActionSell && rec.IsActive {
+		h.Conflicts = append(h.Conflicts, Conflict{
+			Type:    ConflictSellSignal,
+			Message: fmt.Sprintf("SELL recommended on %s while %g shares are held", rec.CreatedAt.Format("2006-01-02"), h.Quantity),
+		})
+	}
+	if rec.StopLoss > 0 && h.Price > 0 && h.Price <= rec.StopLoss {
+		h.Conflicts = append(h.Conflicts, Conflict{
+			Type:    ConflictStopLoss,
+			Message: fmt.Sprintf("price ₹%.2f is at or below the stop-loss of ₹%.2f", h.Price, rec.StopLoss),
+		})
+	}
+	return nil
+}
+
+// portfolioXIRR works out the XIRR of a portfolio's buys, sells and
+// dividends with its holdings sold at marketValue now, in percent. Deposits
+// and withdrawals are moves of cash and left out. It returns nil and the
+// reason if there is none.
+func portfolioXIRR(transactions []storage.PortfolioTransaction, marketValue float64, now time.Time) (*float64, string) {
+	var flows []CashFlow
+	for _, t := range transactions {
+		var amount float64
+		switch t.Type {
+		case storage.TransactionBuy:
+			amount = -(t.Quantity*t.Price + t.Fees)
+		case storage.TransactionSell:
+			amount = t.Quantity*t.Price - t.Fees
+		case storage.TransactionDividend:
+			amount = t.Amount
+		default:
+			continue
+		}
+		if t.Date == nil {
+			return nil, "some holdings have no buy date"
+		}
+		flows = append(flows, CashFlow{Date: *t.Date, Amount: amount})
+	}
+	if len(flows) == 0 {
+		return nil, "no trades recorded"
+	}
+	if marketValue > 0 {
+		flows = append(flows, CashFlow{Date: now, Amount: marketValue})
+	}
+
+	rate, err := XIRR(flows)
+	if err != nil {
+		return nil, err.Error()
+	}
+	pct := rate * 100
+	return &pct, ""
+}
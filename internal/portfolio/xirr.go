@@ -0,0 +1,92 @@
+package portfolio
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// CashFlow is an amount invested (negative) or returned (positive) on a date.
+type CashFlow struct {
+	Date   time.Time
+	Amount float64
+}
+
+// errNoXIRR is returned by XIRR when the flows have no rate of return.
+var errNoXIRR = errors.New("no rate of return solves the cash flows")
+
+// XIRR returns the annualised rate of return, as a fraction, of irregular
+// cash flows: the rate at which their present values sum to zero. It tries
+// Newton's method first and falls back to bisection when that diverges.
+func XIRR(flows []CashFlow) (float64, error) {
+	if len(flows) < 2 {
+		return 0, errors.New("at least two cash flows are needed")
+	}
+
+	flows = append([]CashFlow(nil), flows...)
+	sort.SliceStable(flows, func(i, j int) bool { return flows[i].Date.Before(flows[j].Date) })
+
+	var positive, negative bool
+	for _, f := range flows {
+		positive = positive || f.Amount > 0
+		negative = negative || f.Amount < 0
+	}
+	if !positive || !negative {
+		return 0, errors.New("cash flows need both an investment and a return")
+	}
+
+	start := flows[0].Date
+	years := make([]float64, len(flows))
+	for i, f := range flows {
+		years[i] = f.Date.Sub(start).Hours() / 24 / 365
+	}
+
+	npv := func(rate float64) (value, derivative float64) {
+		for i, f := range flows {
+			factor := math.Pow(1+rate, years[i])
+			value += f.Amount / factor
+			derivative -= years[i] * f.Amount / (factor * (1 + rate))
+		}
+		return value, derivative
+	}
+
+	rate := 0.1
+	for i := 0; i < 100; i++ {
+		value, derivative := npv(rate)
+		if math.Abs(value) < 1e-7 {
+			return rate, nil
+		}
+		if derivative == 0 || math.IsNaN(derivative) {
+			break
+		}
+		next := rate - value/derivative
+		if next <= -1 || math.IsNaN(next) || math.IsInf(next, 0) {
+			break
+		}
+		if math.Abs(next-rate) < 1e-10 {
+			return next, nil
+		}
+		rate = next
+	}
+
+	low, high := -0.9999, 10.0
+	lowValue, _ := npv(low)
+	highValue, _ := npv(high)
+	if lowValue*highValue > 0 {
+		return 0, errNoXIRR
+	}
+	for i := 0; i < 200; i++ {
+		mid := (low + high) / 2
+		value, _ := npv(mid)
+		if math.Abs(value) < 1e-7 || high-low < 1e-10 {
+			return mid, nil
+		}
+		if value*lowValue < 0 {
+			high = mid
+		} else {
+			low, lowValue = mid, value
+		}
+	}
+	return (low + high) / 2, nil
+}
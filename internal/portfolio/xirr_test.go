@@ -0,0 +1,92 @@
+package portfolio
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestXIRR(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		flows   []CashFlow
+		want    float64
+		wantErr bool
+	}{
+		{
+			// The example in the documentation of Excel's XIRR.
+			name: "excel example",
+			flows: []CashFlow{
+				{day(2008, time.January, 1), -10000},
+				{day(2008, time.March, 1), 2750},
+				{day(2008, time.October, 30), 4250},
+				{day(2009, time.February, 15), 3250},
+				{day(2009, time.April, 1), 2750},
+			},
+			want: 0.373362535,
+		},
+		{
+			name: "unsorted flows",
+			flows: []CashFlow{
+				{day(2009, time.April, 1), 2750},
+				{day(2008, time.January, 1), -10000},
+				{day(2009, time.February, 15), 3250},
+				{day(2008, time.October, 30), 4250},
+				{day(2008, time.March, 1), 2750},
+			},
+			want: 0.373362535,
+		},
+		{
+			// 2020 is a leap year, so the return is over 366/365 years.
+			name: "one year",
+			flows: []CashFlow{
+				{day(2020, time.January, 1), -1000},
+				{day(2021, time.January, 1), 1100},
+			},
+			want: math.Pow(1.1, 365.0/366) - 1,
+		},
+		{
+			name: "loss",
+			flows: []CashFlow{
+				{day(2023, time.January, 1), -1000},
+				{day(2024, time.January, 1), 800},
+			},
+			want: -0.2,
+		},
+		{
+			name:    "single flow",
+			flows:   []CashFlow{{day(2024, time.January, 1), -1000}},
+			wantErr: true,
+		},
+		{
+			name: "no return",
+			flows: []CashFlow{
+				{day(2023, time.January, 1), -1000},
+				{day(2024, time.January, 1), -500},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := XIRR(tt.flows)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("XIRR = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("XIRR: %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("XIRR = %.9f, want %.9f", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package recommender
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -14,39 +15,59 @@ import (
 
 // DailyPick represents a daily stock pick with full analysis.
 type DailyPick struct {
-	Rank            int                      `json:"rank"`
-	Symbol          string                   `json:"symbol"`
-	Name            string                   `json:"name"`
-	Sector          string                   `json:"sector,omitempty"`
-	Action          string                   `json:"action"`
-	EntryPrice      float64                  `json:"entry_price"`
-	TargetPrice     float64                  `json:"target_price"`
-	StopLoss        float64                  `json:"stop_loss"`
-	ConfidenceScore float64                  `json:"confidence_score"`
-	Reasoning       string                   `json:"reasoning"`
-	TimeHorizon     string                   `json:"time_horizon"`
-	RiskLevel       string                   `json:"risk_level"`
-	Sources         []string                 `json:"sources"`
-	MarketCap       float64                  `json:"market_cap,omitempty"`
-	PE              float64                  `json:"pe,omitempty"`
-	ROE             float64                  `json:"roe,omitempty"`
-	Recommendation  *storage.Recommendation  `json:"recommendation,omitempty"`
+	Rank            int                     `json:"rank"`
+	Symbol          string                  `json:"symbol"`
+	Name            string                  `json:"name"`
+	Sector          string                  `json:"sector,omitempty"`
+	Action          string                  `json:"action"`
+	EntryPrice      float64                 `json:"entry_price"`
+	TargetPrice     float64                 `json:"target_price"`
+	StopLoss        float64                 `json:"stop_loss"`
+	ConfidenceScore float64                 `json:"confidence_score"`
+	Reasoning       string                  `json:"reasoning"`
+	TimeHorizon     string                  `json:"time_horizon"`
+	RiskLevel       string                  `json:"risk_level"`
+	Sources         []string                `json:"sources"`
+	MarketCap       float64                 `json:"market_cap,omitempty"`
+	PE              float64                 `json:"pe,omitempty"`
+	ROE             float64                 `json:"roe,omitempty"`
+	RevenueGrowth3Y float64                 `json:"revenue_growth_3y,omitempty"`
+	ProfitGrowth3Y  float64                 `json:"profit_growth_3y,omitempty"`
+	Recommendation  *storage.Recommendation `json:"recommendation,omitempty"`
 }
 
 // DailyPicksFilter contains filter criteria for daily picks.
 type DailyPicksFilter struct {
 	MinPrice        float64  `json:"min_price"`
 	MaxPrice        float64  `json:"max_price"`
-	MinMarketCap    float64  `json:"min_market_cap"`    // In Crores
-	MaxMarketCap    float64  `json:"max_market_cap"`    // In Crores
+	MinMarketCap    float64  `json:"min_market_cap"` // In Crores
+	MaxMarketCap    float64  `json:"max_market_cap"` // In Crores
 	MinPE           float64  `json:"min_pe"`
 	MaxPE           float64  `json:"max_pe"`
-	MinConfidence   float64  `json:"min_confidence"`    // 0-100
-	RiskLevels      []string `json:"risk_levels"`       // low, medium, high
-	TimeHorizons    []string `json:"time_horizons"`     // short_term, medium_term, long_term
+	MinConfidence   float64  `json:"min_confidence"` // 0-100
+	RiskLevels      []string `json:"risk_levels"`    // low, medium, high
+	TimeHorizons    []string `json:"time_horizons"`  // short_term, medium_term, long_term
 	Sectors         []string `json:"sectors"`
 	MinROE          float64  `json:"min_roe"`
 	MaxDebtToEquity float64  `json:"max_debt_to_equity"`
+	MinSalesGrowth  float64  `json:"min_sales_growth"`  // 3Y compounded, %
+	MinProfitGrowth float64  `json:"min_profit_growth"` // 3Y compounded, %
+
+	// Attributes filter on figures kept from custom columns of screener
+	// uploads. A stock without the attribute does not pass.
+	Attributes []AttributeFilter `json:"attributes,omitempty"`
+
+	// Watchlists names watchlists whose stocks are analysed instead of the
+	// configured candidates.
+	Watchlists []string `json:"watchlists,omitempty"`
+}
+
+// AttributeFilter bounds an attribute of the latest fundamentals, matched
+// by key or column name. Nil bounds are open.
+type AttributeFilter struct {
+	Key string   `json:"key"`
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
 }
 
 // DailyPicksResult contains the daily picks analysis result.
@@ -80,14 +101,13 @@ func (e *Engine) GenerateDailyPicks(ctx context.Context) (*DailyPicksResult, err
 func (e *Engine) StreamDailyPicks(ctx context.Context, filter *DailyPicksFilter, eventChan chan<- DailyPickEvent) {
 	defer close(eventChan)
 
-	// Step 1: Discover trending stocks
+	// Step 1: Discover trending stocks or read the watchlists
 	eventChan <- DailyPickEvent{
 		Type:    "progress",
-		Message: "Discovering trending stocks...",
+		Message: "Finding candidate stocks...",
 	}
 
-	discovery := analyzer.NewStockDiscovery()
-	candidates, err := discovery.DiscoverTrendingStocks(ctx)
+	candidates, err := e.dailyPickCandidates(ctx, filter)
 	if err != nil {
 		eventChan <- DailyPickEvent{
 			Type:    "error",
@@ -96,12 +116,6 @@ func (e *Engine) StreamDailyPicks(ctx context.Context, filter *DailyPicksFilter,
 		return
 	}
 
-	// Limit candidates
-	maxCandidates := 15
-	if len(candidates) > maxCandidates {
-		candidates = candidates[:maxCandidates]
-	}
-
 	eventChan <- DailyPickEvent{
 		Type:    "progress",
 		Message: fmt.Sprintf("Found %d candidates, starting analysis...", len(candidates)),
@@ -111,6 +125,7 @@ func (e *Engine) StreamDailyPicks(ctx context.Context, filter *DailyPicksFilter,
 	// Step 2: Analyze each candidate sequentially and stream results
 	var picks []DailyPick
 	pickRank := 0
+	analyzed := 0
 
 	for i, candidate := range candidates {
 		select {
@@ -137,6 +152,7 @@ func (e *Engine) StreamDailyPicks(ctx context.Context, filter *DailyPicksFilter,
 		if analysis == nil || analysis.Recommendation == nil {
 			continue
 		}
+		analyzed++
 
 		rec := analysis.Recommendation
 
@@ -177,6 +193,8 @@ func (e *Engine) StreamDailyPicks(ctx context.Context, filter *DailyPicksFilter,
 			pick.MarketCap = analysis.Fundamental.MarketCap
 			pick.PE = analysis.Fundamental.StockPE
 			pick.ROE = analysis.Fundamental.ROE
+			pick.RevenueGrowth3Y = analysis.Fundamental.RevenueGrowth3Y
+			pick.ProfitGrowth3Y = analysis.Fundamental.ProfitGrowth3Y
 		}
 
 		// Add LLM reasoning if available
@@ -205,12 +223,81 @@ func (e *Engine) StreamDailyPicks(ctx context.Context, filter *DailyPicksFilter,
 	}
 
 	// Send completion event
+	sentiment := e.determineMarketSentiment(picks)
 	eventChan <- DailyPickEvent{
 		Type:            "complete",
 		Message:         "Analysis complete",
 		TotalPicks:      len(picks),
-		MarketSentiment: e.determineMarketSentiment(picks),
+		MarketSentiment: sentiment,
+	}
+
+	if picks == nil {
+		picks = []DailyPick{}
+	}
+	e.publish(ctx, EventDailyPicksCompleted, DailyPicksCompleted{
+		DailyPicksResult: &DailyPicksResult{
+			GeneratedAt:     time.Now(),
+			Picks:           picks,
+			TotalAnalyzed:   analyzed,
+			TotalCandidates: len(candidates),
+			MarketSentiment: sentiment,
+			Complete:        true,
+		},
+		Filter: filter,
+	})
+}
+
+// maxTrendingCandidates limits how many trending stocks are analysed for
+// daily picks, to avoid too many requests.
+const maxTrendingCandidates = 15
+
+// ErrWatchlistNotFound is returned when daily picks are asked for from a
+// watchlist that does not exist.
+var ErrWatchlistNotFound = errors.New("watchlist not found")
+
+// dailyPickCandidates returns the stocks to analyse for daily picks: those
+// on the filter's watchlists, else on the configured ones, else the top
+// trending stocks. Every watchlist stock is analysed.
+func (e *Engine) dailyPickCandidates(ctx context.Context, filter *DailyPicksFilter) ([]analyzer.DiscoveredStock, error) {
+	names := e.config.Analysis.DailyPicksWatchlists
+	if filter != nil && len(filter.Watchlists) > 0 {
+		names = filter.Watchlists
+	}
+
+	if len(names) == 0 {
+		discovery := analyzer.NewStockDiscovery()
+		candidates, err := discovery.DiscoverTrendingStocks(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(candidates) > maxTrendingCandidates {
+			candidates = candidates[:maxTrendingCandidates]
+		}
+		return candidates, nil
+	}
+
+	var candidates []analyzer.DiscoveredStock
+	seen := make(map[string]bool)
+	for _, name := range names {
+		watchlist, err := e.repo.GetWatchlistByName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if watchlist == nil {
+			return nil, fmt.Errorf("%w: %s", ErrWatchlistNotFound, name)
+		}
+		for _, item := range watchlist.Items {
+			if seen[item.Symbol] {
+				continue
+			}
+			seen[item.Symbol] = true
+			candidates = append(candidates, analyzer.DiscoveredStock{
+				Symbol: item.Symbol,
+				Source: "watchlist:" + watchlist.Name,
+			})
+		}
 	}
+	return candidates, nil
 }
 
 // GenerateDailyPicksWithFilter discovers and analyzes stocks with optional filters.
@@ -220,10 +307,10 @@ func (e *Engine) GenerateDailyPicksWithFilter(ctx context.Context, filter *Daily
 		Picks:       []DailyPick{},
 	}
 
-	// Step 1: Discover trending stocks from multiple sources
-	fmt.Println("→ Discovering trending stocks...")
-	discovery := analyzer.NewStockDiscovery()
-	candidates, err := discovery.DiscoverTrendingStocks(ctx)
+	// Step 1: Discover trending stocks from multiple sources, or read the
+	// watchlists
+	fmt.Println("→ Finding candidate stocks...")
+	candidates, err := e.dailyPickCandidates(ctx, filter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to discover stocks: %w", err)
 	}
@@ -248,12 +335,6 @@ func (e *Engine) GenerateDailyPicksWithFilter(ctx context.Context, filter *Daily
 	sem := make(chan struct{}, 1) // Limit to 1 concurrent analysis to avoid rate limiting
 	var wg sync.WaitGroup
 
-	// Limit candidates to avoid too many requests
-	maxCandidates := 15
-	if len(candidates) > maxCandidates {
-		candidates = candidates[:maxCandidates]
-	}
-
 	for _, candidate := range candidates {
 		wg.Add(1)
 		go func(c analyzer.DiscoveredStock) {
@@ -336,6 +417,8 @@ func (e *Engine) GenerateDailyPicksWithFilter(ctx context.Context, filter *Daily
 			pick.MarketCap = r.analysis.Fundamental.MarketCap
 			pick.PE = r.analysis.Fundamental.StockPE
 			pick.ROE = r.analysis.Fundamental.ROE
+			pick.RevenueGrowth3Y = r.analysis.Fundamental.RevenueGrowth3Y
+			pick.ProfitGrowth3Y = r.analysis.Fundamental.ProfitGrowth3Y
 		}
 
 		// Add LLM reasoning if available
@@ -374,6 +457,12 @@ func (e *Engine) GenerateDailyPicksWithFilter(ctx context.Context, filter *Daily
 
 	fmt.Printf("  ✓ Generated %d daily picks\n", len(result.Picks))
 
+	if filter == nil {
+		result.Complete = true
+		e.cacheDailyPicks(result)
+	}
+
+	e.publish(ctx, EventDailyPicksCompleted, DailyPicksCompleted{DailyPicksResult: result, Filter: filter})
 	return result, nil
 }
 
@@ -404,11 +493,25 @@ func (e *Engine) determineMarketSentiment(picks []DailyPick) string {
 	return "NEUTRAL"
 }
 
+// dailyPicksCacheTTL is how long unfiltered daily picks are served from cache.
+const dailyPicksCacheTTL = 12 * time.Hour
+
 // GetCachedDailyPicks returns cached daily picks if available and fresh.
 func (e *Engine) GetCachedDailyPicks(ctx context.Context) (*DailyPicksResult, bool) {
-	// For now, we don't cache - always generate fresh
-	// In production, you'd want to cache results for a few hours
-	return nil, false
+	e.picksMu.RLock()
+	defer e.picksMu.RUnlock()
+
+	if e.cachedPicks == nil || time.Since(e.cachedPicks.GeneratedAt) > dailyPicksCacheTTL {
+		return nil, false
+	}
+	return e.cachedPicks, true
+}
+
+// cacheDailyPicks stores an unfiltered daily picks result for later reads.
+func (e *Engine) cacheDailyPicks(result *DailyPicksResult) {
+	e.picksMu.Lock()
+	defer e.picksMu.Unlock()
+	e.cachedPicks = result
 }
 
 // passesFilter checks if a pick passes all filter criteria.
@@ -468,6 +571,25 @@ func (e *Engine) passesFilter(pick DailyPick, fundamental *storage.StockFundamen
 		if filter.MaxDebtToEquity > 0 && fundamental.DebtToEquity > filter.MaxDebtToEquity {
 			return false
 		}
+
+		// Growth filters (3-year compounded)
+		if filter.MinSalesGrowth > 0 && fundamental.RevenueGrowth3Y < filter.MinSalesGrowth {
+			return false
+		}
+		if filter.MinProfitGrowth > 0 && fundamental.ProfitGrowth3Y < filter.MinProfitGrowth {
+			return false
+		}
+	}
+
+	// Attribute filters need the attribute to be present
+	for _, attr := range filter.Attributes {
+		if fundamental == nil {
+			return false
+		}
+		value, ok := fundamental.Attribute(attr.Key)
+		if !ok || (attr.Min != nil && value < *attr.Min) || (attr.Max != nil && value > *attr.Max) {
+			return false
+		}
 	}
 
 	return true
@@ -518,4 +640,3 @@ func (e *Engine) GetAvailableFilters() map[string]interface{} {
 		},
 	}
 }
-
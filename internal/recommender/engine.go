@@ -5,11 +5,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/user/stock-recommender/internal/analyzer"
+	"github.com/user/stock-recommender/internal/calendar"
 	"github.com/user/stock-recommender/internal/llm"
+	"github.com/user/stock-recommender/internal/market"
 	"github.com/user/stock-recommender/internal/screener"
 	"github.com/user/stock-recommender/internal/sentiment"
 	"github.com/user/stock-recommender/internal/storage"
@@ -23,36 +27,74 @@ type Engine struct {
 	sentimentAnalyzer *sentiment.Analyzer
 	newsFetcher       *analyzer.NewsFetcher
 	screenerScraper   *screener.Scraper
+	calendar          *calendar.Calendar
+	market            *market.Service
 	config            *config.Config
+	events            EventPublisher
+
+	picksMu     sync.RWMutex
+	cachedPicks *DailyPicksResult
 }
 
-// NewEngine creates a new recommendation engine.
+// NewEngine creates a new recommendation engine. If cal is nil, a calendar
+// with weekends only (no exchange holidays) is used.
 func NewEngine(
 	repo *storage.Repository,
 	llmProvider llm.Provider,
+	cal *calendar.Calendar,
 	cfg *config.Config,
 ) *Engine {
+	if cal == nil {
+		cal = calendar.New(calendar.LoadLocation(cfg.Market.Timezone))
+	}
+	marketService := market.NewService(repo, cal.Location())
+	if cfg.Market.DataDir != "" {
+		marketService.AddFetcher(market.NewDirFetcher(cfg.Market.DataDir, cal.Location()))
+	}
+	scraper := screener.NewScraper(cfg.Screener.BaseURL, cfg.Screener.ScrapeDelay)
+	if cfg.Screener.CacheDir != "" {
+		scraper.SetCache(screener.NewPageCache(cfg.Screener.CacheDir, cfg.Screener.CacheTTL))
+	}
+	scraper.SetOffline(cfg.Screener.Offline)
+	scraper.SetBasis(cfg.Screener.Basis)
 	return &Engine{
 		repo:              repo,
 		llmProvider:       llmProvider,
 		sentimentAnalyzer: sentiment.NewAnalyzer(),
 		newsFetcher:       analyzer.NewNewsFetcher(cfg.News.Sources),
-		screenerScraper:   screener.NewScraper(cfg.Screener.BaseURL, cfg.Screener.ScrapeDelay),
+		screenerScraper:   scraper,
+		calendar:          cal,
+		market:            marketService,
 		config:            cfg,
 	}
 }
 
+// Calendar returns the trading calendar used by the engine.
+func (e *Engine) Calendar() *calendar.Calendar {
+	return e.calendar
+}
+
+// Market returns the market condition service.
+func (e *Engine) Market() *market.Service {
+	return e.market
+}
+
 // AnalysisResult represents the complete analysis result.
 type AnalysisResult struct {
-	Stock           *storage.Stock
-	Fundamental     *storage.StockFundamental
-	News            []analyzer.FetchedNews
-	NewsSentiment   storage.SentimentScore
-	NewsScore       float64
-	KeywordAnalysis *sentiment.Result
-	LLMAnalysis     *llm.AnalysisResponse
-	Recommendation  *storage.Recommendation
-	DataSources     []string
+	Stock            *storage.Stock
+	Fundamental      *storage.StockFundamental
+	News             []analyzer.FetchedNews
+	NewsSentiment    storage.SentimentScore
+	NewsScore        float64
+	KeywordAnalysis  *sentiment.Result
+	Shareholding     []storage.ShareholdingSnapshot
+	Peers            *PeerComparison
+	DataAge          time.Duration // age of the fundamentals at analysis
+	DataStale        bool          // fundamentals are past the freshness policy's max age
+	MarketConditions []storage.MarketCondition
+	LLMAnalysis      *llm.AnalysisResponse
+	Recommendation   *storage.Recommendation
+	DataSources      []string
 }
 
 // AnalyzeStock performs a complete analysis of a stock.
@@ -99,8 +141,8 @@ func (e *Engine) AnalyzeStock(ctx context.Context, symbol string) (*AnalysisResu
 				}
 
 				// Save fundamentals
-				fundamental := stockData.ToFundamental(stock.ID)
-				if err := e.repo.CreateFundamental(ctx, fundamental); err != nil {
+				fundamental, err := e.saveStockData(ctx, stock, stockData)
+				if err != nil {
 					fmt.Printf("Warning: failed to save fundamentals: %v\n", err)
 				} else {
 					result.Fundamental = fundamental
@@ -130,18 +172,50 @@ func (e *Engine) AnalyzeStock(ctx context.Context, symbol string) (*AnalysisResu
 
 		if fundamental == nil && e.config.Screener.ScrapeEnabled {
 			// Fetch from screener
-			stockData, err := e.screenerScraper.FetchStock(ctx, symbol)
+			stockData, err := e.screenerScraper.FetchStockBasis(ctx, symbol, e.financialBasis(stock))
 			if err == nil {
-				fundamental = stockData.ToFundamental(stock.ID)
-				if err := e.repo.CreateFundamental(ctx, fundamental); err != nil {
+				fundamental, err = e.saveStockData(ctx, stock, stockData)
+				if err != nil {
 					fmt.Printf("Warning: failed to save fundamentals: %v\n", err)
+					fundamental = stockData.ToFundamental(stock.ID)
 				}
 				result.DataSources = append(result.DataSources, "screener.in")
 			}
+		} else if fundamental != nil && e.shouldRefresh(fundamental, time.Now()) {
+			// Re-scrape fundamentals past their max age, keeping the old
+			// snapshot if that fails
+			refreshed, err := e.RefreshFundamentals(ctx, stock)
+			if err != nil {
+				fmt.Printf("Warning: using stale fundamentals: %v\n", err)
+			} else {
+				fundamental = refreshed
+				result.DataSources = append(result.DataSources, "screener.in")
+			}
 		}
 		result.Fundamental = fundamental
 	}
 
+	// Measure how old the fundamentals are against the freshness policy
+	if result.Fundamental != nil {
+		now := time.Now()
+		result.DataAge = now.Sub(result.Fundamental.FetchedAt)
+		result.DataStale = e.isStale(result.Fundamental, now)
+	}
+
+	// Load recent quarters of shareholding for trend analysis
+	shareholding, err := e.repo.ListShareholdingSnapshots(ctx, stock.ID, 8)
+	if err != nil {
+		fmt.Printf("Warning: failed to load shareholding: %v\n", err)
+	}
+	result.Shareholding = shareholding
+
+	// Rank valuation and returns against the industry's peers
+	peers, err := e.ComparePeers(ctx, stock, result.Fundamental)
+	if err != nil {
+		fmt.Printf("Warning: failed to compare peers: %v\n", err)
+	}
+	result.Peers = peers
+
 	// 3. Fetch and analyze news
 	allNews, err := e.newsFetcher.FetchAll(ctx)
 	if err != nil {
@@ -153,16 +227,22 @@ func (e *Engine) AnalyzeStock(ctx context.Context, symbol string) (*AnalysisResu
 		result.NewsSentiment, result.NewsScore = analyzer.CalculateOverallSentiment(stockNews)
 		result.DataSources = append(result.DataSources, "news_rss")
 
-		// Save news to database
+		// Save news to database, linked to this stock and any others mentioned
+		stocks := map[string]*storage.Stock{stock.Symbol: stock}
+		var ingested []storage.News
 		for _, n := range stockNews {
-			existing, _ := e.repo.GetNewsByURL(ctx, n.URL)
-			if existing == nil {
-				newsModel := n.ToNewsModel(&stock.ID)
-				if err := e.repo.CreateNews(ctx, newsModel); err != nil {
-					fmt.Printf("Warning: failed to save news: %v\n", err)
-				}
+			saved, err := e.ingestNews(ctx, n, stock, stocks)
+			if err != nil {
+				fmt.Printf("Warning: failed to save news: %v\n", err)
+				continue
+			}
+			if saved != nil {
+				ingested = append(ingested, *saved)
 			}
 		}
+		if len(ingested) > 0 {
+			e.publish(ctx, EventNewsIngested, NewsIngested{Symbol: stock.Symbol, Articles: ingested})
+		}
 	}
 
 	// 4. Perform keyword sentiment analysis
@@ -178,7 +258,13 @@ func (e *Engine) AnalyzeStock(ctx context.Context, symbol string) (*AnalysisResu
 		result.DataSources = append(result.DataSources, "keyword_sentiment")
 	}
 
-	// 5. Perform LLM analysis
+	// 5. Load the latest market conditions
+	result.MarketConditions = e.currentMarketConditions(ctx)
+	if len(result.MarketConditions) > 0 {
+		result.DataSources = append(result.DataSources, "market_conditions")
+	}
+
+	// 6. Perform LLM analysis
 	if e.config.Analysis.UseLLM && e.llmProvider != nil {
 		llmReq := e.buildLLMRequest(result)
 		llmResp, err := e.llmProvider.AnalyzeStock(ctx, llmReq)
@@ -190,18 +276,47 @@ func (e *Engine) AnalyzeStock(ctx context.Context, symbol string) (*AnalysisResu
 		}
 	}
 
-	// 6. Generate recommendation
+	// 7. Generate recommendation
 	recommendation := e.generateRecommendation(result)
 	result.Recommendation = recommendation
 
-	// 7. Save recommendation
-	if err := e.repo.CreateRecommendation(ctx, recommendation); err != nil {
+	// 8. Save recommendation, superseding the stock's earlier ones
+	superseded, err := e.repo.ReplaceActiveRecommendations(ctx, recommendation)
+	if err != nil {
 		return nil, fmt.Errorf("failed to save recommendation: %w", err)
 	}
+	recommendation.Stock = *stock
+
+	e.publish(ctx, EventRecommendationCreated, recommendation)
+	for _, old := range superseded {
+		e.publish(ctx, EventRecommendationSuperseded, RecommendationSuperseded{
+			Recommendation: old,
+			SupersededBy:   recommendation.ID,
+		})
+	}
 
 	return result, nil
 }
 
+// currentMarketConditions returns the latest condition of each tracked index,
+// or nil if there is none recent enough to describe today's market.
+func (e *Engine) currentMarketConditions(ctx context.Context) []storage.MarketCondition {
+	conditions, err := e.market.Latest(ctx)
+	if err != nil {
+		fmt.Printf("Warning: failed to load market conditions: %v\n", err)
+		return nil
+	}
+
+	maxAge := e.config.Market.ConditionMaxAge
+	var current []storage.MarketCondition
+	for _, mc := range conditions {
+		if maxAge <= 0 || time.Since(mc.RecordedAt) <= maxAge {
+			current = append(current, mc)
+		}
+	}
+	return current
+}
+
 // buildLLMRequest builds an LLM analysis request from the analysis result.
 func (e *Engine) buildLLMRequest(result *AnalysisResult) llm.AnalysisRequest {
 	req := llm.AnalysisRequest{
@@ -211,21 +326,44 @@ func (e *Engine) buildLLMRequest(result *AnalysisResult) llm.AnalysisRequest {
 
 	if result.Fundamental != nil {
 		req.CurrentPrice = result.Fundamental.CurrentPrice
+		req.FinancialBasis = result.Fundamental.Basis
 		req.Fundamentals = map[string]float64{
-			"Market Cap (Cr)":    result.Fundamental.MarketCap,
-			"P/E Ratio":          result.Fundamental.StockPE,
-			"Book Value":         result.Fundamental.BookValue,
-			"ROE (%)":            result.Fundamental.ROE,
-			"ROCE (%)":           result.Fundamental.ROCE,
-			"Dividend Yield (%)": result.Fundamental.DividendYield,
-			"Debt to Equity":     result.Fundamental.DebtToEquity,
-			"EPS":                result.Fundamental.EPS,
+			"Market Cap (Cr)":      result.Fundamental.MarketCap,
+			"P/E Ratio":            result.Fundamental.StockPE,
+			"Book Value":           result.Fundamental.BookValue,
+			"ROE (%)":              result.Fundamental.ROE,
+			"ROCE (%)":             result.Fundamental.ROCE,
+			"Dividend Yield (%)":   result.Fundamental.DividendYield,
+			"Debt to Equity":       result.Fundamental.DebtToEquity,
+			"EPS":                  result.Fundamental.EPS,
 			"Promoter Holding (%)": result.Fundamental.PromoterHolding,
-			"52 Week High":       result.Fundamental.High52Week,
-			"52 Week Low":        result.Fundamental.Low52Week,
+			"52 Week High":         result.Fundamental.High52Week,
+			"52 Week Low":          result.Fundamental.Low52Week,
+		}
+
+		// Growth and return history, where known
+		history := map[string]float64{
+			"Sales Growth 5Y (%)":     result.Fundamental.RevenueGrowth5Y,
+			"Sales Growth 3Y (%)":     result.Fundamental.RevenueGrowth3Y,
+			"Sales Growth TTM (%)":    result.Fundamental.RevenueGrowthTTM,
+			"Profit Growth 5Y (%)":    result.Fundamental.ProfitGrowth5Y,
+			"Profit Growth 3Y (%)":    result.Fundamental.ProfitGrowth3Y,
+			"Profit Growth TTM (%)":   result.Fundamental.ProfitGrowthTTM,
+			"Stock Price CAGR 3Y (%)": result.Fundamental.PriceCAGR3Y,
+			"Stock Price CAGR 1Y (%)": result.Fundamental.PriceCAGR1Y,
+			"ROE 5Y Average (%)":      result.Fundamental.ROE5Y,
+			"ROE 3Y Average (%)":      result.Fundamental.ROE3Y,
+		}
+		for name, value := range history {
+			if value != 0 {
+				req.Fundamentals[name] = value
+			}
 		}
 	}
 
+	// Add valuation relative to the industry
+	req.PeerComparison = result.Peers.Summary()
+
 	// Add news headlines
 	for _, n := range result.News {
 		if len(req.NewsHeadlines) < 10 {
@@ -233,10 +371,8 @@ func (e *Engine) buildLLMRequest(result *AnalysisResult) llm.AnalysisRequest {
 		}
 	}
 
-	// Add market sentiment
-	if result.KeywordAnalysis != nil {
-		req.MarketSentiment = string(result.KeywordAnalysis.Sentiment)
-	}
+	// Add overall market conditions
+	req.MarketSentiment = market.Describe(result.MarketConditions, e.calendar.Location())
 
 	return req
 }
@@ -274,6 +410,17 @@ func (e *Engine) generateRecommendation(result *AnalysisResult) *storage.Recomme
 		rec.ConfidenceScore = 0
 	}
 
+	// Trust recommendations on stale fundamentals less
+	if result.Fundamental != nil {
+		fetchedAt := result.Fundamental.FetchedAt
+		rec.DataAsOf = &fetchedAt
+		rec.DataStale = result.DataStale
+		if result.DataStale {
+			penalty := e.stalenessPenalty(result.DataAge, e.fundamentalMaxAge(result.Fundamental))
+			rec.ConfidenceScore = math.Max(0, rec.ConfidenceScore-penalty)
+		}
+	}
+
 	// Set entry price from fundamentals
 	if result.Fundamental != nil {
 		rec.EntryPrice = result.Fundamental.CurrentPrice
@@ -316,16 +463,16 @@ func (e *Engine) generateRecommendation(result *AnalysisResult) *storage.Recomme
 		rec.RiskLevel = "medium"
 	}
 
-	// Set expiry (7 days for short-term, 30 days for medium-term, 90 days for long-term)
-	var expiry time.Time
+	// Set expiry at market close after 5 trading days for short-term,
+	// 21 for medium-term (about a month) and 63 for long-term (about a quarter)
+	tradingDays := 21
 	switch rec.TimeHorizon {
 	case "short_term":
-		expiry = time.Now().Add(7 * 24 * time.Hour)
+		tradingDays = 5
 	case "long_term":
-		expiry = time.Now().Add(90 * 24 * time.Hour)
-	default:
-		expiry = time.Now().Add(30 * 24 * time.Hour)
+		tradingDays = 63
 	}
+	expiry := e.calendar.MarketClose(e.calendar.AddTradingDays(time.Now(), tradingDays))
 	rec.ExpiresAt = &expiry
 
 	return rec
@@ -339,8 +486,21 @@ func (e *Engine) buildReasoning(result *AnalysisResult) string {
 	if result.Fundamental != nil {
 		f := result.Fundamental
 
+		// State which financials the figures below come from
+		if f.Basis != "" {
+			reasons = append(reasons, fmt.Sprintf("Fundamentals are on a %s basis", f.Basis))
+		}
+		if result.DataStale {
+			reasons = append(reasons, fmt.Sprintf("Fundamentals are %s old and may not reflect the current price, so confidence is reduced",
+				FormatAge(result.DataAge)))
+		}
+
+		// Valuation and returns relative to the industry, which mean more
+		// than the absolute cut-offs below
+		reasons = append(reasons, result.Peers.Summary()...)
+
 		// P/E analysis
-		if f.StockPE > 0 {
+		if f.StockPE > 0 && result.Peers.Metric("P/E") == nil {
 			if f.StockPE < 15 {
 				reasons = append(reasons, fmt.Sprintf("Attractively valued with P/E of %.1f", f.StockPE))
 			} else if f.StockPE > 40 {
@@ -349,10 +509,12 @@ func (e *Engine) buildReasoning(result *AnalysisResult) string {
 		}
 
 		// ROE analysis
-		if f.ROE > 15 {
-			reasons = append(reasons, fmt.Sprintf("Strong return on equity at %.1f%%", f.ROE))
-		} else if f.ROE < 10 && f.ROE > 0 {
-			reasons = append(reasons, fmt.Sprintf("Below average ROE at %.1f%%", f.ROE))
+		if result.Peers.Metric("ROE") == nil {
+			if f.ROE > 15 {
+				reasons = append(reasons, fmt.Sprintf("Strong return on equity at %.1f%%", f.ROE))
+			} else if f.ROE < 10 && f.ROE > 0 {
+				reasons = append(reasons, fmt.Sprintf("Below average ROE at %.1f%%", f.ROE))
+			}
 		}
 
 		// Debt analysis
@@ -383,6 +545,9 @@ func (e *Engine) buildReasoning(result *AnalysisResult) string {
 		}
 	}
 
+	// Add notable shareholding changes
+	reasons = append(reasons, ShareholdingChanges(result.Shareholding)...)
+
 	// Add news sentiment
 	if len(result.News) > 0 {
 		switch result.NewsSentiment {
@@ -429,18 +594,60 @@ func (e *Engine) RefreshNews(ctx context.Context) (int, error) {
 		return 0, fmt.Errorf("failed to fetch news: %w", err)
 	}
 
-	count := 0
+	var ingested []storage.News
+	stocks := make(map[string]*storage.Stock)
 	for _, n := range news {
-		existing, _ := e.repo.GetNewsByURL(ctx, n.URL)
-		if existing == nil {
-			newsModel := n.ToNewsModel(nil)
-			if err := e.repo.CreateNews(ctx, newsModel); err == nil {
-				count++
+		saved, err := e.ingestNews(ctx, n, nil, stocks)
+		if err != nil {
+			continue
+		}
+		if saved != nil {
+			ingested = append(ingested, *saved)
+		}
+	}
+
+	if len(ingested) > 0 {
+		e.publish(ctx, EventNewsIngested, NewsIngested{Articles: ingested})
+	}
+	return len(ingested), nil
+}
+
+// ingestNews saves an article if it is new and links it to every tracked
+// stock it mentions, returning the article if it was new. The primary
+// stock, if given, is always linked. Stock lookups are cached in stocks,
+// keyed by symbol; a nil entry marks a symbol that is not tracked.
+func (e *Engine) ingestNews(ctx context.Context, n analyzer.FetchedNews, primary *storage.Stock, stocks map[string]*storage.Stock) (*storage.News, error) {
+	var primaryID *uint
+	symbols := n.RelatedSymbols
+	if primary != nil {
+		primaryID = &primary.ID
+		symbols = append([]string{primary.Symbol}, symbols...)
+	}
+
+	var links []storage.NewsStock
+	linked := make(map[uint]bool)
+	for _, symbol := range symbols {
+		stock, cached := stocks[symbol]
+		if !cached {
+			var err error
+			if stock, err = e.repo.GetStockBySymbol(ctx, symbol); err != nil {
+				return nil, err
 			}
+			stocks[symbol] = stock
+		}
+		if stock == nil || linked[stock.ID] {
+			continue
 		}
+		linked[stock.ID] = true
+		links = append(links, e.newsFetcher.ScoreMention(n, symbol).ToNewsStock(stock.ID))
 	}
 
-	return count, nil
+	news := n.ToNewsModel(primaryID)
+	created, err := e.repo.SaveNewsWithLinks(ctx, news, links)
+	if err != nil || !created {
+		return nil, err
+	}
+	return news, nil
 }
 
 // GetRecentNews retrieves recent news.
@@ -454,4 +661,3 @@ func min(a, b int) int {
 	}
 	return b
 }
-
@@ -0,0 +1,54 @@
+package recommender
+
+import (
+	"context"
+
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// Events published by the engine.
+const (
+	EventRecommendationCreated    = "recommendation.created"
+	EventRecommendationSuperseded = "recommendation.superseded"
+	EventDailyPicksCompleted      = "daily_picks.completed"
+	EventNewsIngested             = "news.ingested"
+)
+
+// EventPublisher receives the engine's events. Publish is called on the
+// path of the request or job that raised the event, so it should hand
+// slow work off rather than do it inline.
+type EventPublisher interface {
+	Publish(ctx context.Context, event string, data interface{})
+}
+
+// RecommendationSuperseded is the data of a recommendation.superseded
+// event: an active recommendation replaced by a newer one for its stock.
+type RecommendationSuperseded struct {
+	Recommendation storage.Recommendation `json:"recommendation"`
+	SupersededBy   uint                   `json:"superseded_by"`
+}
+
+// DailyPicksCompleted is the data of a daily_picks.completed event.
+type DailyPicksCompleted struct {
+	*DailyPicksResult
+	Filter *DailyPicksFilter `json:"filter,omitempty"`
+}
+
+// NewsIngested is the data of a news.ingested event.
+type NewsIngested struct {
+	Symbol   string         `json:"symbol,omitempty"` // the stock whose analysis fetched the news, if any
+	Articles []storage.News `json:"articles"`
+}
+
+// SetEventPublisher sets where the engine publishes its events. Without
+// one, events are dropped.
+func (e *Engine) SetEventPublisher(p EventPublisher) {
+	e.events = p
+}
+
+// publish publishes an event if a publisher is set.
+func (e *Engine) publish(ctx context.Context, event string, data interface{}) {
+	if e.events != nil {
+		e.events.Publish(ctx, event, data)
+	}
+}
@@ -0,0 +1,80 @@
+package recommender
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// fundamentalMaxAge returns how old a fundamental snapshot may be before it
+// is stale, according to the freshness policy for its source.
+func (e *Engine) fundamentalMaxAge(f *storage.StockFundamental) time.Duration {
+	return e.config.Freshness.MaxAgeFor(f.Source)
+}
+
+// isStale reports whether a fundamental snapshot is older than its source's
+// max age at now. A zero max age never goes stale.
+func (e *Engine) isStale(f *storage.StockFundamental, now time.Time) bool {
+	maxAge := e.fundamentalMaxAge(f)
+	return maxAge > 0 && now.Sub(f.FetchedAt) > maxAge
+}
+
+// staleCutoff returns the time before which data with maxAge is stale at
+// now. A zero max age never goes stale, so its cutoff is the zero time.
+func staleCutoff(now time.Time, maxAge time.Duration) time.Time {
+	if maxAge <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-maxAge)
+}
+
+// shouldRefresh reports whether analysis should re-scrape a stale
+// fundamental snapshot before using it.
+func (e *Engine) shouldRefresh(f *storage.StockFundamental, now time.Time) bool {
+	policy := e.config.Freshness
+	if !policy.RefreshOnAnalysis || !e.config.Screener.ScrapeEnabled || e.config.Screener.Offline {
+		return false
+	}
+	if policy.MarketHoursOnly && !e.calendar.IsOpen(now) {
+		return false
+	}
+	return e.isStale(f, now)
+}
+
+// stalenessPenalty returns the confidence points to take off a
+// recommendation based on fundamentals of the given age: the configured
+// penalty for each max age elapsed, up to the configured cap. Fresh data
+// has no penalty.
+func (e *Engine) stalenessPenalty(age, maxAge time.Duration) float64 {
+	if maxAge <= 0 || age <= maxAge {
+		return 0
+	}
+
+	policy := e.config.Freshness
+	penalty := policy.ConfidencePenalty * age.Hours() / maxAge.Hours()
+	if policy.MaxConfidencePenalty > 0 {
+		penalty = math.Min(penalty, policy.MaxConfidencePenalty)
+	}
+	return math.Round(penalty)
+}
+
+// FormatAge describes a data age in the largest whole unit, e.g. "3 days".
+func FormatAge(age time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+
+	switch {
+	case age >= 48*time.Hour:
+		return plural(int(age.Hours()/24), "day")
+	case age >= time.Hour:
+		return plural(int(age.Hours()), "hour")
+	default:
+		return plural(int(age.Minutes()), "minute")
+	}
+}
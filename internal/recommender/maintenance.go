@@ -0,0 +1,143 @@
+package recommender
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/user/stock-recommender/internal/screener"
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// ExpireRecommendations deactivates recommendations that have passed their
+// expiry time, as well as any older than maxAge regardless of expiry.
+// It returns the number of recommendations expired by ExpiresAt.
+func (e *Engine) ExpireRecommendations(ctx context.Context, maxAge time.Duration) (int64, error) {
+	expired, err := e.repo.ExpireRecommendations(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire recommendations: %w", err)
+	}
+
+	if maxAge > 0 {
+		if err := e.repo.DeactivateOldRecommendations(ctx, maxAge); err != nil {
+			return expired, fmt.Errorf("failed to deactivate old recommendations: %w", err)
+		}
+	}
+
+	return expired, nil
+}
+
+// RefreshFundamentals scrapes screener.in for a stock and stores a new
+// fundamental snapshot.
+func (e *Engine) RefreshFundamentals(ctx context.Context, stock *storage.Stock) (*storage.StockFundamental, error) {
+	stockData, err := e.screenerScraper.FetchStockBasis(ctx, stock.Symbol, e.financialBasis(stock))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s from screener: %w", stock.Symbol, err)
+	}
+
+	fundamental, err := e.saveStockData(ctx, stock, stockData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save fundamentals for %s: %w", stock.Symbol, err)
+	}
+
+	return fundamental, nil
+}
+
+// SetFinancialBasis sets the basis a stock's financials are fetched on, or
+// clears it with "" to use the configured default. If scraping is enabled
+// the stock's fundamentals are refreshed on the new basis and returned.
+func (e *Engine) SetFinancialBasis(ctx context.Context, stock *storage.Stock, basis string) (*storage.StockFundamental, error) {
+	switch basis {
+	case "", storage.BasisConsolidated, storage.BasisStandalone:
+	default:
+		return nil, fmt.Errorf("invalid financial basis %q", basis)
+	}
+
+	stock.FinancialBasis = basis
+	if err := e.repo.UpdateStock(ctx, stock); err != nil {
+		return nil, fmt.Errorf("failed to update %s: %w", stock.Symbol, err)
+	}
+
+	if !e.config.Screener.ScrapeEnabled {
+		return nil, nil
+	}
+	return e.RefreshFundamentals(ctx, stock)
+}
+
+// financialBasis returns the basis to fetch a stock's financials on.
+func (e *Engine) financialBasis(stock *storage.Stock) string {
+	if stock != nil && stock.FinancialBasis != "" {
+		return stock.FinancialBasis
+	}
+	return screener.NormalizeBasis(e.config.Screener.Basis)
+}
+
+// saveStockData stores a fundamental snapshot and the financial statements
+// and shareholding scraped for a stock. Failing to store the latter is only
+// a warning, since the snapshot is what analysis depends on.
+func (e *Engine) saveStockData(ctx context.Context, stock *storage.Stock, stockData *screener.StockData) (*storage.StockFundamental, error) {
+	fundamental := stockData.ToFundamental(stock.ID)
+	if err := e.repo.CreateFundamental(ctx, fundamental); err != nil {
+		return nil, err
+	}
+
+	if err := e.repo.SaveFinancialStatements(ctx, stock.ID, stockData.Statements); err != nil {
+		fmt.Printf("Warning: failed to save financial statements for %s: %v\n", stock.Symbol, err)
+	}
+
+	if err := e.repo.SaveShareholdingSnapshots(ctx, stock.ID, stockData.Shareholding); err != nil {
+		fmt.Printf("Warning: failed to save shareholding for %s: %v\n", stock.Symbol, err)
+	}
+
+	// Stocks created from a CSV upload have no industry to find peers by
+	if stock.Industry == "" && stockData.Industry != "" {
+		stock.Sector, stock.Industry = stockData.Sector, stockData.Industry
+		if err := e.repo.UpdateStock(ctx, stock); err != nil {
+			fmt.Printf("Warning: failed to update industry of %s: %v\n", stock.Symbol, err)
+		}
+	}
+
+	if err := e.repo.SaveIndustryPeers(ctx, stockData.Industry, stockData.Peers); err != nil {
+		fmt.Printf("Warning: failed to save peers of %s: %v\n", stock.Symbol, err)
+	}
+
+	return fundamental, nil
+}
+
+// RefreshStaleFundamentals re-scrapes fundamentals for up to limit stocks
+// whose latest snapshot is older than the max age for its source under the
+// freshness policy. It returns the number of stocks refreshed and the number
+// that failed.
+func (e *Engine) RefreshStaleFundamentals(ctx context.Context, limit int) (int, int, error) {
+	// Offline, a refresh would only re-store the cached pages
+	if !e.config.Screener.ScrapeEnabled || e.config.Screener.Offline {
+		return 0, 0, nil
+	}
+
+	now := time.Now()
+	policy := e.config.Freshness
+	cutoffs := make(map[string]time.Time, len(policy.MaxAge))
+	for source := range policy.MaxAge {
+		cutoffs[source] = staleCutoff(now, policy.MaxAgeFor(source))
+	}
+	stocks, err := e.repo.ListStocksWithStaleFundamentals(ctx, cutoffs, staleCutoff(now, policy.DefaultMaxAge), limit)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list stale stocks: %w", err)
+	}
+
+	refreshed, failed := 0, 0
+	for i := range stocks {
+		if ctx.Err() != nil {
+			return refreshed, failed, ctx.Err()
+		}
+
+		if _, err := e.RefreshFundamentals(ctx, &stocks[i]); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			failed++
+			continue
+		}
+		refreshed++
+	}
+
+	return refreshed, failed, nil
+}
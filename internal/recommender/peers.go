@@ -0,0 +1,220 @@
+package recommender
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// Thresholds for ranking a stock against its peers.
+const (
+	// minPeers is the fewest peers with a value needed to rank a metric.
+	minPeers = 3
+	// peerQuartile is the percentile distance from either end at which a
+	// metric stands out from the peer group.
+	peerQuartile = 25.0
+)
+
+// PeerMetric ranks one of a stock's metrics within its industry.
+type PeerMetric struct {
+	Name       string  `json:"name"`
+	Value      float64 `json:"value"`
+	Median     float64 `json:"median"`     // median of the peers, excluding the stock
+	Percentile float64 `json:"percentile"` // share of peers with a lower value
+	Peers      int     `json:"peers"`      // peers with a value
+}
+
+// PeerComparison ranks a stock's valuation and returns against the peer
+// group of its industry.
+type PeerComparison struct {
+	Industry string                 `json:"industry"`
+	Peers    []storage.IndustryPeer `json:"peers"`
+	Metrics  []PeerMetric           `json:"metrics"`
+}
+
+// peerMetrics are the metrics compared. Valuations must be positive to be
+// ranked, as loss-making companies have no meaningful P/E; returns only need
+// to be known.
+var peerMetrics = []struct {
+	name      string
+	valuation bool
+	peer      func(p storage.IndustryPeer) float64
+	stock     func(f *storage.StockFundamental) float64
+}{
+	{"P/E", true, func(p storage.IndustryPeer) float64 { return p.StockPE }, func(f *storage.StockFundamental) float64 { return f.StockPE }},
+	{"P/B", true, func(p storage.IndustryPeer) float64 { return p.PriceToBook }, func(f *storage.StockFundamental) float64 { return f.PriceToBook }},
+	{"ROE", false, func(p storage.IndustryPeer) float64 { return p.ROE }, func(f *storage.StockFundamental) float64 { return f.ROE }},
+	{"ROCE", false, func(p storage.IndustryPeer) float64 { return p.ROCE }, func(f *storage.StockFundamental) float64 { return f.ROCE }},
+}
+
+// ComparePeers ranks a stock against the stored peer group of its industry.
+// Peer figures missing from screener.in's table, usually P/B and ROE, are
+// taken from the peers' own latest fundamentals where they are tracked. It
+// returns nil if the stock has no industry or the industry no peers.
+func (e *Engine) ComparePeers(ctx context.Context, stock *storage.Stock, fundamental *storage.StockFundamental) (*PeerComparison, error) {
+	if stock.Industry == "" || fundamental == nil {
+		return nil, nil
+	}
+
+	peers, err := e.repo.ListIndustryPeers(ctx, stock.Industry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list peers: %w", err)
+	}
+	if len(peers) == 0 {
+		return nil, nil
+	}
+
+	for i := range peers {
+		if err := e.fillPeerFundamentals(ctx, &peers[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return comparePeers(stock.Symbol, fundamental, stock.Industry, peers), nil
+}
+
+// fillPeerFundamentals fills the figures a peer row lacks from the peer's
+// latest fundamentals, if the peer is a tracked stock.
+func (e *Engine) fillPeerFundamentals(ctx context.Context, peer *storage.IndustryPeer) error {
+	if peer.StockPE != 0 && peer.PriceToBook != 0 && peer.ROE != 0 && peer.ROCE != 0 {
+		return nil
+	}
+
+	stock, err := e.repo.GetStockBySymbol(ctx, peer.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get peer %s: %w", peer.Symbol, err)
+	}
+	if stock == nil {
+		return nil
+	}
+	f, err := e.repo.GetLatestFundamental(ctx, stock.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get fundamentals of peer %s: %w", peer.Symbol, err)
+	}
+	if f == nil {
+		return nil
+	}
+
+	if peer.StockPE == 0 {
+		peer.StockPE = f.StockPE
+	}
+	if peer.PriceToBook == 0 {
+		peer.PriceToBook = f.PriceToBook
+	}
+	if peer.ROE == 0 {
+		peer.ROE = f.ROE
+	}
+	if peer.ROCE == 0 {
+		peer.ROCE = f.ROCE
+	}
+	return nil
+}
+
+// comparePeers ranks a stock's fundamentals against peers, leaving out the
+// stock's own row. Metrics the stock lacks, or that fewer than minPeers
+// peers have, are skipped.
+func comparePeers(symbol string, f *storage.StockFundamental, industry string, peers []storage.IndustryPeer) *PeerComparison {
+	comparison := &PeerComparison{Industry: industry, Peers: peers}
+
+	for _, metric := range peerMetrics {
+		value := metric.stock(f)
+		if value == 0 || (metric.valuation && value < 0) {
+			continue
+		}
+
+		var values []float64
+		for _, peer := range peers {
+			v := metric.peer(peer)
+			if peer.Symbol == symbol || v == 0 || (metric.valuation && v < 0) {
+				continue
+			}
+			values = append(values, v)
+		}
+		if len(values) < minPeers {
+			continue
+		}
+
+		comparison.Metrics = append(comparison.Metrics, PeerMetric{
+			Name:       metric.name,
+			Value:      value,
+			Median:     median(values),
+			Percentile: percentileRank(values, value),
+			Peers:      len(values),
+		})
+	}
+	return comparison
+}
+
+// Metric returns the ranking of a metric, or nil if it was not ranked.
+func (c *PeerComparison) Metric(name string) *PeerMetric {
+	if c == nil {
+		return nil
+	}
+	for i := range c.Metrics {
+		if c.Metrics[i].Name == name {
+			return &c.Metrics[i]
+		}
+	}
+	return nil
+}
+
+// Summary describes where the stock's valuation and returns sit within its
+// peer group, e.g. "P/E of 18.2 is lower than 80% of 10 Industrial
+// Machinery peers (median 32.5)".
+func (c *PeerComparison) Summary() []string {
+	if c == nil {
+		return nil
+	}
+
+	var lines []string
+	for _, m := range c.Metrics {
+		unit := ""
+		if m.Name == "ROE" || m.Name == "ROCE" {
+			unit = "%"
+		}
+		peers := fmt.Sprintf("%d %s peers (median %.1f%s)", m.Peers, c.Industry, m.Median, unit)
+
+		switch {
+		case m.Percentile <= peerQuartile:
+			lines = append(lines, fmt.Sprintf("%s of %.1f%s is lower than %.0f%% of %s",
+				m.Name, m.Value, unit, 100-m.Percentile, peers))
+		case m.Percentile >= 100-peerQuartile:
+			lines = append(lines, fmt.Sprintf("%s of %.1f%s is higher than %.0f%% of %s",
+				m.Name, m.Value, unit, m.Percentile, peers))
+		default:
+			lines = append(lines, fmt.Sprintf("%s of %.1f%s is in line with %s",
+				m.Name, m.Value, unit, peers))
+		}
+	}
+	return lines
+}
+
+// median returns the median of values.
+func median(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// percentileRank returns the percentage of values below value, counting
+// ties as half.
+func percentileRank(values []float64, value float64) float64 {
+	var below float64
+	for _, v := range values {
+		switch {
+		case v < value:
+			below++
+		case v == value:
+			below += 0.5
+		}
+	}
+	return below / float64(len(values)) * 100
+}
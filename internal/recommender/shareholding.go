@@ -0,0 +1,116 @@
+package recommender
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// Thresholds for reporting shareholding changes, in percentage points.
+const (
+	// stakeChangeEpsilon is the smallest change counted as a move.
+	stakeChangeEpsilon = 0.05
+	// largeStakeChange is a single-quarter change worth reporting alone.
+	largeStakeChange = 1.0
+	// pledgeRiseThreshold is a rise in pledged promoter shares worth reporting.
+	pledgeRiseThreshold = 0.5
+	// highPledge is a pledge level that is a concern on its own.
+	highPledge = 10.0
+)
+
+// shareholderGroups are the holder groups whose trends are reported.
+var shareholderGroups = []struct {
+	name  string
+	stake func(s storage.ShareholdingSnapshot) float64
+}{
+	{"Promoters", func(s storage.ShareholdingSnapshot) float64 { return s.Promoters }},
+	{"FIIs", func(s storage.ShareholdingSnapshot) float64 { return s.FIIs }},
+	{"DIIs", func(s storage.ShareholdingSnapshot) float64 { return s.DIIs }},
+}
+
+// ShareholdingChanges describes notable recent changes in a stock's
+// shareholding pattern, such as FIIs cutting their stake several quarters in
+// a row or a rise in pledged promoter shares. Snapshots may be in any order.
+func ShareholdingChanges(snapshots []storage.ShareholdingSnapshot) []string {
+	if len(snapshots) < 2 {
+		return nil
+	}
+
+	series := make([]storage.ShareholdingSnapshot, len(snapshots))
+	copy(series, snapshots)
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].PeriodEnd.Before(series[j].PeriodEnd)
+	})
+
+	latest := series[len(series)-1]
+	previous := series[len(series)-2]
+
+	var changes []string
+	for _, group := range shareholderGroups {
+		if change := stakeTrend(series, group.name, group.stake); change != "" {
+			changes = append(changes, change)
+		}
+	}
+
+	// The pledge is only known for quarters it was recorded in, so a rise
+	// is only reported against a recorded one.
+	if latest.PledgedPromoter != nil {
+		pledged := *latest.PledgedPromoter
+		if previous.PledgedPromoter != nil && pledged-*previous.PledgedPromoter >= pledgeRiseThreshold {
+			changes = append(changes, fmt.Sprintf("Promoter pledge rose to %.1f%% in %s from %.1f%%",
+				pledged, latest.Period, *previous.PledgedPromoter))
+		} else if pledged >= highPledge {
+			changes = append(changes, fmt.Sprintf("%.1f%% of promoter shares are pledged", pledged))
+		}
+	}
+
+	if previous.Shareholders > 0 && latest.Shareholders > 0 {
+		growth := float64(latest.Shareholders-previous.Shareholders) / float64(previous.Shareholders) * 100
+		if math.Abs(growth) >= 10 {
+			direction := "rose"
+			if growth < 0 {
+				direction = "fell"
+			}
+			changes = append(changes, fmt.Sprintf("Number of shareholders %s %.0f%% in %s", direction, math.Abs(growth), latest.Period))
+		}
+	}
+
+	return changes
+}
+
+// stakeTrend describes a holder group's stake if it has moved in the same
+// direction for two or more quarters running, or by a large amount in the
+// latest quarter. series must be oldest first.
+func stakeTrend(series []storage.ShareholdingSnapshot, name string, stake func(storage.ShareholdingSnapshot) float64) string {
+	last := len(series) - 1
+	change := stake(series[last]) - stake(series[last-1])
+	if math.Abs(change) < stakeChangeEpsilon {
+		return ""
+	}
+
+	// Count consecutive quarters moving the same way, ending at the latest.
+	run := 1
+	for i := last - 1; i > 0; i-- {
+		prior := stake(series[i]) - stake(series[i-1])
+		if math.Abs(prior) < stakeChangeEpsilon || (prior > 0) != (change > 0) {
+			break
+		}
+		run++
+	}
+
+	verb := "raised"
+	if change < 0 {
+		verb = "cut"
+	}
+	from, to := stake(series[last-run]), stake(series[last])
+
+	switch {
+	case run >= 2:
+		return fmt.Sprintf("%s have %s stake %d quarters in a row (%.2f%% to %.2f%%)", name, verb, run, from, to)
+	case math.Abs(change) >= largeStakeChange:
+		return fmt.Sprintf("%s %s stake by %.2f points in %s (%.2f%% to %.2f%%)", name, verb, math.Abs(change), series[last].Period, from, to)
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package recommender
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+func pledge(p float64) *float64 { return &p }
+
+func TestShareholdingChangesPledge(t *testing.T) {
+	quarter := func(month time.Month, year int, pledged *float64) storage.ShareholdingSnapshot {
+		end := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+		return storage.ShareholdingSnapshot{
+			Period:          end.Format("Jan 2006"),
+			PeriodEnd:       end,
+			Promoters:       50,
+			FIIs:            20,
+			DIIs:            15,
+			PledgedPromoter: pledged,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		previous *float64
+		latest   *float64
+		want     string // substring of the reported change, or "" for none
+	}{
+		{"previous not recorded", nil, pledge(4), ""},
+		{"latest not recorded", pledge(4), nil, ""},
+		{"both not recorded", nil, nil, ""},
+		{"recorded rise", pledge(1), pledge(4), "Promoter pledge rose to 4.0% in Dec 2024 from 1.0%"},
+		{"small rise", pledge(1), pledge(1.2), ""},
+		{"high pledge without history", nil, pledge(12.5), "12.5% of promoter shares are pledged"},
+		{"high pledge unchanged", pledge(12.5), pledge(12.5), "12.5% of promoter shares are pledged"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := ShareholdingChanges([]storage.ShareholdingSnapshot{
+				quarter(time.December, 2024, tt.latest),
+				quarter(time.September, 2024, tt.previous),
+			})
+
+			var pledgeChanges []string
+			for _, c := range changes {
+				if strings.Contains(c, "pledge") {
+					pledgeChanges = append(pledgeChanges, c)
+				}
+			}
+			switch {
+			case tt.want == "" && len(pledgeChanges) > 0:
+				t.Errorf("got %q, want no pledge change", pledgeChanges)
+			case tt.want != "" && (len(pledgeChanges) != 1 || pledgeChanges[0] != tt.want):
+				t.Errorf("got %q, want %q", pledgeChanges, tt.want)
+			}
+		})
+	}
+}
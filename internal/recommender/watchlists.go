@@ -0,0 +1,131 @@
+package recommender
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/pkg/textutil"
+)
+
+// ActionChange is a watchlist stock whose recommended action changed in a
+// re-analysis.
+type ActionChange struct {
+	Watchlist        string         `json:"watchlist"`
+	Symbol           string         `json:"symbol"`
+	From             storage.Action `json:"from"`
+	To               storage.Action `json:"to"`
+	RecommendationID uint           `json:"recommendation_id"`
+}
+
+// WatchlistRunResult summarises a re-analysis of watchlists.
+type WatchlistRunResult struct {
+	Watchlists int            `json:"watchlists"`
+	Analyzed   int            `json:"analyzed"`
+	Failed     int            `json:"failed"`
+	Changes    []ActionChange `json:"changes"`
+}
+
+// watchlistAnalysis is the outcome of analysing one stock in a run.
+type watchlistAnalysis struct {
+	rec *storage.Recommendation
+	err error
+}
+
+// AnalyzeWatchlists re-runs AnalyzeStock for every stock on the given
+// watchlists, recording the outcome on each item and the action changes
+// since its previous analysis. A stock on several watchlists is analysed
+// once.
+func (e *Engine) AnalyzeWatchlists(ctx context.Context, watchlists []storage.Watchlist) (*WatchlistRunResult, error) {
+	result := &WatchlistRunResult{Changes: []ActionChange{}}
+	analyses := make(map[uint]*watchlistAnalysis)
+
+	for i := range watchlists {
+		w := &watchlists[i]
+		result.Watchlists++
+
+		for j := range w.Items {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			item := &w.Items[j]
+
+			previous := item.LastAction
+			if previous == "" {
+				latest, err := e.repo.GetLatestRecommendationForStock(ctx, item.StockID)
+				if err != nil {
+					return result, err
+				}
+				if latest != nil {
+					previous = latest.Action
+				}
+			}
+
+			analysis, ok := analyses[item.StockID]
+			if !ok {
+				analysis = &watchlistAnalysis{}
+				res, err := e.AnalyzeStock(ctx, item.Symbol)
+				switch {
+				case err != nil:
+					analysis.err = err
+				case res == nil || res.Recommendation == nil:
+					analysis.err = fmt.Errorf("no recommendation made")
+				default:
+					analysis.rec = res.Recommendation
+				}
+				analyses[item.StockID] = analysis
+
+				if analysis.err != nil {
+					result.Failed++
+					fmt.Printf("  ⚠ Failed to analyze %s: %v\n", item.Symbol, analysis.err)
+				} else {
+					result.Analyzed++
+				}
+			}
+
+			now := time.Now()
+			item.LastAnalyzedAt = &now
+			if analysis.err != nil {
+				item.LastError = textutil.Truncate(analysis.err.Error(), 255)
+			} else {
+				rec := analysis.rec
+				item.LastError = ""
+				item.PreviousAction = previous
+				item.LastAction = rec.Action
+				item.LastRecommendationID = &rec.ID
+				item.ActionChanged = previous != "" && previous != rec.Action
+				if item.ActionChanged {
+					item.ActionChangedAt = &now
+					result.Changes = append(result.Changes, ActionChange{
+						Watchlist:        w.Name,
+						Symbol:           item.Symbol,
+						From:             previous,
+						To:               rec.Action,
+						RecommendationID: rec.ID,
+					})
+				}
+			}
+			if err := e.repo.UpdateWatchlistItem(ctx, item); err != nil {
+				return result, fmt.Errorf("failed to save watchlist item %s: %w", item.Symbol, err)
+			}
+		}
+
+		now := time.Now()
+		w.LastAnalyzedAt = &now
+		if err := e.repo.UpdateWatchlist(ctx, w); err != nil {
+			return result, fmt.Errorf("failed to save watchlist %s: %w", w.Name, err)
+		}
+	}
+
+	return result, nil
+}
+
+// AnalyzeAutoWatchlists re-analyses every watchlist with AutoAnalyze set.
+func (e *Engine) AnalyzeAutoWatchlists(ctx context.Context) (*WatchlistRunResult, error) {
+	watchlists, err := e.repo.ListWatchlists(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list watchlists: %w", err)
+	}
+	return e.AnalyzeWatchlists(ctx, watchlists)
+}
@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/user/stock-recommender/internal/alerts"
+	"github.com/user/stock-recommender/internal/calendar"
+	"github.com/user/stock-recommender/internal/recommender"
+	"github.com/user/stock-recommender/internal/webhooks"
+	"github.com/user/stock-recommender/pkg/config"
+)
+
+// Job names.
+const (
+	JobNewsIngestion        = "news_ingestion"
+	JobRecommendationExpiry = "recommendation_expiry"
+	JobFundamentalsRefresh  = "fundamentals_refresh"
+	JobDailyPicks           = "daily_picks"
+	JobMarketConditions     = "market_conditions"
+	JobWatchlistAnalysis    = "watchlist_analysis"
+	JobAlerts               = "alerts"
+	JobWebhookRetries       = "webhook_retries"
+)
+
+// RegisterDefaultJobs registers the built-in jobs using the configured schedules.
+// Jobs that depend on a trading session are skipped on exchange holidays.
+func RegisterDefaultJobs(s *Scheduler, engine *recommender.Engine, alerter *alerts.Service, hooks *webhooks.Dispatcher, cal *calendar.Calendar, cfg *config.Config) error {
+	schedCfg := cfg.Scheduler
+
+	newsSchedule := schedCfg.NewsIngestion
+	if newsSchedule == "" && cfg.News.FetchInterval > 0 {
+		newsSchedule = "@every " + cfg.News.FetchInterval.String()
+	}
+
+	jobs := []struct {
+		name     string
+		schedule string
+		fn       JobFunc
+	}{
+		{JobNewsIngestion, newsSchedule, func(ctx context.Context) (string, error) {
+			count, err := engine.RefreshNews(ctx)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d new articles", count), nil
+		}},
+		{JobRecommendationExpiry, schedCfg.RecommendationExpiry, func(ctx context.Context) (string, error) {
+			expired, err := engine.ExpireRecommendations(ctx, schedCfg.RecommendationMaxAge)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d recommendations expired", expired), nil
+		}},
+		{JobFundamentalsRefresh, schedCfg.FundamentalsRefresh, tradingDaysOnly(cal, func(ctx context.Context) (string, error) {
+			refreshed, failed, err := engine.RefreshStaleFundamentals(ctx, schedCfg.FundamentalsBatchSize)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d refreshed, %d failed", refreshed, failed), nil
+		})},
+		{JobDailyPicks, schedCfg.DailyPicks, tradingDaysOnly(cal, func(ctx context.Context) (string, error) {
+			result, err := engine.GenerateDailyPicks(ctx)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d picks from %d analyzed", len(result.Picks), result.TotalAnalyzed), nil
+		})},
+		{JobMarketConditions, schedCfg.MarketConditions, tradingDaysOnly(cal, func(ctx context.Context) (string, error) {
+			conditions, err := engine.Market().Ingest(ctx)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d market conditions recorded", len(conditions)), nil
+		})},
+		{JobWatchlistAnalysis, schedCfg.WatchlistAnalysis, tradingDaysOnly(cal, func(ctx context.Context) (string, error) {
+			result, err := engine.AnalyzeAutoWatchlists(ctx)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d stocks on %d watchlists analyzed, %d failed, %d action changes",
+				result.Analyzed, result.Watchlists, result.Failed, len(result.Changes)), nil
+		})},
+		{JobAlerts, schedCfg.Alerts, func(ctx context.Context) (string, error) {
+			result, err := alerter.Run(ctx)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d rules evaluated, %d alerts raised, %d sent, %d retrying, %d failed",
+				result.Rules, result.Raised, result.Sent, result.Retrying, result.Failed), nil
+		}},
+		{JobWebhookRetries, schedCfg.WebhookRetries, func(ctx context.Context) (string, error) {
+			result, err := hooks.RetryDue(ctx)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d delivered, %d retrying, %d failed", result.Delivered, result.Retrying, result.Failed), nil
+		}},
+	}
+
+	for _, j := range jobs {
+		if err := s.Register(j.name, j.schedule, j.fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tradingDaysOnly wraps a job so that it is skipped on non-trading days.
+func tradingDaysOnly(cal *calendar.Calendar, fn JobFunc) JobFunc {
+	return func(ctx context.Context) (string, error) {
+		now := time.Now()
+		if !cal.IsTradingDay(now) {
+			if holiday, ok := cal.Holiday(now); ok {
+				return "skipped: market holiday (" + holiday + ")", nil
+			}
+			return "skipped: not a trading day", nil
+		}
+		return fn(ctx)
+	}
+}
@@ -0,0 +1,282 @@
+// Package scheduler runs periodic background jobs such as news ingestion,
+// recommendation expiry and fundamentals refresh.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// Job run statuses.
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Job run triggers.
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// JobFunc is the work performed by a job. The returned message is stored
+// with the run history.
+type JobFunc func(ctx context.Context) (string, error)
+
+// JobInfo describes a registered job and its upcoming run.
+type JobInfo struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	Running  bool      `json:"running"`
+	NextRun  time.Time `json:"next_run"`
+	PrevRun  time.Time `json:"prev_run,omitempty"`
+}
+
+// job is a registered job.
+type job struct {
+	name     string
+	schedule string
+	fn       JobFunc
+	entryID  cron.EntryID
+}
+
+// Scheduler runs registered jobs on cron schedules and records each run.
+type Scheduler struct {
+	cron     *cron.Cron
+	repo     *storage.Repository
+	location *time.Location
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	running map[string]bool
+}
+
+// New creates a scheduler that evaluates schedules in the given location.
+func New(repo *storage.Repository, location *time.Location) *Scheduler {
+	if location == nil {
+		location = time.Local
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		cron:     cron.New(cron.WithLocation(location)),
+		repo:     repo,
+		location: location,
+		ctx:      ctx,
+		cancel:   cancel,
+		jobs:     make(map[string]*job),
+		running:  make(map[string]bool),
+	}
+}
+
+// Register adds a job with a cron schedule. An empty schedule disables the job.
+func (s *Scheduler) Register(name, schedule string, fn JobFunc) error {
+	if schedule == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.jobs[name]; exists {
+		return fmt.Errorf("job %s already registered", name)
+	}
+
+	j := &job{name: name, schedule: schedule, fn: fn}
+	entryID, err := s.cron.AddFunc(schedule, func() {
+		s.run(j, TriggerSchedule)
+	})
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q for job %s: %w", schedule, name, err)
+	}
+	j.entryID = entryID
+	s.jobs[name] = j
+
+	return nil
+}
+
+// Start starts running jobs on their schedules.
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop stops scheduling new runs, cancels in-flight jobs and waits for them
+// to finish or for ctx to expire.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	cronDone := s.cron.Stop()
+
+	s.mu.Lock()
+	s.cancel()
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		<-cronDone.Done()
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ErrJobRunning is returned by RunNow when a run of the job is in progress.
+var ErrJobRunning = errors.New("job is already running")
+
+// errStopped is returned by RunNow once the scheduler is stopped.
+var errStopped = errors.New("scheduler is stopped")
+
+// RunNow triggers a job immediately in the background. It returns
+// ErrJobRunning if the job is already running.
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("job %s not found", name)
+	}
+	// Claim the run before Stop can cancel and start waiting, so Stop
+	// waits for it.
+	if err := s.start(j); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Unlock()
+
+	go s.execute(j, TriggerManual)
+	return nil
+}
+
+// Jobs returns the registered jobs sorted by name.
+func (s *Scheduler) Jobs() []JobInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos := make([]JobInfo, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		entry := s.cron.Entry(j.entryID)
+		infos = append(infos, JobInfo{
+			Name:     j.name,
+			Schedule: j.schedule,
+			Running:  s.running[j.name],
+			NextRun:  entry.Next,
+			PrevRun:  entry.Prev,
+		})
+	}
+
+	sort.Slice(infos, func(i, k int) bool {
+		return infos[i].Name < infos[k].Name
+	})
+	return infos
+}
+
+// HasJob reports whether a job with the given name is registered.
+func (s *Scheduler) HasJob(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.jobs[name]
+	return ok
+}
+
+// Location returns the timezone schedules are evaluated in.
+func (s *Scheduler) Location() *time.Location {
+	return s.location
+}
+
+// run executes a job once, skipping it if a previous run is still going.
+func (s *Scheduler) run(j *job, trigger string) {
+	s.mu.Lock()
+	err := s.start(j)
+	s.mu.Unlock()
+	if err != nil {
+		return
+	}
+	s.execute(j, trigger)
+}
+
+// start marks a job as running, unless it already is or the scheduler is
+// stopped. s.mu must be held. A started run must be finished by execute.
+func (s *Scheduler) start(j *job) error {
+	if s.ctx.Err() != nil {
+		return errStopped
+	}
+	if s.running[j.name] {
+		return ErrJobRunning
+	}
+	s.running[j.name] = true
+	s.wg.Add(1)
+	return nil
+}
+
+// execute runs a started job and records the run.
+func (s *Scheduler) execute(j *job, trigger string) {
+	defer func() {
+		s.mu.Lock()
+		s.running[j.name] = false
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	record := &storage.JobRun{
+		JobName:   j.name,
+		Trigger:   trigger,
+		Status:    StatusRunning,
+		StartedAt: time.Now(),
+	}
+	s.saveRun(record, s.repo.CreateJobRun)
+
+	message, err := s.safeCall(j)
+
+	finished := time.Now()
+	record.FinishedAt = &finished
+	record.DurationMS = finished.Sub(record.StartedAt).Milliseconds()
+	record.Message = message
+	if err != nil {
+		record.Status = StatusFailed
+		record.Message = err.Error()
+		fmt.Printf("  ⚠ Job %s failed: %v\n", j.name, err)
+	} else {
+		record.Status = StatusSucceeded
+	}
+
+	if record.ID != 0 {
+		s.saveRun(record, s.repo.UpdateJobRun)
+	}
+}
+
+// saveRun persists run history with its own context so the final status is
+// recorded even when the job was cancelled by shutdown.
+func (s *Scheduler) saveRun(record *storage.JobRun, save func(context.Context, *storage.JobRun) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := save(ctx, record); err != nil {
+		fmt.Printf("Warning: failed to record run of job %s: %v\n", record.JobName, err)
+	}
+}
+
+// safeCall runs the job function, converting a panic into an error.
+func (s *Scheduler) safeCall(j *job) (message string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return j.fn(s.ctx)
+}
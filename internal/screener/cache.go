@@ -0,0 +1,152 @@
+package screener
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNotCached is returned in offline mode when a page is not in the cache.
+var ErrNotCached = errors.New("page not in screener cache")
+
+// CachedPage is a company page stored by PageCache.
+type CachedPage struct {
+	Key          string    `json:"key"`
+	URL          string    `json:"url"`
+	FetchedAt    time.Time `json:"fetched_at"` // last fetched or revalidated
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Body         []byte    `json:"-"`
+}
+
+// PageCache stores fetched company pages on disk, one <KEY>.html file per
+// page with its response metadata in <KEY>.json. The HTML files are the
+// pages exactly as served, so they can be copied into testdata and parsed
+// with ParseCompanyPage. HTML files without metadata, such as pages saved
+// from a browser, are used with their modification time as fetch time.
+type PageCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// NewPageCache creates a page cache in dir. Pages younger than ttl are
+// fresh and served without contacting screener.in.
+func NewPageCache(dir string, ttl time.Duration) *PageCache {
+	return &PageCache{dir: dir, ttl: ttl}
+}
+
+// Dir returns the cache directory.
+func (c *PageCache) Dir() string {
+	return c.dir
+}
+
+// Path returns the HTML file a page is cached in.
+func (c *PageCache) Path(key string) string {
+	return filepath.Join(c.dir, cacheFileName(key)+".html")
+}
+
+// metaPath returns the metadata file of a cached page.
+func (c *PageCache) metaPath(key string) string {
+	return filepath.Join(c.dir, cacheFileName(key)+".json")
+}
+
+// Fresh reports whether a cached page can be served without revalidation.
+func (c *PageCache) Fresh(page *CachedPage) bool {
+	return time.Since(page.FetchedAt) < c.ttl
+}
+
+// Get returns a cached page, or nil if it is not cached.
+func (c *PageCache) Get(key string) (*CachedPage, error) {
+	path := c.Path(key)
+	body, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached page: %w", err)
+	}
+
+	page := &CachedPage{Key: key}
+	meta, err := os.ReadFile(c.metaPath(key))
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(meta, page); err != nil {
+			return nil, fmt.Errorf("failed to read cache metadata for %s: %w", key, err)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		info, statErr := os.Stat(path)
+		if statErr != nil {
+			return nil, fmt.Errorf("failed to read cached page: %w", statErr)
+		}
+		page.FetchedAt = info.ModTime()
+	default:
+		return nil, fmt.Errorf("failed to read cache metadata for %s: %w", key, err)
+	}
+
+	page.Body = body
+	return page, nil
+}
+
+// Put stores a page, replacing any cached copy.
+func (c *PageCache) Put(page *CachedPage) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := writeFileAtomic(c.Path(page.Key), page.Body); err != nil {
+		return fmt.Errorf("failed to cache page %s: %w", page.Key, err)
+	}
+	return c.saveMeta(page)
+}
+
+// Touch records that a cached page was revalidated.
+func (c *PageCache) Touch(page *CachedPage) error {
+	page.FetchedAt = time.Now()
+	return c.saveMeta(page)
+}
+
+// saveMeta writes the metadata of a cached page.
+func (c *PageCache) saveMeta(page *CachedPage) error {
+	meta, err := json.MarshalIndent(page, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache metadata: %w", err)
+	}
+	if err := writeFileAtomic(c.metaPath(page.Key), meta); err != nil {
+		return fmt.Errorf("failed to save cache metadata for %s: %w", page.Key, err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place,
+// so readers never see a partly written page.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// cacheFileName turns a cache key into a safe file name.
+func cacheFileName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9',
+			r == '-', r == '_', r == '&':
+			return r
+		}
+		return '_'
+	}, key)
+}
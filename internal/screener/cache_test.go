@@ -0,0 +1,136 @@
+package screener
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// offlineScraper returns a scraper serving pages from the testdata cache in
+// offline mode, and a count of the requests it made to screener.in.
+func offlineScraper(t *testing.T) (*Scraper, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "offline scraper made a request", http.StatusTeapot)
+	}))
+	t.Cleanup(server.Close)
+
+	scraper := NewScraper(server.URL, 0)
+	// The cached pages are old; offline mode serves them anyway.
+	scraper.SetCache(NewPageCache("testdata/cache", time.Hour))
+	scraper.SetOffline(true)
+	return scraper, &requests
+}
+
+func TestScraperOfflineCache(t *testing.T) {
+	tests := []struct {
+		name        string
+		symbol      string
+		basis       string
+		wantErr     error
+		wantBasis   string
+		wantFetched time.Time
+	}{
+		{
+			name:        "consolidated hit",
+			symbol:      "EXAMPLE",
+			basis:       storage.BasisConsolidated,
+			wantBasis:   storage.BasisConsolidated,
+			wantFetched: time.Date(2024, time.November, 8, 10, 15, 0, 0, time.UTC),
+		},
+		{
+			name:        "exchange suffix",
+			symbol:      "example.ns",
+			basis:       storage.BasisConsolidated,
+			wantBasis:   storage.BasisConsolidated,
+			wantFetched: time.Date(2024, time.November, 8, 10, 15, 0, 0, time.UTC),
+		},
+		{
+			name:    "standalone miss",
+			symbol:  "EXAMPLE",
+			basis:   storage.BasisStandalone,
+			wantErr: ErrNotCached,
+		},
+		{
+			name:    "miss on both bases",
+			symbol:  "MISSING",
+			basis:   storage.BasisConsolidated,
+			wantErr: ErrNotCached,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scraper, requests := offlineScraper(t)
+
+			data, err := scraper.FetchStockBasis(context.Background(), tt.symbol, tt.basis)
+			if requests.Load() != 0 {
+				t.Errorf("made %d requests in offline mode", requests.Load())
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchStockBasis: %v", err)
+			}
+
+			if data.Symbol != "EXAMPLE" || data.Name != "Example Industries Ltd" {
+				t.Errorf("got %s (%s)", data.Symbol, data.Name)
+			}
+			if data.Basis != tt.wantBasis {
+				t.Errorf("Basis = %q, want %q", data.Basis, tt.wantBasis)
+			}
+			if !data.FetchedAt.Equal(tt.wantFetched) {
+				t.Errorf("FetchedAt = %v, want the cached fetch time %v", data.FetchedAt, tt.wantFetched)
+			}
+			if len(data.Statements) == 0 {
+				t.Fatal("no statements parsed from the cached page")
+			}
+			for _, s := range data.Statements {
+				if s.Basis != tt.wantBasis || !s.FetchedAt.Equal(tt.wantFetched) {
+					t.Errorf("%s %s has basis %q fetched %v", s.StatementType, s.Period, s.Basis, s.FetchedAt)
+				}
+			}
+			if len(data.Peers) == 0 {
+				t.Error("no peers parsed from the cached page")
+			}
+		})
+	}
+}
+
+func TestPageCacheGet(t *testing.T) {
+	cache := NewPageCache("testdata/cache", time.Hour)
+
+	page, err := cache.Get("EXAMPLE-consolidated")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if page == nil {
+		t.Fatal("cached page not found")
+	}
+	if page.ETag != `"9c1f0e7d2a"` || page.URL != "https://www.screener.in/company/EXAMPLE/consolidated/" {
+		t.Errorf("metadata = %+v", page)
+	}
+	if len(page.Body) == 0 {
+		t.Error("empty body")
+	}
+	if cache.Fresh(page) {
+		t.Error("page from 2024 is fresh with a one hour TTL")
+	}
+
+	missing, err := cache.Get("MISSING")
+	if err != nil || missing != nil {
+		t.Errorf("Get(MISSING) = %v, %v, want nil, nil", missing, err)
+	}
+}
@@ -0,0 +1,221 @@
+package screener
+
+import (
+	"sort"
+
+	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/pkg/textutil"
+)
+
+// Fields that identify the stock of a row rather than a fundamental.
+const (
+	FieldSymbol   = "symbol"
+	FieldName     = "name"
+	FieldSector   = "sector"
+	FieldIndustry = "industry"
+	FieldISIN     = "isin"
+	FieldBSECode  = "bse_code"
+)
+
+// identityFields are the columns that describe a row's stock, with the
+// normalized column names matched by default.
+var identityFields = []struct {
+	name    string
+	aliases []string
+}{
+	{FieldSymbol, []string{"symbol", "ticker", "code", "scrip", "nsecode"}},
+	{FieldName, []string{"name", "company", "companyname", "stockname"}},
+	{FieldSector, []string{"sector", "sectorname"}},
+	{FieldIndustry, []string{"industry", "industryname", "subsector"}},
+	{FieldISIN, []string{"isin", "isincode", "isinno"}},
+	{FieldBSECode, []string{"bsecode", "bsescripcode", "scripcode", "bsesymbol"}},
+}
+
+// numericField is a fundamental that export columns are parsed into. Its
+// name is the field's JSON name on storage.StockFundamental.
+type numericField struct {
+	name    string
+	aliases []string // normalized column names matched by default, in order of preference
+	set     func(f *storage.StockFundamental, v float64)
+}
+
+// numericFields lists the fundamentals read from exports.
+var numericFields = []numericField{
+	{"market_cap", []string{"marketcap", "mcap", "market_cap", "marketcapitalization"}, func(f *storage.StockFundamental, v float64) { f.MarketCap = v }},
+	{"current_price", []string{"currentprice", "cmp", "price", "ltp", "lastprice"}, func(f *storage.StockFundamental, v float64) { f.CurrentPrice = v }},
+	{"high_52_week", []string{"52weekhigh", "high52", "52whigh", "yearhigh"}, func(f *storage.StockFundamental, v float64) { f.High52Week = v }},
+	{"low_52_week", []string{"52weeklow", "low52", "52wlow", "yearlow"}, func(f *storage.StockFundamental, v float64) { f.Low52Week = v }},
+	{"stock_pe", []string{"pe", "p/e", "pricetoearnings", "pricetoearning", "peratio", "stockpe"}, func(f *storage.StockFundamental, v float64) { f.StockPE = v }},
+	{"book_value", []string{"bookvalue", "bvps", "bookvaluepershare"}, func(f *storage.StockFundamental, v float64) { f.BookValue = v }},
+	{"dividend_yield", []string{"dividendyield", "divyield", "yield"}, func(f *storage.StockFundamental, v float64) { f.DividendYield = v }},
+	{"roce", []string{"roce", "returnonceemployed"}, func(f *storage.StockFundamental, v float64) { f.ROCE = v }},
+	{"roe", []string{"roe", "returnonequity"}, func(f *storage.StockFundamental, v float64) { f.ROE = v }},
+	{"face_value", []string{"facevalue", "fv"}, func(f *storage.StockFundamental, v float64) { f.FaceValue = v }},
+	{"eps", []string{"eps", "earningspershare"}, func(f *storage.StockFundamental, v float64) { f.EPS = v }},
+	{"debt_to_equity", []string{"debttoequity", "de", "debtratio"}, func(f *storage.StockFundamental, v float64) { f.DebtToEquity = v }},
+	{"promoter_holding", []string{"promoterholding", "promoter", "promoterholdingpercent"}, func(f *storage.StockFundamental, v float64) { f.PromoterHolding = v }},
+	{"pledged_percentage", []string{"pledged", "pledgedpercent", "pledgedpercentage"}, func(f *storage.StockFundamental, v float64) { f.PledgedPercentage = v }},
+	{"revenue_growth_10y", []string{"revenuegrowth10y", "salesgrowth10y", "salesgrowth10years", "compoundedsalesgrowth10years"}, func(f *storage.StockFundamental, v float64) { f.RevenueGrowth10Y = v }},
+	{"revenue_growth_5y", []string{"revenuegrowth5y", "salesgrowth5y", "salesgrowth5years", "compoundedsalesgrowth5years"}, func(f *storage.StockFundamental, v float64) { f.RevenueGrowth5Y = v }},
+	{"revenue_growth_3y", []string{"revenuegrowth3y", "salesgrowth3y", "revenue3y", "salesgrowth3years", "compoundedsalesgrowth3years"}, func(f *storage.StockFundamental, v float64) { f.RevenueGrowth3Y = v }},
+	{"revenue_growth_ttm", []string{"revenuegrowthttm", "salesgrowthttm", "compoundedsalesgrowthttm", "salesgrowth"}, func(f *storage.StockFundamental, v float64) { f.RevenueGrowthTTM = v }},
+	{"profit_growth_10y", []string{"profitgrowth10y", "patgrowth10y", "profitgrowth10years", "compoundedprofitgrowth10years"}, func(f *storage.StockFundamental, v float64) { f.ProfitGrowth10Y = v }},
+	{"profit_growth_5y", []string{"profitgrowth5y", "patgrowth5y", "profitgrowth5years", "compoundedprofitgrowth5years"}, func(f *storage.StockFundamental, v float64) { f.ProfitGrowth5Y = v }},
+	{"profit_growth_3y", []string{"profitgrowth3y", "patgrowth3y", "profit3y", "profitgrowth3years", "compoundedprofitgrowth3years"}, func(f *storage.StockFundamental, v float64) { f.ProfitGrowth3Y = v }},
+	{"profit_growth_ttm", []string{"profitgrowthttm", "patgrowthttm", "compoundedprofitgrowthttm", "profitgrowth"}, func(f *storage.StockFundamental, v float64) { f.ProfitGrowthTTM = v }},
+	{"price_cagr_10y", []string{"pricecagr10y", "stockpricecagr10years", "returnover10years"}, func(f *storage.StockFundamental, v float64) { f.PriceCAGR10Y = v }},
+	{"price_cagr_5y", []string{"pricecagr5y", "stockpricecagr5years", "returnover5years"}, func(f *storage.StockFundamental, v float64) { f.PriceCAGR5Y = v }},
+	{"price_cagr_3y", []string{"pricecagr3y", "stockpricecagr3years", "returnover3years"}, func(f *storage.StockFundamental, v float64) { f.PriceCAGR3Y = v }},
+	{"price_cagr_1y", []string{"pricecagr1y", "stockpricecagr1year", "returnover1year"}, func(f *storage.StockFundamental, v float64) { f.PriceCAGR1Y = v }},
+	{"roe_10y", []string{"roe10y", "averagereturnonequity10years", "returnonequity10years"}, func(f *storage.StockFundamental, v float64) { f.ROE10Y = v }},
+	{"roe_5y", []string{"roe5y", "averagereturnonequity5years", "returnonequity5years"}, func(f *storage.StockFundamental, v float64) { f.ROE5Y = v }},
+	{"roe_3y", []string{"roe3y", "averagereturnonequity3years", "returnonequity3years"}, func(f *storage.StockFundamental, v float64) { f.ROE3Y = v }},
+	{"roe_last_year", []string{"roelastyear", "returnonequitylastyear", "roepreviousyear"}, func(f *storage.StockFundamental, v float64) { f.ROELastYear = v }},
+	{"price_to_book", []string{"pricetobook", "pb", "p/b", "pbratio"}, func(f *storage.StockFundamental, v float64) { f.PriceToBook = v }},
+	{"peg_ratio", []string{"peg", "pegratio"}, func(f *storage.StockFundamental, v float64) { f.PEGRatio = v }},
+}
+
+// MappableFields returns the fields export columns can be mapped to: the
+// stock's symbol, name and other details, then the fundamentals by their
+// JSON names.
+func MappableFields() []string {
+	fields := make([]string, 0, len(identityFields)+len(numericFields))
+	for _, f := range identityFields {
+		fields = append(fields, f.name)
+	}
+	for _, f := range numericFields {
+		fields = append(fields, f.name)
+	}
+	return fields
+}
+
+// IsMappableField reports whether columns can be mapped to field.
+func IsMappableField(field string) bool {
+	for _, f := range MappableFields() {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// ColumnMapping reports which field a column of an export was read into.
+type ColumnMapping struct {
+	Column     string `json:"column"`
+	Normalized string `json:"normalized"`
+	Field      string `json:"field,omitempty"`     // empty if the column is not read into a field
+	Attribute  string `json:"attribute,omitempty"` // key numbers in a column without a field are kept under
+	Custom     bool   `json:"custom,omitempty"`
+}
+
+// columnMap resolves the fields of an export to its columns.
+type columnMap struct {
+	header     []string
+	fields     map[string]int // field -> column index
+	custom     map[int]bool   // columns mapped by a custom mapping
+	attributes map[int]string // column index -> attribute key, for columns without a field
+}
+
+// resolveColumns maps the fields to the columns of header. Custom mappings,
+// keyed by normalized column name, take precedence and claim their column;
+// the remaining fields take the first of their aliases in the header. When
+// a column name repeats, the last one is used.
+func resolveColumns(header []string, custom map[string]string) *columnMap {
+	colIndex := columnIndex(header)
+	m := &columnMap{header: header, fields: make(map[string]int), custom: make(map[int]bool)}
+
+	claimed := make(map[int]bool)
+	columns := make([]string, 0, len(custom))
+	for column := range custom {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		idx, ok := colIndex[column]
+		if !ok {
+			continue
+		}
+		if _, taken := m.fields[custom[column]]; taken {
+			continue
+		}
+		m.fields[custom[column]] = idx
+		m.custom[idx] = true
+		claimed[idx] = true
+	}
+
+	match := func(field string, aliases []string) {
+		if _, ok := m.fields[field]; ok {
+			return
+		}
+		for _, alias := range aliases {
+			if idx, ok := colIndex[alias]; ok && !claimed[idx] {
+				m.fields[field] = idx
+				return
+			}
+		}
+	}
+	for _, f := range identityFields {
+		match(f.name, f.aliases)
+	}
+	for _, f := range numericFields {
+		match(f.name, f.aliases)
+	}
+	m.attributes = m.attributeKeys()
+	return m
+}
+
+// mappings describes the field each column of the header was read into.
+func (m *columnMap) mappings() []ColumnMapping {
+	byColumn := make(map[int]string, len(m.fields))
+	for field, idx := range m.fields {
+		byColumn[idx] = field
+	}
+
+	mappings := make([]ColumnMapping, len(m.header))
+	for i, column := range m.header {
+		mappings[i] = ColumnMapping{
+			Column:     column,
+			Normalized: NormalizeColumnName(column),
+			Field:      byColumn[i],
+			Attribute:  m.attributes[i],
+			Custom:     m.custom[i],
+		}
+	}
+	return mappings
+}
+
+// Sizes of the storage.FundamentalAttribute columns.
+const (
+	maxAttributeKeyLength   = 100
+	maxAttributeLabelLength = 255
+)
+
+// attributeKeys returns the attribute key of each column that is not read
+// into a field, by column index. A key is used by its first column only,
+// and not at all if a column of the same name is read into a field.
+func (m *columnMap) attributeKeys() map[int]string {
+	mapped := make(map[int]bool, len(m.fields))
+	seen := make(map[string]bool)
+	for _, idx := range m.fields {
+		mapped[idx] = true
+		seen[textutil.Truncate(NormalizeColumnName(m.header[idx]), maxAttributeKeyLength)] = true
+	}
+
+	attributes := make(map[int]string)
+	for i, column := range m.header {
+		key := textutil.Truncate(NormalizeColumnName(column), maxAttributeKeyLength)
+		if mapped[i] || key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		attributes[i] = key
+	}
+	return attributes
+}
+
+// hasIdentifier reports whether a symbol or name column was found.
+func (m *columnMap) hasIdentifier() bool {
+	_, symbol := m.fields[FieldSymbol]
+	_, name := m.fields[FieldName]
+	return symbol || name
+}
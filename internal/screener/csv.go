@@ -1,33 +1,96 @@
 package screener
 
 import (
+	"bytes"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/pkg/textutil"
 )
 
 // CSVParser parses screener.in CSV exports.
-type CSVParser struct{}
+type CSVParser struct {
+	mappings map[string]string // normalized column -> field
+}
 
 // NewCSVParser creates a new CSV parser.
 func NewCSVParser() *CSVParser {
 	return &CSVParser{}
 }
 
+// WithMappings returns a parser that reads columns into fields as given by
+// mappings, from column name to one of MappableFields, before matching
+// the remaining fields by their usual column names.
+func (p *CSVParser) WithMappings(mappings map[string]string) *CSVParser {
+	normalized := make(map[string]string, len(mappings))
+	for column, field := range mappings {
+		normalized[NormalizeColumnName(column)] = field
+	}
+	return &CSVParser{mappings: normalized}
+}
+
 // ParsedStock represents a stock parsed from CSV.
 type ParsedStock struct {
 	Symbol      string
 	Name        string
+	Sector      string
+	Industry    string
+	ISIN        string
+	BSECode     string
 	Fundamental *storage.StockFundamental
+	Row         int               // line number in the file, header is line 1
+	Raw         map[string]string // original column -> value
+	Invalid     []InvalidValue    // values that are not numbers
+}
+
+// InvalidValue is a cell of a numeric column that could not be read as a
+// number and was imported as zero.
+type InvalidValue struct {
+	Row    int    `json:"row"`
+	Column string `json:"column"`
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+}
+
+// RowError describes a CSV row that could not be parsed.
+type RowError struct {
+	Row int
+	Raw map[string]string
+	Err error
+}
+
+// Error implements the error interface.
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
 }
 
-// Parse parses a screener.in CSV export.
+// ParseResult holds the rows of a CSV export, split into parsed stocks and
+// rows that failed to parse, and the field each column was read into.
+type ParseResult struct {
+	Stocks  []ParsedStock
+	Errors  []RowError
+	Columns []ColumnMapping
+}
+
+// Parse parses a screener.in CSV export, dropping rows that fail to parse.
 func (p *CSVParser) Parse(reader io.Reader) ([]ParsedStock, error) {
+	result, err := p.ParseWithErrors(reader)
+	if err != nil {
+		return nil, err
+	}
+	return result.Stocks, nil
+}
+
+// ParseWithErrors parses a screener.in CSV export and reports every row that
+// could not be parsed. Blank rows are ignored.
+func (p *CSVParser) ParseWithErrors(reader io.Reader) (*ParseResult, error) {
 	csvReader := csv.NewReader(reader)
 	csvReader.TrimLeadingSpace = true
 	csvReader.LazyQuotes = true
@@ -38,13 +101,8 @@ func (p *CSVParser) Parse(reader io.Reader) ([]ParsedStock, error) {
 		return nil, fmt.Errorf("failed to read CSV header: %w", err)
 	}
 
-	// Build column index map
-	colIndex := make(map[string]int)
-	for i, col := range header {
-		colIndex[normalizeColumnName(col)] = i
-	}
-
-	var stocks []ParsedStock
+	columns := resolveColumns(header, p.mappings)
+	result := &ParseResult{Columns: columns.mappings()}
 
 	// Read data rows
 	for {
@@ -52,73 +110,257 @@ func (p *CSVParser) Parse(reader io.Reader) ([]ParsedStock, error) {
 		if err == io.EOF {
 			break
 		}
+		raw := rawRow(header, record)
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			result.Errors = append(result.Errors, RowError{Row: parseErr.StartLine, Raw: raw, Err: parseErr.Err})
+			continue
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to read CSV row: %w", err)
 		}
+		if isBlankRecord(record) {
+			continue
+		}
+		line, _ := csvReader.FieldPos(0)
+
+		p.addRecord(result, record, columns, line, raw)
+	}
+
+	return result, nil
+}
+
+// ParseXLSX parses a screener.in or broker XLSX export. The chosen sheet,
+// by name or 1-based position, is read, or the first sheet if sheet is
+// empty. The header is the first row naming a symbol or company column,
+// so title rows above the table are skipped. Row numbers are the sheet's.
+func (p *CSVParser) ParseXLSX(r io.ReaderAt, size int64, sheet string) (*ParseResult, error) {
+	wb, err := openXLSX(r, size)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := wb.sheet(sheet)
+	if err != nil {
+		return nil, err
+	}
+	rows, lines, err := wb.rows(ws)
+	if err != nil {
+		return nil, err
+	}
+
+	start := p.headerRow(rows)
+	if start < 0 {
+		return nil, fmt.Errorf("sheet %s has no header row with a Symbol, Ticker, Code, NSE Code, Name or Company column", ws.name)
+	}
+	header := rows[start]
+	columns := resolveColumns(header, p.mappings)
+
+	result := &ParseResult{Columns: columns.mappings()}
+	for i := start + 1; i < len(rows); i++ {
+		if isBlankRecord(rows[i]) {
+			continue
+		}
+		p.addRecord(result, rows[i], columns, lines[i], rawRow(header, rows[i]))
+	}
+	return result, nil
+}
+
+// errLegacyXLS is returned for workbooks in the pre-2007 binary format.
+var errLegacyXLS = errors.New("legacy .xls workbooks are not supported, save the file as .xlsx or CSV")
+
+// ParseFile parses an export in any supported format, detected from its
+// content. sheet chooses the worksheet of XLSX workbooks.
+func (p *CSVParser) ParseFile(data []byte, sheet string) (*ParseResult, error) {
+	switch DetectFormat(data) {
+	case FormatXLSX:
+		return p.ParseXLSX(bytes.NewReader(data), int64(len(data)), sheet)
+	case FormatXLS:
+		return nil, errLegacyXLS
+	default:
+		return p.ParseWithErrors(bytes.NewReader(data))
+	}
+}
+
+// ReadRows reads the non-blank rows of a CSV file or XLSX worksheet, with
+// the line or row number of each, for other exports that share the
+// screener formats. The format is detected from the content.
+func ReadRows(data []byte, sheet string) ([][]string, []int, error) {
+	switch DetectFormat(data) {
+	case FormatXLSX:
+		wb, err := openXLSX(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, nil, err
+		}
+		ws, err := wb.sheet(sheet)
+		if err != nil {
+			return nil, nil, err
+		}
+		rows, lines, err := wb.rows(ws)
+		if err != nil {
+			return nil, nil, err
+		}
+		var kept [][]string
+		var keptLines []int
+		for i, row := range rows {
+			if !isBlankRecord(row) {
+				kept = append(kept, row)
+				keptLines = append(keptLines, lines[i])
+			}
+		}
+		return kept, keptLines, nil
+	case FormatXLS:
+		return nil, nil, errLegacyXLS
+	}
 
-		stock, err := p.parseRow(record, colIndex)
+	csvReader := csv.NewReader(bytes.NewReader(data))
+	csvReader.TrimLeadingSpace = true
+	csvReader.LazyQuotes = true
+	csvReader.FieldsPerRecord = -1
+
+	var rows [][]string
+	var lines []int
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			// Skip invalid rows but continue parsing
+			return nil, nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		if isBlankRecord(record) {
 			continue
 		}
+		line, _ := csvReader.FieldPos(0)
+		rows = append(rows, record)
+		lines = append(lines, line)
+	}
+	return rows, lines, nil
+}
 
-		stocks = append(stocks, *stock)
+// addRecord parses a data row into result, recording it as an error if it
+// cannot be parsed.
+func (p *CSVParser) addRecord(result *ParseResult, record []string, columns *columnMap, line int, raw map[string]string) {
+	stock, err := p.parseRow(record, columns)
+	if err != nil {
+		result.Errors = append(result.Errors, RowError{Row: line, Raw: raw, Err: err})
+		return
+	}
+	stock.Row = line
+	stock.Raw = raw
+	for i := range stock.Invalid {
+		stock.Invalid[i].Row = line
 	}
 
-	return stocks, nil
+	result.Stocks = append(result.Stocks, *stock)
 }
 
-// parseRow parses a single CSV row.
-func (p *CSVParser) parseRow(record []string, colIndex map[string]int) (*ParsedStock, error) {
-	getValue := func(names ...string) string {
-		for _, name := range names {
-			if idx, ok := colIndex[name]; ok && idx < len(record) {
-				return strings.TrimSpace(record[idx])
-			}
+// columnIndex maps normalized column names to their position in header.
+func columnIndex(header []string) map[string]int {
+	colIndex := make(map[string]int)
+	for i, col := range header {
+		colIndex[NormalizeColumnName(col)] = i
+	}
+	return colIndex
+}
+
+// headerRow returns the index of the first row with an identifier column,
+// or -1 if there is none.
+func (p *CSVParser) headerRow(rows [][]string) int {
+	for i, row := range rows {
+		if resolveColumns(row, p.mappings).hasIdentifier() {
+			return i
 		}
-		return ""
 	}
+	return -1
+}
 
-	getFloat := func(names ...string) float64 {
-		s := getValue(names...)
-		return parseCSVNumber(s)
+// rawRow maps header columns to the values of a record.
+func rawRow(header, record []string) map[string]string {
+	raw := make(map[string]string, len(header))
+	for i, col := range header {
+		if i < len(record) {
+			raw[col] = record[i]
+		}
 	}
+	return raw
+}
 
-	symbol := getValue("symbol", "ticker", "code", "scrip")
-	name := getValue("name", "company", "companyname", "stockname")
+// isBlankRecord reports whether every field of a record is empty.
+func isBlankRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseRow parses a single CSV row.
+func (p *CSVParser) parseRow(record []string, columns *columnMap) (*ParsedStock, error) {
+	getValue := func(field string) string {
+		if idx, ok := columns.fields[field]; ok && idx < len(record) {
+			return strings.TrimSpace(record[idx])
+		}
+		return ""
+	}
+
+	symbol := getValue(FieldSymbol)
+	name := getValue(FieldName)
+	bseCode := getValue(FieldBSECode)
 
 	if symbol == "" && name == "" {
 		return nil, fmt.Errorf("no symbol or name found")
 	}
 
-	// If symbol is empty, try to derive from name
+	// If symbol is empty, use the BSE code as screener.in does for stocks
+	// listed only on the BSE, or derive one from the name
+	if symbol == "" {
+		symbol = bseCode
+	}
 	if symbol == "" {
 		symbol = strings.ToUpper(strings.ReplaceAll(name, " ", ""))
 	}
 
 	fundamental := &storage.StockFundamental{
-		MarketCap:         getFloat("marketcap", "mcap", "market_cap", "marketcapitalization"),
-		CurrentPrice:      getFloat("currentprice", "cmp", "price", "ltp", "lastprice"),
-		High52Week:        getFloat("52weekhigh", "high52", "52whigh", "yearhigh"),
-		Low52Week:         getFloat("52weeklow", "low52", "52wlow", "yearlow"),
-		StockPE:           getFloat("pe", "pricetoearnings", "peratio", "stockpe"),
-		BookValue:         getFloat("bookvalue", "bvps", "bookvaluepershare"),
-		DividendYield:     getFloat("dividendyield", "divyield", "yield"),
-		ROCE:              getFloat("roce", "returnonceemployed"),
-		ROE:               getFloat("roe", "returnonequity"),
-		FaceValue:         getFloat("facevalue", "fv"),
-		EPS:               getFloat("eps", "earningspershare"),
-		DebtToEquity:      getFloat("debttoequity", "de", "debtratio"),
-		PromoterHolding:   getFloat("promoterholding", "promoter", "promoterholdingpercent"),
-		PledgedPercentage: getFloat("pledged", "pledgedpercent", "pledgedpercentage"),
-		RevenueGrowth3Y:   getFloat("revenuegrowth3y", "salesgrowth3y", "revenue3y"),
-		ProfitGrowth3Y:    getFloat("profitgrowth3y", "patgrowth3y", "profit3y"),
-		PriceToBook:       getFloat("pricetobook", "pb", "pbratio"),
-		PEGRatio:          getFloat("peg", "pegratio"),
-		Source:            "csv_upload",
-		FetchedAt:         time.Now(),
+		Source:    "csv_upload",
+		FetchedAt: time.Now(),
+	}
+
+	var invalid []InvalidValue
+	for _, field := range numericFields {
+		s := getValue(field.name)
+		value, ok := ParseNumber(s)
+		if !ok {
+			invalid = append(invalid, InvalidValue{
+				Column: columns.header[columns.fields[field.name]],
+				Field:  field.name,
+				Value:  s,
+			})
+		}
+		field.set(fundamental, value)
 	}
 
+	// Keep numbers in columns without a field as attributes
+	for idx, key := range columns.attributes {
+		if idx >= len(record) {
+			continue
+		}
+		s := strings.TrimSpace(record[idx])
+		value, ok := ParseNumber(s)
+		if !ok || isBlankValue(s) {
+			continue
+		}
+		fundamental.Attributes = append(fundamental.Attributes, storage.FundamentalAttribute{
+			Key:   key,
+			Label: textutil.Truncate(strings.TrimSpace(columns.header[idx]), maxAttributeLabelLength),
+			Value: value,
+		})
+	}
+	sort.Slice(fundamental.Attributes, func(i, j int) bool {
+		return fundamental.Attributes[i].Key < fundamental.Attributes[j].Key
+	})
+
 	// Calculate derived metrics if not present
 	if fundamental.PriceToBook == 0 && fundamental.CurrentPrice > 0 && fundamental.BookValue > 0 {
 		fundamental.PriceToBook = fundamental.CurrentPrice / fundamental.BookValue
@@ -132,12 +374,17 @@ func (p *CSVParser) parseRow(record []string, colIndex map[string]int) (*ParsedS
 	return &ParsedStock{
 		Symbol:      normalizeSymbol(symbol),
 		Name:        name,
+		Sector:      getValue(FieldSector),
+		Industry:    getValue(FieldIndustry),
+		ISIN:        strings.ToUpper(getValue(FieldISIN)),
+		BSECode:     bseCode,
 		Fundamental: fundamental,
+		Invalid:     invalid,
 	}, nil
 }
 
-// normalizeColumnName normalizes a column name for matching.
-func normalizeColumnName(name string) string {
+// NormalizeColumnName normalizes a column name for matching.
+func NormalizeColumnName(name string) string {
 	name = strings.ToLower(name)
 	name = strings.ReplaceAll(name, " ", "")
 	name = strings.ReplaceAll(name, "_", "")
@@ -149,10 +396,12 @@ func normalizeColumnName(name string) string {
 	return name
 }
 
-// parseCSVNumber parses a number from CSV cell.
-func parseCSVNumber(s string) float64 {
-	if s == "" || s == "-" || s == "N/A" || s == "NA" {
-		return 0
+// ParseNumber parses a number from an export cell, allowing thousands
+// separators, ₹ and % signs and Cr/L/K suffixes. Blank markers such as "-"
+// are zero; it reports false if the cell holds anything else.
+func ParseNumber(s string) (float64, bool) {
+	if isBlankValue(s) {
+		return 0, true
 	}
 
 	// Remove common formatting
@@ -177,10 +426,15 @@ func parseCSVNumber(s string) float64 {
 
 	value, err := strconv.ParseFloat(s, 64)
 	if err != nil {
-		return 0
+		return 0, false
 	}
 
-	return value * multiplier
+	return value * multiplier, true
+}
+
+// isBlankValue reports whether a CSV cell marks a missing value.
+func isBlankValue(s string) bool {
+	return s == "" || s == "-" || s == "N/A" || s == "NA"
 }
 
 // ValidateCSV validates a CSV file before parsing.
@@ -193,17 +447,8 @@ func (p *CSVParser) ValidateCSV(reader io.Reader) error {
 		return fmt.Errorf("failed to read CSV header: %w", err)
 	}
 
-	// Check for required columns
-	colIndex := make(map[string]bool)
-	for _, col := range header {
-		colIndex[normalizeColumnName(col)] = true
-	}
-
 	// At minimum, we need a symbol or name column
-	hasIdentifier := colIndex["symbol"] || colIndex["ticker"] || colIndex["code"] ||
-		colIndex["name"] || colIndex["company"] || colIndex["companyname"]
-
-	if !hasIdentifier {
+	if !resolveColumns(header, p.mappings).hasIdentifier() {
 		return fmt.Errorf("CSV must contain at least one of: Symbol, Ticker, Code, Name, Company")
 	}
 
@@ -213,8 +458,12 @@ func (p *CSVParser) ValidateCSV(reader io.Reader) error {
 // GetSupportedColumns returns a list of supported column names.
 func (p *CSVParser) GetSupportedColumns() []string {
 	return []string{
-		"Symbol / Ticker / Code",
+		"Symbol / Ticker / Code / NSE Code",
 		"Name / Company",
+		"Sector",
+		"Industry",
+		"ISIN",
+		"BSE Code",
 		"Market Cap",
 		"Current Price / CMP / LTP",
 		"52 Week High",
@@ -229,8 +478,10 @@ func (p *CSVParser) GetSupportedColumns() []string {
 		"Debt to Equity",
 		"Promoter Holding",
 		"Pledged Percentage",
-		"Revenue Growth 3Y",
-		"Profit Growth 3Y",
+		"Sales Growth 10Y / 5Y / 3Y / TTM",
+		"Profit Growth 10Y / 5Y / 3Y / TTM",
+		"Stock Price CAGR 10Y / 5Y / 3Y / 1Y",
+		"Return on Equity 10Y / 5Y / 3Y / Last Year",
 		"Price to Book / P/B",
 		"PEG Ratio",
 	}
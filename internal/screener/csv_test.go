@@ -0,0 +1,224 @@
+package screener
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// exportCSV is the Results sheet of testdata/example_export.xlsx as a CSV
+// file, with the numbers as screener.in writes them.
+const exportCSV = `S.No.,Name,NSE Code,Current Price,Market Capitalization,Price to Earning,Return on equity,Dividend yield,Sales growth 3Years,Result date
+1,Example Industries Ltd,EXAMPLE,1295,"62,418.3",46.7,18.4%,0.58,30,2024-04-30
+
+2,Sample Bank Ltd,SAMPLEBANK,812.5,121044,,15.2%,0.9,12.25,2024-04-08
+3,Missing Code Co,,#N/A,,,,,,
+`
+
+func TestParseFile(t *testing.T) {
+	workbook, err := os.ReadFile("testdata/example_export.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		sheet      string
+		wantFormat string
+		wantErr    string
+		// wantRows are the row numbers of the parsed stocks; the XLSX rows
+		// start under a title row.
+		wantRows []int
+	}{
+		{"xlsx sheet by name", workbook, "Results", FormatXLSX, "", []int{4, 6, 7}},
+		{"xlsx sheet name in any case", workbook, "results", FormatXLSX, "", []int{4, 6, 7}},
+		{"xlsx sheet by position", workbook, "2", FormatXLSX, "", []int{4, 6, 7}},
+		{"xlsx first sheet has no table", workbook, "", FormatXLSX, "sheet Notes has no header row", nil},
+		{"xlsx unknown sheet", workbook, "Screen", FormatXLSX, ErrSheetNotFound.Error(), nil},
+		{"csv", []byte(exportCSV), "", FormatCSV, "", []int{2, 4, 5}},
+		{"legacy xls", []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1rest"), "", FormatXLS, "legacy .xls", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectFormat(tt.data); got != tt.wantFormat {
+				t.Errorf("DetectFormat = %q, want %q", got, tt.wantFormat)
+			}
+
+			result, err := NewCSVParser().ParseFile(tt.data, tt.sheet)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFile: %v", err)
+			}
+			if len(result.Errors) > 0 {
+				t.Errorf("unexpected row errors: %v", result.Errors)
+			}
+			if len(result.Stocks) != len(tt.wantRows) {
+				t.Fatalf("got %d stocks, want %d", len(result.Stocks), len(tt.wantRows))
+			}
+
+			example, bank, missing := result.Stocks[0], result.Stocks[1], result.Stocks[2]
+			for i, s := range result.Stocks {
+				if s.Row != tt.wantRows[i] {
+					t.Errorf("%s is on row %d, want %d", s.Symbol, s.Row, tt.wantRows[i])
+				}
+			}
+
+			f := example.Fundamental
+			if example.Symbol != "EXAMPLE" || example.Name != "Example Industries Ltd" {
+				t.Errorf("first stock = %s (%s)", example.Symbol, example.Name)
+			}
+			if f.CurrentPrice != 1295 || f.MarketCap != 62418.3 || f.StockPE != 46.7 ||
+				f.ROE != 18.4 || f.DividendYield != 0.58 || f.RevenueGrowth3Y != 30 {
+				t.Errorf("EXAMPLE fundamentals = price %v, mcap %v, pe %v, roe %v, yield %v, growth %v",
+					f.CurrentPrice, f.MarketCap, f.StockPE, f.ROE, f.DividendYield, f.RevenueGrowth3Y)
+			}
+			if f.Source != "csv_upload" {
+				t.Errorf("Source = %q", f.Source)
+			}
+
+			// A blank cell is zero, not an invalid value.
+			if bank.Symbol != "SAMPLEBANK" || bank.Fundamental.StockPE != 0 || len(bank.Invalid) != 0 {
+				t.Errorf("bank = %s, pe %v, invalid %v", bank.Symbol, bank.Fundamental.StockPE, bank.Invalid)
+			}
+
+			// A row without a symbol gets one from its name, and a cell
+			// that is not a number is reported.
+			if missing.Symbol != "MISSINGCODECO" {
+				t.Errorf("symbol from name = %q", missing.Symbol)
+			}
+			wantInvalid := []InvalidValue{{Row: tt.wantRows[2], Column: "Current Price", Field: "current_price", Value: "#N/A"}}
+			if !reflect.DeepEqual(missing.Invalid, wantInvalid) {
+				t.Errorf("Invalid = %+v, want %+v", missing.Invalid, wantInvalid)
+			}
+
+			// Numeric columns without a field are kept as attributes; text
+			// columns such as the date are not.
+			if v, ok := example.Fundamental.Attribute("S.No."); !ok || v != 1 {
+				t.Errorf("S.No. attribute = %v, %v", v, ok)
+			}
+			if _, ok := example.Fundamental.Attribute("resultdate"); ok {
+				t.Error("date column kept as an attribute")
+			}
+		})
+	}
+}
+
+func TestResolveColumns(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []string
+		custom map[string]string // normalized column -> field
+		want   []ColumnMapping
+	}{
+		{
+			name:   "aliases",
+			header: []string{"NSE Code", "Name", "CMP", "Market Cap", "P/E"},
+			want: []ColumnMapping{
+				{Column: "NSE Code", Normalized: "nsecode", Field: FieldSymbol},
+				{Column: "Name", Normalized: "name", Field: FieldName},
+				{Column: "CMP", Normalized: "cmp", Field: "current_price"},
+				{Column: "Market Cap", Normalized: "marketcap", Field: "market_cap"},
+				{Column: "P/E", Normalized: "p/e", Field: "stock_pe"},
+			},
+		},
+		{
+			name:   "unknown headers become attributes",
+			header: []string{"Symbol", "Piotroski Score", "G Factor", ""},
+			want: []ColumnMapping{
+				{Column: "Symbol", Normalized: "symbol", Field: FieldSymbol},
+				{Column: "Piotroski Score", Normalized: "piotroskiscore", Attribute: "piotroskiscore"},
+				{Column: "G Factor", Normalized: "gfactor", Attribute: "gfactor"},
+				{Column: "", Normalized: ""},
+			},
+		},
+		{
+			// The last of repeated columns is read; the others are not
+			// kept as attributes either.
+			name:   "duplicate headers",
+			header: []string{"Symbol", "Price", "Score", "Price", "score"},
+			want: []ColumnMapping{
+				{Column: "Symbol", Normalized: "symbol", Field: FieldSymbol},
+				{Column: "Price", Normalized: "price"},
+				{Column: "Score", Normalized: "score", Attribute: "score"},
+				{Column: "Price", Normalized: "price", Field: "current_price"},
+				{Column: "score", Normalized: "score"},
+			},
+		},
+		{
+			name:   "duplicate aliases take the first alias",
+			header: []string{"Symbol", "Price", "Current Price"},
+			want: []ColumnMapping{
+				{Column: "Symbol", Normalized: "symbol", Field: FieldSymbol},
+				{Column: "Price", Normalized: "price", Attribute: "price"},
+				{Column: "Current Price", Normalized: "currentprice", Field: "current_price"},
+			},
+		},
+		{
+			name:   "custom mapping claims its column",
+			header: []string{"Symbol", "Price", "Close"},
+			custom: map[string]string{"close": "current_price", "price": "book_value"},
+			want: []ColumnMapping{
+				{Column: "Symbol", Normalized: "symbol", Field: FieldSymbol},
+				{Column: "Price", Normalized: "price", Field: "book_value", Custom: true},
+				{Column: "Close", Normalized: "close", Field: "current_price", Custom: true},
+			},
+		},
+		{
+			name:   "custom mapping of a missing column",
+			header: []string{"Ticker", "PE"},
+			custom: map[string]string{"nosuchcolumn": "stock_pe"},
+			want: []ColumnMapping{
+				{Column: "Ticker", Normalized: "ticker", Field: FieldSymbol},
+				{Column: "PE", Normalized: "pe", Field: "stock_pe"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveColumns(tt.header, tt.custom).mappings()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mappings:\n got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFileCustomMapping(t *testing.T) {
+	csv := "Ticker,Close,Moat Score\nTCS,3650.5,4\n"
+	result, err := NewCSVParser().WithMappings(map[string]string{"Close": "current_price"}).ParseFile([]byte(csv), "")
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	if len(result.Stocks) != 1 {
+		t.Fatalf("got %d stocks", len(result.Stocks))
+	}
+	stock := result.Stocks[0]
+	if stock.Symbol != "TCS" || stock.Fundamental.CurrentPrice != 3650.5 {
+		t.Errorf("got %s at %v", stock.Symbol, stock.Fundamental.CurrentPrice)
+	}
+	if v, ok := stock.Fundamental.Attribute("Moat Score"); !ok || v != 4 {
+		t.Errorf("Moat Score attribute = %v, %v", v, ok)
+	}
+}
+
+func TestParseFileNoIdentifier(t *testing.T) {
+	result, err := NewCSVParser().ParseFile([]byte("Price,PE\n100,12\n"), "")
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	if len(result.Stocks) != 0 || len(result.Errors) != 1 {
+		t.Fatalf("got %d stocks and %d errors, want a row error", len(result.Stocks), len(result.Errors))
+	}
+	if rowErr := result.Errors[0]; rowErr.Row != 2 || !strings.Contains(rowErr.Error(), "no symbol or name") {
+		t.Errorf("error = %v", result.Errors[0])
+	}
+}
@@ -0,0 +1,61 @@
+package screener
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// parseGrowthRanges parses the compounded growth, stock price CAGR and return
+// on equity summary tables shown under a company's profit & loss.
+func parseGrowthRanges(doc *goquery.Document, data *StockData) {
+	doc.Find("table.ranges-table").Each(func(_ int, table *goquery.Selection) {
+		title := strings.ToLower(strings.TrimSpace(table.Find("th").First().Text()))
+
+		var fields map[string]*float64
+		switch {
+		case strings.Contains(title, "sales growth"):
+			fields = map[string]*float64{
+				"10 years": &data.RevenueGrowth10Y,
+				"5 years":  &data.RevenueGrowth5Y,
+				"3 years":  &data.RevenueGrowth3Y,
+				"ttm":      &data.RevenueGrowthTTM,
+			}
+		case strings.Contains(title, "profit growth"):
+			fields = map[string]*float64{
+				"10 years": &data.ProfitGrowth10Y,
+				"5 years":  &data.ProfitGrowth5Y,
+				"3 years":  &data.ProfitGrowth3Y,
+				"ttm":      &data.ProfitGrowthTTM,
+			}
+		case strings.Contains(title, "stock price cagr"):
+			fields = map[string]*float64{
+				"10 years": &data.PriceCAGR10Y,
+				"5 years":  &data.PriceCAGR5Y,
+				"3 years":  &data.PriceCAGR3Y,
+				"1 year":   &data.PriceCAGR1Y,
+			}
+		case strings.Contains(title, "return on equity"):
+			fields = map[string]*float64{
+				"10 years":  &data.ROE10Y,
+				"5 years":   &data.ROE5Y,
+				"3 years":   &data.ROE3Y,
+				"last year": &data.ROELastYear,
+			}
+		default:
+			return
+		}
+
+		table.Find("tr").Each(func(_ int, tr *goquery.Selection) {
+			cells := tr.Find("td")
+			if cells.Length() < 2 {
+				return
+			}
+			period := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(cells.Eq(0).Text()), ":"))
+			value := strings.TrimSpace(cells.Eq(1).Text())
+			if field, ok := fields[period]; ok && hasDigit(value) {
+				*field = parseNumber(value)
+			}
+		})
+	})
+}
@@ -0,0 +1,492 @@
+package screener
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/pkg/textutil"
+)
+
+// Import stages reported in ImportProgress.
+const (
+	StageQueued    = "queued"
+	StageParsing   = "parsing"
+	StageImporting = "importing"
+	StageCompleted = "completed"
+	StageFailed    = "failed"
+)
+
+// Sizes of the storage.Stock columns an import writes.
+const (
+	maxSymbolLength  = 20
+	maxNameLength    = 255
+	maxISINLength    = 12
+	maxBSECodeLength = 10
+	maxSectorLength  = 100
+)
+
+// progressRetention is how long progress of finished imports is kept.
+const progressRetention = time.Hour
+
+// Limits on the lists in an ImportPreview.
+const (
+	previewSampleSize = 10
+	previewListLimit  = 100
+)
+
+// ErrInvalidCSV wraps errors caused by an unreadable CSV or XLSX file.
+var ErrInvalidCSV = errors.New("invalid screener export")
+
+// ErrInvalidMapping is returned for a column mapping that cannot be saved.
+var ErrInvalidMapping = errors.New("invalid column mapping")
+
+// ImportOptions adjusts how an export is read.
+type ImportOptions struct {
+	// Sheet chooses the worksheet of an XLSX workbook by name or 1-based
+	// position. The first sheet is read if it is empty.
+	Sheet string
+}
+
+// ImportProgress reports how far an import has got.
+type ImportProgress struct {
+	UploadID  uint      `json:"upload_id"`
+	Stage     string    `json:"stage"`
+	Processed int       `json:"processed"`
+	Total     int       `json:"total"`
+	Error     string    `json:"error,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ImportResult is the outcome of a completed import.
+type ImportResult struct {
+	Upload *storage.ScreenerUpload
+	Rows   []storage.ScreenerUploadRow
+}
+
+// Errors returns up to limit rows that failed to import.
+func (r *ImportResult) Errors(limit int) []storage.ScreenerUploadRow {
+	var failed []storage.ScreenerUploadRow
+	for _, row := range r.Rows {
+		if row.Status == storage.UploadRowError {
+			if limit > 0 && len(failed) >= limit {
+				break
+			}
+			failed = append(failed, row)
+		}
+	}
+	return failed
+}
+
+// Importer imports screener.in CSV and XLSX exports into the database. Each import
+// runs in a single transaction, so a failed import leaves no partial data.
+type Importer struct {
+	repo      *storage.Repository
+	parser    *CSVParser
+	batchSize int
+
+	// OnProgress, if set, is called whenever an import makes progress.
+	OnProgress func(ImportProgress)
+
+	mu       sync.Mutex
+	progress map[uint]*ImportProgress
+	wg       sync.WaitGroup
+}
+
+// NewImporter creates a CSV importer.
+func NewImporter(repo *storage.Repository, parser *CSVParser) *Importer {
+	return &Importer{
+		repo:      repo,
+		parser:    parser,
+		batchSize: 500,
+		progress:  make(map[uint]*ImportProgress),
+	}
+}
+
+// Import imports a CSV or XLSX file and waits for it to finish. The format
+// is detected from the content.
+func (im *Importer) Import(ctx context.Context, filename string, r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	upload, err := im.createUpload(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, im.fail(upload, fmt.Errorf("failed to read file: %w", err))
+	}
+	return im.run(ctx, upload, data, opts)
+}
+
+// ImportAsync records the upload and imports it in the background. Poll
+// Progress with the returned upload's ID to follow it.
+func (im *Importer) ImportAsync(ctx context.Context, filename string, data []byte, opts ImportOptions) (*storage.ScreenerUpload, error) {
+	upload, err := im.createUpload(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	im.wg.Add(1)
+	go func() {
+		defer im.wg.Done()
+		if _, err := im.run(context.Background(), upload, data, opts); err != nil {
+			fmt.Printf("Warning: screener import %d failed: %v\n", upload.ID, err)
+		}
+	}()
+
+	return upload, nil
+}
+
+// Wait waits for background imports to finish or for ctx to expire.
+func (im *Importer) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		im.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Progress returns the progress of a recent import.
+func (im *Importer) Progress(uploadID uint) (ImportProgress, bool) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	p, ok := im.progress[uploadID]
+	if !ok {
+		return ImportProgress{}, false
+	}
+	return *p, true
+}
+
+// createUpload creates the upload record an import is tracked under.
+func (im *Importer) createUpload(ctx context.Context, filename string) (*storage.ScreenerUpload, error) {
+	upload := &storage.ScreenerUpload{
+		Filename:    filename,
+		Status:      storage.UploadStatusPending,
+		ProcessedAt: time.Now(),
+	}
+	if err := im.repo.CreateScreenerUpload(ctx, upload); err != nil {
+		return nil, fmt.Errorf("failed to create upload record: %w", err)
+	}
+	im.setProgress(upload.ID, StageQueued, 0, 0, "")
+	return upload, nil
+}
+
+// run parses and imports a file for an existing upload record.
+func (im *Importer) run(ctx context.Context, upload *storage.ScreenerUpload, data []byte, opts ImportOptions) (*ImportResult, error) {
+	upload.Status = storage.UploadStatusProcessing
+	if err := im.repo.UpdateScreenerUpload(ctx, upload); err != nil {
+		return nil, im.fail(upload, err)
+	}
+
+	im.setProgress(upload.ID, StageParsing, 0, 0, "")
+	parser, err := im.mappedParser(ctx)
+	if err != nil {
+		return nil, im.fail(upload, err)
+	}
+	parsed, err := parser.ParseFile(data, opts.Sheet)
+	if err != nil {
+		return nil, im.fail(upload, fmt.Errorf("%w: %v", ErrInvalidCSV, err))
+	}
+
+	rows, items := buildImport(parsed)
+	total := len(items)
+	im.setProgress(upload.ID, StageImporting, 0, total, "")
+
+	err = im.repo.ImportScreenerUpload(ctx, upload, items, rows, im.batchSize, func(done int) {
+		im.setProgress(upload.ID, StageImporting, done, total, "")
+	})
+	if err != nil {
+		return nil, im.fail(upload, err)
+	}
+
+	im.setProgress(upload.ID, StageCompleted, total, total, "")
+	return &ImportResult{Upload: upload, Rows: rows}, nil
+}
+
+// mappedParser returns the importer's parser with the saved custom column
+// mappings applied.
+func (im *Importer) mappedParser(ctx context.Context) (*CSVParser, error) {
+	saved, err := im.repo.ListScreenerColumnMappings(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load column mappings: %w", err)
+	}
+	mappings := make(map[string]string, len(saved))
+	for _, m := range saved {
+		mappings[m.Column] = m.Field
+	}
+	return im.parser.WithMappings(mappings), nil
+}
+
+// SaveColumnMapping saves a custom mapping of an export column to one of
+// MappableFields, applied to later imports and previews.
+func (im *Importer) SaveColumnMapping(ctx context.Context, column, field string) (*storage.ScreenerColumnMapping, error) {
+	normalized := NormalizeColumnName(column)
+	if normalized == "" {
+		return nil, fmt.Errorf("%w: column is required", ErrInvalidMapping)
+	}
+	if !IsMappableField(field) {
+		return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidMapping, field)
+	}
+
+	mapping := &storage.ScreenerColumnMapping{
+		Column: normalized,
+		Label:  strings.TrimSpace(column),
+		Field:  field,
+	}
+	if err := im.repo.SaveScreenerColumnMapping(ctx, mapping); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// ImportPreview describes what importing an export would do.
+type ImportPreview struct {
+	Format        string          `json:"format"`
+	Columns       []ColumnMapping `json:"columns"`
+	Unmapped      []string        `json:"unmapped"`       // columns not read into a field; their numbers are kept as attributes
+	MissingFields []string        `json:"missing_fields"` // fields no column was read into
+	Sample        []PreviewRow    `json:"sample"`
+	Skipped       []PreviewSkip   `json:"skipped"`
+	InvalidValues []InvalidValue  `json:"invalid_values"` // imported as zero
+	TotalRows     int             `json:"total_rows"`
+	ImportRows    int             `json:"import_rows"`
+	SkippedRows   int             `json:"skipped_rows"`
+	InvalidCount  int             `json:"invalid_count"`
+}
+
+// PreviewRow is a row as it would be imported.
+type PreviewRow struct {
+	Row         int                       `json:"row"`
+	Symbol      string                    `json:"symbol"`
+	Name        string                    `json:"name"`
+	Sector      string                    `json:"sector,omitempty"`
+	Industry    string                    `json:"industry,omitempty"`
+	ISIN        string                    `json:"isin,omitempty"`
+	BSECode     string                    `json:"bse_code,omitempty"`
+	Fundamental *storage.StockFundamental `json:"fundamental"`
+}
+
+// PreviewSkip is a row that would not be imported.
+type PreviewSkip struct {
+	Row    int    `json:"row"`
+	Symbol string `json:"symbol,omitempty"`
+	Status string `json:"status"` // skipped, error
+	Reason string `json:"reason"`
+	Raw    string `json:"raw_row"`
+}
+
+// Preview parses an export as Import would, with the saved column mappings,
+// and reports how its columns were mapped and which rows and values would
+// not import cleanly. Nothing is written.
+func (im *Importer) Preview(ctx context.Context, data []byte, opts ImportOptions) (*ImportPreview, error) {
+	parser, err := im.mappedParser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parser.ParseFile(data, opts.Sheet)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCSV, err)
+	}
+
+	preview := &ImportPreview{
+		Format:        DetectFormat(data),
+		Columns:       parsed.Columns,
+		Unmapped:      []string{},
+		MissingFields: []string{},
+		Sample:        []PreviewRow{},
+		Skipped:       []PreviewSkip{},
+		InvalidValues: []InvalidValue{},
+		TotalRows:     len(parsed.Stocks) + len(parsed.Errors),
+	}
+
+	mapped := make(map[string]bool)
+	for _, col := range parsed.Columns {
+		if col.Field == "" {
+			preview.Unmapped = append(preview.Unmapped, col.Column)
+		} else {
+			mapped[col.Field] = true
+		}
+	}
+	for _, field := range MappableFields() {
+		if !mapped[field] {
+			preview.MissingFields = append(preview.MissingFields, field)
+		}
+	}
+
+	rows, items := buildImport(parsed)
+	preview.ImportRows = len(items)
+	for _, row := range rows {
+		if row.Status == "" {
+			continue
+		}
+		preview.SkippedRows++
+		if len(preview.Skipped) < previewListLimit {
+			preview.Skipped = append(preview.Skipped, PreviewSkip{
+				Row:    row.RowNumber,
+				Symbol: row.Symbol,
+				Status: row.Status,
+				Reason: row.ErrorMessage,
+				Raw:    row.RawRow,
+			})
+		}
+	}
+
+	for _, item := range items {
+		if len(preview.Sample) >= previewSampleSize {
+			break
+		}
+		preview.Sample = append(preview.Sample, PreviewRow{
+			Row:         rows[item.Row].RowNumber,
+			Symbol:      item.Symbol,
+			Name:        item.Name,
+			Sector:      item.Sector,
+			Industry:    item.Industry,
+			ISIN:        item.ISIN,
+			BSECode:     item.BSECode,
+			Fundamental: item.Fundamental,
+		})
+	}
+
+	for _, stock := range parsed.Stocks {
+		preview.InvalidCount += len(stock.Invalid)
+		for _, invalid := range stock.Invalid {
+			if len(preview.InvalidValues) >= previewListLimit {
+				break
+			}
+			preview.InvalidValues = append(preview.InvalidValues, invalid)
+		}
+	}
+
+	return preview, nil
+}
+
+// fail marks an upload as failed and returns err.
+func (im *Importer) fail(upload *storage.ScreenerUpload, err error) error {
+	im.setProgress(upload.ID, StageFailed, 0, 0, err.Error())
+
+	// Record the failure even if the import's context was cancelled.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	upload.Status = storage.UploadStatusFailed
+	upload.ErrorMessage = err.Error()
+	if saveErr := im.repo.UpdateScreenerUpload(ctx, upload); saveErr != nil {
+		fmt.Printf("Warning: failed to update upload %d: %v\n", upload.ID, saveErr)
+	}
+	return err
+}
+
+// setProgress records import progress and drops finished imports that are
+// older than progressRetention.
+func (im *Importer) setProgress(uploadID uint, stage string, processed, total int, errMsg string) {
+	now := time.Now()
+
+	im.mu.Lock()
+	p, ok := im.progress[uploadID]
+	if !ok {
+		p = &ImportProgress{UploadID: uploadID, StartedAt: now}
+		im.progress[uploadID] = p
+	}
+	p.Stage = stage
+	p.Error = errMsg
+	if stage != StageFailed {
+		p.Processed, p.Total = processed, total
+	}
+	p.UpdatedAt = now
+	snapshot := *p
+
+	for id, other := range im.progress {
+		finished := other.Stage == StageCompleted || other.Stage == StageFailed
+		if finished && now.Sub(other.UpdatedAt) > progressRetention {
+			delete(im.progress, id)
+		}
+	}
+	im.mu.Unlock()
+
+	if im.OnProgress != nil {
+		im.OnProgress(snapshot)
+	}
+}
+
+// buildImport turns parse results into row results and the rows to import.
+// Rows that failed to parse, have an over-long symbol, ISIN or BSE code, or
+// repeat an earlier symbol are recorded without being imported.
+func buildImport(parsed *ParseResult) ([]storage.ScreenerUploadRow, []storage.UploadImport) {
+	rows := make([]storage.ScreenerUploadRow, 0, len(parsed.Stocks)+len(parsed.Errors))
+	var items []storage.UploadImport
+
+	for _, rowErr := range parsed.Errors {
+		rows = append(rows, storage.ScreenerUploadRow{
+			RowNumber:    rowErr.Row,
+			Status:       storage.UploadRowError,
+			ErrorMessage: rowErr.Err.Error(),
+			RawRow:       encodeRawRow(rowErr.Raw),
+		})
+	}
+
+	firstRow := make(map[string]int)
+	for _, stock := range parsed.Stocks {
+		row := storage.ScreenerUploadRow{
+			RowNumber: stock.Row,
+			Symbol:    stock.Symbol,
+			RawRow:    encodeRawRow(stock.Raw),
+		}
+
+		first, duplicate := firstRow[stock.Symbol]
+		switch {
+		case len(stock.Symbol) > maxSymbolLength:
+			row.Symbol = textutil.Truncate(stock.Symbol, maxSymbolLength)
+			row.Status = storage.UploadRowError
+			row.ErrorMessage = fmt.Sprintf("symbol %q is longer than %d characters", stock.Symbol, maxSymbolLength)
+		case len(stock.ISIN) > maxISINLength:
+			row.Status = storage.UploadRowError
+			row.ErrorMessage = fmt.Sprintf("ISIN %q is longer than %d characters", stock.ISIN, maxISINLength)
+		case len(stock.BSECode) > maxBSECodeLength:
+			row.Status = storage.UploadRowError
+			row.ErrorMessage = fmt.Sprintf("BSE code %q is longer than %d characters", stock.BSECode, maxBSECodeLength)
+		case duplicate:
+			row.Status = storage.UploadRowSkipped
+			row.ErrorMessage = fmt.Sprintf("duplicate of row %d", first)
+		default:
+			firstRow[stock.Symbol] = stock.Row
+			items = append(items, storage.UploadImport{
+				Symbol:      stock.Symbol,
+				Name:        textutil.Truncate(stock.Name, maxNameLength),
+				Exchange:    "NSE",
+				Sector:      textutil.Truncate(stock.Sector, maxSectorLength),
+				Industry:    textutil.Truncate(stock.Industry, maxSectorLength),
+				ISIN:        stock.ISIN,
+				BSECode:     stock.BSECode,
+				Fundamental: stock.Fundamental,
+				Row:         len(rows),
+			})
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, items
+}
+
+// encodeRawRow encodes an original CSV row as JSON for the upload log.
+func encodeRawRow(raw map[string]string) string {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
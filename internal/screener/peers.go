@@ -0,0 +1,108 @@
+package screener
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// ParsePeers parses the peer comparison table screener.in serves from
+// /api/company/<warehouse id>/peers/ for the peers section of a company page.
+func ParsePeers(r io.Reader) ([]storage.IndustryPeer, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	return parsePeerTable(doc.Find("table.data-table").First()), nil
+}
+
+// parsePeerTable parses a peer comparison table. Its columns can be chosen by
+// the screener.in user, so they are matched by header. The median row at the
+// bottom is skipped.
+func parsePeerTable(table *goquery.Selection) []storage.IndustryPeer {
+	var columns []string
+	table.Find("tr").EachWithBreak(func(_ int, tr *goquery.Selection) bool {
+		ths := tr.Find("th")
+		if ths.Length() == 0 {
+			return true
+		}
+		ths.Each(func(_ int, th *goquery.Selection) {
+			columns = append(columns, NormalizeColumnName(th.Text()))
+		})
+		return false
+	})
+	if len(columns) == 0 {
+		return nil
+	}
+
+	fetchedAt := time.Now()
+
+	var peers []storage.IndustryPeer
+	table.Find("tr").Each(func(_ int, tr *goquery.Selection) {
+		cells := tr.Find("td")
+		if cells.Length() == 0 {
+			return
+		}
+
+		peer := storage.IndustryPeer{Source: "screener_scrape", FetchedAt: fetchedAt}
+		cells.Each(func(i int, td *goquery.Selection) {
+			if i >= len(columns) {
+				return
+			}
+			text := strings.TrimSpace(td.Text())
+			value := parseNumber(text)
+
+			switch column := columns[i]; {
+			case column == "name":
+				peer.Name = strings.Join(strings.Fields(text), " ")
+				if href, ok := td.Find("a").Attr("href"); ok {
+					peer.Symbol = peerSymbol(href)
+				}
+			case strings.HasPrefix(column, "cmp/bv"), column == "pricetobook":
+				peer.PriceToBook = value
+			case strings.HasPrefix(column, "cmp"):
+				peer.CurrentPrice = value
+			case column == "p/e":
+				peer.StockPE = value
+			case strings.HasPrefix(column, "marcap"):
+				peer.MarketCap = value
+			case strings.HasPrefix(column, "divyld"):
+				peer.DividendYield = value
+			case strings.HasPrefix(column, "npqtr"):
+				peer.NetProfitQtr = value
+			case strings.HasPrefix(column, "qtrprofitvar"):
+				peer.ProfitGrowthQtr = value
+			case strings.HasPrefix(column, "salesqtr"):
+				peer.SalesQtr = value
+			case strings.HasPrefix(column, "qtrsalesvar"):
+				peer.SalesGrowthQtr = value
+			case column == "roce":
+				peer.ROCE = value
+			case column == "roe":
+				peer.ROE = value
+			}
+		})
+
+		if peer.Symbol == "" || strings.HasPrefix(strings.ToLower(peer.Name), "median") {
+			return
+		}
+		peers = append(peers, peer)
+	})
+	return peers
+}
+
+// peerSymbol returns the symbol of a company link like
+// "/company/TCS/consolidated/".
+func peerSymbol(href string) string {
+	parts := strings.Split(strings.Trim(href, "/"), "/")
+	for i, part := range parts {
+		if part == "company" && i+1 < len(parts) {
+			return normalizeSymbol(parts[i+1])
+		}
+	}
+	return ""
+}
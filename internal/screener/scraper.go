@@ -2,8 +2,10 @@
 package screener
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"regexp"
 	"strconv"
@@ -22,6 +24,9 @@ type Scraper struct {
 	scrapeDelay time.Duration
 	lastRequest time.Time
 	mu          sync.Mutex
+	cache       *PageCache
+	offline     bool
+	basis       string
 }
 
 var scraperMu sync.Mutex
@@ -37,9 +42,37 @@ func NewScraper(baseURL string, scrapeDelay time.Duration) *Scraper {
 			Timeout: 30 * time.Second,
 		},
 		scrapeDelay: scrapeDelay,
+		basis:       storage.BasisConsolidated,
 	}
 }
 
+// SetCache makes the scraper keep fetched company pages in cache and serve
+// fresh copies from it.
+func (s *Scraper) SetCache(cache *PageCache) {
+	s.cache = cache
+}
+
+// SetOffline makes the scraper serve company pages only from its cache.
+// Pages that are not cached fail with ErrNotCached.
+func (s *Scraper) SetOffline(offline bool) {
+	s.offline = offline
+}
+
+// SetBasis sets the financials basis FetchStock asks for. Anything other
+// than standalone means consolidated.
+func (s *Scraper) SetBasis(basis string) {
+	s.basis = NormalizeBasis(basis)
+}
+
+// NormalizeBasis returns storage.BasisStandalone for "standalone" in any
+// case and storage.BasisConsolidated otherwise.
+func NormalizeBasis(basis string) string {
+	if strings.EqualFold(strings.TrimSpace(basis), storage.BasisStandalone) {
+		return storage.BasisStandalone
+	}
+	return storage.BasisConsolidated
+}
+
 // StockData represents scraped stock data.
 type StockData struct {
 	Symbol            string
@@ -60,19 +93,211 @@ type StockData struct {
 	DebtToEquity      float64
 	PromoterHolding   float64
 	PledgedPercentage float64
+	RevenueGrowth10Y  float64
+	RevenueGrowth5Y   float64
 	RevenueGrowth3Y   float64
+	RevenueGrowthTTM  float64
+	ProfitGrowth10Y   float64
+	ProfitGrowth5Y    float64
 	ProfitGrowth3Y    float64
+	ProfitGrowthTTM   float64
+	PriceCAGR10Y      float64
+	PriceCAGR5Y       float64
+	PriceCAGR3Y       float64
+	PriceCAGR1Y       float64
+	ROE10Y            float64
+	ROE5Y             float64
+	ROE3Y             float64
+	ROELastYear       float64
 	PriceToBook       float64
 	IntrinsicValue    float64
 	GrahamNumber      float64
 	PEGRatio          float64
+
+	// Statements holds one entry per period of each financial statement
+	// table on the page.
+	Statements []storage.FinancialStatement
+
+	// Shareholding holds the quarterly shareholding pattern, oldest first.
+	Shareholding []storage.ShareholdingSnapshot
+
+	// Peers holds the peer comparison table of the company's industry.
+	// screener.in loads it separately using WarehouseID.
+	Peers       []storage.IndustryPeer
+	WarehouseID string
+
+	// Basis is consolidated or standalone.
+	Basis string
+
+	// FetchedAt is when the page was fetched from screener.in, which is
+	// earlier than the parse time for cached pages.
+	FetchedAt time.Time
 }
 
-// FetchStock fetches stock data from screener.in
+// FetchStock fetches stock data from screener.in on the scraper's basis.
 func (s *Scraper) FetchStock(ctx context.Context, symbol string) (*StockData, error) {
+	return s.FetchStockBasis(ctx, symbol, s.basis)
+}
+
+// FetchStockBasis fetches stock data on the given basis. Consolidated
+// financials fall back to standalone when the company has none. The basis
+// the data is on is recorded in StockData.Basis.
+func (s *Scraper) FetchStockBasis(ctx context.Context, symbol, basis string) (*StockData, error) {
 	// Normalize symbol (remove .NS or .BO suffix if present)
 	symbol = normalizeSymbol(symbol)
 
+	if NormalizeBasis(basis) == storage.BasisStandalone {
+		return s.fetchStock(ctx, symbol, storage.BasisStandalone)
+	}
+
+	data, err := s.fetchStock(ctx, symbol, storage.BasisConsolidated)
+	if err == nil && len(data.Statements) > 0 {
+		return data, nil
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	standalone, standaloneErr := s.fetchStock(ctx, symbol, storage.BasisStandalone)
+	switch {
+	case standaloneErr == nil:
+		return standalone, nil
+	case err == nil:
+		return data, nil
+	default:
+		return nil, fmt.Errorf("consolidated: %v; standalone: %w", err, standaloneErr)
+	}
+}
+
+// fetchStock fetches and parses a company page on one basis.
+func (s *Scraper) fetchStock(ctx context.Context, symbol, basis string) (*StockData, error) {
+	body, fetchedAt, err := s.fetchCompanyPage(ctx, symbol, basis)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ParseCompanyPage(symbol, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	data.setFetchedAt(fetchedAt)
+
+	if len(data.Peers) == 0 && data.WarehouseID != "" {
+		if err := s.fetchPeers(ctx, data); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			fmt.Printf("Warning: failed to fetch peers of %s: %v\n", symbol, err)
+		}
+	}
+
+	// Trust the page's own label over the URL, in case screener.in served
+	// standalone figures for a consolidated request.
+	if data.Basis == "" {
+		data.Basis = basis
+	}
+	data.setBasis(data.Basis)
+	return data, nil
+}
+
+// fetchPeers fetches the peer comparison table of a company page.
+func (s *Scraper) fetchPeers(ctx context.Context, data *StockData) error {
+	url := fmt.Sprintf("%s/api/company/%s/peers/", s.baseURL, data.WarehouseID)
+	body, fetchedAt, err := s.fetchPage(ctx, "peers-"+data.WarehouseID, url)
+	if err != nil {
+		return err
+	}
+
+	peers, err := ParsePeers(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for i := range peers {
+		peers[i].FetchedAt = fetchedAt
+	}
+	data.Peers = peers
+	return nil
+}
+
+// fetchCompanyPage returns the company page of a symbol on a basis and when
+// it was fetched.
+func (s *Scraper) fetchCompanyPage(ctx context.Context, symbol, basis string) ([]byte, time.Time, error) {
+	key, url := symbol, fmt.Sprintf("%s/company/%s/", s.baseURL, symbol)
+	if basis == storage.BasisConsolidated {
+		key, url = symbol+"-consolidated", url+"consolidated/"
+	}
+	return s.fetchPage(ctx, key, url)
+}
+
+// fetchPage returns a page and when it was fetched, from the cache under key
+// if it holds a fresh copy. A stale copy is revalidated, and served as is if
+// screener.in cannot be reached.
+func (s *Scraper) fetchPage(ctx context.Context, key, url string) ([]byte, time.Time, error) {
+	var cached *CachedPage
+	if s.cache != nil {
+		page, err := s.cache.Get(key)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		cached = page
+	}
+
+	if s.offline {
+		if cached == nil {
+			return nil, time.Time{}, fmt.Errorf("%w: %s (offline mode)", ErrNotCached, key)
+		}
+		return cached.Body, cached.FetchedAt, nil
+	}
+	if cached != nil && s.cache.Fresh(cached) {
+		return cached.Body, cached.FetchedAt, nil
+	}
+
+	resp, err := s.get(ctx, url, cached)
+	if err != nil {
+		if cached != nil && ctx.Err() == nil {
+			fmt.Printf("Warning: serving cached page %s from %s: %v\n", key, cached.FetchedAt.Format(time.RFC3339), err)
+			return cached.Body, cached.FetchedAt, nil
+		}
+		return nil, time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		if err := s.cache.Touch(cached); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		return cached.Body, cached.FetchedAt, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, time.Time{}, fmt.Errorf("screener returned status %d for %s", resp.StatusCode, url)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read page: %w", err)
+	}
+
+	fetchedAt := time.Now()
+	if s.cache != nil {
+		page := &CachedPage{
+			Key:          key,
+			URL:          url,
+			FetchedAt:    fetchedAt,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			Body:         body,
+		}
+		if err := s.cache.Put(page); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+
+	return body, fetchedAt, nil
+}
+
+// get requests a page, waiting out the scrape delay and retrying when rate
+// limited. If cached is set, the request is conditional on it.
+func (s *Scraper) get(ctx context.Context, url string, cached *CachedPage) (*http.Response, error) {
 	// Rate limiting - ensure minimum delay between requests
 	s.mu.Lock()
 	elapsed := time.Since(s.lastRequest)
@@ -89,13 +314,11 @@ func (s *Scraper) FetchStock(ctx context.Context, symbol string) (*StockData, er
 	s.lastRequest = time.Now()
 	s.mu.Unlock()
 
-	url := fmt.Sprintf("%s/company/%s/", s.baseURL, symbol)
-
 	// Retry with exponential backoff
 	var resp *http.Response
 	var err error
 	maxRetries := 3
-	
+
 	for attempt := 0; attempt < maxRetries; attempt++ {
 		req, reqErr := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 		if reqErr != nil {
@@ -108,6 +331,16 @@ func (s *Scraper) FetchStock(ctx context.Context, symbol string) (*StockData, er
 		req.Header.Set("Accept-Language", "en-US,en;q=0.5")
 		req.Header.Set("Cache-Control", "no-cache")
 
+		// Revalidate a cached copy
+		if cached != nil {
+			if cached.ETag != "" {
+				req.Header.Set("If-None-Match", cached.ETag)
+			}
+			if cached.LastModified != "" {
+				req.Header.Set("If-Modified-Since", cached.LastModified)
+			}
+		}
+
 		resp, err = s.client.Do(req)
 		if err != nil {
 			return nil, fmt.Errorf("failed to fetch page: %w", err)
@@ -126,31 +359,55 @@ func (s *Scraper) FetchStock(ctx context.Context, symbol string) (*StockData, er
 		}
 		break
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("screener returned status %d for symbol %s", resp.StatusCode, symbol)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("screener rate limit exceeded for %s", url)
 	}
+	return resp, nil
+}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+// ParseCompanyPage parses a screener.in company page. It is separate from
+// FetchStock so saved pages can be parsed offline.
+func ParseCompanyPage(symbol string, r io.Reader) (*StockData, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
 	data := &StockData{
-		Symbol: symbol,
+		Symbol:    symbol,
+		FetchedAt: time.Now(),
 	}
 
 	// Parse company name
 	data.Name = strings.TrimSpace(doc.Find("h1.margin-0").First().Text())
 
+	// Parse which basis the figures are on from the section subtitles,
+	// e.g. "Consolidated Figures in Rs. Crores"
+	doc.Find("section p.sub").EachWithBreak(func(i int, sel *goquery.Selection) bool {
+		text := strings.ToLower(sel.Text())
+		switch {
+		case strings.Contains(text, "consolidated figures"):
+			data.Basis = storage.BasisConsolidated
+		case strings.Contains(text, "standalone figures"):
+			data.Basis = storage.BasisStandalone
+		default:
+			return true
+		}
+		return false
+	})
+
+	// The warehouse ID identifies the company in screener.in's page APIs
+	data.WarehouseID, _ = doc.Find("[data-warehouse-id]").First().Attr("data-warehouse-id")
+
 	// Parse sector and industry from company info
 	doc.Find(".company-info a").Each(func(i int, sel *goquery.Selection) {
 		href, _ := sel.Attr("href")
+		title, _ := sel.Attr("title")
 		text := strings.TrimSpace(sel.Text())
-		if strings.Contains(href, "/sector/") {
+		if strings.Contains(href, "/sector/") || title == "Sector" {
 			data.Sector = text
-		} else if strings.Contains(href, "/industry/") {
+		} else if strings.Contains(href, "/industry/") || title == "Industry" {
 			data.Industry = text
 		}
 	})
@@ -185,6 +442,10 @@ func (s *Scraper) FetchStock(ctx context.Context, symbol string) (*StockData, er
 			data.ROE = value
 		case strings.Contains(name, "Face Value"):
 			data.FaceValue = value
+		case strings.Contains(name, "Intrinsic Value"):
+			data.IntrinsicValue = value
+		case strings.Contains(name, "Pledged"):
+			data.PledgedPercentage = value
 		}
 	})
 
@@ -221,6 +482,41 @@ func (s *Scraper) FetchStock(ctx context.Context, symbol string) (*StockData, er
 		data.GrahamNumber = sqrt(22.5 * data.EPS * data.BookValue)
 	}
 
+	// Parse the financial statement tables and growth summaries. Growth is
+	// computed from the annual results if the page has no summary.
+	data.Statements = parseStatements(doc)
+	parseGrowthRanges(doc, data)
+	if data.RevenueGrowth3Y == 0 {
+		if growth, ok := statementCAGR(data.Statements, storage.StatementProfitLoss, 3, "Sales", "Revenue"); ok {
+			data.RevenueGrowth3Y = growth
+		}
+	}
+	if data.ProfitGrowth3Y == 0 {
+		if growth, ok := statementCAGR(data.Statements, storage.StatementProfitLoss, 3, "Net Profit"); ok {
+			data.ProfitGrowth3Y = growth
+		}
+	}
+	if data.IntrinsicValue == 0 {
+		data.IntrinsicValue = intrinsicValue(data.EPS, data.ProfitGrowth3Y)
+	}
+
+	// Parse the quarterly shareholding pattern. The page only shows the
+	// current pledge, so it is recorded against the latest quarter.
+	data.Shareholding = parseShareholding(doc)
+	if n := len(data.Shareholding); n > 0 {
+		latest := &data.Shareholding[n-1]
+		pledged := data.PledgedPercentage
+		latest.PledgedPromoter = &pledged
+		if data.PromoterHolding == 0 {
+			data.PromoterHolding = latest.Promoters
+		}
+	}
+
+	// Saved pages may hold the peer table loaded by the browser
+	if table := doc.Find("section#peers table.data-table").First(); table.Length() > 0 {
+		data.Peers = parsePeerTable(table)
+	}
+
 	return data, nil
 }
 
@@ -242,14 +538,51 @@ func (d *StockData) ToFundamental(stockID uint) *storage.StockFundamental {
 		DebtToEquity:      d.DebtToEquity,
 		PromoterHolding:   d.PromoterHolding,
 		PledgedPercentage: d.PledgedPercentage,
+		RevenueGrowth10Y:  d.RevenueGrowth10Y,
+		RevenueGrowth5Y:   d.RevenueGrowth5Y,
 		RevenueGrowth3Y:   d.RevenueGrowth3Y,
+		RevenueGrowthTTM:  d.RevenueGrowthTTM,
+		ProfitGrowth10Y:   d.ProfitGrowth10Y,
+		ProfitGrowth5Y:    d.ProfitGrowth5Y,
 		ProfitGrowth3Y:    d.ProfitGrowth3Y,
+		ProfitGrowthTTM:   d.ProfitGrowthTTM,
+		PriceCAGR10Y:      d.PriceCAGR10Y,
+		PriceCAGR5Y:       d.PriceCAGR5Y,
+		PriceCAGR3Y:       d.PriceCAGR3Y,
+		PriceCAGR1Y:       d.PriceCAGR1Y,
+		ROE10Y:            d.ROE10Y,
+		ROE5Y:             d.ROE5Y,
+		ROE3Y:             d.ROE3Y,
+		ROELastYear:       d.ROELastYear,
 		PriceToBook:       d.PriceToBook,
 		IntrinsicValue:    d.IntrinsicValue,
 		GrahamNumber:      d.GrahamNumber,
 		PEGRatio:          d.PEGRatio,
+		Basis:             d.Basis,
 		Source:            "screener_scrape",
-		FetchedAt:         time.Now(),
+		FetchedAt:         d.FetchedAt,
+	}
+}
+
+// setBasis records the basis of the data and its financial statements.
+func (d *StockData) setBasis(basis string) {
+	d.Basis = basis
+	for i := range d.Statements {
+		d.Statements[i].Basis = basis
+	}
+}
+
+// setFetchedAt records when the page the data was parsed from was fetched.
+func (d *StockData) setFetchedAt(t time.Time) {
+	d.FetchedAt = t
+	for i := range d.Statements {
+		d.Statements[i].FetchedAt = t
+	}
+	for i := range d.Shareholding {
+		d.Shareholding[i].FetchedAt = t
+	}
+	for i := range d.Peers {
+		d.Peers[i].FetchedAt = t
 	}
 }
 
@@ -0,0 +1,240 @@
+package screener
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// readFixture returns a file from testdata.
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// removeSection cuts a <section> of a company page out by its ID.
+func removeSection(id string) func(string) string {
+	pattern := regexp.MustCompile(`(?s)<section id="` + id + `".*?</section>`)
+	return func(page string) string {
+		return pattern.ReplaceAllString(page, "")
+	}
+}
+
+func TestParseCompanyPage(t *testing.T) {
+	page := readFixture(t, "cache/EXAMPLE-consolidated.html")
+
+	tests := []struct {
+		name      string
+		edit      func(string) string
+		wantBasis string
+		// wantPeriods is the number of periods parsed per statement type.
+		wantPeriods map[string]int
+	}{
+		{
+			name:      "consolidated",
+			wantBasis: storage.BasisConsolidated,
+			wantPeriods: map[string]int{
+				storage.StatementQuarterly:    8,
+				storage.StatementProfitLoss:   7,
+				storage.StatementBalanceSheet: 7,
+				storage.StatementCashFlow:     6,
+			},
+		},
+		{
+			name: "standalone",
+			edit: func(page string) string {
+				return strings.ReplaceAll(page, "Consolidated Figures", "Standalone Figures")
+			},
+			wantBasis: storage.BasisStandalone,
+			wantPeriods: map[string]int{
+				storage.StatementQuarterly:    8,
+				storage.StatementProfitLoss:   7,
+				storage.StatementBalanceSheet: 7,
+				storage.StatementCashFlow:     6,
+			},
+		},
+		{
+			name: "no basis label",
+			edit: func(page string) string {
+				return strings.ReplaceAll(page, "Consolidated Figures", "Figures")
+			},
+			wantBasis: "",
+			wantPeriods: map[string]int{
+				storage.StatementQuarterly:    8,
+				storage.StatementProfitLoss:   7,
+				storage.StatementBalanceSheet: 7,
+				storage.StatementCashFlow:     6,
+			},
+		},
+		{
+			name:      "missing balance sheet",
+			edit:      removeSection("balance-sheet"),
+			wantBasis: storage.BasisConsolidated,
+			wantPeriods: map[string]int{
+				storage.StatementQuarterly:  8,
+				storage.StatementProfitLoss: 7,
+				storage.StatementCashFlow:   6,
+			},
+		},
+		{
+			name: "no statements",
+			edit: func(page string) string {
+				for _, id := range []string{"quarters", "profit-loss", "balance-sheet", "cash-flow"} {
+					page = removeSection(id)(page)
+				}
+				return page
+			},
+			// The ratios section is still labelled.
+			wantBasis:   storage.BasisConsolidated,
+			wantPeriods: map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html := page
+			if tt.edit != nil {
+				html = tt.edit(html)
+			}
+
+			data, err := ParseCompanyPage("EXAMPLE", strings.NewReader(html))
+			if err != nil {
+				t.Fatalf("ParseCompanyPage: %v", err)
+			}
+
+			if data.Basis != tt.wantBasis {
+				t.Errorf("Basis = %q, want %q", data.Basis, tt.wantBasis)
+			}
+			periods := make(map[string]int)
+			for _, s := range data.Statements {
+				periods[s.StatementType]++
+			}
+			for statementType, want := range tt.wantPeriods {
+				if periods[statementType] != want {
+					t.Errorf("%s has %d periods, want %d", statementType, periods[statementType], want)
+				}
+			}
+			for statementType, got := range periods {
+				if _, ok := tt.wantPeriods[statementType]; !ok {
+					t.Errorf("%s has %d periods, want none", statementType, got)
+				}
+			}
+
+			// The rest of the page parses regardless of the statements.
+			if data.Name != "Example Industries Ltd" {
+				t.Errorf("Name = %q", data.Name)
+			}
+			if data.CurrentPrice != 1295 || data.StockPE != 46.7 {
+				t.Errorf("CurrentPrice = %v, StockPE = %v, want 1295 and 46.7", data.CurrentPrice, data.StockPE)
+			}
+			if len(data.Shareholding) != 5 {
+				t.Errorf("got %d shareholding quarters, want 5", len(data.Shareholding))
+			}
+		})
+	}
+}
+
+func TestParseCompanyPageStatements(t *testing.T) {
+	data, err := ParseCompanyPage("EXAMPLE", strings.NewReader(readFixture(t, "cache/EXAMPLE-consolidated.html")))
+	if err != nil {
+		t.Fatalf("ParseCompanyPage: %v", err)
+	}
+
+	find := func(statementType, period string) *storage.FinancialStatement {
+		for i := range data.Statements {
+			if data.Statements[i].StatementType == statementType && data.Statements[i].Period == period {
+				return &data.Statements[i]
+			}
+		}
+		return nil
+	}
+	date := func(year int, month time.Month, day int) *time.Time {
+		d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+
+	tests := []struct {
+		statementType string
+		period        string
+		wantEnd       *time.Time
+		wantItems     int
+		item          string
+		wantValue     float64
+		wantPosition  int
+	}{
+		{storage.StatementQuarterly, "Sep 2022", date(2022, time.September, 30), 11, "Sales", 2410, 0},
+		{storage.StatementQuarterly, "Jun 2024", date(2024, time.June, 30), 11, "EPS in Rs", 6.99, 10},
+		{storage.StatementProfitLoss, "Mar 2024", date(2024, time.March, 31), 12, "Sales", 10750, 0},
+		{storage.StatementProfitLoss, "Mar 2024", date(2024, time.March, 31), 12, "Dividend Payout %", 23, 11},
+		{storage.StatementProfitLoss, "TTM", nil, 11, "EPS in Rs", 27.72, 10},
+		{storage.StatementBalanceSheet, "Sep 2024", date(2024, time.September, 30), 10, "Total Assets", 10806, 9},
+		{storage.StatementBalanceSheet, "Mar 2019", date(2019, time.March, 31), 10, "Equity Capital", 96, 0},
+		{storage.StatementCashFlow, "Mar 2024", date(2024, time.March, 31), 4, "Cash from Operating Activity", 1402, 0},
+		{storage.StatementCashFlow, "Mar 2020", date(2020, time.March, 31), 4, "Net Cash Flow", 27, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.statementType+" "+tt.period+" "+tt.item, func(t *testing.T) {
+			statement := find(tt.statementType, tt.period)
+			if statement == nil {
+				t.Fatal("statement not found")
+			}
+			switch {
+			case tt.wantEnd == nil && statement.PeriodEnd != nil:
+				t.Errorf("PeriodEnd = %v, want nil", statement.PeriodEnd)
+			case tt.wantEnd != nil && (statement.PeriodEnd == nil || !statement.PeriodEnd.Equal(*tt.wantEnd)):
+				t.Errorf("PeriodEnd = %v, want %v", statement.PeriodEnd, tt.wantEnd)
+			}
+			if statement.Source != "screener_scrape" {
+				t.Errorf("Source = %q", statement.Source)
+			}
+			if len(statement.LineItems) != tt.wantItems {
+				t.Errorf("got %d line items, want %d", len(statement.LineItems), tt.wantItems)
+			}
+
+			for _, item := range statement.LineItems {
+				if item.Name == tt.item {
+					if item.Value != tt.wantValue || item.Position != tt.wantPosition {
+						t.Errorf("%s = %v at %d, want %v at %d", tt.item, item.Value, item.Position, tt.wantValue, tt.wantPosition)
+					}
+					return
+				}
+			}
+			t.Errorf("line item %q not found", tt.item)
+		})
+	}
+}
+
+func TestParseCompanyPageShareholding(t *testing.T) {
+	data, err := ParseCompanyPage("EXAMPLE", strings.NewReader(readFixture(t, "cache/EXAMPLE-consolidated.html")))
+	if err != nil {
+		t.Fatalf("ParseCompanyPage: %v", err)
+	}
+
+	if len(data.Shareholding) == 0 {
+		t.Fatal("no shareholding parsed")
+	}
+	latest := data.Shareholding[len(data.Shareholding)-1]
+	if latest.Period != "Sep 2024" || latest.Promoters != 49.12 || latest.Shareholders != 318442 {
+		t.Errorf("latest quarter = %s, promoters %v, %d shareholders", latest.Period, latest.Promoters, latest.Shareholders)
+	}
+
+	// The page only shows the current pledge, so earlier quarters have
+	// none recorded.
+	if latest.PledgedPromoter == nil || *latest.PledgedPromoter != 2.35 {
+		t.Errorf("latest PledgedPromoter = %v, want 2.35", latest.PledgedPromoter)
+	}
+	for _, s := range data.Shareholding[:len(data.Shareholding)-1] {
+		if s.PledgedPromoter != nil {
+			t.Errorf("%s PledgedPromoter = %v, want nil", s.Period, *s.PledgedPromoter)
+		}
+	}
+}
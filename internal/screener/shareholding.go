@@ -0,0 +1,55 @@
+package screener
+
+import (
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// parseShareholding parses the quarterly shareholding pattern table of a
+// company page, oldest quarter first.
+func parseShareholding(doc *goquery.Document) []storage.ShareholdingSnapshot {
+	table := doc.Find("section#shareholding #quarterly-shp table.data-table").First()
+	if table.Length() == 0 {
+		table = doc.Find("section#shareholding table.data-table").First()
+	}
+	if table.Length() == 0 {
+		return nil
+	}
+
+	fetchedAt := time.Now()
+
+	var snapshots []storage.ShareholdingSnapshot
+	for _, quarter := range parseStatementTable(table, "") {
+		if quarter.PeriodEnd == nil {
+			continue
+		}
+
+		snapshot := storage.ShareholdingSnapshot{
+			Period:    quarter.Period,
+			PeriodEnd: *quarter.PeriodEnd,
+			Source:    "screener_scrape",
+			FetchedAt: fetchedAt,
+		}
+		for _, item := range quarter.LineItems {
+			switch strings.ToLower(item.Name) {
+			case "promoters":
+				snapshot.Promoters = item.Value
+			case "fiis":
+				snapshot.FIIs = item.Value
+			case "diis":
+				snapshot.DIIs = item.Value
+			case "government":
+				snapshot.Government = item.Value
+			case "public":
+				snapshot.Public = item.Value
+			case "no. of shareholders":
+				snapshot.Shareholders = int(item.Value)
+			}
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
@@ -0,0 +1,181 @@
+package screener
+
+import (
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// statementSections maps the section IDs of a screener.in company page to
+// the statement type of the table they hold.
+var statementSections = []struct {
+	id            string
+	statementType string
+}{
+	{"quarters", storage.StatementQuarterly},
+	{"profit-loss", storage.StatementProfitLoss},
+	{"balance-sheet", storage.StatementBalanceSheet},
+	{"cash-flow", storage.StatementCashFlow},
+}
+
+// parseStatements parses the quarterly results, profit & loss, balance sheet
+// and cash flow tables of a company page into one statement per period.
+func parseStatements(doc *goquery.Document) []storage.FinancialStatement {
+	fetchedAt := time.Now()
+
+	var statements []storage.FinancialStatement
+	for _, section := range statementSections {
+		table := doc.Find("section#" + section.id + " table.data-table").First()
+		if table.Length() == 0 {
+			continue
+		}
+		for _, statement := range parseStatementTable(table, section.statementType) {
+			statement.Source = "screener_scrape"
+			statement.FetchedAt = fetchedAt
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+// parseStatementTable parses a results table whose header row holds periods
+// and whose body rows hold one line item each. Periods without any values
+// are dropped.
+func parseStatementTable(table *goquery.Selection, statementType string) []storage.FinancialStatement {
+	var statements []storage.FinancialStatement
+	table.Find("thead tr").First().Find("th").Each(func(i int, th *goquery.Selection) {
+		if i == 0 {
+			return
+		}
+		period := strings.Join(strings.Fields(th.Text()), " ")
+		statements = append(statements, storage.FinancialStatement{
+			StatementType: statementType,
+			Period:        period,
+			PeriodEnd:     parsePeriodEnd(period),
+		})
+	})
+
+	position := 0
+	table.Find("tbody tr").Each(func(_ int, tr *goquery.Selection) {
+		cells := tr.Find("td")
+		name := cleanLabel(cells.First().Text())
+		if name == "" {
+			return
+		}
+
+		found := false
+		cells.Slice(1, goquery.ToEnd).Each(func(i int, td *goquery.Selection) {
+			text := strings.TrimSpace(td.Text())
+			if i >= len(statements) || !hasDigit(text) {
+				return
+			}
+			statements[i].LineItems = append(statements[i].LineItems, storage.FinancialLineItem{
+				Name:     name,
+				Value:    parseNumber(text),
+				Position: position,
+			})
+			found = true
+		})
+		if found {
+			position++
+		}
+	})
+
+	result := statements[:0]
+	for _, statement := range statements {
+		if statement.Period != "" && len(statement.LineItems) > 0 {
+			result = append(result, statement)
+		}
+	}
+	return result
+}
+
+// parsePeriodEnd returns the last day of a period labelled like "Mar 2024".
+// It returns nil for labels that are not a month, such as "TTM".
+func parsePeriodEnd(period string) *time.Time {
+	fields := strings.Fields(period)
+	if len(fields) < 2 {
+		return nil
+	}
+
+	month, err := time.Parse("Jan 2006", fields[0]+" "+fields[1])
+	if err != nil {
+		return nil
+	}
+
+	end := month.AddDate(0, 1, -1)
+	return &end
+}
+
+// cleanLabel strips the expand button ("+") and non-breaking spaces that
+// screener.in appends to line item names.
+func cleanLabel(s string) string {
+	s = strings.ReplaceAll(s, "\u00a0", " ")
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(s, "+")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// hasDigit reports whether s contains a digit.
+func hasDigit(s string) bool {
+	return strings.IndexFunc(s, unicode.IsDigit) >= 0
+}
+
+// statementCAGR returns the compound annual growth rate, in percent, of the
+// first of names found in the latest dated statement of statementType over
+// the given number of years. It needs positive values at both ends.
+func statementCAGR(statements []storage.FinancialStatement, statementType string, years int, names ...string) (float64, bool) {
+	var latest *storage.FinancialStatement
+	for i := range statements {
+		s := &statements[i]
+		if s.StatementType != statementType || s.PeriodEnd == nil {
+			continue
+		}
+		if latest == nil || s.PeriodEnd.After(*latest.PeriodEnd) {
+			latest = s
+		}
+	}
+	if latest == nil {
+		return 0, false
+	}
+
+	startEnd := latest.PeriodEnd.AddDate(-years, 0, 0)
+	for i := range statements {
+		start := &statements[i]
+		if start.StatementType != statementType || start.PeriodEnd == nil {
+			continue
+		}
+		if start.PeriodEnd.Year() != startEnd.Year() || start.PeriodEnd.Month() != startEnd.Month() {
+			continue
+		}
+
+		for _, name := range names {
+			from, ok := start.Item(name)
+			if !ok {
+				continue
+			}
+			to, ok := latest.Item(name)
+			if !ok || from <= 0 || to <= 0 {
+				return 0, false
+			}
+			return (math.Pow(to/from, 1/float64(years)) - 1) * 100, true
+		}
+		return 0, false
+	}
+	return 0, false
+}
+
+// intrinsicValue estimates intrinsic value per share with Graham's growth
+// formula, EPS x (8.5 + 2g). Growth is capped at 15% so a few strong years
+// do not dominate the estimate.
+func intrinsicValue(eps, growth float64) float64 {
+	if eps <= 0 {
+		return 0
+	}
+	growth = math.Max(0, math.Min(growth, 15))
+	return eps * (8.5 + 2*growth)
+}
@@ -0,0 +1,448 @@
+package screener
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export formats recognised by DetectFormat.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+	FormatXLS  = "xls"
+)
+
+// ErrSheetNotFound is returned when a chosen worksheet is not in a workbook.
+var ErrSheetNotFound = errors.New("sheet not found")
+
+// DetectFormat identifies an export from its content rather than its file
+// name: XLSX workbooks are zip archives, legacy XLS workbooks are OLE
+// compound files, and anything else is treated as CSV.
+func DetectFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return FormatXLSX
+	case bytes.HasPrefix(data, []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")):
+		return FormatXLS
+	default:
+		return FormatCSV
+	}
+}
+
+// xlsxWorkbook reads worksheets from an XLSX file. Only what is needed to
+// read cell values is parsed: the sheet list, shared strings and the number
+// formats that mark dates and percentages.
+type xlsxWorkbook struct {
+	files    map[string]*zip.File
+	sheets   []xlsxSheet
+	strings  []string
+	styles   []cellStyle // by cell style index
+	date1904 bool
+}
+
+// xlsxSheet is a worksheet and the zip entry holding it.
+type xlsxSheet struct {
+	name string
+	path string
+}
+
+// cellStyle is how a numeric cell is displayed.
+type cellStyle int
+
+const (
+	styleNumber cellStyle = iota
+	styleDate
+	stylePercent
+)
+
+// openXLSX opens a workbook and reads its sheet list, shared strings and
+// styles.
+func openXLSX(r io.ReaderAt, size int64) (*xlsxWorkbook, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not an XLSX workbook: %w", err)
+	}
+
+	wb := &xlsxWorkbook{files: make(map[string]*zip.File)}
+	for _, f := range zr.File {
+		wb.files[strings.TrimPrefix(f.Name, "/")] = f
+	}
+	if _, ok := wb.files["xl/workbook.xml"]; !ok {
+		return nil, errors.New("not an XLSX workbook: xl/workbook.xml is missing")
+	}
+
+	if err := wb.readSheets(); err != nil {
+		return nil, err
+	}
+	if err := wb.readSharedStrings(); err != nil {
+		return nil, err
+	}
+	if err := wb.readStyles(); err != nil {
+		return nil, err
+	}
+	return wb, nil
+}
+
+// decode unmarshals a zip entry. Missing optional entries are not an error.
+func (wb *xlsxWorkbook) decode(name string, v interface{}, required bool) error {
+	f, ok := wb.files[name]
+	if !ok {
+		if required {
+			return fmt.Errorf("invalid XLSX workbook: %s is missing", name)
+		}
+		return nil
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer rc.Close()
+
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+// readSheets reads the workbook's sheets in tab order and resolves their
+// relationship IDs to zip entries.
+func (wb *xlsxWorkbook) readSheets() error {
+	var workbook struct {
+		WorkbookPr struct {
+			Date1904 string `xml:"date1904,attr"`
+		} `xml:"workbookPr"`
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := wb.decode("xl/workbook.xml", &workbook, true); err != nil {
+		return err
+	}
+	wb.date1904 = workbook.WorkbookPr.Date1904 == "1" || workbook.WorkbookPr.Date1904 == "true"
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := wb.decode("xl/_rels/workbook.xml.rels", &rels, true); err != nil {
+		return err
+	}
+	targets := make(map[string]string, len(rels.Relationships))
+	for _, rel := range rels.Relationships {
+		target := rel.Target
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("xl", target)
+		}
+		targets[rel.ID] = target
+	}
+
+	for _, sheet := range workbook.Sheets {
+		if target, ok := targets[sheet.RID]; ok {
+			wb.sheets = append(wb.sheets, xlsxSheet{name: sheet.Name, path: target})
+		}
+	}
+	if len(wb.sheets) == 0 {
+		return errors.New("XLSX workbook has no worksheets")
+	}
+	return nil
+}
+
+// readSharedStrings reads the shared string table, joining the runs of
+// rich text strings.
+func (wb *xlsxWorkbook) readSharedStrings() error {
+	var sst struct {
+		Items []struct {
+			Text string `xml:"t"`
+			Runs []struct {
+				Text string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := wb.decode("xl/sharedStrings.xml", &sst, false); err != nil {
+		return err
+	}
+
+	wb.strings = make([]string, len(sst.Items))
+	for i, item := range sst.Items {
+		text := item.Text
+		for _, run := range item.Runs {
+			text += run.Text
+		}
+		wb.strings[i] = text
+	}
+	return nil
+}
+
+// readStyles works out which cell styles display numbers as dates or
+// percentages.
+func (wb *xlsxWorkbook) readStyles() error {
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := wb.decode("xl/styles.xml", &styles, false); err != nil {
+		return err
+	}
+
+	custom := make(map[int]string, len(styles.NumFmts))
+	for _, f := range styles.NumFmts {
+		custom[f.ID] = f.Code
+	}
+
+	wb.styles = make([]cellStyle, len(styles.CellXfs))
+	for i, xf := range styles.CellXfs {
+		wb.styles[i] = numFmtStyle(xf.NumFmtID, custom[xf.NumFmtID])
+	}
+	return nil
+}
+
+// numFmtStyle classifies a number format by its built-in ID or custom
+// format code.
+func numFmtStyle(id int, code string) cellStyle {
+	switch {
+	case id == 9 || id == 10:
+		return stylePercent
+	case (id >= 14 && id <= 22) || (id >= 45 && id <= 47):
+		return styleDate
+	case code == "":
+		return styleNumber
+	}
+
+	// Drop quoted literals, escaped characters and [colour]/[$-locale]
+	// sections before looking for date or percent tokens.
+	var b strings.Builder
+	inQuote, inBracket, escaped := false, false, false
+	for _, r := range code {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case r == '[':
+			inBracket = true
+		case r == ']':
+			inBracket = false
+		case inBracket:
+		default:
+			b.WriteRune(r)
+		}
+	}
+	plain := strings.ToLower(b.String())
+
+	switch {
+	case strings.Contains(plain, "%"):
+		return stylePercent
+	case strings.ContainsAny(plain, "dy"), strings.Contains(plain, "mm") && !strings.Contains(plain, "0"):
+		return styleDate
+	}
+	return styleNumber
+}
+
+// sheet returns the worksheet chosen by name (case-insensitive) or 1-based
+// position, or the first sheet if choice is empty.
+func (wb *xlsxWorkbook) sheet(choice string) (xlsxSheet, error) {
+	choice = strings.TrimSpace(choice)
+	if choice == "" {
+		return wb.sheets[0], nil
+	}
+
+	for _, s := range wb.sheets {
+		if strings.EqualFold(s.name, choice) {
+			return s, nil
+		}
+	}
+	if n, err := strconv.Atoi(choice); err == nil && n >= 1 && n <= len(wb.sheets) {
+		return wb.sheets[n-1], nil
+	}
+
+	names := make([]string, len(wb.sheets))
+	for i, s := range wb.sheets {
+		names[i] = s.name
+	}
+	return xlsxSheet{}, fmt.Errorf("%w: %q (workbook has %s)", ErrSheetNotFound, choice, strings.Join(names, ", "))
+}
+
+// rows reads the cell values of a worksheet. Each row is returned with its
+// 1-based row number; missing cells are empty strings.
+func (wb *xlsxWorkbook) rows(sheet xlsxSheet) ([][]string, []int, error) {
+	f, ok := wb.files[sheet.path]
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid XLSX workbook: %s is missing", sheet.path)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open sheet %s: %w", sheet.name, err)
+	}
+	defer rc.Close()
+
+	type cell struct {
+		Ref    string `xml:"r,attr"`
+		Type   string `xml:"t,attr"`
+		Style  int    `xml:"s,attr"`
+		Value  string `xml:"v"`
+		Inline struct {
+			Text string `xml:"t"`
+			Runs []struct {
+				Text string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"is"`
+	}
+	type row struct {
+		Number int    `xml:"r,attr"`
+		Cells  []cell `xml:"c"`
+	}
+
+	var rows [][]string
+	var numbers []int
+	decoder := xml.NewDecoder(rc)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse sheet %s: %w", sheet.name, err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+
+		var r row
+		if err := decoder.DecodeElement(&r, &start); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse sheet %s: %w", sheet.name, err)
+		}
+		if r.Number == 0 {
+			r.Number = len(numbers) + 1
+			if len(numbers) > 0 {
+				r.Number = numbers[len(numbers)-1] + 1
+			}
+		}
+
+		var values []string
+		for i, c := range r.Cells {
+			col := i
+			if c.Ref != "" {
+				if n, ok := columnNumber(c.Ref); ok {
+					col = n
+				}
+			}
+			for len(values) <= col {
+				values = append(values, "")
+			}
+
+			switch c.Type {
+			case "s":
+				if idx, err := strconv.Atoi(c.Value); err == nil && idx >= 0 && idx < len(wb.strings) {
+					values[col] = wb.strings[idx]
+				}
+			case "inlineStr":
+				text := c.Inline.Text
+				for _, run := range c.Inline.Runs {
+					text += run.Text
+				}
+				values[col] = text
+			case "b":
+				values[col] = map[string]string{"1": "TRUE", "0": "FALSE"}[c.Value]
+			case "str", "e":
+				values[col] = c.Value
+			default:
+				values[col] = wb.formatNumber(c.Value, c.Style)
+			}
+		}
+
+		rows = append(rows, values)
+		numbers = append(numbers, r.Number)
+	}
+	return rows, numbers, nil
+}
+
+// formatNumber renders a numeric cell the way it would appear in a CSV
+// export: dates as 2006-01-02, percentages with a % sign and other numbers
+// without float noise.
+func (wb *xlsxWorkbook) formatNumber(value string, style int) string {
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return value
+	}
+
+	var s cellStyle
+	if style >= 0 && style < len(wb.styles) {
+		s = wb.styles[style]
+	}
+
+	switch s {
+	case styleDate:
+		t := excelTime(v, wb.date1904)
+		if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+			return t.Format("2006-01-02")
+		}
+		return t.Format("2006-01-02 15:04:05")
+	case stylePercent:
+		return strconv.FormatFloat(roundFloat(v*100), 'f', -1, 64) + "%"
+	default:
+		return strconv.FormatFloat(roundFloat(v), 'f', -1, 64)
+	}
+}
+
+// excelTime converts an Excel serial date to a time. Serial dates count
+// days from 1899-12-30, or from 1904-01-01 in 1904-based workbooks.
+func excelTime(serial float64, date1904 bool) time.Time {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if date1904 {
+		epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	days := int(serial)
+	seconds := int((serial-float64(days))*86400 + 0.5)
+	return epoch.AddDate(0, 0, days).Add(time.Duration(seconds) * time.Second)
+}
+
+// roundFloat drops the binary noise Excel leaves in stored numbers, such
+// as 0.30000000000000004.
+func roundFloat(v float64) float64 {
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 15, 64), 64)
+	if err != nil {
+		return v
+	}
+	return rounded
+}
+
+// columnNumber returns the 0-based column of a cell reference like "AB12".
+func columnNumber(ref string) (int, bool) {
+	n := 0
+	letters := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		n = n*26 + int(r-'A'+1)
+		letters++
+	}
+	if letters == 0 {
+		return 0, false
+	}
+	return n - 1, true
+}
@@ -0,0 +1,103 @@
+package slack
+
+import "github.com/user/stock-recommender/pkg/textutil"
+
+// Response types of a message sent in reply to a command.
+const (
+	ResponseInChannel = "in_channel" // seen by everyone in the channel
+	ResponseEphemeral = "ephemeral"  // seen only by the user who ran the command
+)
+
+// Message is a reply to a command or interaction, returned in the
+// response or POSTed to its response_url.
+type Message struct {
+	ResponseType    string  `json:"response_type,omitempty"`
+	ReplaceOriginal bool    `json:"replace_original,omitempty"`
+	Text            string  `json:"text"` // fallback for notifications and clients without blocks
+	Blocks          []Block `json:"blocks,omitempty"`
+}
+
+// Block is a Block Kit layout block. Only the fields of its type are set.
+type Block struct {
+	Type     string        `json:"type"`
+	Text     *Text         `json:"text,omitempty"`
+	Fields   []*Text       `json:"fields,omitempty"`
+	Elements []interface{} `json:"elements,omitempty"` // *Text in context blocks, *Button in actions blocks
+}
+
+// Text is a Block Kit text object.
+type Text struct {
+	Type  string `json:"type"` // plain_text or mrkdwn
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+// Button is a Block Kit button element. A button with a URL opens it; the
+// click is still sent to the interactivity endpoint.
+type Button struct {
+	Type     string `json:"type"`
+	Text     *Text  `json:"text"`
+	ActionID string `json:"action_id"`
+	URL      string `json:"url,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Style    string `json:"style,omitempty"` // primary or danger
+}
+
+// Limits Slack puts on block text, in characters.
+const (
+	maxHeaderText  = 150
+	maxSectionText = 3000
+	maxFieldText   = 2000
+)
+
+// plainText makes a plain_text object.
+func plainText(s string) *Text {
+	return &Text{Type: "plain_text", Text: s, Emoji: true}
+}
+
+// markdown makes a mrkdwn text object.
+func markdown(s string) *Text {
+	return &Text{Type: "mrkdwn", Text: s}
+}
+
+// headerBlock makes a header block.
+func headerBlock(s string) Block {
+	return Block{Type: "header", Text: plainText(textutil.Ellipsize(s, maxHeaderText))}
+}
+
+// sectionBlock makes a section block of mrkdwn text.
+func sectionBlock(s string) Block {
+	return Block{Type: "section", Text: markdown(textutil.Ellipsize(s, maxSectionText))}
+}
+
+// fieldsBlock makes a section block of mrkdwn fields, shown in two columns.
+func fieldsBlock(texts ...string) Block {
+	b := Block{Type: "section"}
+	for _, t := range texts {
+		b.Fields = append(b.Fields, markdown(textutil.Ellipsize(t, maxFieldText)))
+	}
+	return b
+}
+
+// contextBlock makes a context block of mrkdwn text.
+func contextBlock(texts ...string) Block {
+	b := Block{Type: "context"}
+	for _, t := range texts {
+		b.Elements = append(b.Elements, markdown(textutil.Ellipsize(t, maxFieldText)))
+	}
+	return b
+}
+
+// actionsBlock makes an actions block of buttons.
+func actionsBlock(buttons ...*Button) Block {
+	b := Block{Type: "actions"}
+	for _, button := range buttons {
+		b.Elements = append(b.Elements, button)
+	}
+	return b
+}
+
+// ephemeral makes a plain text reply seen only by the user.
+func ephemeral(text string) *Message {
+	return &Message{ResponseType: ResponseEphemeral, Text: text}
+}
@@ -0,0 +1,394 @@
+// Package slack implements the /stockchef slash command and its
+// interactive buttons: requests are verified with the app's signing
+// secret, analyses too slow to answer within Slack's three seconds are
+// posted to the command's response_url, and results are Block Kit
+// messages.
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/stock-recommender/internal/recommender"
+	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/pkg/config"
+)
+
+// Action IDs of the buttons on an analysis.
+const (
+	ActionOpenRecommendation = "open_recommendation"
+	ActionReanalyze          = "reanalyze"
+)
+
+// maxBodySize limits the size of a Slack request body.
+const maxBodySize = 1 << 20
+
+// ErrNotConfigured is returned by ReadRequest when no signing secret is
+// set.
+var ErrNotConfigured = errors.New("slack signing secret is not configured")
+
+// symbolPattern matches an NSE or BSE trading symbol.
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9&\-.]{0,19}$`)
+
+// SlashCommand is a slash command invocation, as sent by Slack in a form
+// body.
+type SlashCommand struct {
+	Command     string // e.g. /stockchef
+	Text        string // what follows the command, e.g. "analyze RELIANCE"
+	TeamID      string
+	ChannelID   string
+	UserID      string
+	UserName    string
+	ResponseURL string
+	TriggerID   string
+}
+
+// ParseSlashCommand reads a slash command from its form values.
+func ParseSlashCommand(form url.Values) SlashCommand {
+	return SlashCommand{
+		Command:     form.Get("command"),
+		Text:        strings.TrimSpace(form.Get("text")),
+		TeamID:      form.Get("team_id"),
+		ChannelID:   form.Get("channel_id"),
+		UserID:      form.Get("user_id"),
+		UserName:    form.Get("user_name"),
+		ResponseURL: form.Get("response_url"),
+		TriggerID:   form.Get("trigger_id"),
+	}
+}
+
+// Interaction is a click on an interactive element, as sent by Slack in
+// the payload form value.
+type Interaction struct {
+	Type string `json:"type"` // block_actions for button clicks
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	ResponseURL string `json:"response_url"`
+	Actions     []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// ParseInteraction reads an interaction from its form values.
+func ParseInteraction(form url.Values) (*Interaction, error) {
+	payload := form.Get("payload")
+	if payload == "" {
+		return nil, fmt.Errorf("missing payload")
+	}
+	var in Interaction
+	if err := json.Unmarshal([]byte(payload), &in); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	return &in, nil
+}
+
+// Analyzer analyzes stocks. *recommender.Engine is the Analyzer used in
+// the server; tests can stand in a faster one.
+type Analyzer interface {
+	AnalyzeStock(ctx context.Context, symbol string) (*recommender.AnalysisResult, error)
+}
+
+// Handler answers slash commands and interactions.
+type Handler struct {
+	analyzer  Analyzer
+	client    *http.Client
+	config    config.SlackConfig
+	publicURL string
+	now       func() time.Time // checked against request timestamps
+	wg        sync.WaitGroup
+}
+
+// NewHandler creates a Slack command handler.
+func NewHandler(analyzer Analyzer, cfg *config.Config) *Handler {
+	h := &Handler{
+		analyzer:  analyzer,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		config:    cfg.Slack,
+		publicURL: strings.TrimRight(cfg.Server.PublicURL, "/"),
+		now:       time.Now,
+	}
+	if h.config.AckTimeout <= 0 {
+		h.config.AckTimeout = 2 * time.Second
+	}
+	if h.config.AnalysisTimeout <= 0 {
+		h.config.AnalysisTimeout = 5 * time.Minute
+	}
+	return h
+}
+
+// ReadRequest reads a request from Slack, verifies its signature and
+// returns its form values.
+func (h *Handler) ReadRequest(r *http.Request) (url.Values, error) {
+	if h.config.SigningSecret == "" {
+		return nil, ErrNotConfigured
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request: %w", err)
+	}
+	if len(body) > maxBodySize {
+		return nil, fmt.Errorf("request body too large")
+	}
+
+	err = Verify(h.config.SigningSecret, r.Header.Get(TimestampHeader), r.Header.Get(SignatureHeader), body, h.now())
+	if err != nil {
+		return nil, err
+	}
+	return url.ParseQuery(string(body))
+}
+
+// Command answers a slash command with the message to respond with.
+func (h *Handler) Command(cmd SlashCommand) *Message {
+	fields := strings.Fields(cmd.Text)
+	if len(fields) == 0 {
+		return h.help(cmd.Command)
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case "analyze":
+		if len(fields) != 2 {
+			return ephemeral(fmt.Sprintf("Usage: %s analyze SYMBOL, e.g. %s analyze RELIANCE", cmd.Command, cmd.Command))
+		}
+		symbol, ok := parseSymbol(fields[1])
+		if !ok {
+			return ephemeral(fmt.Sprintf("%q is not a valid symbol.", fields[1]))
+		}
+		return h.analyze(symbol, cmd.UserID, cmd.ResponseURL, h.config.AckTimeout)
+	case "help":
+		return h.help(cmd.Command)
+	default:
+		return ephemeral(fmt.Sprintf("Unknown command %q. Try %s help.", fields[0], cmd.Command))
+	}
+}
+
+// Interaction handles a button click. Slack ignores the response to a
+// click, so any reply is posted to the interaction's response_url.
+func (h *Handler) Interaction(in *Interaction) {
+	if in.Type != "block_actions" {
+		return
+	}
+	for _, action := range in.Actions {
+		// Opening the recommendation page needs no reply.
+		if action.ActionID != ActionReanalyze {
+			continue
+		}
+		if symbol, ok := parseSymbol(action.Value); ok {
+			h.analyze(symbol, in.User.ID, in.ResponseURL, 0)
+		}
+	}
+}
+
+// analyze analyzes a stock in the background. If the analysis finishes
+// within wait, its message is returned; otherwise it is posted to
+// responseURL when it finishes and an acknowledgement is returned, or nil
+// if wait is zero.
+func (h *Handler) analyze(symbol, userID, responseURL string, wait time.Duration) *Message {
+	result := make(chan *Message, 1)
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), h.config.AnalysisTimeout)
+		defer cancel()
+		result <- h.analysisMessage(ctx, symbol, userID)
+	}()
+
+	if wait > 0 {
+		select {
+		case msg := <-result:
+			return msg
+		case <-time.After(wait):
+		}
+	}
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		msg := <-result
+		ctx, cancel := context.WithTimeout(context.Background(), h.client.Timeout)
+		defer cancel()
+		if err := h.Respond(ctx, responseURL, msg); err != nil {
+			fmt.Printf("Warning: failed to post Slack analysis of %s: %v\n", symbol, err)
+		}
+	}()
+
+	if wait == 0 {
+		return nil
+	}
+	return ephemeral(fmt.Sprintf("Analyzing %s, the result will be posted here shortly...", symbol))
+}
+
+// analysisMessage analyzes a stock and returns the message to post: the
+// analysis for the channel, or an error only the user sees.
+func (h *Handler) analysisMessage(ctx context.Context, symbol, userID string) *Message {
+	result, err := h.analyzer.AnalyzeStock(ctx, symbol)
+	if err != nil {
+		return ephemeral(fmt.Sprintf("Analysis of %s failed: %v", symbol, err))
+	}
+	return h.AnalysisMessage(result, userID)
+}
+
+// AnalysisMessage formats an analysis as a Block Kit message: the action,
+// target, stop-loss and confidence, the reasoning, and buttons to open the
+// recommendation page and to analyze again.
+func (h *Handler) AnalysisMessage(result *recommender.AnalysisResult, userID string) *Message {
+	rec := result.Recommendation
+	stock := result.Stock
+
+	title := stock.Symbol
+	if stock.Name != "" && stock.Name != stock.Symbol {
+		title += " · " + stock.Name
+	}
+
+	blocks := []Block{
+		headerBlock(title),
+		fieldsBlock(
+			fmt.Sprintf("*Action*\n%s %s", actionEmoji(rec.Action), rec.Action),
+			fmt.Sprintf("*Confidence*\n%.0f%%", rec.ConfidenceScore),
+			fmt.Sprintf("*Target*\n₹%.2f%s", rec.TargetPrice, percentFrom(rec.EntryPrice, rec.TargetPrice)),
+			fmt.Sprintf("*Stop-loss*\n₹%.2f%s", rec.StopLoss, percentFrom(rec.EntryPrice, rec.StopLoss)),
+			fmt.Sprintf("*Entry*\n₹%.2f", rec.EntryPrice),
+			fmt.Sprintf("*Horizon · Risk*\n%s · %s", humanize(rec.TimeHorizon), humanize(rec.RiskLevel)),
+		),
+	}
+	if reasoning := strings.TrimSpace(rec.Reasoning); reasoning != "" {
+		blocks = append(blocks, sectionBlock(reasoning))
+	}
+
+	var notes []string
+	if userID != "" {
+		notes = append(notes, fmt.Sprintf("Requested by <@%s>", userID))
+	}
+	if result.DataStale {
+		notes = append(notes, fmt.Sprintf(":warning: Fundamentals are %.0f days old", result.DataAge.Hours()/24))
+	}
+	if len(notes) > 0 {
+		blocks = append(blocks, contextBlock(notes...))
+	}
+
+	var buttons []*Button
+	if rec.ID != 0 {
+		buttons = append(buttons, &Button{
+			Type:     "button",
+			Text:     plainText("View recommendation"),
+			ActionID: ActionOpenRecommendation,
+			URL:      fmt.Sprintf("%s/recommendation/%d", h.publicURL, rec.ID),
+			Style:    "primary",
+		})
+	}
+	buttons = append(buttons, &Button{
+		Type:     "button",
+		Text:     plainText("Analyze again"),
+		ActionID: ActionReanalyze,
+		Value:    stock.Symbol,
+	})
+	blocks = append(blocks, actionsBlock(buttons...))
+
+	return &Message{
+		ResponseType: ResponseInChannel,
+		Text: fmt.Sprintf("%s: %s, target ₹%.2f, stop-loss ₹%.2f, confidence %.0f%%",
+			stock.Symbol, rec.Action, rec.TargetPrice, rec.StopLoss, rec.ConfidenceScore),
+		Blocks: blocks,
+	}
+}
+
+// help lists the subcommands.
+func (h *Handler) help(command string) *Message {
+	if command == "" {
+		command = "/stockchef"
+	}
+	return ephemeral(fmt.Sprintf("%s analyze SYMBOL - analyze a stock and post the recommendation to the channel\n%s help - show this message",
+		command, command))
+}
+
+// Respond POSTs a message to a response_url.
+func (h *Handler) Respond(ctx context.Context, responseURL string, msg *Message) error {
+	if responseURL == "" {
+		return fmt.Errorf("no response_url")
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("response_url returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// Wait waits for background analyses and their replies to finish or for
+// ctx to expire.
+func (h *Handler) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parseSymbol normalizes and validates a trading symbol.
+func parseSymbol(s string) (string, bool) {
+	symbol := strings.ToUpper(strings.TrimSpace(s))
+	return symbol, symbolPattern.MatchString(symbol)
+}
+
+// actionEmoji marks an action in messages.
+func actionEmoji(action storage.Action) string {
+	switch action {
+	case storage.ActionBuy:
+		return ":large_green_circle:"
+	case storage.ActionSell:
+		return ":red_circle:"
+	default:
+		return ":large_yellow_circle:"
+	}
+}
+
+// percentFrom formats the change from base to price, e.g. " (+12.5%)".
+func percentFrom(base, price float64) string {
+	if base == 0 || price == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%+.1f%%)", (price-base)/base*100)
+}
+
+// humanize turns "medium_term" into "medium term", and empty into "n/a".
+func humanize(s string) string {
+	if s == "" {
+		return "n/a"
+	}
+	return strings.ReplaceAll(s, "_", " ")
+}
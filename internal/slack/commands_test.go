@@ -0,0 +1,250 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/stock-recommender/internal/recommender"
+	"github.com/user/stock-recommender/internal/storage"
+)
+
+// fakeAnalyzer returns a BUY recommendation for any symbol. If release is
+// set, analyses wait for it to be closed.
+type fakeAnalyzer struct {
+	release chan struct{}
+	symbols chan string
+}
+
+func newFakeAnalyzer(slow bool) *fakeAnalyzer {
+	a := &fakeAnalyzer{symbols: make(chan string, 10)}
+	if slow {
+		a.release = make(chan struct{})
+	}
+	return a
+}
+
+func (a *fakeAnalyzer) AnalyzeStock(ctx context.Context, symbol string) (*recommender.AnalysisResult, error) {
+	a.symbols <- symbol
+	if a.release != nil {
+		select {
+		case <-a.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return &recommender.AnalysisResult{
+		Stock: &storage.Stock{Symbol: symbol, Name: "Reliance Industries Ltd"},
+		Recommendation: &storage.Recommendation{
+			ID:              42,
+			Action:          storage.ActionBuy,
+			EntryPrice:      2800,
+			TargetPrice:     3220,
+			StopLoss:        2660,
+			ConfidenceScore: 72,
+			Reasoning:       "Strong retail and Jio growth.",
+			TimeHorizon:     "medium_term",
+			RiskLevel:       "medium",
+		},
+	}, nil
+}
+
+// responseServer records the messages POSTed to it as a response_url.
+func responseServer(t *testing.T) (*httptest.Server, chan *Message) {
+	t.Helper()
+	messages := make(chan *Message, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		var msg Message
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("invalid message: %v", err)
+		}
+		messages <- &msg
+	}))
+	t.Cleanup(server.Close)
+	return server, messages
+}
+
+// wait waits for the handler's background work.
+func wait(t *testing.T, h *Handler) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.Wait(ctx); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+}
+
+// checkAnalysis checks a message is the fake analysis of RELIANCE
+// requested by the user of the testdata requests, as Slack receives it.
+func checkAnalysis(t *testing.T, msg *Message) {
+	t.Helper()
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg = &Message{}
+	if err := json.Unmarshal(data, msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.ResponseType != ResponseInChannel {
+		t.Errorf("ResponseType = %q, want %q", msg.ResponseType, ResponseInChannel)
+	}
+	if want := "RELIANCE: BUY, target ₹3220.00, stop-loss ₹2660.00, confidence 72%"; msg.Text != want {
+		t.Errorf("Text = %q, want %q", msg.Text, want)
+	}
+
+	var types []string
+	for _, b := range msg.Blocks {
+		types = append(types, b.Type)
+	}
+	if got := strings.Join(types, ","); got != "header,section,section,context,actions" {
+		t.Fatalf("blocks = %s", got)
+	}
+	if header := msg.Blocks[0].Text.Text; header != "RELIANCE · Reliance Industries Ltd" {
+		t.Errorf("header = %q", header)
+	}
+	if target := msg.Blocks[1].Fields[2].Text; target != "*Target*\n₹3220.00 (+15.0%)" {
+		t.Errorf("target field = %q", target)
+	}
+	// Decoded from JSON, the elements are maps.
+	if note := msg.Blocks[3].Elements[0].(map[string]interface{})["text"]; note != "Requested by <@U05RB8ZK1JN>" {
+		t.Errorf("context = %q", note)
+	}
+
+	buttons := msg.Blocks[4].Elements
+	if len(buttons) != 2 {
+		t.Fatalf("got %d buttons, want 2", len(buttons))
+	}
+	view, again := buttons[0].(map[string]interface{}), buttons[1].(map[string]interface{})
+	if view["action_id"] != ActionOpenRecommendation || view["url"] != "https://stockchef.example.com/recommendation/42" {
+		t.Errorf("view button = %v", view)
+	}
+	if again["action_id"] != ActionReanalyze || again["value"] != "RELIANCE" {
+		t.Errorf("analyze again button = %v", again)
+	}
+}
+
+// slashCommand reads the analyze command in testdata, with its response_url
+// pointed at responseURL.
+func slashCommand(t *testing.T, h *Handler, responseURL string) SlashCommand {
+	t.Helper()
+	form, err := h.ReadRequest(readRequest(t, "slash_command_analyze.http"))
+	if err != nil {
+		t.Fatalf("ReadRequest: %v", err)
+	}
+	cmd := ParseSlashCommand(form)
+	cmd.ResponseURL = responseURL
+	return cmd
+}
+
+func TestCommandSlowAnalysis(t *testing.T) {
+	server, messages := responseServer(t)
+	analyzer := newFakeAnalyzer(true)
+	h := newTestHandler(analyzer, signingSecret, time.Unix(1792339200, 0))
+	h.config.AckTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	msg := h.Command(slashCommand(t, h, server.URL))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("acknowledged after %v", elapsed)
+	}
+	if msg.ResponseType != ResponseEphemeral || msg.Text != "Analyzing RELIANCE, the result will be posted here shortly..." {
+		t.Errorf("acknowledgement = %+v", msg)
+	}
+	if symbol := <-analyzer.symbols; symbol != "RELIANCE" {
+		t.Errorf("analyzed %q", symbol)
+	}
+	select {
+	case msg := <-messages:
+		t.Fatalf("posted %q before the analysis finished", msg.Text)
+	default:
+	}
+
+	close(analyzer.release)
+	wait(t, h)
+	select {
+	case msg := <-messages:
+		checkAnalysis(t, msg)
+	default:
+		t.Fatal("analysis not posted to response_url")
+	}
+}
+
+func TestCommandFastAnalysis(t *testing.T) {
+	server, messages := responseServer(t)
+	h := newTestHandler(newFakeAnalyzer(false), signingSecret, time.Unix(1792339200, 0))
+
+	checkAnalysis(t, h.Command(slashCommand(t, h, server.URL)))
+	wait(t, h)
+	if len(messages) != 0 {
+		t.Errorf("posted %d messages for an analysis returned in the response", len(messages))
+	}
+}
+
+func TestCommandText(t *testing.T) {
+	h := newTestHandler(newFakeAnalyzer(false), signingSecret, time.Now())
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", "/stockchef analyze SYMBOL - analyze a stock"},
+		{"help", "/stockchef analyze SYMBOL - analyze a stock"},
+		{"analyze", "Usage: /stockchef analyze SYMBOL"},
+		{"analyze RELIANCE TCS", "Usage: /stockchef analyze SYMBOL"},
+		{"analyze $$$", `"$$$" is not a valid symbol.`},
+		{"buy RELIANCE", `Unknown command "buy". Try /stockchef help.`},
+	}
+	for _, tt := range tests {
+		msg := h.Command(SlashCommand{Command: "/stockchef", Text: tt.text})
+		if msg.ResponseType != ResponseEphemeral || !strings.HasPrefix(msg.Text, tt.want) {
+			t.Errorf("Command(%q) = %+v, want an ephemeral %q", tt.text, msg, tt.want)
+		}
+	}
+}
+
+func TestInteractionReanalyze(t *testing.T) {
+	server, messages := responseServer(t)
+	analyzer := newFakeAnalyzer(false)
+	h := newTestHandler(analyzer, signingSecret, time.Unix(1792339260, 0))
+
+	form, err := h.ReadRequest(readRequest(t, "block_actions_reanalyze.http"))
+	if err != nil {
+		t.Fatalf("ReadRequest: %v", err)
+	}
+	in, err := ParseInteraction(form)
+	if err != nil {
+		t.Fatalf("ParseInteraction: %v", err)
+	}
+	if in.Type != "block_actions" || in.User.ID != "U05RB8ZK1JN" || len(in.Actions) != 1 || in.Actions[0].ActionID != ActionReanalyze {
+		t.Fatalf("interaction = %+v", in)
+	}
+	in.ResponseURL = server.URL
+
+	h.Interaction(in)
+	wait(t, h)
+	if symbol := <-analyzer.symbols; symbol != "RELIANCE" {
+		t.Errorf("analyzed %q", symbol)
+	}
+	select {
+	case msg := <-messages:
+		checkAnalysis(t, msg)
+	default:
+		t.Fatal("analysis not posted to response_url")
+	}
+
+	// Opening the recommendation page is not answered.
+	in.Actions[0].ActionID = ActionOpenRecommendation
+	h.Interaction(in)
+	wait(t, h)
+	if len(analyzer.symbols) != 0 || len(messages) != 0 {
+		t.Errorf("view button started %d analyses and posted %d messages", len(analyzer.symbols), len(messages))
+	}
+}
@@ -0,0 +1,54 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Headers Slack signs its requests with.
+const (
+	TimestampHeader = "X-Slack-Request-Timestamp"
+	SignatureHeader = "X-Slack-Signature"
+)
+
+// MaxRequestAge is how old a signed request may be before it is rejected
+// as a possible replay, as Slack recommends.
+const MaxRequestAge = 5 * time.Minute
+
+// ErrInvalidSignature is returned by Verify for a request that was not
+// signed with the signing secret or is too old.
+var ErrInvalidSignature = errors.New("invalid Slack request signature")
+
+// Sign returns the v0 signature of a request body sent at timestamp:
+// "v0=" and the hex HMAC-SHA256 of "v0:<timestamp>:<body>".
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the timestamp and signature headers of a request against
+// its raw body. A timestamp more than MaxRequestAge from now is rejected.
+func Verify(secret, timestamp, signature string, body []byte, now time.Time) error {
+	if timestamp == "" || signature == "" {
+		return fmt.Errorf("%w: missing %s or %s", ErrInvalidSignature, TimestampHeader, SignatureHeader)
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: malformed timestamp", ErrInvalidSignature)
+	}
+	age := now.Sub(time.Unix(unix, 0))
+	if age > MaxRequestAge || age < -MaxRequestAge {
+		return fmt.Errorf("%w: timestamp too old", ErrInvalidSignature)
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidSignature)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package slack
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/user/stock-recommender/pkg/config"
+)
+
+// signingSecret signed the requests in testdata. It is the secret of the
+// example in Slack's documentation, which slash_command_example.http is.
+const signingSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+// readRequest reads a raw HTTP request saved in testdata.
+func readRequest(t *testing.T, name string) *http.Request {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	r, err := http.ReadRequest(bufio.NewReader(f))
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", name, err)
+	}
+	return r
+}
+
+// newTestHandler returns a handler that checks request timestamps against
+// now.
+func newTestHandler(analyzer Analyzer, secret string, now time.Time) *Handler {
+	h := NewHandler(analyzer, &config.Config{
+		Server: config.ServerConfig{PublicURL: "https://stockchef.example.com/"},
+		Slack:  config.SlackConfig{SigningSecret: secret},
+	})
+	h.now = func() time.Time { return now }
+	return h
+}
+
+func TestReadRequest(t *testing.T) {
+	// The timestamp of the stockchef requests in testdata.
+	sent := time.Unix(1792339200, 0)
+
+	tests := []struct {
+		name     string
+		file     string
+		secret   string
+		now      time.Time
+		edit     func(*http.Request)
+		wantErr  error
+		wantText string
+	}{
+		{
+			name:     "slack documentation example",
+			file:     "slash_command_example.http",
+			secret:   signingSecret,
+			now:      time.Unix(1531420618, 0),
+			wantText: "",
+		},
+		{
+			name:     "analyze",
+			file:     "slash_command_analyze.http",
+			secret:   signingSecret,
+			now:      sent.Add(2 * time.Second),
+			wantText: "analyze RELIANCE",
+		},
+		{
+			name:   "interaction",
+			file:   "block_actions_reanalyze.http",
+			secret: signingSecret,
+			now:    sent.Add(time.Minute),
+		},
+		{
+			name:     "within the allowed age",
+			file:     "slash_command_help.http",
+			secret:   signingSecret,
+			now:      sent.Add(MaxRequestAge),
+			wantText: "",
+		},
+		{
+			// The signature of the analyze request on a body asking for
+			// another stock.
+			name:    "tampered body",
+			file:    "slash_command_tampered.http",
+			secret:  signingSecret,
+			now:     sent.Add(2 * time.Second),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "wrong secret",
+			file:    "slash_command_analyze.http",
+			secret:  "not-the-signing-secret",
+			now:     sent.Add(2 * time.Second),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "stale timestamp",
+			file:    "slash_command_analyze.http",
+			secret:  signingSecret,
+			now:     sent.Add(MaxRequestAge + time.Second),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "timestamp in the future",
+			file:    "slash_command_analyze.http",
+			secret:  signingSecret,
+			now:     sent.Add(-MaxRequestAge - time.Second),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "missing signature",
+			file:    "slash_command_analyze.http",
+			secret:  signingSecret,
+			now:     sent.Add(2 * time.Second),
+			edit:    func(r *http.Request) { r.Header.Del(SignatureHeader) },
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "missing timestamp",
+			file:    "slash_command_analyze.http",
+			secret:  signingSecret,
+			now:     sent.Add(2 * time.Second),
+			edit:    func(r *http.Request) { r.Header.Del(TimestampHeader) },
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "not configured",
+			file:    "slash_command_analyze.http",
+			now:     sent.Add(2 * time.Second),
+			wantErr: ErrNotConfigured,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := readRequest(t, tt.file)
+			if tt.edit != nil {
+				tt.edit(r)
+			}
+
+			form, err := newTestHandler(nil, tt.secret, tt.now).ReadRequest(r)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadRequest: %v", err)
+			}
+			if got := form.Get("text"); got != tt.wantText {
+				t.Errorf("text = %q, want %q", got, tt.wantText)
+			}
+		})
+	}
+}
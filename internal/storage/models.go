@@ -2,6 +2,7 @@
 package storage
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -25,17 +26,28 @@ const (
 	SentimentNeutral SentimentScore = "NEUTRAL"
 )
 
+// Financial statement bases. Consolidated figures include subsidiaries;
+// standalone figures cover the listed company alone.
+const (
+	BasisConsolidated = "consolidated"
+	BasisStandalone   = "standalone"
+)
+
 // Stock represents a stock entity with fundamental data.
 type Stock struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Symbol    string         `gorm:"uniqueIndex;size:20;not null" json:"symbol"`
-	Name      string         `gorm:"size:255;not null" json:"name"`
-	Exchange  string         `gorm:"size:10;default:NSE" json:"exchange"`
-	Sector    string         `gorm:"size:100" json:"sector"`
-	Industry  string         `gorm:"size:100" json:"industry"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	Symbol         string         `gorm:"uniqueIndex;size:20;not null" json:"symbol"`
+	Name           string         `gorm:"size:255;not null" json:"name"`
+	Exchange       string         `gorm:"size:10;default:NSE" json:"exchange"`
+	Sector         string         `gorm:"size:100" json:"sector"`
+	Industry       string         `gorm:"size:100" json:"industry"`
+	ISIN           string         `gorm:"size:12;index" json:"isin,omitempty"`
+	BSECode        string         `gorm:"size:10" json:"bse_code,omitempty"`
+	FinancialBasis string         `gorm:"size:20" json:"financial_basis,omitempty"`  // consolidated or standalone; empty uses the configured default
+	FMV2018        float64        `gorm:"column:fmv_2018" json:"fmv_2018,omitempty"` // highest price on 31 Jan 2018, for grandfathering long-term gains
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// Relationships
 	Fundamentals    []StockFundamental  `gorm:"foreignKey:StockID" json:"fundamentals,omitempty"`
@@ -45,33 +57,164 @@ type Stock struct {
 
 // StockFundamental holds fundamental data from screener.in
 type StockFundamental struct {
-	ID                   uint           `gorm:"primaryKey" json:"id"`
-	StockID              uint           `gorm:"index;not null" json:"stock_id"`
-	MarketCap            float64        `json:"market_cap"`
-	CurrentPrice         float64        `json:"current_price"`
-	High52Week           float64        `json:"high_52_week"`
-	Low52Week            float64        `json:"low_52_week"`
-	StockPE              float64        `json:"stock_pe"`
-	BookValue            float64        `json:"book_value"`
-	DividendYield        float64        `json:"dividend_yield"`
-	ROCE                 float64        `json:"roce"`
-	ROE                  float64        `json:"roe"`
-	FaceValue            float64        `json:"face_value"`
-	EPS                  float64        `json:"eps"`
-	DebtToEquity         float64        `json:"debt_to_equity"`
-	PromoterHolding      float64        `json:"promoter_holding"`
-	PledgedPercentage    float64        `json:"pledged_percentage"`
-	RevenueGrowth3Y      float64        `json:"revenue_growth_3y"`
-	ProfitGrowth3Y       float64        `json:"profit_growth_3y"`
-	PriceToBook          float64        `json:"price_to_book"`
-	IntrinsicValue       float64        `json:"intrinsic_value"`
-	GrahamNumber         float64        `json:"graham_number"`
-	PEGRatio             float64        `json:"peg_ratio"`
-	Source               string         `gorm:"size:50" json:"source"` // screener_scrape, csv_upload
-	FetchedAt            time.Time      `json:"fetched_at"`
-	CreatedAt            time.Time      `json:"created_at"`
-	UpdatedAt            time.Time      `json:"updated_at"`
-	DeletedAt            gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                uint           `gorm:"primaryKey" json:"id"`
+	StockID           uint           `gorm:"index;not null" json:"stock_id"`
+	MarketCap         float64        `json:"market_cap"`
+	CurrentPrice      float64        `json:"current_price"`
+	High52Week        float64        `json:"high_52_week"`
+	Low52Week         float64        `json:"low_52_week"`
+	StockPE           float64        `json:"stock_pe"`
+	BookValue         float64        `json:"book_value"`
+	DividendYield     float64        `json:"dividend_yield"`
+	ROCE              float64        `json:"roce"`
+	ROE               float64        `json:"roe"`
+	FaceValue         float64        `json:"face_value"`
+	EPS               float64        `json:"eps"`
+	DebtToEquity      float64        `json:"debt_to_equity"`
+	PromoterHolding   float64        `json:"promoter_holding"`
+	PledgedPercentage float64        `json:"pledged_percentage"`
+	RevenueGrowth10Y  float64        `json:"revenue_growth_10y"` // compounded sales growth, %
+	RevenueGrowth5Y   float64        `json:"revenue_growth_5y"`
+	RevenueGrowth3Y   float64        `json:"revenue_growth_3y"`
+	RevenueGrowthTTM  float64        `json:"revenue_growth_ttm"`
+	ProfitGrowth10Y   float64        `json:"profit_growth_10y"` // compounded profit growth, %
+	ProfitGrowth5Y    float64        `json:"profit_growth_5y"`
+	ProfitGrowth3Y    float64        `json:"profit_growth_3y"`
+	ProfitGrowthTTM   float64        `json:"profit_growth_ttm"`
+	PriceCAGR10Y      float64        `json:"price_cagr_10y"` // stock price CAGR, %
+	PriceCAGR5Y       float64        `json:"price_cagr_5y"`
+	PriceCAGR3Y       float64        `json:"price_cagr_3y"`
+	PriceCAGR1Y       float64        `json:"price_cagr_1y"`
+	ROE10Y            float64        `json:"roe_10y"` // average return on equity, %
+	ROE5Y             float64        `json:"roe_5y"`
+	ROE3Y             float64        `json:"roe_3y"`
+	ROELastYear       float64        `json:"roe_last_year"`
+	PriceToBook       float64        `json:"price_to_book"`
+	IntrinsicValue    float64        `json:"intrinsic_value"`
+	GrahamNumber      float64        `json:"graham_number"`
+	PEGRatio          float64        `json:"peg_ratio"`
+	Basis             string         `gorm:"size:20" json:"basis,omitempty"`   // consolidated or standalone; empty if unknown
+	Source            string         `gorm:"size:50" json:"source"`            // screener_scrape, csv_upload
+	UploadID          *uint          `gorm:"index" json:"upload_id,omitempty"` // screener upload that created this record
+	FetchedAt         time.Time      `json:"fetched_at"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Relationships
+	Attributes []FundamentalAttribute `gorm:"foreignKey:FundamentalID;constraint:OnDelete:CASCADE" json:"attributes,omitempty"`
+}
+
+// Attribute returns the value of the attribute with the given key or label,
+// ignoring case.
+func (f *StockFundamental) Attribute(key string) (float64, bool) {
+	for _, attr := range f.Attributes {
+		if strings.EqualFold(attr.Key, key) || strings.EqualFold(attr.Label, key) {
+			return attr.Value, true
+		}
+	}
+	return 0, false
+}
+
+// FundamentalAttribute is a figure from a column of an uploaded screener
+// export that has no field of its own, such as a custom screen's ratio.
+type FundamentalAttribute struct {
+	ID            uint    `gorm:"primaryKey" json:"id"`
+	FundamentalID uint    `gorm:"uniqueIndex:idx_fundamental_attribute;not null" json:"fundamental_id"`
+	Key           string  `gorm:"size:100;uniqueIndex:idx_fundamental_attribute;index:idx_attribute_key_value,priority:1;not null" json:"key"` // normalized column name
+	Label         string  `gorm:"size:255" json:"label"`                                                                                       // column name as uploaded
+	Value         float64 `gorm:"index:idx_attribute_key_value,priority:2" json:"value"`
+}
+
+// Financial statement types, one per screener.in results table.
+const (
+	StatementQuarterly    = "quarterly_results"
+	StatementProfitLoss   = "profit_loss"
+	StatementBalanceSheet = "balance_sheet"
+	StatementCashFlow     = "cash_flow"
+)
+
+// FinancialStatement holds one period of a company's financial statement,
+// such as the Mar 2024 profit & loss or the Dec 2024 quarterly results.
+type FinancialStatement struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	StockID       uint       `gorm:"uniqueIndex:idx_statement_basis_period;not null" json:"stock_id"`
+	StatementType string     `gorm:"size:30;uniqueIndex:idx_statement_basis_period;not null" json:"statement_type"`   // quarterly_results, profit_loss, balance_sheet, cash_flow
+	Basis         string     `gorm:"size:20;uniqueIndex:idx_statement_basis_period;not null;default:''" json:"basis"` // consolidated or standalone
+	Period        string     `gorm:"size:20;uniqueIndex:idx_statement_basis_period;not null" json:"period"`           // "Mar 2024", or "TTM"
+	PeriodEnd     *time.Time `gorm:"index" json:"period_end,omitempty"`                                               // last day of the period; nil for TTM
+	Source        string     `gorm:"size:50" json:"source"`
+	FetchedAt     time.Time  `json:"fetched_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	// Relationships
+	LineItems []FinancialLineItem `gorm:"foreignKey:StatementID;constraint:OnDelete:CASCADE" json:"line_items,omitempty"`
+}
+
+// FinancialLineItem is one row of a financial statement, in Rs. crores
+// unless the name says otherwise (e.g. "OPM %", "EPS in Rs").
+type FinancialLineItem struct {
+	ID          uint    `gorm:"primaryKey" json:"id"`
+	StatementID uint    `gorm:"index;not null" json:"statement_id"`
+	Name        string  `gorm:"size:100;not null" json:"name"`
+	Value       float64 `json:"value"`
+	Position    int     `json:"position"` // row order in the source table
+}
+
+// Item returns the value of the first line item with the given name.
+func (s *FinancialStatement) Item(name string) (float64, bool) {
+	for _, item := range s.LineItems {
+		if strings.EqualFold(item.Name, name) {
+			return item.Value, true
+		}
+	}
+	return 0, false
+}
+
+// ShareholdingSnapshot is a stock's shareholding pattern at the end of a
+// quarter. Holdings are percentages of total shares.
+type ShareholdingSnapshot struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	StockID         uint      `gorm:"uniqueIndex:idx_shareholding_period;not null" json:"stock_id"`
+	Period          string    `gorm:"size:20;not null" json:"period"` // "Sep 2024"
+	PeriodEnd       time.Time `gorm:"uniqueIndex:idx_shareholding_period;not null" json:"period_end"`
+	Promoters       float64   `json:"promoters"`
+	FIIs            float64   `json:"fiis"`
+	DIIs            float64   `json:"diis"`
+	Government      float64   `json:"government"`
+	Public          float64   `json:"public"`
+	Shareholders    int       `json:"shareholders"`     // number of shareholders
+	PledgedPromoter *float64  `json:"pledged_promoter"` // percentage of promoter shares pledged; nil if not recorded for the quarter
+	Source          string    `gorm:"size:50" json:"source"`
+	FetchedAt       time.Time `json:"fetched_at"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// IndustryPeer is a company in an industry's peer group, with the
+// valuation and return figures of screener.in's peer comparison table.
+// Percentages are in percent and amounts in Rs. crores.
+type IndustryPeer struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	Industry        string    `gorm:"size:100;uniqueIndex:idx_industry_peer;not null" json:"industry"`
+	Symbol          string    `gorm:"size:20;uniqueIndex:idx_industry_peer;not null" json:"symbol"`
+	Name            string    `gorm:"size:255" json:"name"`
+	CurrentPrice    float64   `json:"current_price"`
+	StockPE         float64   `json:"stock_pe"`
+	PriceToBook     float64   `json:"price_to_book"`
+	MarketCap       float64   `json:"market_cap"`
+	DividendYield   float64   `json:"dividend_yield"`
+	NetProfitQtr    float64   `json:"net_profit_qtr"`
+	ProfitGrowthQtr float64   `json:"profit_growth_qtr"` // year-on-year
+	SalesQtr        float64   `json:"sales_qtr"`
+	SalesGrowthQtr  float64   `json:"sales_growth_qtr"` // year-on-year
+	ROCE            float64   `json:"roce"`
+	ROE             float64   `json:"roe"`
+	Source          string    `gorm:"size:50" json:"source"`
+	FetchedAt       time.Time `json:"fetched_at"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
 }
 
 // News represents a news article.
@@ -92,10 +235,35 @@ type News struct {
 	UpdatedAt      time.Time      `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 
+	// Relationships
+	Stock      *Stock      `gorm:"foreignKey:StockID" json:"stock,omitempty"` // primary stock, if any
+	StockLinks []NewsStock `gorm:"foreignKey:NewsID" json:"stock_links,omitempty"`
+}
+
+// NewsStock links a news article to a stock it mentions. An article can be
+// linked to many stocks, each with its own relevance and sentiment.
+type NewsStock struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	NewsID         uint           `gorm:"uniqueIndex:idx_news_stock;not null" json:"news_id"`
+	StockID        uint           `gorm:"uniqueIndex:idx_news_stock;index;not null" json:"stock_id"`
+	Relevance      float64        `json:"relevance"` // 0 to 1
+	Sentiment      SentimentScore `gorm:"size:20" json:"sentiment"`
+	SentimentScore float64        `json:"sentiment_score"` // -1 to 1
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+
 	// Relationships
 	Stock *Stock `gorm:"foreignKey:StockID" json:"stock,omitempty"`
 }
 
+// StockNews is a news article as seen from one linked stock.
+type StockNews struct {
+	News
+	Relevance          float64        `json:"relevance"`
+	LinkSentiment      SentimentScore `json:"link_sentiment"`
+	LinkSentimentScore float64        `json:"link_sentiment_score"`
+}
+
 // Recommendation represents a stock recommendation.
 type Recommendation struct {
 	ID              uint           `gorm:"primaryKey" json:"id"`
@@ -113,6 +281,8 @@ type Recommendation struct {
 	RiskLevel       string         `gorm:"size:20" json:"risk_level"`     // low, medium, high
 	IsActive        bool           `gorm:"default:true" json:"is_active"`
 	ExpiresAt       *time.Time     `json:"expires_at,omitempty"`
+	DataAsOf        *time.Time     `json:"data_as_of,omitempty"` // when the fundamentals used were fetched
+	DataStale       bool           `json:"data_stale"`           // fundamentals were past their max age
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
@@ -139,16 +309,278 @@ type MarketCondition struct {
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// Screener upload statuses.
+const (
+	UploadStatusPending    = "pending"
+	UploadStatusProcessing = "processing"
+	UploadStatusCompleted  = "completed"
+	UploadStatusFailed     = "failed"
+	UploadStatusRolledBack = "rolled_back"
+)
+
+// Screener upload row statuses.
+const (
+	UploadRowCreated = "created"
+	UploadRowSkipped = "skipped"
+	UploadRowError   = "error"
+)
+
 // ScreenerUpload tracks CSV uploads from screener.in
 type ScreenerUpload struct {
-	ID            uint           `gorm:"primaryKey" json:"id"`
-	Filename      string         `gorm:"size:255" json:"filename"`
-	RecordsCount  int            `json:"records_count"`
-	ProcessedAt   time.Time      `json:"processed_at"`
-	Status        string         `gorm:"size:20" json:"status"` // pending, processing, completed, failed
-	ErrorMessage  string         `gorm:"type:text" json:"error_message,omitempty"`
-	CreatedAt     time.Time      `json:"created_at"`
-	UpdatedAt     time.Time      `json:"updated_at"`
-	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	Filename     string         `gorm:"size:255" json:"filename"`
+	RecordsCount int            `json:"records_count"`
+	CreatedCount int            `json:"created_count"`
+	SkippedCount int            `json:"skipped_count"`
+	ErrorCount   int            `json:"error_count"`
+	ProcessedAt  time.Time      `json:"processed_at"`
+	Status       string         `gorm:"size:20" json:"status"` // pending, processing, completed, failed, rolled_back
+	ErrorMessage string         `gorm:"type:text" json:"error_message,omitempty"`
+	RolledBackAt *time.Time     `json:"rolled_back_at,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// ScreenerUploadRow records the outcome of importing one CSV row.
+type ScreenerUploadRow struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UploadID      uint      `gorm:"index;not null" json:"upload_id"`
+	RowNumber     int       `json:"row_number"` // line number in the file, header is line 1
+	Symbol        string    `gorm:"size:50" json:"symbol"`
+	Status        string    `gorm:"size:20;index" json:"status"` // created, skipped, error
+	ErrorMessage  string    `gorm:"type:text" json:"error_message,omitempty"`
+	RawRow        string    `gorm:"type:text" json:"raw_row"` // JSON object of column -> value
+	StockID       *uint     `json:"stock_id,omitempty"`
+	StockCreated  bool      `json:"stock_created"` // the stock did not exist before this upload
+	FundamentalID *uint     `json:"fundamental_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ScreenerColumnMapping maps a column of screener exports to the field it
+// is imported into, for columns the parser does not recognise by name.
+type ScreenerColumnMapping struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Column    string    `gorm:"size:100;uniqueIndex;not null" json:"column"` // normalized column name
+	Label     string    `gorm:"size:255" json:"label"`                       // column name as first entered
+	Field     string    `gorm:"size:50;not null" json:"field"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// JobRun records a single execution of a scheduled background job.
+type JobRun struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	JobName    string         `gorm:"size:100;index;not null" json:"job_name"`
+	Trigger    string         `gorm:"size:20" json:"trigger"`      // schedule, manual
+	Status     string         `gorm:"size:20;index" json:"status"` // running, succeeded, failed
+	Message    string         `gorm:"type:text" json:"message,omitempty"`
+	StartedAt  time.Time      `gorm:"index" json:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	DurationMS int64          `json:"duration_ms"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Portfolio is a set of holdings, usually imported from a broker export.
+// Its transactions are the ledger its lots and cash are worked out from.
+type Portfolio struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Name      string         `gorm:"size:100;not null" json:"name"`
+	Broker    string         `gorm:"size:30" json:"broker"`    // format of the import, e.g. zerodha_console
+	Filename  string         `gorm:"size:255" json:"filename"` // file the portfolio was imported from
+	Cash      float64        `json:"cash"`                     // sum of the amounts of manual transactions
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Relationships
+	Lots         []PortfolioLot         `gorm:"foreignKey:PortfolioID;constraint:OnDelete:CASCADE" json:"lots,omitempty"`
+	Transactions []PortfolioTransaction `gorm:"foreignKey:PortfolioID;constraint:OnDelete:CASCADE" json:"transactions,omitempty"`
+}
+
+// Portfolio transaction types.
+const (
+	TransactionBuy        = "buy"
+	TransactionSell       = "sell"
+	TransactionDividend   = "dividend"
+	TransactionDeposit    = "deposit"
+	TransactionWithdrawal = "withdrawal"
+)
+
+// Portfolio transaction sources.
+const (
+	TransactionSourceImport = "import"
+	TransactionSourceManual = "manual"
+)
+
+// PortfolioTransaction is an entry in a portfolio's ledger. Buys and sells
+// carry the stock, quantity and price; dividends, deposits and withdrawals
+// only an amount. Imported trades record holdings bought outside the
+// portfolio's cash, so only manual transactions change the cash balance.
+type PortfolioTransaction struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	PortfolioID uint       `gorm:"index;not null" json:"portfolio_id"`
+	StockID     *uint      `gorm:"index" json:"stock_id,omitempty"`
+	Symbol      string     `gorm:"size:20" json:"symbol,omitempty"`
+	Type        string     `gorm:"size:20;not null" json:"type"` // buy, sell, dividend, deposit, withdrawal
+	Quantity    float64    `json:"quantity,omitempty"`
+	Price       float64    `json:"price,omitempty"`             // per share
+	Fees        float64    `json:"fees,omitempty"`              // brokerage and charges
+	Amount      float64    `json:"amount"`                      // change in cash: negative for buys and withdrawals
+	RealizedPnL float64    `json:"realized_pnl,omitempty"`      // sells: proceeds less fees less the cost of the lots sold
+	Date        *time.Time `gorm:"index" json:"date,omitempty"` // nil for holdings imported without a buy date
+	Source      string     `gorm:"size:20" json:"source"`       // import, manual
+	Notes       string     `gorm:"size:255" json:"notes,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// PortfolioLot is a quantity of a stock held in a portfolio at one cost.
+// Holdings exports give one lot per stock at the average cost; tradebooks
+// give one lot per day of buying that is still held.
+type PortfolioLot struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	PortfolioID uint       `gorm:"index;not null" json:"portfolio_id"`
+	StockID     uint       `gorm:"index;not null" json:"stock_id"`
+	Symbol      string     `gorm:"size:20;not null" json:"symbol"`
+	Quantity    float64    `json:"quantity"`
+	AverageCost float64    `json:"average_cost"`       // per share
+	BuyDate     *time.Time `json:"buy_date,omitempty"` // nil if the export does not say
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Watchlist is a named list of stocks that are re-analysed on a schedule.
+type Watchlist struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	Name           string     `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	Description    string     `gorm:"size:255" json:"description,omitempty"`
+	AutoAnalyze    bool       `gorm:"default:true" json:"auto_analyze"` // re-analysed by the watchlist_analysis job
+	LastAnalyzedAt *time.Time `json:"last_analyzed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+
+	// Relationships
+	Items []WatchlistItem `gorm:"foreignKey:WatchlistID;constraint:OnDelete:CASCADE" json:"items,omitempty"`
+}
+
+// WatchlistItem is a stock on a watchlist with the outcome of its latest
+// analysis.
+type WatchlistItem struct {
+	ID                   uint       `gorm:"primaryKey" json:"id"`
+	WatchlistID          uint       `gorm:"uniqueIndex:idx_watchlist_stock;not null" json:"watchlist_id"`
+	StockID              uint       `gorm:"uniqueIndex:idx_watchlist_stock;index;not null" json:"stock_id"`
+	Symbol               string     `gorm:"size:20;not null" json:"symbol"`
+	LastAction           Action     `gorm:"size:10" json:"last_action,omitempty"`
+	PreviousAction       Action     `gorm:"size:10" json:"previous_action,omitempty"` // action before the latest analysis
+	ActionChanged        bool       `json:"action_changed"`                           // the latest analysis changed the action
+	ActionChangedAt      *time.Time `json:"action_changed_at,omitempty"`
+	LastRecommendationID *uint      `json:"last_recommendation_id,omitempty"`
+	LastAnalyzedAt       *time.Time `json:"last_analyzed_at,omitempty"`
+	LastError            string     `gorm:"size:255" json:"last_error,omitempty"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+}
+
+// Alert conditions.
+const (
+	AlertTargetHit    = "target_hit"    // price reached an active recommendation's target
+	AlertStopLossHit  = "stop_loss_hit" // price reached an active recommendation's stop-loss
+	AlertActionChange = "action_change" // a watchlist stock's recommended action changed
+	AlertBearishNews  = "bearish_news"  // news scored at or below -Threshold
+	AlertDailyPick    = "daily_pick"    // a stock was picked with at least Threshold confidence
+)
+
+// Alert channels.
+const (
+	AlertChannelWebhook  = "webhook"
+	AlertChannelEmail    = "email"
+	AlertChannelTelegram = "telegram"
+)
+
+// Alert delivery statuses.
+const (
+	AlertDeliveryPending = "pending"
+	AlertDeliverySent    = "sent"
+	AlertDeliveryFailed  = "failed" // gave up after the configured attempts
+)
+
+// AlertRule raises alerts when its condition is met, for one stock, the
+// stocks on a watchlist or every stock, and sends them through a channel.
+type AlertRule struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"size:100;not null" json:"name"`
+	Condition   string    `gorm:"size:30;not null;index" json:"condition"`
+	Symbol      string    `gorm:"size:20" json:"symbol,omitempty"`     // only this stock
+	WatchlistID *uint     `gorm:"index" json:"watchlist_id,omitempty"` // only stocks on this watchlist
+	Threshold   float64   `json:"threshold"`                           // news score magnitude (0-1) or pick confidence (0-100)
+	Channel     string    `gorm:"size:20;not null" json:"channel"`
+	Target      string    `gorm:"size:500;not null" json:"target"` // webhook URL, email address or Telegram chat ID
+	Enabled     bool      `gorm:"default:true" json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AlertDelivery is an alert raised by a rule and its delivery attempts. The
+// dedupe key identifies what the alert is about, so the same event never
+// alerts twice through a rule.
+type AlertDelivery struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	RuleID        uint       `gorm:"uniqueIndex:idx_alert_dedupe;not null" json:"rule_id"`
+	DedupeKey     string     `gorm:"size:200;uniqueIndex:idx_alert_dedupe;not null" json:"dedupe_key"`
+	Channel       string     `gorm:"size:20;not null" json:"channel"`
+	Target        string     `gorm:"size:500;not null" json:"target"`
+	Subject       string     `gorm:"size:255" json:"subject"`
+	Message       string     `gorm:"type:text" json:"message"`
+	Payload       string     `gorm:"type:text" json:"payload,omitempty"` // JSON sent to webhooks
+	Status        string     `gorm:"size:20;index;not null" json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `gorm:"size:500" json:"last_error,omitempty"`
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Webhook delivery statuses.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed" // gave up after the configured attempts
+)
+
+// WebhookSubscription is an external URL that receives signed JSON
+// payloads for the events it subscribes to.
+type WebhookSubscription struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"size:100;not null" json:"name"`
+	URL         string    `gorm:"size:1000;not null" json:"url"`
+	Secret      string    `gorm:"size:100;not null" json:"-"`      // HMAC key for the signature header
+	Events      string    `gorm:"size:500;not null" json:"events"` // comma-separated event types, or *
+	Enabled     bool      `gorm:"default:true" json:"enabled"`
+	Description string    `gorm:"size:255" json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is an event sent, or to be sent, to a subscription, and
+// the outcome of its latest attempt.
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint       `gorm:"uniqueIndex:idx_webhook_event;not null" json:"subscription_id"`
+	EventID        string     `gorm:"size:40;uniqueIndex:idx_webhook_event;not null" json:"event_id"`
+	Event          string     `gorm:"size:50;index;not null" json:"event"`
+	Payload        string     `gorm:"type:text" json:"payload"`
+	Status         string     `gorm:"size:20;index;not null" json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	ResponseBody   string     `gorm:"size:500" json:"response_body,omitempty"`
+	LastError      string     `gorm:"size:500" json:"last_error,omitempty"`
+	DurationMS     int64      `json:"duration_ms"` // of the latest attempt
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -29,14 +32,44 @@ func NewRepository(dsn string) (*Repository, error) {
 	if err := db.AutoMigrate(
 		&Stock{},
 		&StockFundamental{},
+		&FundamentalAttribute{},
+		&FinancialStatement{},
+		&FinancialLineItem{},
+		&ShareholdingSnapshot{},
+		&IndustryPeer{},
 		&News{},
+		&NewsStock{},
 		&Recommendation{},
 		&MarketCondition{},
 		&ScreenerUpload{},
+		&ScreenerUploadRow{},
+		&ScreenerColumnMapping{},
+		&JobRun{},
+		&Portfolio{},
+		&PortfolioLot{},
+		&PortfolioTransaction{},
+		&Watchlist{},
+		&WatchlistItem{},
+		&AlertRule{},
+		&AlertDelivery{},
+		&WebhookSubscription{},
+		&WebhookDelivery{},
 	); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	if err := migrateNewsSearch(db); err != nil {
+		return nil, err
+	}
+
+	if err := backfillNewsStocks(db); err != nil {
+		return nil, err
+	}
+
+	if err := migrateFinancialBasis(db); err != nil {
+		return nil, err
+	}
+
 	return &Repository{db: db}, nil
 }
 
@@ -71,6 +104,26 @@ func (r *Repository) GetStockBySymbol(ctx context.Context, symbol string) (*Stoc
 	return &stock, err
 }
 
+// GetStockByISIN retrieves a stock by its ISIN.
+func (r *Repository) GetStockByISIN(ctx context.Context, isin string) (*Stock, error) {
+	var stock Stock
+	err := r.db.WithContext(ctx).Where("isin = ?", isin).First(&stock).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &stock, err
+}
+
+// GetStockByName retrieves a stock by its name, ignoring case.
+func (r *Repository) GetStockByName(ctx context.Context, name string) (*Stock, error) {
+	var stock Stock
+	err := r.db.WithContext(ctx).Where("LOWER(name) = LOWER(?)", name).Order("id").First(&stock).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &stock, err
+}
+
 // GetStockByID retrieves a stock by its ID.
 func (r *Repository) GetStockByID(ctx context.Context, id uint) (*Stock, error) {
 	var stock Stock
@@ -116,6 +169,43 @@ func (r *Repository) ListStocks(ctx context.Context, limit, offset int) ([]Stock
 	return stocks, err
 }
 
+// ListStocksWithStaleFundamentals lists stocks whose latest fundamental snapshot
+// was fetched before the cutoff for its source, or defaultCutoff for sources
+// not in cutoffs, and stocks which have no fundamentals at all.
+func (r *Repository) ListStocksWithStaleFundamentals(ctx context.Context, cutoffs map[string]time.Time, defaultCutoff time.Time, limit int) ([]Stock, error) {
+	var stocks []Stock
+	latest := r.db.WithContext(ctx).
+		Model(&StockFundamental{}).
+		Select("DISTINCT ON (stock_id) stock_id, fetched_at, source").
+		Order("stock_id, fetched_at DESC")
+
+	sources := make([]string, 0, len(cutoffs))
+	for source := range cutoffs {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	cutoff := "CASE LOWER(lf.source)"
+	var args []interface{}
+	for _, source := range sources {
+		cutoff += " WHEN ? THEN ?::timestamptz"
+		args = append(args, strings.ToLower(source), cutoffs[source])
+	}
+	cutoff += " ELSE ?::timestamptz END"
+	args = append(args, defaultCutoff)
+
+	query := r.db.WithContext(ctx).
+		Model(&Stock{}).
+		Joins("LEFT JOIN (?) AS lf ON lf.stock_id = stocks.id", latest).
+		Where("lf.fetched_at IS NULL OR lf.fetched_at < "+cutoff, args...).
+		Order("lf.fetched_at ASC NULLS FIRST")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&stocks).Error
+	return stocks, err
+}
+
 // UpdateStock updates a stock.
 func (r *Repository) UpdateStock(ctx context.Context, stock *Stock) error {
 	return r.db.WithContext(ctx).Save(stock).Error
@@ -132,6 +222,7 @@ func (r *Repository) CreateFundamental(ctx context.Context, fundamental *StockFu
 func (r *Repository) GetLatestFundamental(ctx context.Context, stockID uint) (*StockFundamental, error) {
 	var fundamental StockFundamental
 	err := r.db.WithContext(ctx).
+		Preload("Attributes").
 		Where("stock_id = ?", stockID).
 		Order("fetched_at DESC").
 		First(&fundamental).Error
@@ -141,6 +232,176 @@ func (r *Repository) GetLatestFundamental(ctx context.Context, stockID uint) (*S
 	return &fundamental, err
 }
 
+// migrateFinancialBasis marks data scraped before the basis was recorded as
+// standalone, which is what screener.in's plain company page shows, and
+// drops the statement index that did not include the basis. It is
+// idempotent.
+func migrateFinancialBasis(db *gorm.DB) error {
+	statements := []string{
+		`DROP INDEX IF EXISTS idx_statement_period`,
+		`UPDATE financial_statements SET basis = 'standalone' WHERE basis = ''`,
+		`UPDATE stock_fundamentals SET basis = 'standalone'
+			WHERE (basis IS NULL OR basis = '') AND source = 'screener_scrape'`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to migrate financial basis: %w", err)
+		}
+	}
+	return nil
+}
+
+// SaveFinancialStatements stores scraped financial statements for a stock.
+// A statement already stored for the same type, basis and period is
+// replaced, along with its line items.
+func (r *Repository) SaveFinancialStatements(ctx context.Context, stockID uint, statements []FinancialStatement) error {
+	if len(statements) == 0 {
+		return nil
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range statements {
+			statement := &statements[i]
+			statement.StockID = stockID
+
+			var existing FinancialStatement
+			err := tx.Where("stock_id = ? AND statement_type = ? AND basis = ? AND period = ?",
+				stockID, statement.StatementType, statement.Basis, statement.Period).
+				First(&existing).Error
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				statement.ID = 0
+			case err != nil:
+				return fmt.Errorf("failed to look up %s %s: %w", statement.StatementType, statement.Period, err)
+			default:
+				statement.ID = existing.ID
+				statement.CreatedAt = existing.CreatedAt
+				if err := tx.Where("statement_id = ?", existing.ID).Delete(&FinancialLineItem{}).Error; err != nil {
+					return fmt.Errorf("failed to clear line items of %s %s: %w", statement.StatementType, statement.Period, err)
+				}
+			}
+
+			items := statement.LineItems
+			statement.LineItems = nil
+			if err := tx.Save(statement).Error; err != nil {
+				return fmt.Errorf("failed to save %s %s: %w", statement.StatementType, statement.Period, err)
+			}
+
+			for j := range items {
+				items[j].ID = 0
+				items[j].StatementID = statement.ID
+			}
+			if len(items) > 0 {
+				if err := tx.Create(&items).Error; err != nil {
+					return fmt.Errorf("failed to save line items of %s %s: %w", statement.StatementType, statement.Period, err)
+				}
+			}
+			statement.LineItems = items
+		}
+		return nil
+	})
+}
+
+// ListFinancialStatements retrieves a stock's financial statements with their
+// line items, latest period first. TTM figures sort ahead of dated periods.
+// An empty statementType or basis returns all types or bases.
+func (r *Repository) ListFinancialStatements(ctx context.Context, stockID uint, statementType, basis string, limit int) ([]FinancialStatement, error) {
+	var statements []FinancialStatement
+	query := r.db.WithContext(ctx).
+		Preload("LineItems", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position")
+		}).
+		Where("stock_id = ?", stockID)
+
+	if statementType != "" {
+		query = query.Where("statement_type = ?", statementType)
+	}
+	if basis != "" {
+		query = query.Where("basis = ?", basis)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	err := query.Order("statement_type, basis, period_end DESC NULLS FIRST").Find(&statements).Error
+	return statements, err
+}
+
+// SaveShareholdingSnapshots stores a stock's quarterly shareholding, replacing
+// any snapshot already stored for the same quarter. A pledge already recorded
+// for a quarter is kept if the new snapshot has none.
+func (r *Repository) SaveShareholdingSnapshots(ctx context.Context, stockID uint, snapshots []ShareholdingSnapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	for i := range snapshots {
+		snapshots[i].StockID = stockID
+	}
+
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "stock_id"}, {Name: "period_end"}},
+			DoUpdates: append(clause.AssignmentColumns([]string{
+				"period", "promoters", "fiis", "diis", "government", "public",
+				"shareholders", "source", "fetched_at", "updated_at",
+			}), clause.Assignment{
+				Column: clause.Column{Name: "pledged_promoter"},
+				Value:  gorm.Expr("COALESCE(EXCLUDED.pledged_promoter, shareholding_snapshots.pledged_promoter)"),
+			}),
+		}).
+		Create(&snapshots).Error
+}
+
+// ListShareholdingSnapshots retrieves up to limit of a stock's most recent
+// quarterly shareholding snapshots, latest first.
+func (r *Repository) ListShareholdingSnapshots(ctx context.Context, stockID uint, limit int) ([]ShareholdingSnapshot, error) {
+	var snapshots []ShareholdingSnapshot
+	query := r.db.WithContext(ctx).
+		Where("stock_id = ?", stockID).
+		Order("period_end DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&snapshots).Error
+	return snapshots, err
+}
+
+// SaveIndustryPeers upserts the peer group of an industry. Peers already
+// stored for the industry but missing from peers are kept, since screener.in
+// only lists the largest companies around the one being viewed.
+func (r *Repository) SaveIndustryPeers(ctx context.Context, industry string, peers []IndustryPeer) error {
+	if industry == "" || len(peers) == 0 {
+		return nil
+	}
+
+	for i := range peers {
+		peers[i].Industry = industry
+	}
+
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "industry"}, {Name: "symbol"}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"name", "current_price", "stock_pe", "price_to_book", "market_cap",
+				"dividend_yield", "net_profit_qtr", "profit_growth_qtr", "sales_qtr",
+				"sales_growth_qtr", "roce", "roe", "source", "fetched_at", "updated_at",
+			}),
+		}).
+		Create(&peers).Error
+}
+
+// ListIndustryPeers retrieves the peer group of an industry, largest
+// company first.
+func (r *Repository) ListIndustryPeers(ctx context.Context, industry string) ([]IndustryPeer, error) {
+	var peers []IndustryPeer
+	err := r.db.WithContext(ctx).
+		Where("industry = ?", industry).
+		Order("market_cap DESC").
+		Find(&peers).Error
+	return peers, err
+}
+
 // News operations
 
 // CreateNews creates a new news article.
@@ -189,19 +450,72 @@ func (r *Repository) UpdateNews(ctx context.Context, news *News) error {
 	return r.db.WithContext(ctx).Save(news).Error
 }
 
-// ListNewsByStockID lists news for a specific stock.
-func (r *Repository) ListNewsByStockID(ctx context.Context, stockID uint, limit int) ([]News, error) {
-	var news []News
+// ListNewsByStockID lists news linked to a specific stock, with the
+// relevance and sentiment of each link.
+func (r *Repository) ListNewsByStockID(ctx context.Context, stockID uint, limit int) ([]StockNews, error) {
+	var news []StockNews
 	query := r.db.WithContext(ctx).
-		Where("stock_id = ?", stockID).
-		Order("published_at DESC")
+		Model(&News{}).
+		Select("news.*, news_stocks.relevance, news_stocks.sentiment AS link_sentiment, news_stocks.sentiment_score AS link_sentiment_score").
+		Joins("JOIN news_stocks ON news_stocks.news_id = news.id").
+		Where("news_stocks.stock_id = ?", stockID).
+		Order("news.published_at DESC")
 	if limit > 0 {
 		query = query.Limit(limit)
 	}
-	err := query.Find(&news).Error
+	err := query.Scan(&news).Error
 	return news, err
 }
 
+// SaveNewsWithLinks stores an article if its URL is new and links it to the
+// given stocks. Links to an existing article are added or updated, so an
+// article first saved for one stock is still associated with later ones.
+// It reports whether the article was newly created.
+func (r *Repository) SaveNewsWithLinks(ctx context.Context, news *News, links []NewsStock) (bool, error) {
+	created := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing News
+		err := tx.Where("url = ?", news.URL).First(&existing).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Create(news).Error; err != nil {
+				return err
+			}
+			created = true
+		case err != nil:
+			return err
+		default:
+			*news = existing
+		}
+
+		for i := range links {
+			links[i].NewsID = news.ID
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "news_id"}, {Name: "stock_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"relevance", "sentiment", "sentiment_score", "updated_at"}),
+			}).Create(&links[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return created, err
+}
+
+// backfillNewsStocks links news saved with a single StockID before the
+// news_stocks table existed. It is idempotent.
+func backfillNewsStocks(db *gorm.DB) error {
+	err := db.Exec(`INSERT INTO news_stocks (news_id, stock_id, relevance, sentiment, sentiment_score, created_at, updated_at)
+		SELECT id, stock_id, 1, sentiment, sentiment_score, NOW(), NOW()
+		FROM news
+		WHERE stock_id IS NOT NULL AND deleted_at IS NULL
+		ON CONFLICT (news_id, stock_id) DO NOTHING`).Error
+	if err != nil {
+		return fmt.Errorf("failed to backfill news stock links: %w", err)
+	}
+	return nil
+}
+
 // Recommendation operations
 
 // CreateRecommendation creates a new recommendation.
@@ -209,6 +523,31 @@ func (r *Repository) CreateRecommendation(ctx context.Context, rec *Recommendati
 	return r.db.WithContext(ctx).Create(rec).Error
 }
 
+// ReplaceActiveRecommendations creates a recommendation and deactivates the
+// stock's earlier active recommendations, returning those it superseded.
+func (r *Repository) ReplaceActiveRecommendations(ctx context.Context, rec *Recommendation) ([]Recommendation, error) {
+	var superseded []Recommendation
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Stock").
+			Where("stock_id = ? AND is_active = ?", rec.StockID, true).
+			Find(&superseded).Error; err != nil {
+			return err
+		}
+		if len(superseded) > 0 {
+			ids := make([]uint, len(superseded))
+			for i := range superseded {
+				ids[i] = superseded[i].ID
+				superseded[i].IsActive = false
+			}
+			if err := tx.Model(&Recommendation{}).Where("id IN ?", ids).Update("is_active", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(rec).Error
+	})
+	return superseded, err
+}
+
 // GetRecommendationByID retrieves a recommendation by ID.
 func (r *Repository) GetRecommendationByID(ctx context.Context, id uint) (*Recommendation, error) {
 	var rec Recommendation
@@ -269,6 +608,16 @@ func (r *Repository) DeactivateOldRecommendations(ctx context.Context, olderThan
 		Update("is_active", false).Error
 }
 
+// ExpireRecommendations deactivates active recommendations whose expiry time has passed.
+// It returns the number of recommendations deactivated.
+func (r *Repository) ExpireRecommendations(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Model(&Recommendation{}).
+		Where("is_active = ? AND expires_at IS NOT NULL AND expires_at < ?", true, now).
+		Update("is_active", false)
+	return result.RowsAffected, result.Error
+}
+
 // MarketCondition operations
 
 // CreateMarketCondition creates a new market condition record.
@@ -289,6 +638,51 @@ func (r *Repository) GetLatestMarketCondition(ctx context.Context, indexName str
 	return &mc, err
 }
 
+// SaveMarketCondition stores a market condition, updating the existing record
+// for the same index and time if there is one.
+func (r *Repository) SaveMarketCondition(ctx context.Context, mc *MarketCondition) error {
+	var existing MarketCondition
+	err := r.db.WithContext(ctx).
+		Where("index_name = ? AND recorded_at = ?", mc.IndexName, mc.RecordedAt).
+		First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return r.db.WithContext(ctx).Create(mc).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	mc.ID = existing.ID
+	mc.CreatedAt = existing.CreatedAt
+	return r.db.WithContext(ctx).Save(mc).Error
+}
+
+// ListLatestMarketConditions returns the latest market condition of each of
+// the given indices, in the order given. Indices without data are omitted.
+func (r *Repository) ListLatestMarketConditions(ctx context.Context, indexNames []string) ([]MarketCondition, error) {
+	var latest []MarketCondition
+	err := r.db.WithContext(ctx).
+		Select("DISTINCT ON (index_name) *").
+		Where("index_name IN ?", indexNames).
+		Order("index_name, recorded_at DESC").
+		Find(&latest).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]MarketCondition, len(latest))
+	for _, mc := range latest {
+		byName[mc.IndexName] = mc
+	}
+	ordered := make([]MarketCondition, 0, len(latest))
+	for _, name := range indexNames {
+		if mc, ok := byName[name]; ok {
+			ordered = append(ordered, mc)
+		}
+	}
+	return ordered, nil
+}
+
 // ScreenerUpload operations
 
 // CreateScreenerUpload creates a new screener upload record.
@@ -301,6 +695,340 @@ func (r *Repository) UpdateScreenerUpload(ctx context.Context, upload *ScreenerU
 	return r.db.WithContext(ctx).Save(upload).Error
 }
 
+// GetScreenerUpload retrieves a screener upload by ID.
+func (r *Repository) GetScreenerUpload(ctx context.Context, id uint) (*ScreenerUpload, error) {
+	var upload ScreenerUpload
+	err := r.db.WithContext(ctx).First(&upload, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &upload, err
+}
+
+// CreateScreenerUploadRows stores the row results of an upload.
+func (r *Repository) CreateScreenerUploadRows(ctx context.Context, rows []ScreenerUploadRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(rows, 500).Error
+}
+
+// ListScreenerUploadRows lists the row results of an upload, optionally
+// filtered by status.
+func (r *Repository) ListScreenerUploadRows(ctx context.Context, uploadID uint, status string, limit, offset int) ([]ScreenerUploadRow, error) {
+	var rows []ScreenerUploadRow
+	query := r.db.WithContext(ctx).
+		Where("upload_id = ?", uploadID).
+		Order("row_number")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+	err := query.Find(&rows).Error
+	return rows, err
+}
+
+// UploadImport is a parsed CSV row to be imported by ImportScreenerUpload.
+type UploadImport struct {
+	Symbol      string
+	Name        string // empty keeps the name of an existing stock
+	Exchange    string
+	Sector      string
+	Industry    string
+	ISIN        string
+	BSECode     string
+	Fundamental *StockFundamental
+	Row         int // index of the row result in the rows passed alongside
+}
+
+// ImportScreenerUpload imports an upload in a single transaction: missing
+// stocks are inserted in bulk, fundamentals are inserted in batches, and the
+// row results and upload totals are saved. On error nothing is written.
+// progress, if set, is called with the number of fundamentals inserted so far.
+func (r *Repository) ImportScreenerUpload(ctx context.Context, upload *ScreenerUpload, items []UploadImport, rows []ScreenerUploadRow, batchSize int, progress func(done int)) error {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		stockIDs, created, err := upsertStocks(tx, items, batchSize)
+		if err != nil {
+			return err
+		}
+
+		var fundamentals []*StockFundamental
+		var fundamentalRows []int
+		for _, item := range items {
+			row := &rows[item.Row]
+			stockID, ok := stockIDs[item.Symbol]
+			if !ok {
+				row.Status = UploadRowError
+				row.ErrorMessage = "stock could not be created"
+				continue
+			}
+			row.StockID = &stockID
+			row.StockCreated = created[item.Symbol]
+
+			item.Fundamental.StockID = stockID
+			item.Fundamental.UploadID = &upload.ID
+			fundamentals = append(fundamentals, item.Fundamental)
+			fundamentalRows = append(fundamentalRows, item.Row)
+		}
+
+		for start := 0; start < len(fundamentals); start += batchSize {
+			end := min(start+batchSize, len(fundamentals))
+			if err := tx.Create(fundamentals[start:end]).Error; err != nil {
+				return fmt.Errorf("failed to insert fundamentals: %w", err)
+			}
+			if progress != nil {
+				progress(end)
+			}
+		}
+		for i, f := range fundamentals {
+			row := &rows[fundamentalRows[i]]
+			row.Status = UploadRowCreated
+			row.FundamentalID = &f.ID
+		}
+
+		for i := range rows {
+			rows[i].UploadID = upload.ID
+		}
+		if len(rows) > 0 {
+			if err := tx.CreateInBatches(rows, batchSize).Error; err != nil {
+				return fmt.Errorf("failed to save upload rows: %w", err)
+			}
+		}
+
+		upload.RecordsCount = len(rows)
+		upload.CreatedCount, upload.SkippedCount, upload.ErrorCount = 0, 0, 0
+		for _, row := range rows {
+			switch row.Status {
+			case UploadRowCreated:
+				upload.CreatedCount++
+			case UploadRowSkipped:
+				upload.SkippedCount++
+			case UploadRowError:
+				upload.ErrorCount++
+			}
+		}
+		upload.Status = UploadStatusCompleted
+		upload.ProcessedAt = time.Now()
+		return tx.Save(upload).Error
+	})
+}
+
+// upsertStocks inserts the stocks of items that do not exist yet and returns
+// the ID of every symbol, and which symbols were newly created.
+func upsertStocks(tx *gorm.DB, items []UploadImport, batchSize int) (map[string]uint, map[string]bool, error) {
+	var symbols []string
+	bySymbol := make(map[string]UploadImport)
+	for _, item := range items {
+		if _, ok := bySymbol[item.Symbol]; !ok {
+			bySymbol[item.Symbol] = item
+			symbols = append(symbols, item.Symbol)
+		}
+	}
+
+	stockIDs, err := lookupStockIDs(tx, symbols, batchSize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var missing, existing []Stock
+	for _, symbol := range symbols {
+		item := bySymbol[symbol]
+		stock := Stock{
+			Symbol:   item.Symbol,
+			Name:     item.Name,
+			Exchange: item.Exchange,
+			Sector:   item.Sector,
+			Industry: item.Industry,
+			ISIN:     item.ISIN,
+			BSECode:  item.BSECode,
+		}
+		if _, ok := stockIDs[symbol]; !ok {
+			if stock.Name == "" {
+				stock.Name = stock.Symbol
+			}
+			missing = append(missing, stock)
+		} else if stock.Name != "" || stock.Sector != "" || stock.Industry != "" || stock.ISIN != "" || stock.BSECode != "" {
+			existing = append(existing, stock)
+		}
+	}
+
+	if err := updateStockDetails(tx, existing, batchSize); err != nil {
+		return nil, nil, err
+	}
+
+	created := make(map[string]bool)
+	if len(missing) == 0 {
+		return stockIDs, created, nil
+	}
+
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "symbol"}},
+		DoNothing: true,
+	}).CreateInBatches(missing, batchSize).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to insert stocks: %w", err)
+	}
+
+	// Stocks inserted concurrently by another writer are skipped, and gorm
+	// assigns the IDs returned for the others in order, so they may land on
+	// the wrong stocks. Look every symbol up instead, and count as created
+	// the stocks whose ID was returned by the insert.
+	inserted := make(map[uint]bool, len(missing))
+	missingSymbols := make([]string, 0, len(missing))
+	for _, stock := range missing {
+		if stock.ID != 0 {
+			inserted[stock.ID] = true
+		}
+		missingSymbols = append(missingSymbols, stock.Symbol)
+	}
+	found, err := lookupStockIDs(tx, missingSymbols, batchSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	for symbol, id := range found {
+		stockIDs[symbol] = id
+		if inserted[id] {
+			created[symbol] = true
+		}
+	}
+
+	return stockIDs, created, nil
+}
+
+// updateStockDetails sets the name, sector, industry, ISIN and BSE code of
+// existing stocks to the non-empty values of stocks, matched by symbol.
+func updateStockDetails(tx *gorm.DB, stocks []Stock, batchSize int) error {
+	if len(stocks) == 0 {
+		return nil
+	}
+
+	assignments := map[string]interface{}{"updated_at": gorm.Expr("excluded.updated_at")}
+	for _, column := range []string{"name", "sector", "industry", "isin", "bse_code"} {
+		assignments[column] = gorm.Expr(fmt.Sprintf("COALESCE(NULLIF(excluded.%s, ''), stocks.%s)", column, column))
+	}
+
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "symbol"}},
+		DoUpdates: clause.Assignments(assignments),
+	}).CreateInBatches(stocks, batchSize).Error; err != nil {
+		return fmt.Errorf("failed to update stocks: %w", err)
+	}
+	return nil
+}
+
+// lookupStockIDs returns the IDs of existing stocks by symbol.
+func lookupStockIDs(tx *gorm.DB, symbols []string, batchSize int) (map[string]uint, error) {
+	ids := make(map[string]uint, len(symbols))
+	for start := 0; start < len(symbols); start += batchSize {
+		end := min(start+batchSize, len(symbols))
+		var stocks []Stock
+		if err := tx.Select("id", "symbol").Where("symbol IN ?", symbols[start:end]).Find(&stocks).Error; err != nil {
+			return nil, fmt.Errorf("failed to look up stocks: %w", err)
+		}
+		for _, stock := range stocks {
+			ids[stock.Symbol] = stock.ID
+		}
+	}
+	return ids, nil
+}
+
+// ErrUploadRolledBack is returned when rolling back an upload twice.
+var ErrUploadRolledBack = errors.New("upload already rolled back")
+
+// ErrUploadNotCompleted is returned when rolling back an upload whose import
+// has not completed.
+var ErrUploadNotCompleted = errors.New("upload not completed")
+
+// UploadRollback summarises what rolling back an upload removed.
+type UploadRollback struct {
+	FundamentalsDeleted int64 `json:"fundamentals_deleted"`
+	StocksDeleted       int64 `json:"stocks_deleted"`
+}
+
+// RollbackScreenerUpload removes everything a completed upload created in a
+// single transaction: its fundamentals, and stocks it created that have no
+// other data and are not held in a portfolio or on a watchlist. The upload
+// and its row results are kept, marked as rolled back.
+func (r *Repository) RollbackScreenerUpload(ctx context.Context, id uint) (*UploadRollback, error) {
+	result := &UploadRollback{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var upload ScreenerUpload
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&upload, id).Error; err != nil {
+			return err
+		}
+		if err := checkRollback(upload.Status); err != nil {
+			return err
+		}
+
+		uploaded := tx.Unscoped().Model(&StockFundamental{}).Select("id").Where("upload_id = ?", id)
+		if err := tx.Where("fundamental_id IN (?)", uploaded).Delete(&FundamentalAttribute{}).Error; err != nil {
+			return err
+		}
+
+		deleted := tx.Unscoped().Where("upload_id = ?", id).Delete(&StockFundamental{})
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		result.FundamentalsDeleted = deleted.RowsAffected
+
+		var stockIDs []uint
+		if err := tx.Model(&ScreenerUploadRow{}).
+			Where("upload_id = ? AND stock_created AND stock_id IS NOT NULL", id).
+			Pluck("stock_id", &stockIDs).Error; err != nil {
+			return err
+		}
+		if len(stockIDs) > 0 {
+			deleted = tx.Unscoped().
+				Where("id IN ?", stockIDs).
+				Where("NOT EXISTS (SELECT 1 FROM stock_fundamentals WHERE stock_fundamentals.stock_id = stocks.id)").
+				Where("NOT EXISTS (SELECT 1 FROM recommendations WHERE recommendations.stock_id = stocks.id)").
+				Where("NOT EXISTS (SELECT 1 FROM news WHERE news.stock_id = stocks.id)").
+				Where("NOT EXISTS (SELECT 1 FROM news_stocks WHERE news_stocks.stock_id = stocks.id)").
+				Where("NOT EXISTS (SELECT 1 FROM financial_statements WHERE financial_statements.stock_id = stocks.id)").
+				Where("NOT EXISTS (SELECT 1 FROM shareholding_snapshots WHERE shareholding_snapshots.stock_id = stocks.id)").
+				Where("NOT EXISTS (SELECT 1 FROM portfolio_lots WHERE portfolio_lots.stock_id = stocks.id)").
+				Where("NOT EXISTS (SELECT 1 FROM portfolio_transactions WHERE portfolio_transactions.stock_id = stocks.id)").
+				Where("NOT EXISTS (SELECT 1 FROM watchlist_items WHERE watchlist_items.stock_id = stocks.id)").
+				Delete(&Stock{})
+			if deleted.Error != nil {
+				return deleted.Error
+			}
+			result.StocksDeleted = deleted.RowsAffected
+		}
+
+		now := time.Now()
+		return tx.Model(&upload).Updates(map[string]interface{}{
+			"status":         UploadStatusRolledBack,
+			"rolled_back_at": &now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkRollback returns why an upload with status cannot be rolled back, or
+// nil if it can. Only completed imports committed any data.
+func checkRollback(status string) error {
+	switch status {
+	case UploadStatusCompleted:
+		return nil
+	case UploadStatusRolledBack:
+		return ErrUploadRolledBack
+	default:
+		return ErrUploadNotCompleted
+	}
+}
+
 // ListScreenerUploads lists screener uploads.
 func (r *Repository) ListScreenerUploads(ctx context.Context, limit int) ([]ScreenerUpload, error) {
 	var uploads []ScreenerUpload
@@ -312,3 +1040,567 @@ func (r *Repository) ListScreenerUploads(ctx context.Context, limit int) ([]Scre
 	return uploads, err
 }
 
+// Screener column mapping operations
+
+// ListScreenerColumnMappings lists the custom column mappings for screener
+// exports.
+func (r *Repository) ListScreenerColumnMappings(ctx context.Context) ([]ScreenerColumnMapping, error) {
+	var mappings []ScreenerColumnMapping
+	err := r.db.WithContext(ctx).Order("label").Find(&mappings).Error
+	return mappings, err
+}
+
+// SaveScreenerColumnMapping creates or replaces the mapping of a column.
+func (r *Repository) SaveScreenerColumnMapping(ctx context.Context, mapping *ScreenerColumnMapping) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "column"}},
+			DoUpdates: clause.AssignmentColumns([]string{"label", "field", "updated_at"}),
+		}).
+		Create(mapping).Error
+}
+
+// DeleteScreenerColumnMapping deletes a column mapping, reporting whether
+// it existed.
+func (r *Repository) DeleteScreenerColumnMapping(ctx context.Context, id uint) (bool, error) {
+	result := r.db.WithContext(ctx).Delete(&ScreenerColumnMapping{}, id)
+	return result.RowsAffected > 0, result.Error
+}
+
+// AttributeKey is an attribute key in use, with how many fundamental
+// snapshots have it.
+type AttributeKey struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	Count int64  `json:"count"`
+}
+
+// ListFundamentalAttributeKeys lists the attribute keys stored from
+// screener uploads.
+func (r *Repository) ListFundamentalAttributeKeys(ctx context.Context) ([]AttributeKey, error) {
+	var keys []AttributeKey
+	err := r.db.WithContext(ctx).
+		Model(&FundamentalAttribute{}).
+		Select("key, MIN(label) AS label, COUNT(*) AS count").
+		Group("key").
+		Order("key").
+		Scan(&keys).Error
+	return keys, err
+}
+
+// JobRun operations
+
+// CreateJobRun creates a new job run record.
+func (r *Repository) CreateJobRun(ctx context.Context, run *JobRun) error {
+	return r.db.WithContext(ctx).Create(run).Error
+}
+
+// UpdateJobRun updates a job run record.
+func (r *Repository) UpdateJobRun(ctx context.Context, run *JobRun) error {
+	return r.db.WithContext(ctx).Save(run).Error
+}
+
+// ListJobRuns lists recent job runs, optionally filtered by job name.
+func (r *Repository) ListJobRuns(ctx context.Context, jobName string, limit int) ([]JobRun, error) {
+	var runs []JobRun
+	query := r.db.WithContext(ctx).Order("started_at DESC")
+	if jobName != "" {
+		query = query.Where("job_name = ?", jobName)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&runs).Error
+	return runs, err
+}
+
+// GetLatestJobRun gets the most recent run of a job.
+func (r *Repository) GetLatestJobRun(ctx context.Context, jobName string) (*JobRun, error) {
+	var run JobRun
+	err := r.db.WithContext(ctx).
+		Where("job_name = ?", jobName).
+		Order("started_at DESC").
+		First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &run, err
+}
+
+// Portfolio operations
+
+// CreatePortfolio creates a portfolio together with its lots and
+// transactions.
+func (r *Repository) CreatePortfolio(ctx context.Context, portfolio *Portfolio) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Create(portfolio).Error
+	})
+}
+
+// GetPortfolio retrieves a portfolio with its lots.
+func (r *Repository) GetPortfolio(ctx context.Context, id uint) (*Portfolio, error) {
+	var portfolio Portfolio
+	err := r.db.WithContext(ctx).
+		Preload("Lots", func(db *gorm.DB) *gorm.DB {
+			return db.Order("symbol, buy_date NULLS FIRST, id")
+		}).
+		First(&portfolio, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &portfolio, err
+}
+
+// ListPortfolioTransactions lists a portfolio's transactions in the order
+// they were made, undated ones first.
+func (r *Repository) ListPortfolioTransactions(ctx context.Context, portfolioID uint) ([]PortfolioTransaction, error) {
+	var transactions []PortfolioTransaction
+	err := r.db.WithContext(ctx).
+		Where("portfolio_id = ?", portfolioID).
+		Order("date NULLS FIRST, id").
+		Find(&transactions).Error
+	return transactions, err
+}
+
+// SavePortfolioLedger replaces a portfolio's transactions, lots and cash
+// balance in one transaction. Transactions without an ID are created,
+// the others updated, and any of the portfolio's transactions missing from
+// transactions are deleted.
+func (r *Repository) SavePortfolioLedger(ctx context.Context, portfolio *Portfolio, transactions []PortfolioTransaction, lots []PortfolioLot) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var keep []uint
+		for i := range transactions {
+			transactions[i].PortfolioID = portfolio.ID
+			if err := tx.Save(&transactions[i]).Error; err != nil {
+				return fmt.Errorf("failed to save transaction: %w", err)
+			}
+			keep = append(keep, transactions[i].ID)
+		}
+
+		stale := tx.Where("portfolio_id = ?", portfolio.ID)
+		if len(keep) > 0 {
+			stale = stale.Where("id NOT IN ?", keep)
+		}
+		if err := stale.Delete(&PortfolioTransaction{}).Error; err != nil {
+			return fmt.Errorf("failed to delete transactions: %w", err)
+		}
+
+		if err := tx.Where("portfolio_id = ?", portfolio.ID).Delete(&PortfolioLot{}).Error; err != nil {
+			return fmt.Errorf("failed to delete lots: %w", err)
+		}
+		for i := range lots {
+			lots[i].ID = 0
+			lots[i].PortfolioID = portfolio.ID
+		}
+		if len(lots) > 0 {
+			if err := tx.Create(&lots).Error; err != nil {
+				return fmt.Errorf("failed to save lots: %w", err)
+			}
+		}
+
+		return tx.Model(portfolio).Update("cash", portfolio.Cash).Error
+	})
+}
+
+// ListPortfolios lists portfolios, newest first, without their lots.
+func (r *Repository) ListPortfolios(ctx context.Context) ([]Portfolio, error) {
+	var portfolios []Portfolio
+	err := r.db.WithContext(ctx).Order("created_at DESC").Find(&portfolios).Error
+	return portfolios, err
+}
+
+// ListPortfoliosHoldingStock lists the portfolios with lots of a stock.
+func (r *Repository) ListPortfoliosHoldingStock(ctx context.Context, stockID uint) ([]Portfolio, error) {
+	var portfolios []Portfolio
+	err := r.db.WithContext(ctx).
+		Where("id IN (?)", r.db.Model(&PortfolioLot{}).Select("portfolio_id").Where("stock_id = ?", stockID)).
+		Order("name").
+		Find(&portfolios).Error
+	return portfolios, err
+}
+
+// DeletePortfolio deletes a portfolio and its lots, reporting whether it
+// existed.
+func (r *Repository) DeletePortfolio(ctx context.Context, id uint) (bool, error) {
+	var deleted bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("portfolio_id = ?", id).Delete(&PortfolioLot{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("portfolio_id = ?", id).Delete(&PortfolioTransaction{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&Portfolio{}, id)
+		deleted = result.RowsAffected > 0
+		return result.Error
+	})
+	return deleted, err
+}
+
+// Watchlist operations
+
+// CreateWatchlist creates a watchlist together with its items.
+func (r *Repository) CreateWatchlist(ctx context.Context, watchlist *Watchlist) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(watchlist).Error; err != nil {
+			return err
+		}
+		// Create leaves a false AutoAnalyze to the column default.
+		if !watchlist.AutoAnalyze {
+			return tx.Model(watchlist).Update("auto_analyze", false).Error
+		}
+		return nil
+	})
+}
+
+// GetWatchlist retrieves a watchlist with its items.
+func (r *Repository) GetWatchlist(ctx context.Context, id uint) (*Watchlist, error) {
+	var watchlist Watchlist
+	err := r.db.WithContext(ctx).
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("symbol")
+		}).
+		First(&watchlist, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &watchlist, err
+}
+
+// GetWatchlistByName retrieves a watchlist with its items by name,
+// ignoring case.
+func (r *Repository) GetWatchlistByName(ctx context.Context, name string) (*Watchlist, error) {
+	var watchlist Watchlist
+	err := r.db.WithContext(ctx).
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("symbol")
+		}).
+		Where("LOWER(name) = LOWER(?)", name).
+		First(&watchlist).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &watchlist, err
+}
+
+// ListWatchlists lists watchlists with their items, by name.
+func (r *Repository) ListWatchlists(ctx context.Context, autoAnalyzeOnly bool) ([]Watchlist, error) {
+	var watchlists []Watchlist
+	query := r.db.WithContext(ctx).
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("symbol")
+		})
+	if autoAnalyzeOnly {
+		query = query.Where("auto_analyze = ?", true)
+	}
+	err := query.Order("name").Find(&watchlists).Error
+	return watchlists, err
+}
+
+// UpdateWatchlist updates a watchlist, leaving its items alone.
+func (r *Repository) UpdateWatchlist(ctx context.Context, watchlist *Watchlist) error {
+	return r.db.WithContext(ctx).Omit("Items").Save(watchlist).Error
+}
+
+// DeleteWatchlist deletes a watchlist and its items, reporting whether it
+// existed.
+func (r *Repository) DeleteWatchlist(ctx context.Context, id uint) (bool, error) {
+	var deleted bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("watchlist_id = ?", id).Delete(&WatchlistItem{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&Watchlist{}, id)
+		deleted = result.RowsAffected > 0
+		return result.Error
+	})
+	return deleted, err
+}
+
+// AddWatchlistItems adds stocks to a watchlist, skipping those already on
+// it, and returns how many were added.
+func (r *Repository) AddWatchlistItems(ctx context.Context, items []WatchlistItem) (int64, error) {
+	if len(items) == 0 {
+		return 0, nil
+	}
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "watchlist_id"}, {Name: "stock_id"}},
+			DoNothing: true,
+		}).
+		Create(&items)
+	return result.RowsAffected, result.Error
+}
+
+// RemoveWatchlistItem removes a stock from a watchlist, reporting whether it
+// was on it.
+func (r *Repository) RemoveWatchlistItem(ctx context.Context, watchlistID, stockID uint) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Where("watchlist_id = ? AND stock_id = ?", watchlistID, stockID).
+		Delete(&WatchlistItem{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdateWatchlistItem updates a watchlist item.
+func (r *Repository) UpdateWatchlistItem(ctx context.Context, item *WatchlistItem) error {
+	return r.db.WithContext(ctx).Save(item).Error
+}
+
+// Alert operations
+
+// CreateAlertRule creates an alert rule.
+func (r *Repository) CreateAlertRule(ctx context.Context, rule *AlertRule) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(rule).Error; err != nil {
+			return err
+		}
+		// Create leaves a false Enabled to the column default.
+		if !rule.Enabled {
+			return tx.Model(rule).Update("enabled", false).Error
+		}
+		return nil
+	})
+}
+
+// GetAlertRule retrieves an alert rule.
+func (r *Repository) GetAlertRule(ctx context.Context, id uint) (*AlertRule, error) {
+	var rule AlertRule
+	err := r.db.WithContext(ctx).First(&rule, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &rule, err
+}
+
+// ListAlertRules lists alert rules, oldest first.
+func (r *Repository) ListAlertRules(ctx context.Context, enabledOnly bool) ([]AlertRule, error) {
+	var rules []AlertRule
+	query := r.db.WithContext(ctx)
+	if enabledOnly {
+		query = query.Where("enabled = ?", true)
+	}
+	err := query.Order("id").Find(&rules).Error
+	return rules, err
+}
+
+// UpdateAlertRule updates an alert rule.
+func (r *Repository) UpdateAlertRule(ctx context.Context, rule *AlertRule) error {
+	return r.db.WithContext(ctx).Save(rule).Error
+}
+
+// DeleteAlertRule deletes an alert rule and its deliveries, reporting
+// whether it existed.
+func (r *Repository) DeleteAlertRule(ctx context.Context, id uint) (bool, error) {
+	var deleted bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("rule_id = ?", id).Delete(&AlertDelivery{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&AlertRule{}, id)
+		deleted = result.RowsAffected > 0
+		return result.Error
+	})
+	return deleted, err
+}
+
+// CreateAlertDeliveries records alerts, skipping those a rule has already
+// raised for the same dedupe key, and returns how many were new.
+func (r *Repository) CreateAlertDeliveries(ctx context.Context, deliveries []AlertDelivery) (int64, error) {
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "rule_id"}, {Name: "dedupe_key"}},
+			DoNothing: true,
+		}).
+		Create(&deliveries)
+	return result.RowsAffected, result.Error
+}
+
+// GetAlertDelivery retrieves an alert delivery.
+func (r *Repository) GetAlertDelivery(ctx context.Context, id uint) (*AlertDelivery, error) {
+	var delivery AlertDelivery
+	err := r.db.WithContext(ctx).First(&delivery, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &delivery, err
+}
+
+// ListDueAlertDeliveries lists pending deliveries whose next attempt is due,
+// oldest first.
+func (r *Repository) ListDueAlertDeliveries(ctx context.Context, now time.Time, limit int) ([]AlertDelivery, error) {
+	var deliveries []AlertDelivery
+	query := r.db.WithContext(ctx).
+		Where("status = ?", AlertDeliveryPending).
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+		Order("id")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ListAlertDeliveries lists alert deliveries, newest first, optionally for
+// one rule or with one status.
+func (r *Repository) ListAlertDeliveries(ctx context.Context, ruleID uint, status string, limit int) ([]AlertDelivery, error) {
+	var deliveries []AlertDelivery
+	query := r.db.WithContext(ctx)
+	if ruleID > 0 {
+		query = query.Where("rule_id = ?", ruleID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Order("id DESC").Find(&deliveries).Error
+	return deliveries, err
+}
+
+// UpdateAlertDelivery updates an alert delivery.
+func (r *Repository) UpdateAlertDelivery(ctx context.Context, delivery *AlertDelivery) error {
+	return r.db.WithContext(ctx).Save(delivery).Error
+}
+
+// ListBearishNews lists news saved since the given time that scored at or
+// below maxScore, newest first. With stock IDs, only news linked to those
+// stocks counts, by the score of the link.
+func (r *Repository) ListBearishNews(ctx context.Context, since time.Time, maxScore float64, stockIDs []uint, limit int) ([]News, error) {
+	var news []News
+	query := r.db.WithContext(ctx).
+		Where("news.created_at > ?", since).
+		Where("news.analyzed = ?", true)
+	if len(stockIDs) > 0 {
+		query = query.
+			Where("EXISTS (SELECT 1 FROM news_stocks WHERE news_stocks.news_id = news.id AND news_stocks.stock_id IN ? AND news_stocks.sentiment_score <= ?)", stockIDs, maxScore).
+			Preload("StockLinks", "stock_id IN ?", stockIDs).
+			Preload("StockLinks.Stock")
+	} else {
+		query = query.Where("news.sentiment_score <= ?", maxScore).Preload("Stock")
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Order("news.published_at DESC").Find(&news).Error
+	return news, err
+}
+
+// Webhook operations
+
+// CreateWebhookSubscription creates a webhook subscription.
+func (r *Repository) CreateWebhookSubscription(ctx context.Context, sub *WebhookSubscription) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(sub).Error; err != nil {
+			return err
+		}
+		// Create leaves a false Enabled to the column default.
+		if !sub.Enabled {
+			return tx.Model(sub).Update("enabled", false).Error
+		}
+		return nil
+	})
+}
+
+// GetWebhookSubscription retrieves a webhook subscription.
+func (r *Repository) GetWebhookSubscription(ctx context.Context, id uint) (*WebhookSubscription, error) {
+	var sub WebhookSubscription
+	err := r.db.WithContext(ctx).First(&sub, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &sub, err
+}
+
+// ListWebhookSubscriptions lists webhook subscriptions, oldest first.
+func (r *Repository) ListWebhookSubscriptions(ctx context.Context, enabledOnly bool) ([]WebhookSubscription, error) {
+	var subs []WebhookSubscription
+	query := r.db.WithContext(ctx)
+	if enabledOnly {
+		query = query.Where("enabled = ?", true)
+	}
+	err := query.Order("id").Find(&subs).Error
+	return subs, err
+}
+
+// UpdateWebhookSubscription updates a webhook subscription.
+func (r *Repository) UpdateWebhookSubscription(ctx context.Context, sub *WebhookSubscription) error {
+	return r.db.WithContext(ctx).Save(sub).Error
+}
+
+// DeleteWebhookSubscription deletes a webhook subscription and its
+// deliveries, reporting whether it existed.
+func (r *Repository) DeleteWebhookSubscription(ctx context.Context, id uint) (bool, error) {
+	var deleted bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("subscription_id = ?", id).Delete(&WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&WebhookSubscription{}, id)
+		deleted = result.RowsAffected > 0
+		return result.Error
+	})
+	return deleted, err
+}
+
+// CreateWebhookDeliveries records deliveries, skipping any event already
+// recorded for a subscription.
+func (r *Repository) CreateWebhookDeliveries(ctx context.Context, deliveries []WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "subscription_id"}, {Name: "event_id"}},
+			DoNothing: true,
+		}).
+		Create(&deliveries).Error
+}
+
+// GetWebhookDelivery retrieves a webhook delivery.
+func (r *Repository) GetWebhookDelivery(ctx context.Context, id uint) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	err := r.db.WithContext(ctx).First(&delivery, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return &delivery, err
+}
+
+// ListDueWebhookDeliveries lists pending deliveries whose next attempt is
+// due, oldest first.
+func (r *Repository) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	query := r.db.WithContext(ctx).
+		Where("status = ?", WebhookDeliveryPending).
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+		Order("id")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ListWebhookDeliveries lists a subscription's deliveries, newest first,
+// optionally with one status.
+func (r *Repository) ListWebhookDeliveries(ctx context.Context, subscriptionID uint, status string, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	query := r.db.WithContext(ctx).Where("subscription_id = ?", subscriptionID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Order("id DESC").Find(&deliveries).Error
+	return deliveries, err
+}
+
+// UpdateWebhookDelivery updates a webhook delivery.
+func (r *Repository) UpdateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	return r.db.WithContext(ctx).Save(delivery).Error
+}
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckRollback(t *testing.T) {
+	tests := []struct {
+		status string
+		want   error
+	}{
+		{UploadStatusCompleted, nil},
+		{UploadStatusRolledBack, ErrUploadRolledBack},
+		{UploadStatusPending, ErrUploadNotCompleted},
+		{UploadStatusProcessing, ErrUploadNotCompleted},
+		{UploadStatusFailed, ErrUploadNotCompleted},
+	}
+
+	for _, tt := range tests {
+		if err := checkRollback(tt.status); !errors.Is(err, tt.want) {
+			t.Errorf("checkRollback(%q) = %v, want %v", tt.status, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Highlight markers used by ts_headline. Control characters cannot appear in
+// stored article text, so they survive HTML escaping unambiguously.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+// newsSearchVector is the weighted document indexed for full-text search:
+// title matches rank above description, which ranks above body content.
+const newsSearchVector = `setweight(to_tsvector('english', coalesce(title, '')), 'A') || ` +
+	`setweight(to_tsvector('english', coalesce(description, '')), 'B') || ` +
+	`setweight(to_tsvector('english', coalesce(content, '')), 'C')`
+
+// migrateNewsSearch adds the generated tsvector column and GIN index used by
+// SearchNews. Both statements are idempotent.
+func migrateNewsSearch(db *gorm.DB) error {
+	statements := []string{
+		`ALTER TABLE news ADD COLUMN IF NOT EXISTS search_vector tsvector ` +
+			`GENERATED ALWAYS AS (` + newsSearchVector + `) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_news_search_vector ON news USING GIN (search_vector)`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to create news search index: %w", err)
+		}
+	}
+	return nil
+}
+
+// NewsSearchParams holds filters for a news search.
+type NewsSearchParams struct {
+	Query     string         // web-search syntax: words, "phrases", OR, -exclude
+	Symbol    string         // only news linked to this stock
+	Sentiment SentimentScore // only news with this sentiment
+	From      time.Time      // published at or after
+	To        time.Time      // published before
+	Limit     int
+	Offset    int
+}
+
+// NewsSearchResult is a news article matched by a search, with its rank and
+// highlighted fragments.
+type NewsSearchResult struct {
+	News
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"title_highlight"`
+	Snippet        string  `json:"snippet"`
+}
+
+// SearchNews runs a ranked full-text search over news titles, descriptions
+// and content. Without a query, matching news is returned newest first.
+func (r *Repository) SearchNews(ctx context.Context, params NewsSearchParams) ([]NewsSearchResult, error) {
+	query := r.db.WithContext(ctx).Model(&News{})
+
+	query = applyNewsSearchFilters(query, params)
+
+	if params.Query != "" {
+		tsQuery := "websearch_to_tsquery('english', @q)"
+		headlineOpts := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=\" … \"",
+			highlightStart, highlightStop)
+		titleOpts := fmt.Sprintf("StartSel=%s, StopSel=%s, HighlightAll=true", highlightStart, highlightStop)
+
+		query = query.
+			Select("news.*, "+
+				"ts_rank_cd(news.search_vector, "+tsQuery+") AS rank, "+
+				"ts_headline('english', news.title, "+tsQuery+", @titleOpts) AS title_highlight, "+
+				"ts_headline('english', coalesce(news.description, '') || ' ' || coalesce(news.content, ''), "+tsQuery+", @headlineOpts) AS snippet",
+				map[string]interface{}{"q": params.Query, "titleOpts": titleOpts, "headlineOpts": headlineOpts}).
+			Where("news.search_vector @@ "+tsQuery, map[string]interface{}{"q": params.Query}).
+			Order("rank DESC").
+			Order("news.published_at DESC")
+	} else {
+		query = query.
+			Select("news.*, 0 AS rank, news.title AS title_highlight, news.description AS snippet").
+			Order("news.published_at DESC")
+	}
+
+	if params.Limit > 0 {
+		query = query.Limit(params.Limit)
+	}
+	if params.Offset > 0 {
+		query = query.Offset(params.Offset)
+	}
+
+	var results []NewsSearchResult
+	if err := query.Scan(&results).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].TitleHighlight = HighlightHTML(results[i].TitleHighlight)
+		results[i].Snippet = HighlightHTML(results[i].Snippet)
+	}
+
+	return results, nil
+}
+
+// applyNewsSearchFilters applies the non-text filters of a news search.
+func applyNewsSearchFilters(query *gorm.DB, params NewsSearchParams) *gorm.DB {
+	if params.Symbol != "" {
+		query = query.Where("news.id IN (?)", query.Session(&gorm.Session{NewDB: true}).
+			Table("news_stocks").
+			Select("news_stocks.news_id").
+			Joins("JOIN stocks ON stocks.id = news_stocks.stock_id").
+			Where("stocks.symbol = ?", strings.ToUpper(params.Symbol)))
+	}
+	if params.Sentiment != "" {
+		query = query.Where("news.sentiment = ?", params.Sentiment)
+	}
+	if !params.From.IsZero() {
+		query = query.Where("news.published_at >= ?", params.From)
+	}
+	if !params.To.IsZero() {
+		query = query.Where("news.published_at < ?", params.To)
+	}
+	return query
+}
+
+// HighlightHTML escapes text for HTML and converts search highlight markers
+// into <mark> elements.
+func HighlightHTML(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, highlightStart, "<mark>")
+	s = strings.ReplaceAll(s, highlightStop, "</mark>")
+	return s
+}
@@ -0,0 +1,186 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/stock-recommender/internal/recommender"
+	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/pkg/config"
+	"github.com/user/stock-recommender/pkg/textutil"
+)
+
+// pollRetryDelay is the wait after a failed getUpdates call.
+const pollRetryDelay = 5 * time.Second
+
+// maxMessageLength is the longest text Telegram accepts in a message.
+const maxMessageLength = 4096
+
+// Bot answers commands sent from allowed chats, fetching them from the Bot
+// API by long polling.
+type Bot struct {
+	client      *Client
+	engine      *recommender.Engine
+	repo        *storage.Repository
+	allowed     map[int64]bool
+	pollTimeout time.Duration
+	watchlist   string
+	publicURL   string
+	wg          sync.WaitGroup
+}
+
+// NewBot creates a bot that answers the chats in
+// cfg.Telegram.AllowedChatIDs.
+func NewBot(client *Client, engine *recommender.Engine, repo *storage.Repository, cfg *config.Config) *Bot {
+	b := &Bot{
+		client:      client,
+		engine:      engine,
+		repo:        repo,
+		allowed:     make(map[int64]bool),
+		pollTimeout: cfg.Telegram.PollTimeout,
+		watchlist:   strings.TrimSpace(cfg.Telegram.Watchlist),
+		publicURL:   strings.TrimRight(cfg.Server.PublicURL, "/"),
+	}
+	for _, id := range cfg.Telegram.AllowedChatIDs {
+		b.allowed[id] = true
+	}
+	if b.pollTimeout <= 0 {
+		b.pollTimeout = 30 * time.Second
+	}
+	if b.watchlist == "" {
+		b.watchlist = "Telegram"
+	}
+	return b
+}
+
+// Start polls for messages and answers them in the background until ctx is
+// cancelled. Each message is handled in its own goroutine, since an
+// analysis can take a while.
+func (b *Bot) Start(ctx context.Context) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.poll(ctx)
+	}()
+}
+
+// Wait waits for the bot to stop after its context is cancelled, or for
+// ctx to expire.
+func (b *Bot) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// poll fetches updates until ctx is cancelled. Updates are acknowledged by
+// asking for those after the last one seen, so a message is handled once
+// even if a reply fails.
+func (b *Bot) poll(ctx context.Context) {
+	var offset int64
+	for ctx.Err() == nil {
+		pollCtx, cancel := context.WithTimeout(ctx, b.pollTimeout+pollRetryDelay*2)
+		updates, err := b.client.GetUpdates(pollCtx, offset, b.pollTimeout)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Printf("Warning: telegram bot failed to get updates: %v\n", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(pollRetryDelay):
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			if u.UpdateID >= offset {
+				offset = u.UpdateID + 1
+			}
+			if u.Message == nil || u.Message.Text == "" {
+				continue
+			}
+			msg := u.Message
+			b.wg.Add(1)
+			go func() {
+				defer b.wg.Done()
+				b.handle(ctx, msg)
+			}()
+		}
+	}
+}
+
+// handle answers a message if it is a command from an allowed chat. Other
+// messages are ignored.
+func (b *Bot) handle(ctx context.Context, msg *Message) {
+	name, args := parseCommand(msg.Text)
+	if name == "" {
+		return
+	}
+	chatID := msg.Chat.ID
+
+	if !b.allowed[chatID] {
+		fmt.Printf("Warning: telegram bot refused /%s from chat %d, which is not in telegram.allowed_chat_ids\n", name, chatID)
+		b.reply(ctx, chatID, fmt.Sprintf("This chat (ID %d) is not allowed to use this bot.", chatID))
+		return
+	}
+
+	// Telegram sends /start when a chat with the bot is opened.
+	if name == "help" || name == "start" {
+		b.reply(ctx, chatID, helpText())
+		return
+	}
+	cmd, ok := findCommand(name)
+	if !ok {
+		b.reply(ctx, chatID, fmt.Sprintf("Unknown command /%s.\n\n%s", name, helpText()))
+		return
+	}
+
+	text, err := cmd.run(b, ctx, chatID, args)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Printf("Warning: telegram bot /%s failed: %v\n", name, err)
+		text = fmt.Sprintf("Sorry, /%s failed: %v", name, err)
+	}
+	b.reply(ctx, chatID, text)
+}
+
+// reply sends a message to a chat, logging a failure.
+func (b *Bot) reply(ctx context.Context, chatID int64, text string) {
+	if text == "" {
+		return
+	}
+	if err := b.client.SendMessage(ctx, strconv.FormatInt(chatID, 10), textutil.Ellipsize(text, maxMessageLength)); err != nil {
+		fmt.Printf("Warning: telegram bot failed to reply to chat %d: %v\n", chatID, err)
+	}
+}
+
+// parseCommand splits a message into a lower-case command name and its
+// arguments. "/analyze@StockChefBot tcs" gives "analyze" and ["tcs"]. A
+// message that is not a command gives an empty name.
+func parseCommand(text string) (string, []string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return "", nil
+	}
+	name := strings.TrimPrefix(fields[0], "/")
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	return strings.ToLower(name), fields[1:]
+}
@@ -0,0 +1,286 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/user/stock-recommender/pkg/config"
+)
+
+const (
+	testToken     = "123456:TEST-TOKEN"
+	allowedChatID = 1001
+	otherChatID   = 2002
+)
+
+// sentMessage is a sendMessage call received by a fakeAPI.
+type sentMessage struct {
+	ChatID string `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+// fakeAPI stands in for the Bot API. getUpdates returns the batches of
+// updates in turn, then waits a little and returns none, as a long poll
+// with nothing new does.
+type fakeAPI struct {
+	t       *testing.T
+	batches [][]Update
+
+	mu      sync.Mutex
+	offsets []int64
+	sent    []sentMessage
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var params map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		f.t.Errorf("invalid parameters: %v", err)
+	}
+
+	var result interface{} = true
+	switch r.URL.Path {
+	case "/bot" + testToken + "/getUpdates":
+		var offset int64
+		json.Unmarshal(params["offset"], &offset)
+		f.mu.Lock()
+		f.offsets = append(f.offsets, offset)
+		n := len(f.offsets)
+		f.mu.Unlock()
+		if n > len(f.batches) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(10 * time.Millisecond):
+			}
+			result = []Update{}
+		} else {
+			result = f.batches[n-1]
+		}
+	case "/bot" + testToken + "/sendMessage":
+		var msg sentMessage
+		json.Unmarshal(params["chat_id"], &msg.ChatID)
+		json.Unmarshal(params["text"], &msg.Text)
+		f.mu.Lock()
+		f.sent = append(f.sent, msg)
+		f.mu.Unlock()
+	default:
+		f.t.Errorf("unexpected call to %s", r.URL.Path)
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+}
+
+// calls returns the offsets getUpdates was called with and the messages
+// sent so far.
+func (f *fakeAPI) calls() ([]int64, []sentMessage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int64(nil), f.offsets...), append([]sentMessage(nil), f.sent...)
+}
+
+// newTestBot returns a bot without an engine or repository that talks to
+// api and answers allowedChatID.
+func newTestBot(t *testing.T, api *fakeAPI) *Bot {
+	t.Helper()
+	api.t = t
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{Telegram: config.TelegramConfig{
+		BotToken:       testToken,
+		APIURL:         server.URL,
+		AllowedChatIDs: []int64{allowedChatID},
+		PollTimeout:    time.Second,
+	}}
+	return NewBot(NewClient(cfg.Telegram, server.Client()), nil, nil, cfg)
+}
+
+// textMessage is a text message sent in a chat.
+func textMessage(chatID int64, text string) *Message {
+	return &Message{Chat: Chat{ID: chatID, Type: "private"}, Text: text}
+}
+
+func TestPollAcknowledgesUpdates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	api := &fakeAPI{
+		batches: [][]Update{
+			{
+				{UpdateID: 41, Message: textMessage(allowedChatID, "/help")},
+				{UpdateID: 42}, // not a message
+			},
+			{
+				{UpdateID: 43, Message: textMessage(allowedChatID, "hello")},
+				{UpdateID: 45, Message: textMessage(allowedChatID, "/start")},
+			},
+		},
+	}
+	b := newTestBot(t, api)
+	b.Start(ctx)
+
+	// Poll until both batches are acknowledged and both commands answered.
+	deadline := time.Now().Add(5 * time.Second)
+	offsets, sent := api.calls()
+	for (len(offsets) < 3 || len(sent) < 2) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		offsets, sent = api.calls()
+	}
+	cancel()
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer waitCancel()
+	if err := b.Wait(waitCtx); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+
+	// Each poll asks for the updates after the last one received.
+	if len(offsets) < 3 || !reflect.DeepEqual(offsets[:3], []int64{0, 43, 46}) {
+		t.Errorf("offsets = %v, want 0, 43, 46", offsets)
+	}
+	for _, offset := range offsets[min(3, len(offsets)):] {
+		if offset != 46 {
+			t.Errorf("polled again from %d, want 46", offset)
+		}
+	}
+	// /help and /start are answered; other text is not.
+	if _, sent := api.calls(); len(sent) != 2 {
+		t.Fatalf("sent %d messages, want 2: %+v", len(sent), sent)
+	}
+	for _, msg := range sent {
+		if msg.ChatID != "1001" || msg.Text != helpText() {
+			t.Errorf("sent %q to %s, want help", msg.Text, msg.ChatID)
+		}
+	}
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *Message
+		want string // reply, or "" for none
+	}{
+		{
+			name: "chat not allowed",
+			msg:  textMessage(otherChatID, "/analyze TCS"),
+			want: "This chat (ID 2002) is not allowed to use this bot.",
+		},
+		{
+			name: "chat not allowed, not a command",
+			msg:  textMessage(otherChatID, "hello"),
+		},
+		{
+			name: "help",
+			msg:  textMessage(allowedChatID, "/help"),
+			want: helpText(),
+		},
+		{
+			name: "addressed to the bot",
+			msg:  textMessage(allowedChatID, "/Analyze@StockChefBot"),
+			want: "Usage: /analyze SYMBOL, e.g. /analyze TCS",
+		},
+		{
+			name: "invalid symbol",
+			msg:  textMessage(allowedChatID, "/analyze $$$"),
+			want: `"$$$" is not a valid symbol.`,
+		},
+		{
+			name: "unknown command",
+			msg:  textMessage(allowedChatID, "/sell TCS"),
+			want: "Unknown command /sell.\n\n" + helpText(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeAPI{}
+			b := newTestBot(t, api)
+
+			// The bot has no engine, so a refused command must not reach it.
+			b.handle(context.Background(), tt.msg)
+
+			if tt.want == "" {
+				if len(api.sent) != 0 {
+					t.Errorf("sent %+v, want no reply", api.sent)
+				}
+				return
+			}
+			if len(api.sent) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(api.sent))
+			}
+			want := sentMessage{ChatID: strconv.FormatInt(tt.msg.Chat.ID, 10), Text: tt.want}
+			if api.sent[0] != want {
+				t.Errorf("sent %+v, want %+v", api.sent[0], want)
+			}
+		})
+	}
+}
+
+func TestReplyTruncates(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"short", "TCS: BUY", "TCS: BUY"},
+		{"at the limit", strings.Repeat("a", maxMessageLength), strings.Repeat("a", maxMessageLength)},
+		{"over the limit", strings.Repeat("₹", maxMessageLength+1), strings.Repeat("₹", maxMessageLength-1) + "…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeAPI{}
+			b := newTestBot(t, api)
+			b.reply(context.Background(), allowedChatID, tt.text)
+
+			if len(api.sent) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(api.sent))
+			}
+			got := api.sent[0].Text
+			if got != tt.want {
+				t.Errorf("sent %d characters ending %q, want %d ending %q",
+					utf8.RuneCountInString(got), got[max(0, len(got)-8):], utf8.RuneCountInString(tt.want), tt.want[max(0, len(tt.want)-8):])
+			}
+		})
+	}
+
+	// Nothing is sent for an empty reply.
+	api := &fakeAPI{}
+	newTestBot(t, api).reply(context.Background(), allowedChatID, "")
+	if len(api.sent) != 0 {
+		t.Errorf("sent %+v for an empty reply", api.sent)
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		text     string
+		wantName string
+		wantArgs []string
+	}{
+		{"/analyze TCS", "analyze", []string{"TCS"}},
+		{"/analyze@StockChefBot tcs", "analyze", []string{"tcs"}},
+		{"/WATCH@StockChefBot add INFY  TCS", "watch", []string{"add", "INFY", "TCS"}},
+		{"/picks@StockChefBot", "picks", []string{}},
+		{"  /help  ", "help", []string{}},
+		{"analyze TCS", "", nil},
+		{"", "", nil},
+	}
+
+	for _, tt := range tests {
+		name, args := parseCommand(tt.text)
+		if name != tt.wantName || !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("parseCommand(%q) = %q, %q, want %q, %q", tt.text, name, args, tt.wantName, tt.wantArgs)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Package telegram provides a minimal Telegram Bot API client.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/user/stock-recommender/pkg/config"
+)
+
+// Client calls the Telegram Bot API. The API URL is configurable so that a
+// local stand-in server can be used instead of api.telegram.org.
+type Client struct {
+	apiURL string
+	token  string
+	http   *http.Client
+}
+
+// NewClient creates a Bot API client. If httpClient is nil,
+// http.DefaultClient is used.
+func NewClient(cfg config.TelegramConfig, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	apiURL := strings.TrimRight(cfg.APIURL, "/")
+	if apiURL == "" {
+		apiURL = "https://api.telegram.org"
+	}
+	return &Client{
+		apiURL: apiURL,
+		token:  cfg.BotToken,
+		http:   httpClient,
+	}
+}
+
+// response is the envelope of every Bot API response.
+type response struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+}
+
+// Update is an incoming update. Only messages are requested.
+type Update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *Message `json:"message"`
+}
+
+// Message is a message sent to the bot.
+type Message struct {
+	MessageID int64  `json:"message_id"`
+	From      *User  `json:"from"`
+	Chat      Chat   `json:"chat"`
+	Date      int64  `json:"date"`
+	Text      string `json:"text"`
+}
+
+// User is the sender of a message.
+type User struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// Chat is the chat a message was sent in.
+type Chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"` // private, group, supergroup or channel
+}
+
+// GetUpdates long-polls for updates after offset, waiting up to timeout
+// for one to arrive.
+func (c *Client) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]Update, error) {
+	var updates []Update
+	err := c.call(ctx, "getUpdates", map[string]interface{}{
+		"offset":          offset,
+		"timeout":         int(timeout.Seconds()),
+		"allowed_updates": []string{"message"},
+	}, &updates)
+	return updates, err
+}
+
+// SendMessage sends a plain text message to a chat, given by its ID or an
+// @channel username.
+func (c *Client) SendMessage(ctx context.Context, chatID, text string) error {
+	return c.call(ctx, "sendMessage", map[string]interface{}{
+		"chat_id":                  chatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	}, nil)
+}
+
+// call invokes a Bot API method with JSON parameters and decodes its result
+// into result, if not nil.
+func (c *Client) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/bot%s/%s", c.apiURL, c.token, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// The URL holds the bot token; keep it out of the error.
+		return fmt.Errorf("telegram %s failed: %w", method, unwrapURLError(err))
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("telegram %s failed: %w", method, err)
+	}
+
+	var r response
+	if err := json.Unmarshal(data, &r); err != nil {
+		return fmt.Errorf("telegram %s failed: HTTP %d", method, resp.StatusCode)
+	}
+	if !r.OK {
+		return fmt.Errorf("telegram %s failed: %d %s", method, r.ErrorCode, r.Description)
+	}
+	if result != nil {
+		if err := json.Unmarshal(r.Result, result); err != nil {
+			return fmt.Errorf("telegram %s returned an unexpected result: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// unwrapURLError drops the *url.Error wrapper, which quotes the request
+// URL.
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
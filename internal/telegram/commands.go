@@ -0,0 +1,394 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/user/stock-recommender/internal/recommender"
+	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/pkg/textutil"
+)
+
+// symbolPattern matches an NSE or BSE trading symbol.
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9&\-.]{0,19}$`)
+
+// Limits on what a reply lists.
+const (
+	newsLimit      = 5
+	picksLimit     = 10
+	reasoningLimit = 600
+)
+
+// command is a bot command. run returns the reply; it may send progress
+// messages of its own first.
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(b *Bot, ctx context.Context, chatID int64, args []string) (string, error)
+}
+
+// commands lists the bot's commands in the order help shows them.
+var commands = []command{
+	{"analyze", "/analyze SYMBOL", "Analyze a stock now, e.g. /analyze TCS", (*Bot).analyze},
+	{"picks", "/picks", "Today's daily picks", (*Bot).picks},
+	{"news", "/news [SYMBOL]", "Latest news for a stock, or for the market", (*Bot).news},
+	{"watch", "/watch [add|remove SYMBOL...]", "Show or change the Telegram watchlist", (*Bot).watch},
+}
+
+// findCommand returns the command with a name.
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// helpText lists the commands, in reply to /help, /start or an unknown
+// command.
+func helpText() string {
+	var sb strings.Builder
+	sb.WriteString("StockChef commands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(&sb, "%s - %s\n", cmd.usage, cmd.description)
+	}
+	sb.WriteString("/help - Show this message")
+	return sb.String()
+}
+
+// analyze answers /analyze SYMBOL with a summary of a fresh analysis.
+func (b *Bot) analyze(ctx context.Context, chatID int64, args []string) (string, error) {
+	if len(args) != 1 {
+		return "Usage: /analyze SYMBOL, e.g. /analyze TCS", nil
+	}
+	symbol, ok := parseSymbol(args[0])
+	if !ok {
+		return fmt.Sprintf("%q is not a valid symbol.", args[0]), nil
+	}
+
+	b.reply(ctx, chatID, fmt.Sprintf("Analyzing %s, this can take a minute...", symbol))
+	result, err := b.engine.AnalyzeStock(ctx, symbol)
+	if err != nil {
+		return "", err
+	}
+	return b.formatAnalysis(result), nil
+}
+
+// formatAnalysis summarises an analysis: the call, its levels and the
+// reasoning, with a link to the recommendation page.
+func (b *Bot) formatAnalysis(result *recommender.AnalysisResult) string {
+	rec := result.Recommendation
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%s", result.Stock.Symbol)
+	if result.Stock.Name != "" && result.Stock.Name != result.Stock.Symbol {
+		fmt.Fprintf(&sb, " · %s", result.Stock.Name)
+	}
+	fmt.Fprintf(&sb, "\n%s %s · confidence %.0f%%\n\n", actionEmoji(rec.Action), rec.Action, rec.ConfidenceScore)
+	fmt.Fprintf(&sb, "Entry: ₹%.2f\n", rec.EntryPrice)
+	fmt.Fprintf(&sb, "Target: ₹%.2f%s\n", rec.TargetPrice, percentFrom(rec.EntryPrice, rec.TargetPrice))
+	fmt.Fprintf(&sb, "Stop-loss: ₹%.2f%s\n", rec.StopLoss, percentFrom(rec.EntryPrice, rec.StopLoss))
+	fmt.Fprintf(&sb, "Horizon: %s · Risk: %s\n", humanize(rec.TimeHorizon), humanize(rec.RiskLevel))
+	if result.DataStale {
+		fmt.Fprintf(&sb, "⚠ Fundamentals are %s old\n", formatAge(result.DataAge))
+	}
+
+	if reasoning := strings.TrimSpace(rec.Reasoning); reasoning != "" {
+		fmt.Fprintf(&sb, "\n%s\n", textutil.Ellipsize(reasoning, reasoningLimit))
+	}
+	if rec.ID != 0 {
+		fmt.Fprintf(&sb, "\n%s/recommendation/%d", b.publicURL, rec.ID)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// picks answers /picks with the cached daily picks, generating them if
+// there are none.
+func (b *Bot) picks(ctx context.Context, chatID int64, args []string) (string, error) {
+	result, found := b.engine.GetCachedDailyPicks(ctx)
+	if !found {
+		b.reply(ctx, chatID, "Generating today's picks, this can take a few minutes...")
+		var err error
+		if result, err = b.engine.GenerateDailyPicks(ctx); err != nil {
+			return "", err
+		}
+	}
+	if len(result.Picks) == 0 {
+		return fmt.Sprintf("No picks today (%d stocks analyzed).", result.TotalAnalyzed), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Daily picks · %s · market %s\n\n",
+		result.GeneratedAt.In(b.engine.Calendar().Location()).Format("02 Jan 15:04"), strings.ToLower(result.MarketSentiment))
+	for i, p := range result.Picks {
+		if i == picksLimit {
+			break
+		}
+		fmt.Fprintf(&sb, "%d. %s %s %s · %.0f%%\n   ₹%.2f → ₹%.2f, SL ₹%.2f\n",
+			p.Rank, actionEmoji(storage.Action(p.Action)), p.Symbol, p.Action, p.ConfidenceScore,
+			p.EntryPrice, p.TargetPrice, p.StopLoss)
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+// news answers /news SYMBOL with a stock's latest news, or /news with the
+// market's.
+func (b *Bot) news(ctx context.Context, chatID int64, args []string) (string, error) {
+	if len(args) > 1 {
+		return "Usage: /news [SYMBOL], e.g. /news INFY", nil
+	}
+
+	if len(args) == 0 {
+		news, err := b.engine.GetRecentNews(ctx, newsLimit, time.Now().Add(-24*time.Hour))
+		if err != nil {
+			return "", err
+		}
+		if len(news) == 0 {
+			return "No news in the last 24 hours.", nil
+		}
+		var sb strings.Builder
+		sb.WriteString("Latest market news\n")
+		for _, n := range news {
+			writeNews(&sb, n, n.Sentiment)
+		}
+		return strings.TrimRight(sb.String(), "\n"), nil
+	}
+
+	symbol, ok := parseSymbol(args[0])
+	if !ok {
+		return fmt.Sprintf("%q is not a valid symbol.", args[0]), nil
+	}
+	stock, err := b.repo.GetStockBySymbol(ctx, symbol)
+	if err != nil {
+		return "", err
+	}
+	if stock == nil {
+		return fmt.Sprintf("%s is not tracked yet. Use /analyze %s to fetch its data and news.", symbol, symbol), nil
+	}
+	news, err := b.repo.ListNewsByStockID(ctx, stock.ID, newsLimit)
+	if err != nil {
+		return "", err
+	}
+	if len(news) == 0 {
+		return fmt.Sprintf("No news for %s yet.", symbol), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Latest news for %s\n", symbol)
+	for _, n := range news {
+		writeNews(&sb, n.News, n.LinkSentiment)
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
+
+// writeNews writes a news item of a list.
+func writeNews(sb *strings.Builder, n storage.News, sentiment storage.SentimentScore) {
+	fmt.Fprintf(sb, "\n• %s\n", n.Title)
+	var meta []string
+	if n.Source != "" {
+		meta = append(meta, n.Source)
+	}
+	if !n.PublishedAt.IsZero() {
+		meta = append(meta, formatAge(time.Since(n.PublishedAt))+" ago")
+	}
+	if sentiment != "" {
+		meta = append(meta, strings.ToLower(string(sentiment)))
+	}
+	if len(meta) > 0 {
+		fmt.Fprintf(sb, "  %s\n", strings.Join(meta, " · "))
+	}
+	if n.URL != "" {
+		fmt.Fprintf(sb, "  %s\n", n.URL)
+	}
+}
+
+// watch answers /watch, /watch add SYMBOL... and /watch remove SYMBOL...
+// against the watchlist named by telegram.watchlist, creating it on first
+// use.
+func (b *Bot) watch(ctx context.Context, chatID int64, args []string) (string, error) {
+	usage := "Usage: /watch, /watch add SYMBOL... or /watch remove SYMBOL..."
+	sub := "list"
+	if len(args) > 0 {
+		sub = strings.ToLower(args[0])
+		args = args[1:]
+	}
+
+	watchlist, err := b.telegramWatchlist(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	switch sub {
+	case "list":
+		return b.formatWatchlist(watchlist), nil
+	case "add":
+		if len(args) == 0 {
+			return usage, nil
+		}
+		return b.watchAdd(ctx, watchlist, args)
+	case "remove", "rm":
+		if len(args) == 0 {
+			return usage, nil
+		}
+		return b.watchRemove(ctx, watchlist, args)
+	default:
+		return usage, nil
+	}
+}
+
+// telegramWatchlist returns the bot's watchlist, creating it if needed.
+func (b *Bot) telegramWatchlist(ctx context.Context) (*storage.Watchlist, error) {
+	watchlist, err := b.repo.GetWatchlistByName(ctx, b.watchlist)
+	if err != nil || watchlist != nil {
+		return watchlist, err
+	}
+	watchlist = &storage.Watchlist{
+		Name:        b.watchlist,
+		Description: "Stocks added from Telegram",
+		AutoAnalyze: true,
+	}
+	if err := b.repo.CreateWatchlist(ctx, watchlist); err != nil {
+		return nil, fmt.Errorf("failed to create watchlist %s: %w", b.watchlist, err)
+	}
+	return watchlist, nil
+}
+
+// watchAdd adds stocks to the watchlist, tracking any that are new.
+func (b *Bot) watchAdd(ctx context.Context, watchlist *storage.Watchlist, args []string) (string, error) {
+	var items []storage.WatchlistItem
+	var symbols []string
+	for _, arg := range args {
+		symbol, ok := parseSymbol(arg)
+		if !ok {
+			return fmt.Sprintf("%q is not a valid symbol.", arg), nil
+		}
+		stock, err := b.repo.GetOrCreateStock(ctx, symbol, symbol, "NSE")
+		if err != nil {
+			return "", fmt.Errorf("failed to save stock %s: %w", symbol, err)
+		}
+		items = append(items, storage.WatchlistItem{
+			WatchlistID: watchlist.ID,
+			StockID:     stock.ID,
+			Symbol:      stock.Symbol,
+		})
+		symbols = append(symbols, stock.Symbol)
+	}
+
+	added, err := b.repo.AddWatchlistItems(ctx, items)
+	if err != nil {
+		return "", err
+	}
+	if added == 0 {
+		return fmt.Sprintf("%s already on %s.", strings.Join(symbols, ", "), watchlist.Name), nil
+	}
+	return fmt.Sprintf("Added %s to %s (%d new). It is re-analyzed by the watchlist job during market days.",
+		strings.Join(symbols, ", "), watchlist.Name, added), nil
+}
+
+// watchRemove removes stocks from the watchlist.
+func (b *Bot) watchRemove(ctx context.Context, watchlist *storage.Watchlist, args []string) (string, error) {
+	var removed, missing []string
+	for _, arg := range args {
+		symbol, ok := parseSymbol(arg)
+		if !ok {
+			return fmt.Sprintf("%q is not a valid symbol.", arg), nil
+		}
+		stock, err := b.repo.GetStockBySymbol(ctx, symbol)
+		if err != nil {
+			return "", err
+		}
+		ok = false
+		if stock != nil {
+			if ok, err = b.repo.RemoveWatchlistItem(ctx, watchlist.ID, stock.ID); err != nil {
+				return "", err
+			}
+		}
+		if ok {
+			removed = append(removed, symbol)
+		} else {
+			missing = append(missing, symbol)
+		}
+	}
+
+	var parts []string
+	if len(removed) > 0 {
+		parts = append(parts, fmt.Sprintf("Removed %s from %s.", strings.Join(removed, ", "), watchlist.Name))
+	}
+	if len(missing) > 0 {
+		parts = append(parts, fmt.Sprintf("%s not on %s.", strings.Join(missing, ", "), watchlist.Name))
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// formatWatchlist lists the watchlist's stocks with their latest action.
+func (b *Bot) formatWatchlist(watchlist *storage.Watchlist) string {
+	if len(watchlist.Items) == 0 {
+		return fmt.Sprintf("%s is empty. Add stocks with /watch add SYMBOL.", watchlist.Name)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s (%d)\n", watchlist.Name, len(watchlist.Items))
+	for _, item := range watchlist.Items {
+		fmt.Fprintf(&sb, "\n%s", item.Symbol)
+		if item.LastAction != "" {
+			fmt.Fprintf(&sb, " · %s %s", actionEmoji(item.LastAction), item.LastAction)
+			if item.ActionChanged && item.PreviousAction != "" {
+				fmt.Fprintf(&sb, " (was %s)", item.PreviousAction)
+			}
+		} else {
+			sb.WriteString(" · not analyzed yet")
+		}
+	}
+	return sb.String()
+}
+
+// parseSymbol normalizes and validates a trading symbol.
+func parseSymbol(s string) (string, bool) {
+	symbol := strings.ToUpper(strings.TrimSpace(s))
+	return symbol, symbolPattern.MatchString(symbol)
+}
+
+// actionEmoji marks an action in replies.
+func actionEmoji(action storage.Action) string {
+	switch action {
+	case storage.ActionBuy:
+		return "🟢"
+	case storage.ActionSell:
+		return "🔴"
+	default:
+		return "🟡"
+	}
+}
+
+// percentFrom formats the change from base to price, e.g. " (+12.5%)".
+func percentFrom(base, price float64) string {
+	if base == 0 || price == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%+.1f%%)", (price-base)/base*100)
+}
+
+// humanize turns "medium_term" into "medium term", and empty into "n/a".
+func humanize(s string) string {
+	if s == "" {
+		return "n/a"
+	}
+	return strings.ReplaceAll(s, "_", " ")
+}
+
+// formatAge formats a duration as a rough age: minutes, hours or days.
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%d days", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,173 @@
+package webhooks
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	const secret = "whsec_test"
+	sent := time.Unix(1792339200, 0)
+	payload := []byte(`{"id":"evt_1","event":"recommendation.created","data":{"symbol":"TCS"}}`)
+	header := Sign(secret, sent, payload)
+	sig := strings.TrimPrefix(header, "t=1792339200,v1=")
+
+	tests := []struct {
+		name      string
+		secret    string
+		header    string
+		payload   []byte
+		tolerance time.Duration
+		now       time.Time
+		wantErr   bool
+	}{
+		{
+			name:      "round trip",
+			secret:    secret,
+			header:    header,
+			payload:   payload,
+			tolerance: 5 * time.Minute,
+			now:       sent.Add(time.Second),
+		},
+		{
+			name:      "within the tolerance",
+			secret:    secret,
+			header:    header,
+			payload:   payload,
+			tolerance: 5 * time.Minute,
+			now:       sent.Add(5 * time.Minute),
+		},
+		{
+			name:    "no tolerance",
+			secret:  secret,
+			header:  header,
+			payload: payload,
+			now:     sent.Add(30 * 24 * time.Hour),
+		},
+		{
+			// Receivers accept any of several v1 signatures, e.g. while a
+			// secret is being rotated.
+			name:      "one of several signatures",
+			secret:    secret,
+			header:    "t=1792339200, v1=" + strings.Repeat("0", 64) + ", v1=" + sig,
+			payload:   payload,
+			tolerance: 5 * time.Minute,
+			now:       sent,
+		},
+		{
+			name:      "stale timestamp",
+			secret:    secret,
+			header:    header,
+			payload:   payload,
+			tolerance: 5 * time.Minute,
+			now:       sent.Add(5*time.Minute + time.Second),
+			wantErr:   true,
+		},
+		{
+			name:      "timestamp in the future",
+			secret:    secret,
+			header:    header,
+			payload:   payload,
+			tolerance: 5 * time.Minute,
+			now:       sent.Add(-5*time.Minute - time.Second),
+			wantErr:   true,
+		},
+		{
+			name:      "tampered body",
+			secret:    secret,
+			header:    header,
+			payload:   []byte(`{"id":"evt_1","event":"recommendation.created","data":{"symbol":"INFY"}}`),
+			tolerance: 5 * time.Minute,
+			now:       sent,
+			wantErr:   true,
+		},
+		{
+			name:      "wrong secret",
+			secret:    "whsec_other",
+			header:    header,
+			payload:   payload,
+			tolerance: 5 * time.Minute,
+			now:       sent,
+			wantErr:   true,
+		},
+		{
+			// The signature of another second's delivery of the same body.
+			name:      "replayed with another timestamp",
+			secret:    secret,
+			header:    "t=1792339201,v1=" + sig,
+			payload:   payload,
+			tolerance: 5 * time.Minute,
+			now:       sent,
+			wantErr:   true,
+		},
+		{
+			name:      "empty header",
+			secret:    secret,
+			payload:   payload,
+			tolerance: 5 * time.Minute,
+			now:       sent,
+			wantErr:   true,
+		},
+		{
+			name:      "no signature",
+			secret:    secret,
+			header:    "t=1792339200",
+			payload:   payload,
+			tolerance: 5 * time.Minute,
+			now:       sent,
+			wantErr:   true,
+		},
+		{
+			name:      "no timestamp",
+			secret:    secret,
+			header:    "v1=" + sig,
+			payload:   payload,
+			tolerance: 5 * time.Minute,
+			now:       sent,
+			wantErr:   true,
+		},
+		{
+			name:      "malformed timestamp",
+			secret:    secret,
+			header:    "t=yesterday,v1=" + sig,
+			payload:   payload,
+			tolerance: 5 * time.Minute,
+			now:       sent,
+			wantErr:   true,
+		},
+		{
+			name:      "not key=value pairs",
+			secret:    secret,
+			header:    "sha256 " + sig,
+			payload:   payload,
+			tolerance: 5 * time.Minute,
+			now:       sent,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.header, tt.payload, tt.tolerance, tt.now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSignature) {
+					t.Errorf("Verify = %v, want %v", err, ErrInvalidSignature)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	// The HMAC-SHA256 of "1792339200.{}" with the key "secret".
+	want := "t=1792339200,v1=d48e0e5e09ef3765e2d42f87993520197c90a3241efb307e6f1770095f86ced8"
+	if got := Sign("secret", time.Unix(1792339200, 0), []byte("{}")); got != want {
+		t.Errorf("Sign = %q, want %q", got, want)
+	}
+}
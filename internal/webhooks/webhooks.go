@@ -0,0 +1,485 @@
+// Package webhooks delivers the recommendation engine's events to
+// subscribed URLs as signed JSON payloads. Every delivery is recorded;
+// failed ones are retried with backoff by the webhook_retries job and can
+// be redelivered by hand.
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/stock-recommender/internal/recommender"
+	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/pkg/config"
+	"github.com/user/stock-recommender/pkg/textutil"
+)
+
+// EventPing is sent by Test to check a subscription.
+const EventPing = "ping"
+
+// AllEvents subscribes to every event type.
+const AllEvents = "*"
+
+// Headers set on every delivery, besides SignatureHeader.
+const (
+	EventHeader   = "X-StockChef-Event"
+	EventIDHeader = "X-StockChef-Event-ID"
+	userAgent     = "StockChef-Webhooks/1.0"
+)
+
+// ErrInvalidSubscription is returned for a subscription that cannot be
+// saved.
+var ErrInvalidSubscription = errors.New("invalid webhook subscription")
+
+// deliveryBatchSize limits how many due deliveries are retried in a run.
+const deliveryBatchSize = 100
+
+// maxBackoff caps the wait between delivery attempts.
+const maxBackoff = 24 * time.Hour
+
+// eventDescriptions describes the event types a subscription can select.
+var eventDescriptions = map[string]string{
+	recommender.EventRecommendationCreated:    "A stock was analyzed and a new recommendation saved; data is the recommendation",
+	recommender.EventRecommendationSuperseded: "An active recommendation was replaced by a newer one for the same stock",
+	recommender.EventDailyPicksCompleted:      "A daily picks run finished; data is the picks with the filter used",
+	recommender.EventNewsIngested:             "New news articles were stored",
+}
+
+// EventTypes returns the event types a subscription can select, sorted.
+func EventTypes() []string {
+	types := make([]string, 0, len(eventDescriptions))
+	for t := range eventDescriptions {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// EventDescriptions returns a description of each event type.
+func EventDescriptions() map[string]string {
+	return eventDescriptions
+}
+
+// Envelope is the JSON body of every delivery.
+type Envelope struct {
+	ID        string      `json:"id"` // the same for every subscription and redelivery of an event
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// RetryResult summarises a retry run.
+type RetryResult struct {
+	Delivered int `json:"delivered"`
+	Retrying  int `json:"retrying"`
+	Failed    int `json:"failed"`
+}
+
+// store keeps webhook subscriptions and deliveries. *storage.Repository is
+// the store used in the server; tests can stand in one kept in memory.
+type store interface {
+	ListWebhookSubscriptions(ctx context.Context, enabledOnly bool) ([]storage.WebhookSubscription, error)
+	CreateWebhookDeliveries(ctx context.Context, deliveries []storage.WebhookDelivery) error
+	ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]storage.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery *storage.WebhookDelivery) error
+}
+
+// Dispatcher records and sends webhook deliveries. It implements
+// recommender.EventPublisher.
+type Dispatcher struct {
+	repo   store
+	client *http.Client
+	config config.WebhooksConfig
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher creates a webhook dispatcher.
+func NewDispatcher(repo *storage.Repository, cfg *config.Config) *Dispatcher {
+	d := &Dispatcher{
+		repo:   repo,
+		client: &http.Client{},
+		config: cfg.Webhooks,
+	}
+	if d.config.MaxAttempts <= 0 {
+		d.config.MaxAttempts = 1
+	}
+	if d.config.Timeout <= 0 {
+		d.config.Timeout = 10 * time.Second
+	}
+	return d
+}
+
+// ValidateSubscription checks a subscription before it is saved,
+// normalizing its event list and generating a secret if it has none.
+func (d *Dispatcher) ValidateSubscription(sub *storage.WebhookSubscription) error {
+	sub.Name = strings.TrimSpace(sub.Name)
+	sub.URL = strings.TrimSpace(sub.URL)
+	sub.Secret = strings.TrimSpace(sub.Secret)
+
+	if sub.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSubscription)
+	}
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidSubscription)
+	}
+
+	var events []string
+	seen := make(map[string]bool)
+	for _, e := range strings.Split(sub.Events, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" || seen[e] {
+			continue
+		}
+		if _, ok := eventDescriptions[e]; !ok && e != AllEvents {
+			return fmt.Errorf("%w: unknown event %q (one of %s, or %s)", ErrInvalidSubscription, e, strings.Join(EventTypes(), ", "), AllEvents)
+		}
+		seen[e] = true
+		events = append(events, e)
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("%w: at least one event is required", ErrInvalidSubscription)
+	}
+	if seen[AllEvents] {
+		events = []string{AllEvents}
+	}
+	sub.Events = strings.Join(events, ",")
+
+	if sub.Secret == "" {
+		secret, err := NewSecret()
+		if err != nil {
+			return err
+		}
+		sub.Secret = secret
+	}
+	return nil
+}
+
+// NewSecret returns a random signing secret.
+func NewSecret() (string, error) {
+	token, err := randomHex(24)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + token, nil
+}
+
+// Subscribed reports whether a subscription receives an event.
+func Subscribed(sub *storage.WebhookSubscription, event string) bool {
+	for _, e := range strings.Split(sub.Events, ",") {
+		e = strings.TrimSpace(e)
+		if e == AllEvents || e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish records a delivery of an event to each enabled subscription that
+// receives it and sends them in the background. Deliveries that fail are
+// left for RetryDue. Errors are logged, not returned, so that a webhook
+// problem never fails the analysis that raised the event.
+func (d *Dispatcher) Publish(ctx context.Context, event string, data interface{}) {
+	// The event outlives the request that raised it.
+	ctx = context.WithoutCancel(ctx)
+
+	subs, err := d.repo.ListWebhookSubscriptions(ctx, true)
+	if err != nil {
+		fmt.Printf("Warning: failed to list webhook subscriptions for %s: %v\n", event, err)
+		return
+	}
+	byID := make(map[uint]*storage.WebhookSubscription)
+	for i := range subs {
+		if Subscribed(&subs[i], event) {
+			byID[subs[i].ID] = &subs[i]
+		}
+	}
+	if len(byID) == 0 {
+		return
+	}
+
+	envelope, err := newEnvelope(event, data)
+	if err != nil {
+		fmt.Printf("Warning: failed to encode %s webhook: %v\n", event, err)
+		return
+	}
+
+	// Until the background send records an outcome, the deliveries are
+	// leased so that the retry job does not send them as well.
+	lease := time.Now().Add(2*d.config.Timeout + time.Minute)
+	deliveries := make([]storage.WebhookDelivery, 0, len(byID))
+	for _, sub := range subs {
+		if byID[sub.ID] != nil {
+			delivery := envelope.delivery(sub.ID)
+			delivery.NextAttemptAt = &lease
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	if err := d.repo.CreateWebhookDeliveries(ctx, deliveries); err != nil {
+		fmt.Printf("Warning: failed to record %s webhooks: %v\n", event, err)
+		return
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		for i := range deliveries {
+			delivery := &deliveries[i]
+			if delivery.ID == 0 {
+				continue
+			}
+			if err := d.attempt(ctx, byID[delivery.SubscriptionID], delivery); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		}
+	}()
+}
+
+// Wait waits for background deliveries to finish or for ctx to expire.
+func (d *Dispatcher) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RetryDue attempts the pending deliveries that are due. Deliveries of a
+// subscription that was disabled are given up.
+func (d *Dispatcher) RetryDue(ctx context.Context) (*RetryResult, error) {
+	result := &RetryResult{}
+	deliveries, err := d.repo.ListDueWebhookDeliveries(ctx, time.Now(), deliveryBatchSize)
+	if err != nil {
+		return result, fmt.Errorf("failed to list due webhooks: %w", err)
+	}
+	if len(deliveries) == 0 {
+		return result, nil
+	}
+
+	subs, err := d.repo.ListWebhookSubscriptions(ctx, false)
+	if err != nil {
+		return result, fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+	byID := make(map[uint]*storage.WebhookSubscription, len(subs))
+	for i := range subs {
+		byID[subs[i].ID] = &subs[i]
+	}
+
+	for i := range deliveries {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		delivery := &deliveries[i]
+		if err := d.attempt(ctx, byID[delivery.SubscriptionID], delivery); err != nil {
+			return result, err
+		}
+		switch delivery.Status {
+		case storage.WebhookDeliveryDelivered:
+			result.Delivered++
+		case storage.WebhookDeliveryFailed:
+			result.Failed++
+		default:
+			result.Retrying++
+		}
+	}
+	return result, nil
+}
+
+// Test sends a ping event to a subscription now, whether or not it is
+// enabled, and records it with the subscription's deliveries. A failed
+// test is not retried.
+func (d *Dispatcher) Test(ctx context.Context, sub *storage.WebhookSubscription) (*storage.WebhookDelivery, error) {
+	envelope, err := newEnvelope(EventPing, map[string]interface{}{
+		"subscription_id": sub.ID,
+		"name":            sub.Name,
+		"events":          strings.Split(sub.Events, ","),
+		"message":         "This is a test delivery. Real events will be signed the same way.",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	saved := []storage.WebhookDelivery{envelope.delivery(sub.ID)}
+	if err := d.repo.CreateWebhookDeliveries(ctx, saved); err != nil {
+		return nil, err
+	}
+	delivery := &saved[0]
+
+	d.record(delivery, d.send(ctx, sub, delivery), true)
+	if err := d.repo.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// Redeliver sends a delivery again now, restarting its attempts. The body
+// and event ID are unchanged, so receivers can recognise a repeat; the
+// signature is made afresh.
+func (d *Dispatcher) Redeliver(ctx context.Context, sub *storage.WebhookSubscription, delivery *storage.WebhookDelivery) error {
+	delivery.Status = storage.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = nil
+	return d.attempt(ctx, sub, delivery)
+}
+
+// attempt sends a delivery once and records the outcome: delivered,
+// pending with the next attempt backed off, or failed once attempts run
+// out. Deliveries to a deleted or disabled subscription are given up.
+// Only saving the outcome can fail.
+func (d *Dispatcher) attempt(ctx context.Context, sub *storage.WebhookSubscription, delivery *storage.WebhookDelivery) error {
+	var sendErr error
+	final := true
+	switch {
+	case sub == nil:
+		sendErr = errors.New("subscription no longer exists")
+	case !sub.Enabled:
+		sendErr = errors.New("subscription is disabled")
+	default:
+		sendErr = d.send(ctx, sub, delivery)
+		final = false
+	}
+	d.record(delivery, sendErr, final)
+
+	if err := d.repo.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		return fmt.Errorf("failed to save webhook delivery %d: %w", delivery.ID, err)
+	}
+	return nil
+}
+
+// record applies the outcome of an attempt to a delivery. With final set,
+// a failure is not retried.
+func (d *Dispatcher) record(delivery *storage.WebhookDelivery, sendErr error, final bool) {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.NextAttemptAt = nil
+	if sendErr == nil {
+		delivery.Status = storage.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = textutil.Truncate(sendErr.Error(), 500)
+	if final || delivery.Attempts >= d.config.MaxAttempts {
+		delivery.Status = storage.WebhookDeliveryFailed
+		return
+	}
+	delivery.Status = storage.WebhookDeliveryPending
+	next := now.Add(d.backoff(delivery.Attempts))
+	delivery.NextAttemptAt = &next
+}
+
+// send POSTs a delivery's payload to its subscription, recording the
+// response on the delivery. Any status other than 2xx is a failure.
+func (d *Dispatcher) send(ctx context.Context, sub *storage.WebhookSubscription, delivery *storage.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(ctx, d.config.Timeout)
+	defer cancel()
+
+	payload := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(EventIDHeader, delivery.EventID)
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, time.Now(), payload))
+
+	start := time.Now()
+	resp, err := d.client.Do(req)
+	delivery.DurationMS = time.Since(start).Milliseconds()
+	delivery.ResponseStatus = 0
+	delivery.ResponseBody = ""
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	delivery.ResponseStatus = resp.StatusCode
+	delivery.ResponseBody = textutil.Truncate(string(body), 500)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.config.RetryBackoff
+	if wait <= 0 {
+		wait = 30 * time.Second
+	}
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+// encodedEnvelope is an envelope with its encoded body.
+type encodedEnvelope struct {
+	Envelope
+	payload string
+}
+
+// newEnvelope wraps an event's data in an envelope with a new event ID.
+func newEnvelope(event string, data interface{}) (*encodedEnvelope, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	e := &encodedEnvelope{Envelope: Envelope{
+		ID:        "evt_" + id,
+		Event:     event,
+		CreatedAt: time.Now(),
+		Data:      data,
+	}}
+	payload, err := json.Marshal(e.Envelope)
+	if err != nil {
+		return nil, err
+	}
+	e.payload = string(payload)
+	return e, nil
+}
+
+// delivery makes a pending delivery of the envelope to a subscription.
+func (e *encodedEnvelope) delivery(subscriptionID uint) storage.WebhookDelivery {
+	return storage.WebhookDelivery{
+		SubscriptionID: subscriptionID,
+		EventID:        e.ID,
+		Event:          e.Event,
+		Payload:        e.payload,
+		Status:         storage.WebhookDeliveryPending,
+	}
+}
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
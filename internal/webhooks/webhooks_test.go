@@ -0,0 +1,311 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/stock-recommender/internal/recommender"
+	"github.com/user/stock-recommender/internal/storage"
+	"github.com/user/stock-recommender/pkg/config"
+)
+
+// memStore keeps webhook subscriptions and deliveries in memory.
+type memStore struct {
+	mu         sync.Mutex
+	subs       []storage.WebhookSubscription
+	deliveries []storage.WebhookDelivery
+}
+
+func (m *memStore) ListWebhookSubscriptions(ctx context.Context, enabledOnly bool) ([]storage.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var subs []storage.WebhookSubscription
+	for _, sub := range m.subs {
+		if sub.Enabled || !enabledOnly {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+func (m *memStore) CreateWebhookDeliveries(ctx context.Context, deliveries []storage.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range deliveries {
+		deliveries[i].ID = uint(len(m.deliveries) + 1)
+		m.deliveries = append(m.deliveries, deliveries[i])
+	}
+	return nil
+}
+
+func (m *memStore) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]storage.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []storage.WebhookDelivery
+	for _, d := range m.deliveries {
+		if d.Status == storage.WebhookDeliveryPending && (d.NextAttemptAt == nil || !d.NextAttemptAt.After(now)) && len(due) < limit {
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+func (m *memStore) UpdateWebhookDelivery(ctx context.Context, delivery *storage.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries[delivery.ID-1] = *delivery
+	return nil
+}
+
+// list returns a copy of the recorded deliveries.
+func (m *memStore) list() []storage.WebhookDelivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]storage.WebhookDelivery(nil), m.deliveries...)
+}
+
+// makeDue moves the next attempt of every pending delivery into the past,
+// as if the backoff had passed.
+func (m *memStore) makeDue() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	past := time.Now().Add(-time.Second)
+	for i := range m.deliveries {
+		if m.deliveries[i].NextAttemptAt != nil {
+			m.deliveries[i].NextAttemptAt = &past
+		}
+	}
+}
+
+// receiver is a webhook endpoint that checks each delivery's signature and
+// answers with status.
+type receiver struct {
+	t      *testing.T
+	secret string
+	status int
+
+	mu     sync.Mutex
+	events []Envelope
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		rc.t.Errorf("failed to read delivery: %v", err)
+	}
+	if err := Verify(rc.secret, r.Header.Get(SignatureHeader), body, time.Minute, time.Now()); err != nil {
+		rc.t.Errorf("Verify: %v", err)
+	}
+	var envelope Envelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		rc.t.Errorf("invalid envelope: %v", err)
+	}
+	if r.Header.Get(EventHeader) != envelope.Event || r.Header.Get(EventIDHeader) != envelope.ID {
+		rc.t.Errorf("headers %s=%q, %s=%q for event %s %s", EventHeader, r.Header.Get(EventHeader),
+			EventIDHeader, r.Header.Get(EventIDHeader), envelope.Event, envelope.ID)
+	}
+
+	rc.mu.Lock()
+	rc.events = append(rc.events, envelope)
+	rc.mu.Unlock()
+	w.WriteHeader(rc.status)
+	io.WriteString(w, http.StatusText(rc.status))
+}
+
+// received returns the envelopes received so far.
+func (rc *receiver) received() []Envelope {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]Envelope(nil), rc.events...)
+}
+
+// newTestDispatcher returns a dispatcher giving up after maxAttempts, with
+// one enabled subscription to recommendation.created events delivered to rc.
+func newTestDispatcher(t *testing.T, rc *receiver, maxAttempts int) (*Dispatcher, *memStore) {
+	t.Helper()
+	rc.t = t
+	rc.secret = "whsec_test"
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+
+	d := NewDispatcher(nil, &config.Config{Webhooks: config.WebhooksConfig{
+		MaxAttempts:  maxAttempts,
+		RetryBackoff: time.Minute,
+		Timeout:      5 * time.Second,
+	}})
+	store := &memStore{subs: []storage.WebhookSubscription{
+		{ID: 1, Name: "created", URL: server.URL, Secret: rc.secret, Events: recommender.EventRecommendationCreated, Enabled: true},
+		{ID: 2, Name: "daily picks", URL: server.URL, Secret: rc.secret, Events: recommender.EventDailyPicksCompleted, Enabled: true},
+		{ID: 3, Name: "disabled", URL: server.URL, Secret: rc.secret, Events: AllEvents},
+	}}
+	d.repo = store
+	return d, store
+}
+
+// publish publishes a recommendation.created event and waits for it to be
+// sent.
+func publish(t *testing.T, d *Dispatcher) {
+	t.Helper()
+	d.Publish(context.Background(), recommender.EventRecommendationCreated, map[string]string{"symbol": "TCS"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Wait(ctx); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+}
+
+func TestPublishDelivers(t *testing.T) {
+	rc := &receiver{status: http.StatusOK}
+	d, store := newTestDispatcher(t, rc, 3)
+	publish(t, d)
+
+	// Only the enabled subscription to the event receives it.
+	deliveries := store.list()
+	if len(deliveries) != 1 {
+		t.Fatalf("recorded %d deliveries, want 1", len(deliveries))
+	}
+	delivery := deliveries[0]
+	if delivery.SubscriptionID != 1 || delivery.Status != storage.WebhookDeliveryDelivered || delivery.Attempts != 1 {
+		t.Errorf("delivery to %d is %s after %d attempts, want delivered to 1 after 1",
+			delivery.SubscriptionID, delivery.Status, delivery.Attempts)
+	}
+	if delivery.ResponseStatus != http.StatusOK || delivery.DeliveredAt == nil || delivery.NextAttemptAt != nil {
+		t.Errorf("delivery = %+v", delivery)
+	}
+
+	events := rc.received()
+	if len(events) != 1 || events[0].ID != delivery.EventID || events[0].Event != recommender.EventRecommendationCreated {
+		t.Errorf("received %+v, want event %s", events, delivery.EventID)
+	}
+}
+
+func TestPublishGivesUpAfterMaxAttempts(t *testing.T) {
+	rc := &receiver{status: http.StatusInternalServerError}
+	d, store := newTestDispatcher(t, rc, 3)
+	publish(t, d)
+
+	// The first two failures are retried after backing off.
+	for attempts := 1; attempts < 3; attempts++ {
+		delivery := store.list()[0]
+		if delivery.Status != storage.WebhookDeliveryPending || delivery.Attempts != attempts {
+			t.Fatalf("delivery is %s after %d attempts, want pending after %d", delivery.Status, delivery.Attempts, attempts)
+		}
+		if delivery.ResponseStatus != http.StatusInternalServerError || delivery.LastError != "webhook returned HTTP 500" {
+			t.Errorf("response %d, error %q", delivery.ResponseStatus, delivery.LastError)
+		}
+		if delivery.NextAttemptAt == nil || time.Until(*delivery.NextAttemptAt) < time.Duration(attempts-1)*time.Minute {
+			t.Errorf("next attempt at %v after %d attempts", delivery.NextAttemptAt, attempts)
+		}
+
+		// Nothing is due until the backoff has passed.
+		result, err := d.RetryDue(context.Background())
+		if err != nil {
+			t.Fatalf("RetryDue: %v", err)
+		}
+		if *result != (RetryResult{}) {
+			t.Errorf("RetryDue before the backoff = %+v", result)
+		}
+
+		store.makeDue()
+		result, err = d.RetryDue(context.Background())
+		if err != nil {
+			t.Fatalf("RetryDue: %v", err)
+		}
+		want := RetryResult{Retrying: 1}
+		if attempts == 2 {
+			want = RetryResult{Failed: 1}
+		}
+		if *result != want {
+			t.Errorf("RetryDue after %d attempts = %+v, want %+v", attempts, result, want)
+		}
+	}
+
+	delivery := store.list()[0]
+	if delivery.Status != storage.WebhookDeliveryFailed || delivery.Attempts != 3 || delivery.NextAttemptAt != nil {
+		t.Errorf("delivery is %s after %d attempts, next at %v, want failed after 3",
+			delivery.Status, delivery.Attempts, delivery.NextAttemptAt)
+	}
+	if n := len(rc.received()); n != 3 {
+		t.Errorf("received %d attempts, want 3", n)
+	}
+
+	// A failed delivery is not retried again.
+	store.makeDue()
+	if result, err := d.RetryDue(context.Background()); err != nil || *result != (RetryResult{}) {
+		t.Errorf("RetryDue after giving up = %+v, %v", result, err)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	d := NewDispatcher(nil, &config.Config{Webhooks: config.WebhooksConfig{MaxAttempts: 3, RetryBackoff: time.Minute}})
+	failure := errors.New("connection refused")
+
+	tests := []struct {
+		name       string
+		attempts   int // before this one
+		sendErr    error
+		final      bool
+		wantStatus string
+		wantRetry  bool
+	}{
+		{"delivered", 0, nil, false, storage.WebhookDeliveryDelivered, false},
+		{"delivered on the last attempt", 2, nil, false, storage.WebhookDeliveryDelivered, false},
+		{"first failure", 0, failure, false, storage.WebhookDeliveryPending, true},
+		{"second failure", 1, failure, false, storage.WebhookDeliveryPending, true},
+		{"attempts run out", 2, failure, false, storage.WebhookDeliveryFailed, false},
+		{"final failure", 0, failure, true, storage.WebhookDeliveryFailed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivery := &storage.WebhookDelivery{Status: storage.WebhookDeliveryPending, Attempts: tt.attempts, LastError: "earlier failure"}
+			d.record(delivery, tt.sendErr, tt.final)
+
+			if delivery.Status != tt.wantStatus || delivery.Attempts != tt.attempts+1 {
+				t.Errorf("delivery is %s after %d attempts, want %s after %d",
+					delivery.Status, delivery.Attempts, tt.wantStatus, tt.attempts+1)
+			}
+			if (delivery.NextAttemptAt != nil) != tt.wantRetry {
+				t.Errorf("next attempt at %v, want a retry: %v", delivery.NextAttemptAt, tt.wantRetry)
+			}
+			if tt.sendErr == nil && (delivery.LastError != "" || delivery.DeliveredAt == nil) {
+				t.Errorf("delivered with error %q at %v", delivery.LastError, delivery.DeliveredAt)
+			}
+			if tt.sendErr != nil && delivery.LastError != tt.sendErr.Error() {
+				t.Errorf("LastError = %q, want %q", delivery.LastError, tt.sendErr.Error())
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		base     time.Duration
+		attempts int
+		want     time.Duration
+	}{
+		{time.Minute, 1, time.Minute},
+		{time.Minute, 2, 2 * time.Minute},
+		{time.Minute, 5, 16 * time.Minute},
+		{time.Hour, 5, 16 * time.Hour},
+		{time.Hour, 6, maxBackoff},
+		{time.Minute, 1000, maxBackoff},
+		{48 * time.Hour, 1, maxBackoff},
+		{0, 1, 30 * time.Second},
+		{0, 3, 2 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		d := &Dispatcher{config: config.WebhooksConfig{RetryBackoff: tt.base}}
+		if got := d.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) with base %v = %v, want %v", tt.attempts, tt.base, got, tt.want)
+		}
+	}
+}
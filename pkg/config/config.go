@@ -13,13 +13,20 @@ import (
 
 // Config holds all configuration for the application.
 type Config struct {
-	App      AppConfig      `mapstructure:"app"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Server   ServerConfig   `mapstructure:"server"`
-	LLM      LLMConfig      `mapstructure:"llm"`
-	Analysis AnalysisConfig `mapstructure:"analysis"`
-	News     NewsConfig     `mapstructure:"news"`
-	Screener ScreenerConfig `mapstructure:"screener"`
+	App       AppConfig       `mapstructure:"app"`
+	Database  DatabaseConfig  `mapstructure:"database"`
+	Server    ServerConfig    `mapstructure:"server"`
+	LLM       LLMConfig       `mapstructure:"llm"`
+	Analysis  AnalysisConfig  `mapstructure:"analysis"`
+	News      NewsConfig      `mapstructure:"news"`
+	Screener  ScreenerConfig  `mapstructure:"screener"`
+	Scheduler SchedulerConfig `mapstructure:"scheduler"`
+	Market    MarketConfig    `mapstructure:"market"`
+	Freshness FreshnessConfig `mapstructure:"freshness"`
+	Alerts    AlertsConfig    `mapstructure:"alerts"`
+	Telegram  TelegramConfig  `mapstructure:"telegram"`
+	Webhooks  WebhooksConfig  `mapstructure:"webhooks"`
+	Slack     SlackConfig     `mapstructure:"slack"`
 }
 
 // AppConfig holds application-level configuration.
@@ -52,6 +59,7 @@ type ServerConfig struct {
 	Port         int           `mapstructure:"port"`
 	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
 	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	PublicURL    string        `mapstructure:"public_url"` // base of links to the dashboard in notifications
 }
 
 // LLMConfig holds LLM provider configuration.
@@ -84,6 +92,10 @@ type GeminiConfig struct {
 type AnalysisConfig struct {
 	UseLLM              bool `mapstructure:"use_llm"`
 	UseKeywordSentiment bool `mapstructure:"use_keyword_sentiment"`
+
+	// DailyPicksWatchlists names watchlists whose stocks are the candidates
+	// for daily picks instead of trending stocks.
+	DailyPicksWatchlists []string `mapstructure:"daily_picks_watchlists"`
 }
 
 // NewsConfig holds news fetching configuration.
@@ -97,6 +109,96 @@ type ScreenerConfig struct {
 	BaseURL       string        `mapstructure:"base_url"`
 	ScrapeEnabled bool          `mapstructure:"scrape_enabled"`
 	ScrapeDelay   time.Duration `mapstructure:"scrape_delay"`
+	CacheDir      string        `mapstructure:"cache_dir"` // fetched company pages; empty disables the cache
+	CacheTTL      time.Duration `mapstructure:"cache_ttl"` // cached pages younger than this are served without a request
+	Offline       bool          `mapstructure:"offline"`   // serve only from the cache, never contact screener.in
+	Basis         string        `mapstructure:"basis"`     // consolidated (falls back to standalone) or standalone
+}
+
+// SchedulerConfig holds background job scheduling configuration.
+// Schedules use standard 5-field cron syntax (or descriptors such as
+// "@every 15m") and are evaluated in the configured timezone.
+type SchedulerConfig struct {
+	Enabled               bool          `mapstructure:"enabled"`
+	Timezone              string        `mapstructure:"timezone"`
+	NewsIngestion         string        `mapstructure:"news_ingestion"` // empty uses news.fetch_interval
+	RecommendationExpiry  string        `mapstructure:"recommendation_expiry"`
+	FundamentalsRefresh   string        `mapstructure:"fundamentals_refresh"`
+	DailyPicks            string        `mapstructure:"daily_picks"`
+	MarketConditions      string        `mapstructure:"market_conditions"`
+	WatchlistAnalysis     string        `mapstructure:"watchlist_analysis"`
+	Alerts                string        `mapstructure:"alerts"`
+	WebhookRetries        string        `mapstructure:"webhook_retries"`
+	RecommendationMaxAge  time.Duration `mapstructure:"recommendation_max_age"`
+	FundamentalsBatchSize int           `mapstructure:"fundamentals_batch_size"`
+}
+
+// MarketConfig holds exchange calendar and market data configuration.
+type MarketConfig struct {
+	Timezone        string        `mapstructure:"timezone"`
+	HolidaysDir     string        `mapstructure:"holidays_dir"`      // directory of yearly NSE holiday JSON files
+	DataDir         string        `mapstructure:"data_dir"`          // directory of NSE/BSE index, VIX and FII/DII downloads
+	ConditionMaxAge time.Duration `mapstructure:"condition_max_age"` // older market conditions are not passed to the LLM
+}
+
+// FreshnessConfig holds the policy for how old fundamentals may be before
+// analysis re-scrapes them and trusts them less.
+type FreshnessConfig struct {
+	MaxAge               map[string]time.Duration `mapstructure:"max_age"`                // by fundamental source, e.g. screener_scrape, csv_upload
+	DefaultMaxAge        time.Duration            `mapstructure:"default_max_age"`        // for sources not in max_age
+	RefreshOnAnalysis    bool                     `mapstructure:"refresh_on_analysis"`    // re-scrape stale fundamentals before analysing
+	MarketHoursOnly      bool                     `mapstructure:"market_hours_only"`      // only re-scrape while NSE is open
+	ConfidencePenalty    float64                  `mapstructure:"confidence_penalty"`     // points off confidence per max age of staleness
+	MaxConfidencePenalty float64                  `mapstructure:"max_confidence_penalty"` // cap on the penalty
+}
+
+// AlertsConfig holds alert evaluation and delivery configuration.
+type AlertsConfig struct {
+	MaxAttempts  int           `mapstructure:"max_attempts"`  // deliveries are given up after this many failures
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"` // wait before the first retry, doubled after each failure
+	Timeout      time.Duration `mapstructure:"timeout"`       // per delivery attempt
+	SMTP         SMTPConfig    `mapstructure:"smtp"`
+}
+
+// SMTPConfig holds the mail server used for email alerts.
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"` // empty disables email alerts
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+}
+
+// TelegramConfig holds Telegram Bot API configuration.
+type TelegramConfig struct {
+	BotToken       string        `mapstructure:"bot_token"` // empty disables Telegram
+	APIURL         string        `mapstructure:"api_url"`
+	AllowedChatIDs []int64       `mapstructure:"allowed_chat_ids"` // chats the bot answers; empty disables the bot
+	PollTimeout    time.Duration `mapstructure:"poll_timeout"`     // how long a getUpdates long poll waits
+	Watchlist      string        `mapstructure:"watchlist"`        // watchlist changed by /watch
+}
+
+// WebhooksConfig holds outbound webhook delivery configuration.
+type WebhooksConfig struct {
+	MaxAttempts  int           `mapstructure:"max_attempts"`  // deliveries are given up after this many failures
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"` // wait before the first retry, doubled after each failure
+	Timeout      time.Duration `mapstructure:"timeout"`       // per delivery attempt
+}
+
+// SlackConfig holds Slack app configuration.
+type SlackConfig struct {
+	SigningSecret   string        `mapstructure:"signing_secret"`   // empty disables the Slack endpoints
+	AckTimeout      time.Duration `mapstructure:"ack_timeout"`      // analyses slower than this are answered through response_url
+	AnalysisTimeout time.Duration `mapstructure:"analysis_timeout"` // of an analysis requested from Slack
+}
+
+// MaxAgeFor returns how old fundamentals from source may be before they
+// are stale.
+func (f *FreshnessConfig) MaxAgeFor(source string) time.Duration {
+	if maxAge, ok := f.MaxAge[strings.ToLower(source)]; ok {
+		return maxAge
+	}
+	return f.DefaultMaxAge
 }
 
 // Load loads configuration from file and environment variables.
@@ -142,6 +244,10 @@ func Load(configPath string) (*Config, error) {
 	// Bind environment variables
 	bindEnvVars(v)
 
+	if v.IsSet("scheduler.fundamentals_max_age") {
+		fmt.Printf("Warning: scheduler.fundamentals_max_age is no longer used; fundamentals_refresh follows freshness.max_age\n")
+	}
+
 	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
@@ -171,6 +277,7 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("server.port", 8080)
 	v.SetDefault("server.read_timeout", "30s")
 	v.SetDefault("server.write_timeout", "30s")
+	v.SetDefault("server.public_url", "http://localhost:8080")
 
 	// LLM defaults
 	v.SetDefault("llm.provider", "ollama")
@@ -182,6 +289,7 @@ func setDefaults(v *viper.Viper) {
 	// Analysis defaults
 	v.SetDefault("analysis.use_llm", true)
 	v.SetDefault("analysis.use_keyword_sentiment", true)
+	v.SetDefault("analysis.daily_picks_watchlists", []string{})
 
 	// News defaults
 	v.SetDefault("news.fetch_interval", "15m")
@@ -194,6 +302,61 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("screener.base_url", "https://www.screener.in")
 	v.SetDefault("screener.scrape_enabled", true)
 	v.SetDefault("screener.scrape_delay", "2s")
+	v.SetDefault("screener.cache_dir", "data/screener")
+	v.SetDefault("screener.cache_ttl", "24h")
+	v.SetDefault("screener.offline", false)
+	v.SetDefault("screener.basis", "consolidated")
+
+	// Scheduler defaults (times are IST)
+	v.SetDefault("scheduler.enabled", true)
+	v.SetDefault("scheduler.timezone", "Asia/Kolkata")
+	v.SetDefault("scheduler.news_ingestion", "")
+	v.SetDefault("scheduler.recommendation_expiry", "0 * * * *")
+	v.SetDefault("scheduler.fundamentals_refresh", "30 18 * * 1-5")
+	v.SetDefault("scheduler.daily_picks", "45 8 * * 1-5")
+	v.SetDefault("scheduler.market_conditions", "30 19 * * 1-5")
+	v.SetDefault("scheduler.watchlist_analysis", "0 16 * * 1-5")
+	v.SetDefault("scheduler.alerts", "*/5 * * * *")
+	v.SetDefault("scheduler.webhook_retries", "* * * * *")
+	v.SetDefault("scheduler.recommendation_max_age", "2160h")
+	v.SetDefault("scheduler.fundamentals_batch_size", 20)
+
+	// Market calendar and data defaults
+	v.SetDefault("market.timezone", "Asia/Kolkata")
+	v.SetDefault("market.holidays_dir", "configs/holidays")
+	v.SetDefault("market.data_dir", "data/market")
+	v.SetDefault("market.condition_max_age", "120h")
+
+	// Freshness defaults
+	v.SetDefault("freshness.max_age", map[string]string{
+		"screener_scrape": "72h",
+		"csv_upload":      "168h",
+	})
+	v.SetDefault("freshness.default_max_age", "168h")
+	v.SetDefault("freshness.refresh_on_analysis", true)
+	v.SetDefault("freshness.market_hours_only", false)
+	v.SetDefault("freshness.confidence_penalty", 10)
+	v.SetDefault("freshness.max_confidence_penalty", 30)
+
+	// Alert defaults
+	v.SetDefault("alerts.max_attempts", 5)
+	v.SetDefault("alerts.retry_backoff", "1m")
+	v.SetDefault("alerts.timeout", "10s")
+	v.SetDefault("alerts.smtp.port", 587)
+
+	// Telegram defaults
+	v.SetDefault("telegram.api_url", "https://api.telegram.org")
+	v.SetDefault("telegram.poll_timeout", "30s")
+	v.SetDefault("telegram.watchlist", "Telegram")
+
+	// Slack defaults
+	v.SetDefault("slack.ack_timeout", "2s")
+	v.SetDefault("slack.analysis_timeout", "5m")
+
+	// Webhook defaults
+	v.SetDefault("webhooks.max_attempts", 8)
+	v.SetDefault("webhooks.retry_backoff", "30s")
+	v.SetDefault("webhooks.timeout", "10s")
 }
 
 // bindEnvVars binds environment variables to config keys.
@@ -212,6 +375,7 @@ func bindEnvVars(v *viper.Viper) {
 
 	// Server
 	_ = v.BindEnv("server.port", "SERVER_PORT")
+	_ = v.BindEnv("server.public_url", "PUBLIC_URL")
 
 	// LLM
 	_ = v.BindEnv("llm.provider", "LLM_PROVIDER")
@@ -225,6 +389,29 @@ func bindEnvVars(v *viper.Viper) {
 	// Analysis
 	_ = v.BindEnv("analysis.use_llm", "USE_LLM")
 	_ = v.BindEnv("analysis.use_keyword_sentiment", "USE_KEYWORD_SENTIMENT")
+
+	// Screener
+	_ = v.BindEnv("screener.offline", "SCREENER_OFFLINE")
+	_ = v.BindEnv("screener.basis", "SCREENER_BASIS")
+
+	// Scheduler
+	_ = v.BindEnv("scheduler.enabled", "SCHEDULER_ENABLED")
+	_ = v.BindEnv("scheduler.timezone", "SCHEDULER_TIMEZONE")
+
+	// Alerts
+	_ = v.BindEnv("alerts.smtp.host", "SMTP_HOST")
+	_ = v.BindEnv("alerts.smtp.port", "SMTP_PORT")
+	_ = v.BindEnv("alerts.smtp.username", "SMTP_USERNAME")
+	_ = v.BindEnv("alerts.smtp.password", "SMTP_PASSWORD")
+	_ = v.BindEnv("alerts.smtp.from", "SMTP_FROM")
+
+	// Telegram
+	_ = v.BindEnv("telegram.bot_token", "TELEGRAM_BOT_TOKEN")
+	_ = v.BindEnv("telegram.api_url", "TELEGRAM_API_URL")
+	_ = v.BindEnv("telegram.allowed_chat_ids", "TELEGRAM_ALLOWED_CHAT_IDS")
+
+	// Slack
+	_ = v.BindEnv("slack.signing_secret", "SLACK_SIGNING_SECRET")
 }
 
 // IsDevelopment returns true if the app is in development mode.
@@ -236,4 +423,3 @@ func (c *Config) IsDevelopment() bool {
 func (c *Config) IsProduction() bool {
 	return c.App.Env == "production"
 }
-
@@ -0,0 +1,31 @@
+// Package textutil provides string helpers shared across the application.
+package textutil
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Truncate shortens s to at most n characters without splitting one, so
+// the result is valid UTF-8 and fits a varchar(n) column.
+func Truncate(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// Ellipsize shortens s to at most n characters, marking the cut with "…".
+func Ellipsize(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	if n <= 1 {
+		return Truncate("…", n)
+	}
+	return strings.TrimRightFunc(Truncate(s, n-1), unicode.IsSpace) + "…"
+}
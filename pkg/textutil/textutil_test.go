@@ -0,0 +1,59 @@
+package textutil
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{"short", "abc", 5, "abc"},
+		{"exact", "abcde", 5, "abcde"},
+		{"ascii", "abcdef", 3, "abc"},
+		{"multi-byte", "₹1,250 crore", 2, "₹1"},
+		{"devanagari", "नमस्ते दुनिया", 4, "नमस्"},
+		{"zero", "abc", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Truncate(tt.s, tt.n)
+			if got != tt.want {
+				t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("Truncate(%q, %d) = %q is not valid UTF-8", tt.s, tt.n, got)
+			}
+		})
+	}
+}
+
+func TestEllipsize(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{"short", "abc", 5, "abc"},
+		{"cut", "abcdef", 4, "abc…"},
+		{"trailing space", "ab cdef", 4, "ab…"},
+		{"multi-byte", "₹₹₹₹₹", 3, "₹₹…"},
+		{"one", "abc", 1, "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Ellipsize(tt.s, tt.n)
+			if got != tt.want {
+				t.Errorf("Ellipsize(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+			}
+			if n := utf8.RuneCountInString(got); n > tt.n {
+				t.Errorf("Ellipsize(%q, %d) has %d characters", tt.s, tt.n, n)
+			}
+		})
+	}
+}